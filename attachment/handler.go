@@ -0,0 +1,202 @@
+package attachment
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/response"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// AttachmentHandler defines the interface for handling HTTP requests related to message attachments.
+type AttachmentHandler interface {
+	Upload(c *fiber.Ctx) error
+	Download(c *fiber.Ctx) error
+	DownloadThumbnail(c *fiber.Ctx) error
+}
+
+// AttachmentHandlerImpl implements the AttachmentHandler interface.
+type AttachmentHandlerImpl struct {
+	attachmentService AttachmentService
+}
+
+// NewAttachmentHandler initializes and returns a new AttachmentHandler with the provided AttachmentService implementation.
+func NewAttachmentHandler(attachmentService AttachmentService) AttachmentHandler {
+	return &AttachmentHandlerImpl{attachmentService: attachmentService}
+}
+
+// Upload handles a multipart upload of a single file in the "file" field. An optional "sha256" form field or
+// X-Content-SHA256 header is verified against the stored content.
+func (ah *AttachmentHandlerImpl) Upload(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	callerId := auth.CallerID(c)
+	if callerId == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   errormodel.ErrUnauthenticated.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Uploads Require A Caller Identity.",
+		})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "A file Form Field is required.",
+		})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Upload.",
+		})
+	}
+	defer file.Close()
+
+	checksum := c.FormValue("sha256")
+	if checksum == "" {
+		checksum = c.Get("X-Content-SHA256")
+	}
+
+	log.Println("Upload Attachment to Room with id: ", roomId, " Request Received.")
+
+	attachment, err := ah.attachmentService.Upload(c.Context(), UploadRequest{
+		RoomID:     roomId,
+		UploaderID: callerId,
+		FileName:   sanitizeFileName(fileHeader.Filename),
+		Size:       fileHeader.Size,
+		Content:    file,
+		SHA256:     strings.TrimSpace(checksum),
+	})
+	if errors.Is(err, errormodel.ErrAttachmentTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusRequestEntityTooLarge,
+			Message: "Attachment Is Empty Or Exceeds The Size Limit.",
+		})
+	} else if errors.Is(err, errormodel.ErrUnsupportedMediaType) {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnsupportedMediaType,
+			Message: "Attachment Type Is Not Allowed.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidImage) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnprocessableEntity,
+			Message: "Image Could Not Be Processed.",
+		})
+	} else if errors.Is(err, errormodel.ErrChecksumMismatch) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Uploaded Content Does Not Match The Given Checksum.",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Store Attachment",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Attachment Uploaded",
+		Data:    attachment,
+	})
+}
+
+// Download streams the content of an attachment as a download, never inline, so that uploaded content cannot be
+// rendered in the context of the application.
+func (ah *AttachmentHandlerImpl) Download(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	id := c.Params("id")
+
+	log.Println("Download Attachment ", id, " from Room with id: ", roomId, " Request Received.")
+
+	attachment, content, err := ah.attachmentService.Open(c.Context(), roomId, id)
+	if errors.Is(err, errormodel.ErrAttachmentNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Attachment Found with given id.",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Get Attachment",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, attachment.ContentType)
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderETag, `"`+attachment.SHA256+`"`)
+	return c.SendStream(content, int(attachment.Size))
+}
+
+// DownloadThumbnail streams a generated thumbnail of an image attachment. Thumbnails that are still being generated
+// are reported with 202 Accepted and their "processing" status.
+func (ah *AttachmentHandlerImpl) DownloadThumbnail(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	id := c.Params("id")
+	size, err := c.ParamsInt("size")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "size Path Variable must be a number.",
+		})
+	}
+
+	thumbnail, content, err := ah.attachmentService.OpenThumbnail(c.Context(), roomId, id, size)
+	if errors.Is(err, errormodel.ErrThumbnailNotReady) {
+		return c.Status(fiber.StatusAccepted).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusAccepted,
+			Message: "Thumbnail Is Not Ready Yet.",
+			Data:    thumbnail,
+		})
+	} else if errors.Is(err, errormodel.ErrAttachmentNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Thumbnail Found with given id and size.",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Get Thumbnail",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, thumbnail.ContentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=86400")
+	return c.SendStream(content)
+}
+
+// sanitizeFileName strips directories and control characters from a client-supplied file name.
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
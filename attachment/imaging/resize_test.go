@@ -0,0 +1,108 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		name                   string
+		width, height, maxEdge int
+		wantWidth, wantHeight  int
+	}{
+		{"landscape", 4000, 3000, 320, 320, 240},
+		{"portrait", 3000, 4000, 320, 240, 320},
+		{"square", 1024, 1024, 320, 320, 320},
+		{"small", 100, 50, 320, 100, 50},
+		{"exactly fitting", 320, 200, 320, 320, 200},
+		{"panorama", 10000, 10, 320, 320, 1},
+		{"tall strip", 3, 5000, 320, 1, 320},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h := FitWithin(tt.width, tt.height, tt.maxEdge)
+			if w != tt.wantWidth || h != tt.wantHeight {
+				t.Errorf("FitWithin(%d, %d, %d) = %dx%d, want %dx%d", tt.width, tt.height, tt.maxEdge, w, h, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	for _, size := range []struct{ width, height int }{{640, 480}, {480, 640}, {40, 30}} {
+		src := testImage(size.width, size.height)
+		w, h := FitWithin(size.width, size.height, 64)
+		thumb := Resize(src, w, h)
+		if thumb.Bounds() != image.Rect(0, 0, w, h) {
+			t.Errorf("Resize of %dx%d = %v, want %dx%d", size.width, size.height, thumb.Bounds(), w, h)
+		}
+	}
+
+	// Each target pixel averages the source pixels it covers.
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		shade := uint8(0)
+		if x%2 == 1 {
+			shade = 200
+		}
+		src.Set(x, 0, color.RGBA{R: shade, G: 0, B: 0, A: 255})
+		src.Set(x, 1, color.RGBA{R: shade, G: 100, B: 0, A: 255})
+	}
+	if got, want := Resize(src, 2, 1).RGBAAt(1, 0), (color.RGBA{R: 100, G: 50, B: 0, A: 255}); got != want {
+		t.Errorf("averaged pixel = %v, want %v", got, want)
+	}
+
+	// Sub-images are read from their own origin.
+	sub := testImage(8, 8).SubImage(image.Rect(4, 4, 8, 8))
+	if got, want := Resize(sub, 4, 4).RGBAAt(0, 0), testImage(8, 8).RGBAAt(4, 4); got != want {
+		t.Errorf("sub-image pixel = %v, want %v", got, want)
+	}
+}
+
+func TestBlurHash(t *testing.T) {
+	img := testImage(64, 48)
+	hash := BlurHash(img, 4, 3)
+	// A size flag, a maximum, a DC value of 4 characters and 2 characters per AC component.
+	if len(hash) != 1+1+4+2*(4*3-1) {
+		t.Fatalf("BlurHash = %q, want %d characters", hash, 1+1+4+2*(4*3-1))
+	}
+	for _, c := range hash {
+		if !strings.ContainsRune(blurHashAlphabet, c) {
+			t.Fatalf("BlurHash = %q contains %q outside the base 83 alphabet", hash, c)
+		}
+	}
+	if again := BlurHash(img, 4, 3); again != hash {
+		t.Errorf("BlurHash is not stable: %q then %q", hash, again)
+	}
+	if nrgba := BlurHash(toNRGBA(img), 4, 3); nrgba != hash {
+		t.Errorf("BlurHash of the same pixels in another color model = %q, want %q", nrgba, hash)
+	}
+	if other := BlurHash(testImage(48, 64), 4, 3); other == hash {
+		t.Error("BlurHash does not tell different images apart")
+	}
+
+	// A solid white image has the 4x3 size flag and the white DC value.
+	white := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range white.Pix {
+		white.Pix[i] = 255
+	}
+	if got := BlurHash(white, 4, 3); got[:1] != "L" || got[2:6] != "TSUA" {
+		t.Errorf("BlurHash of white = %q, want size flag L and DC value TSUA", got)
+	}
+	if got := BlurHash(white, 1, 1); got != "00TSUA" {
+		t.Errorf("BlurHash of white with one component = %q, want %q", got, "00TSUA")
+	}
+}
+
+func toNRGBA(src *image.RGBA) *image.NRGBA {
+	dst := image.NewNRGBA(src.Bounds())
+	for y := src.Bounds().Min.Y; y < src.Bounds().Max.Y; y++ {
+		for x := src.Bounds().Min.X; x < src.Bounds().Max.X; x++ {
+			dst.Set(x, y, src.At(x, y))
+		}
+	}
+	return dst
+}
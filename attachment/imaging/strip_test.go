@@ -0,0 +1,245 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// gpsDate is the GPS date stamp written into test EXIF blocks, to find any trace of them after stripping.
+const gpsDate = "2026:01:02"
+
+// testImage returns a small image with a gradient, so that decoded pixels can be compared.
+func testImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// exifBlock returns a big-endian TIFF structure whose first IFD points to a GPS IFD holding latitude and a date
+// stamp, as cameras and phones write it.
+func exifBlock() []byte {
+	var b bytes.Buffer
+	b.WriteString("MM\x00\x2A")
+	binary.Write(&b, binary.BigEndian, uint32(8))
+	// IFD0: one entry, the GPS IFD pointer.
+	binary.Write(&b, binary.BigEndian, uint16(1))
+	binary.Write(&b, binary.BigEndian, []uint16{0x8825, 4})
+	binary.Write(&b, binary.BigEndian, []uint32{1, 26})
+	binary.Write(&b, binary.BigEndian, uint32(0))
+	// GPS IFD at 26: GPSLatitudeRef "N" inline and GPSDateStamp stored after the IFD.
+	binary.Write(&b, binary.BigEndian, uint16(2))
+	binary.Write(&b, binary.BigEndian, []uint16{0x0001, 2})
+	binary.Write(&b, binary.BigEndian, uint32(2))
+	b.WriteString("N\x00\x00\x00")
+	binary.Write(&b, binary.BigEndian, []uint16{0x001D, 2})
+	binary.Write(&b, binary.BigEndian, []uint32{uint32(len(gpsDate) + 1), 26 + 2 + 2*12 + 4})
+	binary.Write(&b, binary.BigEndian, uint32(0))
+	b.WriteString(gpsDate + "\x00")
+	return b.Bytes()
+}
+
+// jpegSegment returns a JPEG marker segment with the given payload.
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withSegments inserts marker segments right after the start-of-image marker of a JPEG.
+func withSegments(jpg []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, jpg[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, jpg[2:]...)
+}
+
+// pngChunk returns a PNG chunk with a valid CRC.
+func pngChunk(chunkType string, payload []byte) []byte {
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// webpChunk returns a RIFF chunk of a WebP file, padded to an even size.
+func webpChunk(chunkType string, payload []byte) []byte {
+	chunk := append([]byte(chunkType), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func webpFile(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	file := append([]byte("RIFF"), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(file[4:], uint32(len(body)))
+	return append(file, body...)
+}
+
+// assertNoMetadata fails when stripped data still carries any of the metadata written into the test images.
+func assertNoMetadata(t *testing.T, stripped []byte) {
+	t.Helper()
+	for _, trace := range []string{"Exif", "MM\x00\x2A", gpsDate, "http://ns.adobe.com/xap/1.0/", "Shot on a phone", "GPSLatitude"} {
+		if bytes.Contains(stripped, []byte(trace)) {
+			t.Errorf("stripped image still contains %q", trace)
+		}
+	}
+}
+
+func TestStripJPEG(t *testing.T) {
+	src := testImage(32, 24)
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, src, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	icc := jpegSegment(0xE2, []byte("ICC_PROFILE\x00\x01\x01profile"))
+	tagged := withSegments(encoded.Bytes(),
+		jpegSegment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")),
+		jpegSegment(0xE1, append([]byte("Exif\x00\x00"), exifBlock()...)),
+		jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta><GPSLatitude>48.8584</GPSLatitude></x:xmpmeta>")),
+		icc,
+		jpegSegment(0xFE, []byte("Shot on a phone")),
+	)
+	if !bytes.Contains(tagged, []byte(gpsDate)) {
+		t.Fatal("test image carries no GPS data")
+	}
+
+	stripped, err := StripMetadata("image/jpeg", tagged)
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	assertNoMetadata(t, stripped)
+	if !bytes.Contains(stripped, icc) || !bytes.Contains(stripped, []byte("JFIF")) {
+		t.Error("color information was stripped")
+	}
+
+	// The pixels are copied, not re-encoded.
+	want, err := jpeg.Decode(bytes.NewReader(encoded.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("stripped JPEG does not decode: %v", err)
+	}
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("stripped JPEG is %v, want %v", got.Bounds(), want.Bounds())
+	}
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			if got.At(x, y) != want.At(x, y) {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got.At(x, y), want.At(x, y))
+			}
+		}
+	}
+
+	if again, err := StripMetadata("image/jpeg", stripped); err != nil || !bytes.Equal(again, stripped) {
+		t.Errorf("stripping twice changed the image: %v", err)
+	}
+}
+
+func TestStripPNG(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, testImage(16, 16)); err != nil {
+		t.Fatal(err)
+	}
+	iend := len(encoded.Bytes()) - 12
+	var tagged []byte
+	tagged = append(tagged, encoded.Bytes()[:iend]...)
+	tagged = append(tagged, pngChunk("eXIf", exifBlock())...)
+	tagged = append(tagged, pngChunk("tEXt", []byte("Comment\x00Shot on a phone"))...)
+	tagged = append(tagged, pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00http://ns.adobe.com/xap/1.0/"))...)
+	tagged = append(tagged, pngChunk("tIME", []byte{0x07, 0xEA, 1, 2, 3, 4, 5})...)
+	tagged = append(tagged, encoded.Bytes()[iend:]...)
+	if _, err := png.Decode(bytes.NewReader(tagged)); err != nil {
+		t.Fatalf("test image does not decode: %v", err)
+	}
+
+	stripped, err := StripMetadata("image/png", tagged)
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	assertNoMetadata(t, stripped)
+	if bytes.Contains(stripped, []byte("tIME")) {
+		t.Error("stripped PNG still contains its timestamp")
+	}
+	if !bytes.Equal(stripped, encoded.Bytes()) {
+		t.Error("stripping changed more than the metadata chunks")
+	}
+}
+
+func TestStripWebP(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x08 | 0x04 | 0x10 // EXIF, XMP and alpha
+	image := webpChunk("VP8L", []byte{0x2F, 0, 0, 0, 0x10, 0x07})
+	tagged := webpFile(
+		webpChunk("VP8X", vp8x),
+		image,
+		webpChunk("EXIF", exifBlock()),
+		webpChunk("XMP ", []byte("http://ns.adobe.com/xap/1.0/ Shot on a phone")),
+	)
+
+	stripped, err := StripMetadata("image/webp", tagged)
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	assertNoMetadata(t, stripped)
+	if want := webpFile(webpChunk("VP8X", append([]byte{0x10}, vp8x[1:]...)), image); !bytes.Equal(stripped, want) {
+		t.Errorf("stripped WebP = %x, want %x", stripped, want)
+	}
+}
+
+func TestStripMetadataRejectsMalformedImages(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, testImage(8, 8), nil); err != nil {
+		t.Fatal(err)
+	}
+	jpg := encoded.Bytes()
+	truncatedSegment := append(append([]byte{}, jpg[:2]...), 0xFF, 0xE1, 0x40, 0x00, 'E', 'x')
+
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+	}{
+		{"empty jpeg", "image/jpeg", nil},
+		{"not a jpeg", "image/jpeg", []byte("GIF89a....")},
+		{"truncated jpeg segment", "image/jpeg", truncatedSegment},
+		{"jpeg without scan", "image/jpeg", jpg[:20]},
+		{"not a png", "image/png", []byte("\x89PNX\r\n\x1a\n")},
+		{"truncated png chunk", "image/png", append(append([]byte{}, pngSignature...), pngChunk("IHDR", make([]byte, 13))[:10]...)},
+		{"png without end", "image/png", append(append([]byte{}, pngSignature...), pngChunk("IHDR", make([]byte, 13))...)},
+		{"not a webp", "image/webp", []byte("RIFF\x00\x00\x00\x00WAVE")},
+		{"truncated webp chunk", "image/webp", webpFile(webpChunk("EXIF", exifBlock()))[:30]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := StripMetadata(tt.contentType, tt.data); !errors.Is(err, ErrMalformedImage) {
+				t.Errorf("StripMetadata = %v, want ErrMalformedImage", err)
+			}
+		})
+	}
+
+	if out, err := StripMetadata("image/gif", []byte("GIF89a")); err != nil || string(out) != "GIF89a" {
+		t.Errorf("StripMetadata of a GIF = %q, %v; want it unchanged", out, err)
+	}
+}
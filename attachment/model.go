@@ -0,0 +1,59 @@
+package attachment
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Attachment represents an uploaded file stored in the blob store. MessageID is empty until a message
+// referencing the attachment has been posted; unreferenced uploads are garbage-collected.
+type Attachment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RoomID      string             `bson:"room_id" json:"room_id"`
+	UploaderID  string             `bson:"uploader_id" json:"uploader_id"`
+	MessageID   string             `bson:"message_id,omitempty" json:"message_id,omitempty"`
+	FileName    string             `bson:"file_name" json:"file_name"`
+	ContentType string             `bson:"content_type" json:"content_type"`
+	Size        int64              `bson:"size" json:"size"`
+	SHA256      string             `bson:"sha256" json:"sha256"`
+	BlobKey     string             `bson:"blob_key" json:"-"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	Image       *ImageInfo         `bson:"image,omitempty" json:"image,omitempty"`
+}
+
+// Thumbnail processing states.
+const (
+	ThumbnailProcessing = "processing"
+	ThumbnailReady      = "ready"
+	ThumbnailFailed     = "failed"
+)
+
+// ImageInfo holds the dimensions, BlurHash placeholder and thumbnails of an image attachment.
+// BlurHash stays empty until the thumbnails have been generated.
+type ImageInfo struct {
+	Width      int         `bson:"width" json:"width"`
+	Height     int         `bson:"height" json:"height"`
+	BlurHash   string      `bson:"blurhash,omitempty" json:"blurhash,omitempty"`
+	Thumbnails []Thumbnail `bson:"thumbnails" json:"thumbnails"`
+}
+
+// Thumbnail is a downscaled rendition of an image attachment that fits within a Size x Size square.
+type Thumbnail struct {
+	Size        int    `bson:"size" json:"size"`
+	Width       int    `bson:"width" json:"width"`
+	Height      int    `bson:"height" json:"height"`
+	Status      string `bson:"status" json:"status"`
+	URL         string `bson:"url" json:"url"`
+	ContentType string `bson:"content_type,omitempty" json:"-"`
+	BlobKey     string `bson:"blob_key" json:"-"`
+}
+
+// Pending reports whether any thumbnail of the image is still being processed.
+func (i *ImageInfo) Pending() bool {
+	for _, t := range i.Thumbnails {
+		if t.Status == ThumbnailProcessing {
+			return true
+		}
+	}
+	return false
+}
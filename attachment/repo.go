@@ -0,0 +1,187 @@
+package attachment
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"messages-go/models/errormodel"
+	"os"
+	"time"
+)
+
+// AttachmentRepo defines an interface for attachment metadata persistence.
+type AttachmentRepo interface {
+	CreateAttachment(ctx context.Context, a *Attachment) (*Attachment, error)
+	GetAttachmentByID(ctx context.Context, id string) (*Attachment, error)
+	GetAttachmentsByIDs(ctx context.Context, ids []string) ([]Attachment, error)
+	BindToMessage(ctx context.Context, ids []string, messageID string) (int64, error)
+	GetOrphans(ctx context.Context, olderThan time.Time, limit int64) ([]Attachment, error)
+	DeleteAttachment(ctx context.Context, id primitive.ObjectID) error
+	UpdateImage(ctx context.Context, id primitive.ObjectID, image *ImageInfo) (*Attachment, error)
+	GetPendingImages(ctx context.Context, limit int64) ([]Attachment, error)
+}
+
+// AttachmentRepoImpl is a concrete implementation of the AttachmentRepo interface backed by MongoDB.
+type AttachmentRepoImpl struct {
+	attachmentCollection *mongo.Collection
+}
+
+// NewAttachmentRepository initializes and returns a new instance of AttachmentRepo for managing attachments in MongoDB.
+func NewAttachmentRepository(client *mongo.Client) AttachmentRepo {
+	return &AttachmentRepoImpl{
+		attachmentCollection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("attachments"),
+	}
+}
+
+// CreateAttachment inserts a new attachment document and returns it with its generated ID.
+func (r *AttachmentRepoImpl) CreateAttachment(ctx context.Context, a *Attachment) (*Attachment, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.attachmentCollection.InsertOne(timeoutCtx, a)
+	if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		a.ID = oid
+	}
+	return a, nil
+}
+
+// GetAttachmentByID retrieves an attachment by its ID.
+func (r *AttachmentRepoImpl) GetAttachmentByID(ctx context.Context, id string) (*Attachment, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrAttachmentNotFound
+	}
+
+	var a Attachment
+	err = r.attachmentCollection.FindOne(timeoutCtx, bson.M{"_id": objID}).Decode(&a)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrAttachmentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAttachmentsByIDs retrieves the attachments with the given IDs. Unknown or malformed IDs are skipped.
+func (r *AttachmentRepoImpl) GetAttachmentsByIDs(ctx context.Context, ids []string) ([]Attachment, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+
+	cursor, err := r.attachmentCollection.Find(timeoutCtx, bson.M{"_id": bson.M{"$in": objIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var attachments []Attachment
+	if err := cursor.All(timeoutCtx, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// BindToMessage marks unbound attachments as referenced by a message and returns how many were bound.
+func (r *AttachmentRepoImpl) BindToMessage(ctx context.Context, ids []string, messageID string) (int64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+
+	result, err := r.attachmentCollection.UpdateMany(
+		timeoutCtx,
+		bson.M{"_id": bson.M{"$in": objIDs}, "message_id": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"message_id": messageID}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// GetOrphans returns up to limit attachments created before olderThan that were never bound to a message.
+func (r *AttachmentRepoImpl) GetOrphans(ctx context.Context, olderThan time.Time, limit int64) ([]Attachment, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"message_id": bson.M{"$exists": false}, "created_at": bson.M{"$lt": olderThan}}
+	cursor, err := r.attachmentCollection.Find(timeoutCtx, filter, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var attachments []Attachment
+	if err := cursor.All(timeoutCtx, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// DeleteAttachment removes the attachment metadata document with the given ID.
+func (r *AttachmentRepoImpl) DeleteAttachment(ctx context.Context, id primitive.ObjectID) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.attachmentCollection.DeleteOne(timeoutCtx, bson.M{"_id": id})
+	return err
+}
+
+// UpdateImage replaces the image information of an attachment and returns the updated attachment.
+func (r *AttachmentRepoImpl) UpdateImage(ctx context.Context, id primitive.ObjectID, image *ImageInfo) (*Attachment, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var updated Attachment
+	err := r.attachmentCollection.FindOneAndUpdate(
+		timeoutCtx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"image": image}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrAttachmentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// GetPendingImages returns up to limit image attachments that still have thumbnails being processed.
+func (r *AttachmentRepoImpl) GetPendingImages(ctx context.Context, limit int64) ([]Attachment, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"image.thumbnails.status": ThumbnailProcessing}
+	cursor, err := r.attachmentCollection.Find(timeoutCtx, filter, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var attachments []Attachment
+	if err := cursor.All(timeoutCtx, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
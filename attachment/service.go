@@ -0,0 +1,272 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"log"
+	"messages-go/attachment/imaging"
+	"messages-go/internal/blobstore"
+	"messages-go/models/errormodel"
+	ws "messages-go/websocket"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sniffLength is the number of leading bytes inspected to detect the content type of an upload.
+const sniffLength = 512
+
+// Config holds the limits applied to uploads and the garbage collection schedule for orphaned uploads.
+type Config struct {
+	MaxBytes         int64
+	AllowedTypes     []string
+	OrphanTTL        time.Duration
+	GCInterval       time.Duration
+	ThumbnailWorkers int
+}
+
+// UploadRequest describes a file being uploaded to a room.
+type UploadRequest struct {
+	RoomID     string
+	UploaderID string
+	FileName   string
+	Size       int64
+	Content    io.Reader
+	// SHA256 is the hex digest the client expects the upload to have, if it sent one.
+	SHA256 string
+}
+
+// AttachmentService defines the interface for storing, retrieving and collecting attachments.
+type AttachmentService interface {
+	Upload(ctx context.Context, req UploadRequest) (*Attachment, error)
+	Open(ctx context.Context, roomId string, id string) (*Attachment, io.ReadCloser, error)
+	OpenThumbnail(ctx context.Context, roomId string, id string, size int) (*Thumbnail, io.ReadCloser, error)
+	CollectOrphans(ctx context.Context) (int, error)
+}
+
+// AttachmentServiceImpl stores attachment content in a blob store and its metadata in an AttachmentRepo.
+type AttachmentServiceImpl struct {
+	attachmentRepo AttachmentRepo
+	store          blobstore.Store
+	cfg            Config
+	wsHandler      *ws.Handler
+
+	// thumbnailJobs queues the IDs of image attachments awaiting thumbnails; queued dedupes them.
+	thumbnailJobs chan primitive.ObjectID
+	queued        sync.Map
+}
+
+// NewAttachmentService initializes and returns a new instance of AttachmentServiceImpl.
+func NewAttachmentService(attachmentRepo AttachmentRepo, store blobstore.Store, cfg Config, wsHandler *ws.Handler) *AttachmentServiceImpl {
+	return &AttachmentServiceImpl{
+		attachmentRepo: attachmentRepo,
+		store:          store,
+		cfg:            cfg,
+		wsHandler:      wsHandler,
+		thumbnailJobs:  make(chan primitive.ObjectID, 1024),
+	}
+}
+
+// Upload sniffs the content type of the file, stores it in the blob store along with its SHA-256 checksum, and
+// records the attachment. The declared content type of the upload is never trusted. Image metadata is stripped
+// before storage and thumbnails are generated in the background.
+func (as *AttachmentServiceImpl) Upload(ctx context.Context, req UploadRequest) (*Attachment, error) {
+	if req.Size <= 0 || req.Size > as.cfg.MaxBytes {
+		return nil, errormodel.ErrAttachmentTooLarge
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(req.Content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !as.allowed(contentType) {
+		return nil, errormodel.ErrUnsupportedMediaType
+	}
+
+	id := primitive.NewObjectID()
+	attachment := &Attachment{
+		ID:          id,
+		RoomID:      req.RoomID,
+		UploaderID:  req.UploaderID,
+		FileName:    req.FileName,
+		ContentType: contentType,
+		BlobKey:     "attachments/" + req.RoomID + "/" + id.Hex(),
+		CreatedAt:   time.Now().UTC(),
+	}
+	content := io.MultiReader(bytes.NewReader(head), req.Content)
+
+	if strings.HasPrefix(contentType, "image/") {
+		err = as.storeImage(ctx, attachment, content, req.SHA256)
+	} else {
+		err = as.storeFile(ctx, attachment, content, req.Size, req.SHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := as.attachmentRepo.CreateAttachment(ctx, attachment)
+	if err != nil {
+		as.deleteBlob(ctx, attachment.BlobKey)
+		return nil, err
+	}
+	if created.Image != nil && created.Image.Pending() {
+		as.enqueueThumbnails(created.ID)
+	}
+	return created, nil
+}
+
+// storeFile streams content to the blob store while computing its checksum.
+func (as *AttachmentServiceImpl) storeFile(ctx context.Context, a *Attachment, content io.Reader, size int64, expectedSHA256 string) error {
+	hasher := sha256.New()
+	if err := as.store.Put(ctx, a.BlobKey, io.TeeReader(content, hasher), size, a.ContentType); err != nil {
+		return err
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if expectedSHA256 != "" && !strings.EqualFold(expectedSHA256, checksum) {
+		as.deleteBlob(ctx, a.BlobKey)
+		return errormodel.ErrChecksumMismatch
+	}
+	a.Size = size
+	a.SHA256 = checksum
+	return nil
+}
+
+// storeImage buffers an image so that the client checksum can be verified against the bytes received and the
+// EXIF, GPS and other metadata can be stripped before anything is written. The stored checksum and size describe
+// the stripped image.
+func (as *AttachmentServiceImpl) storeImage(ctx context.Context, a *Attachment, content io.Reader, expectedSHA256 string) error {
+	data, err := io.ReadAll(io.LimitReader(content, as.cfg.MaxBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > as.cfg.MaxBytes {
+		return errormodel.ErrAttachmentTooLarge
+	}
+	received := sha256.Sum256(data)
+	if expectedSHA256 != "" && !strings.EqualFold(expectedSHA256, hex.EncodeToString(received[:])) {
+		return errormodel.ErrChecksumMismatch
+	}
+
+	stripped, err := imaging.StripMetadata(a.ContentType, data)
+	if err != nil {
+		return errormodel.ErrInvalidImage
+	}
+	a.Image = as.planThumbnails(a, stripped)
+
+	if err := as.store.Put(ctx, a.BlobKey, bytes.NewReader(stripped), int64(len(stripped)), a.ContentType); err != nil {
+		return err
+	}
+	checksum := sha256.Sum256(stripped)
+	a.Size = int64(len(stripped))
+	a.SHA256 = hex.EncodeToString(checksum[:])
+	return nil
+}
+
+// Open returns the metadata and content of an attachment of the given room. The caller must close the reader.
+func (as *AttachmentServiceImpl) Open(ctx context.Context, roomId string, id string) (*Attachment, io.ReadCloser, error) {
+	attachment, err := as.attachmentRepo.GetAttachmentByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if attachment.RoomID != roomId {
+		return nil, nil, errormodel.ErrAttachmentNotFound
+	}
+
+	content, err := as.store.Get(ctx, attachment.BlobKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, nil, errormodel.ErrAttachmentNotFound
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+// CollectOrphans deletes uploads that were never referenced by a message within the orphan TTL and returns how
+// many were removed.
+func (as *AttachmentServiceImpl) CollectOrphans(ctx context.Context) (int, error) {
+	const batchSize = 100
+	removed := 0
+	cutoff := time.Now().Add(-as.cfg.OrphanTTL)
+
+	for {
+		orphans, err := as.attachmentRepo.GetOrphans(ctx, cutoff, batchSize)
+		if err != nil {
+			return removed, err
+		}
+		for _, orphan := range orphans {
+			if err := as.store.Delete(ctx, orphan.BlobKey); err != nil {
+				return removed, err
+			}
+			if orphan.Image != nil {
+				for _, thumbnail := range orphan.Image.Thumbnails {
+					if err := as.store.Delete(ctx, thumbnail.BlobKey); err != nil {
+						return removed, err
+					}
+				}
+			}
+			if err := as.attachmentRepo.DeleteAttachment(ctx, orphan.ID); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+		if len(orphans) < batchSize {
+			return removed, nil
+		}
+	}
+}
+
+// StartGarbageCollector periodically collects orphaned uploads and re-queues images whose thumbnails are still
+// pending, for instance because the queue was full or the server restarted, until ctx is cancelled.
+func (as *AttachmentServiceImpl) StartGarbageCollector(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(as.cfg.GCInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				removed, err := as.CollectOrphans(ctx)
+				if err != nil {
+					log.Printf("Attachment garbage collection failed: %v", err)
+				}
+				if removed > 0 {
+					log.Printf("Removed %d orphaned attachments", removed)
+				}
+				as.requeuePendingThumbnails(ctx)
+			}
+		}
+	}()
+}
+
+// allowed reports whether a sniffed content type matches one of the configured type prefixes.
+func (as *AttachmentServiceImpl) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(as.cfg.AllowedTypes, func(prefix string) bool {
+		return strings.HasPrefix(mediaType, prefix)
+	})
+}
+
+// deleteBlob removes a blob whose upload could not be completed.
+func (as *AttachmentServiceImpl) deleteBlob(ctx context.Context, key string) {
+	if err := as.store.Delete(ctx, key); err != nil {
+		log.Printf("Failed to delete blob %s: %v", key, err)
+	}
+}
@@ -0,0 +1,204 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"messages-go/attachment/imaging"
+	"messages-go/internal/blobstore"
+	"messages-go/models/errormodel"
+)
+
+// thumbnailSizes are the edge lengths of the squares thumbnails are scaled to fit, smallest first.
+var thumbnailSizes = []int{160, 320, 640}
+
+const (
+	// maxImagePixels bounds the images that are decoded for thumbnails, guarding against decompression bombs.
+	maxImagePixels = 50_000_000
+	// blurHashSource is the edge length images are scaled down to before computing their BlurHash.
+	blurHashSource = 32
+)
+
+// planThumbnails reads the dimensions of an image and returns its ImageInfo with every thumbnail marked as
+// processing. Images that cannot be decoded, or are too large to decode safely, get no ImageInfo.
+func (as *AttachmentServiceImpl) planThumbnails(a *Attachment, data []byte) *ImageInfo {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return nil
+	}
+
+	info := &ImageInfo{Width: cfg.Width, Height: cfg.Height}
+	for _, size := range thumbnailSizes {
+		// Larger sizes would only repeat the original; the smallest size is always produced.
+		if len(info.Thumbnails) > 0 && size >= max(cfg.Width, cfg.Height) {
+			break
+		}
+		width, height := imaging.FitWithin(cfg.Width, cfg.Height, size)
+		info.Thumbnails = append(info.Thumbnails, Thumbnail{
+			Size:    size,
+			Width:   width,
+			Height:  height,
+			Status:  ThumbnailProcessing,
+			URL:     fmt.Sprintf("/api/message/%s/attachments/%s/thumbnails/%d", a.RoomID, a.ID.Hex(), size),
+			BlobKey: fmt.Sprintf("thumbnails/%s/%s-%d", a.RoomID, a.ID.Hex(), size),
+		})
+	}
+	return info
+}
+
+// StartThumbnailWorkers starts the pool of workers generating thumbnails until ctx is cancelled, and queues the
+// images left pending by a previous run.
+func (as *AttachmentServiceImpl) StartThumbnailWorkers(ctx context.Context) {
+	for range max(1, as.cfg.ThumbnailWorkers) {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-as.thumbnailJobs:
+					as.processThumbnails(ctx, id)
+					as.queued.Delete(id)
+				}
+			}
+		}()
+	}
+	go as.requeuePendingThumbnails(ctx)
+}
+
+// enqueueThumbnails queues an image attachment for processing unless it is already queued. When the queue is
+// full the attachment stays pending and is picked up again by the periodic requeue.
+func (as *AttachmentServiceImpl) enqueueThumbnails(id primitive.ObjectID) {
+	if _, loaded := as.queued.LoadOrStore(id, true); loaded {
+		return
+	}
+	select {
+	case as.thumbnailJobs <- id:
+	default:
+		as.queued.Delete(id)
+		log.Printf("Thumbnail queue full, deferring attachment %s", id.Hex())
+	}
+}
+
+// requeuePendingThumbnails queues every image attachment whose thumbnails are still processing.
+func (as *AttachmentServiceImpl) requeuePendingThumbnails(ctx context.Context) {
+	pending, err := as.attachmentRepo.GetPendingImages(ctx, int64(cap(as.thumbnailJobs)))
+	if err != nil {
+		log.Printf("Failed to load pending thumbnails: %v", err)
+		return
+	}
+	for _, a := range pending {
+		as.enqueueThumbnails(a.ID)
+	}
+}
+
+// processThumbnails generates the thumbnails and BlurHash of an image attachment, records them, and notifies the
+// room so clients can replace their "processing" placeholders.
+func (as *AttachmentServiceImpl) processThumbnails(ctx context.Context, id primitive.ObjectID) {
+	a, err := as.attachmentRepo.GetAttachmentByID(ctx, id.Hex())
+	if err != nil {
+		log.Printf("Failed to load attachment %s for thumbnails: %v", id.Hex(), err)
+		return
+	}
+	if a.Image == nil || !a.Image.Pending() {
+		return
+	}
+
+	info := *a.Image
+	info.Thumbnails = append([]Thumbnail(nil), a.Image.Thumbnails...)
+	if err := as.renderThumbnails(ctx, a, &info); err != nil {
+		log.Printf("Failed to generate thumbnails for attachment %s: %v", id.Hex(), err)
+		for i := range info.Thumbnails {
+			if info.Thumbnails[i].Status == ThumbnailProcessing {
+				info.Thumbnails[i].Status = ThumbnailFailed
+			}
+		}
+	}
+
+	updated, err := as.attachmentRepo.UpdateImage(ctx, a.ID, &info)
+	if err != nil {
+		log.Printf("Failed to record thumbnails for attachment %s: %v", id.Hex(), err)
+		return
+	}
+	if as.wsHandler != nil {
+		as.wsHandler.BroadcastToRoom(updated.RoomID, map[string]interface{}{
+			"type":       "attachment_updated",
+			"attachment": updated,
+		})
+	}
+}
+
+// renderThumbnails decodes the stored image and writes each planned thumbnail, largest first so that every
+// smaller size is scaled from the previous one.
+func (as *AttachmentServiceImpl) renderThumbnails(ctx context.Context, a *Attachment, info *ImageInfo) error {
+	content, err := as.store.Get(ctx, a.BlobKey)
+	if err != nil {
+		return err
+	}
+	src, _, err := image.Decode(content)
+	content.Close()
+	if err != nil {
+		return err
+	}
+
+	for i := len(info.Thumbnails) - 1; i >= 0; i-- {
+		t := &info.Thumbnails[i]
+		src = imaging.Resize(src, t.Width, t.Height)
+
+		var buf bytes.Buffer
+		contentType := "image/png"
+		if a.ContentType == "image/jpeg" {
+			contentType = "image/jpeg"
+			err = jpeg.Encode(&buf, src, &jpeg.Options{Quality: 80})
+		} else {
+			err = png.Encode(&buf, src)
+		}
+		if err != nil {
+			return err
+		}
+		if err := as.store.Put(ctx, t.BlobKey, &buf, int64(buf.Len()), contentType); err != nil {
+			return err
+		}
+		t.ContentType = contentType
+		t.Status = ThumbnailReady
+	}
+
+	w, h := imaging.FitWithin(src.Bounds().Dx(), src.Bounds().Dy(), blurHashSource)
+	info.BlurHash = imaging.BlurHash(imaging.Resize(src, w, h), 4, 3)
+	return nil
+}
+
+// OpenThumbnail returns a ready thumbnail of an attachment of the given room. The caller must close the reader.
+func (as *AttachmentServiceImpl) OpenThumbnail(ctx context.Context, roomId string, id string, size int) (*Thumbnail, io.ReadCloser, error) {
+	a, err := as.attachmentRepo.GetAttachmentByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.RoomID != roomId || a.Image == nil {
+		return nil, nil, errormodel.ErrAttachmentNotFound
+	}
+
+	for _, t := range a.Image.Thumbnails {
+		if t.Size != size {
+			continue
+		}
+		if t.Status != ThumbnailReady {
+			return &t, nil, errormodel.ErrThumbnailNotReady
+		}
+		content, err := as.store.Get(ctx, t.BlobKey)
+		if errors.Is(err, blobstore.ErrNotFound) {
+			return nil, nil, errormodel.ErrAttachmentNotFound
+		} else if err != nil {
+			return nil, nil, err
+		}
+		return &t, content, nil
+	}
+	return nil, nil, errormodel.ErrAttachmentNotFound
+}
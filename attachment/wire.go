@@ -0,0 +1,37 @@
+package attachment
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/internal/blobstore"
+	"messages-go/utils"
+	ws "messages-go/websocket"
+	"strings"
+	"time"
+)
+
+// InitAttachmentHandler wires the attachment handler against the blob store selected by the environment and starts
+// the thumbnail workers and the collection of orphaned uploads, both running until ctx is cancelled.
+func InitAttachmentHandler(ctx context.Context, client *mongo.Client, wsHandler *ws.Handler) (AttachmentHandler, AttachmentRepo, AttachmentService) {
+	store, err := blobstore.NewFromEnv()
+	if err != nil {
+		log.Fatal("Blob store initialization failed:", err)
+	}
+
+	cfg := Config{
+		MaxBytes:     int64(utils.GetEnvInt("ATTACHMENT_MAX_BYTES", 10<<20)),
+		AllowedTypes: strings.Split(utils.GetEnv("ATTACHMENT_ALLOWED_TYPES", "image/,audio/,video/,text/plain,application/pdf,application/zip"), ","),
+		OrphanTTL:    utils.GetEnvDuration("ATTACHMENT_ORPHAN_TTL", 24*time.Hour),
+		GCInterval:   utils.GetEnvDuration("ATTACHMENT_GC_INTERVAL", time.Hour),
+
+		ThumbnailWorkers: utils.GetEnvInt("THUMBNAIL_WORKERS", 2),
+	}
+
+	repo := NewAttachmentRepository(client)
+	service := NewAttachmentService(repo, store, cfg, wsHandler)
+	service.StartThumbnailWorkers(ctx)
+	service.StartGarbageCollector(ctx)
+	handler := NewAttachmentHandler(service)
+	return handler, repo, service
+}
@@ -0,0 +1,160 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/response"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultAuditPageSize is used when a query of the audit log does not specify a limit.
+	defaultAuditPageSize = 100
+	// maxAuditPageSize caps the number of entries returned by a single query.
+	maxAuditPageSize = 1000
+	// exportTimeout bounds how long an export may take.
+	exportTimeout = 10 * time.Minute
+)
+
+// AuditHandler defines the interface for handling HTTP requests to query and export the audit log.
+type AuditHandler interface {
+	GetEntries(c *fiber.Ctx) error
+	Export(c *fiber.Ctx) error
+}
+
+// AuditHandlerImpl implements the AuditHandler interface.
+type AuditHandlerImpl struct {
+	auditService AuditService
+}
+
+// NewAuditHandler initializes and returns a new AuditHandler with the provided AuditService implementation.
+func NewAuditHandler(auditService AuditService) AuditHandler {
+	return &AuditHandlerImpl{auditService: auditService}
+}
+
+// GetEntries handles an administrator querying the audit log, newest first. The action, actor, target, room,
+// since and until query parameters filter the entries, and before and limit page through them.
+func (ah *AuditHandlerImpl) GetEntries(c *fiber.Ctx) error {
+	q, err := parseQuery(c)
+	if err != nil {
+		return invalidQuery(c, err)
+	}
+	limit := c.QueryInt("limit", defaultAuditPageSize)
+	if limit < 1 || limit > maxAuditPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   "Invalid paging parameters",
+			Status:  fiber.StatusBadRequest,
+			Message: "limit must be between 1 and 1000.",
+		})
+	}
+
+	log.Println("Query Audit Log Request Received.")
+
+	entries, err := ah.auditService.GetEntries(c.Context(), auth.CallerID(c), q, strings.TrimSpace(c.Query("before")), limit)
+	if err != nil {
+		return auditError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Audit Entries Found",
+		Data:    entries,
+	})
+}
+
+// Export handles an administrator downloading the audit log entries matching the same filters as GetEntries, as
+// JSON Lines, oldest first.
+func (ah *AuditHandlerImpl) Export(c *fiber.Ctx) error {
+	q, err := parseQuery(c)
+	if err != nil {
+		return invalidQuery(c, err)
+	}
+	callerId := auth.CallerID(c)
+	if err := ah.auditService.Authorize(callerId); err != nil {
+		return auditError(c, err)
+	}
+
+	log.Println("Export Audit Log Request Received.")
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit.jsonl"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The stream is written after the handler returns, outliving the request context.
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		if err := ah.auditService.Export(ctx, callerId, q, w); err != nil {
+			log.Printf("Audit log export failed: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Audit log export failed: %v", err)
+		}
+	})
+	return nil
+}
+
+// parseQuery reads the filters of an audit log request.
+func parseQuery(c *fiber.Ctx) (Query, error) {
+	q := Query{
+		Action:   strings.TrimSpace(c.Query("action")),
+		ActorID:  strings.TrimSpace(c.Query("actor")),
+		TargetID: strings.TrimSpace(c.Query("target")),
+		RoomID:   strings.TrimSpace(c.Query("room")),
+	}
+	var err error
+	if q.Since, err = parseTimeParam(c.Query("since")); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter, returning the zero time when it is empty.
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func invalidQuery(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusBadRequest,
+		Message: "since and until must be RFC 3339 timestamps.",
+	})
+}
+
+// auditError maps the service errors shared by the audit endpoints to API responses.
+func auditError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "The Audit Log Requires A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Only Administrators May Read The Audit Log.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "before must be an audit entry id.",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed To Read Audit Log",
+	})
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Actions recorded in the audit log, named after the kind of their target.
+const (
+	ActionRoomRenamed      = "room.renamed"
+	ActionRoomSettings     = "room.settings_updated"
+	ActionRoomTopic        = "room.topic_changed"
+	ActionMemberAdded      = "room.member_added"
+	ActionMemberRemoved    = "room.member_removed"
+	ActionModeratorAdded   = "room.moderator_added"
+	ActionModeratorRemoved = "room.moderator_removed"
+	ActionMessageDeleted   = "message.deleted"
+	ActionReportResolved   = "report.resolved"
+	ActionWebhookCreated   = "webhook.created"
+	ActionWebhookDeleted   = "webhook.deleted"
+	ActionIncomingCreated  = "incoming_webhook.created"
+	ActionIncomingRevoked  = "incoming_webhook.revoked"
+	ActionBotRegistered    = "bot.registered"
+	ActionBotDeleted       = "bot.deleted"
+	ActionBotInstalled     = "bot.installed"
+	ActionBotUninstalled   = "bot.uninstalled"
+	ActionUserCreated      = "user.created"
+	ActionUserLoggedIn     = "user.logged_in"
+	// ActionUserLoginFailed entries name the user when the username exists, and record the username tried
+	ActionUserLoginFailed = "user.login_failed"
+	// ActionModerationPrefix is followed by the moderation action, such as moderation.ban
+	ActionModerationPrefix = "moderation."
+)
+
+// Kinds of audit targets.
+const (
+	TargetRoom     = "room"
+	TargetUser     = "user"
+	TargetMessage  = "message"
+	TargetReport   = "report"
+	TargetWebhook  = "webhook"
+	TargetIncoming = "incoming_webhook"
+	TargetBot      = "bot"
+)
+
+// Entry records an administrative or security-relevant action: who did what to which target, from where and
+// when, with the values it changed. Entries are never updated or removed.
+type Entry struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	Action     string             `bson:"action" json:"action"`
+	ActorID    string             `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	TargetType string             `bson:"target_type" json:"target_type"`
+	TargetID   string             `bson:"target_id" json:"target_id"`
+	// RoomID is the room the action took place in, including actions on the room itself
+	RoomID string `bson:"room_id,omitempty" json:"room_id,omitempty"`
+	// Before and After hold the values the action changed, as they were before and after it
+	Before map[string]interface{} `bson:"before,omitempty" json:"before,omitempty"`
+	After  map[string]interface{} `bson:"after,omitempty" json:"after,omitempty"`
+	IP     string                 `bson:"ip,omitempty" json:"ip,omitempty"`
+	At     time.Time              `bson:"at" json:"at"`
+}
+
+// Query filters the audit log. Empty fields match every entry.
+type Query struct {
+	Action   string
+	ActorID  string
+	TargetID string
+	RoomID   string
+	Since    time.Time
+	Until    time.Time
+}
+
+// Values converts a struct into the map recorded as the Before or After of an entry, keyed by its JSON field
+// names. It returns nil when v cannot be converted.
+func Values(v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil
+	}
+	return values
+}
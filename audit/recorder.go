@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Recorder appends entries to the audit log. Services record an entry after the action it describes succeeds, in
+// the same call, so no path that performs the action can leave it out.
+type Recorder interface {
+	Record(ctx context.Context, entry Entry)
+}
+
+// Limits on writing an entry to the audit log before it is spooled to disk instead.
+const (
+	insertAttempts = 3
+	insertBackoff  = 100 * time.Millisecond
+)
+
+// RecorderImpl writes entries to the audit repository. An entry that cannot be written after a few attempts is
+// appended to a spool file, which Start replays into the repository until it is empty, so entries survive an
+// outage of the database. Entries are stored under an ID assigned when they are recorded, making replays
+// idempotent.
+type RecorderImpl struct {
+	auditRepo AuditRepo
+	// spoolPath is the JSON Lines file holding the entries waiting to be written
+	spoolPath string
+	// spoolMu serializes appending to the spool and taking it over for a replay
+	spoolMu sync.Mutex
+	now     func() time.Time
+}
+
+// NewRecorder initializes and returns a new instance of RecorderImpl spooling to the file at spoolPath.
+func NewRecorder(auditRepo AuditRepo, spoolPath string) *RecorderImpl {
+	return &RecorderImpl{auditRepo: auditRepo, spoolPath: spoolPath, now: time.Now}
+}
+
+// Record completes an entry with its ID, time, and the actor and address of the request ctx belongs to where the
+// entry does not name them, then writes it. It never fails the action being audited: entries that cannot be
+// written are spooled, and entries that cannot be spooled either are logged in full.
+func (r *RecorderImpl) Record(ctx context.Context, entry Entry) {
+	entry.ID = primitive.NewObjectID()
+	entry.At = r.now().UTC()
+	source := SourceFrom(ctx)
+	if entry.ActorID == "" {
+		entry.ActorID = source.ActorID
+	}
+	if entry.IP == "" {
+		entry.IP = source.IP
+	}
+
+	// The entry is written even when the request is cancelled once the action has succeeded.
+	ctx = context.WithoutCancel(ctx)
+	var err error
+	for attempt := range insertAttempts {
+		if attempt > 0 {
+			time.Sleep(insertBackoff << (attempt - 1))
+		}
+		if err = r.auditRepo.InsertEntry(ctx, &entry); err == nil {
+			return
+		}
+	}
+	log.Printf("Failed to write audit entry %s, spooling it: %v", entry.ID.Hex(), err)
+	if err := r.spool(&entry); err != nil {
+		data, _ := json.Marshal(entry)
+		log.Printf("Failed to spool audit entry: %v; entry: %s", err, data)
+	}
+}
+
+// spool appends an entry to the spool file.
+func (r *RecorderImpl) spool(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	r.spoolMu.Lock()
+	defer r.spoolMu.Unlock()
+
+	f, err := os.OpenFile(r.spoolPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Start replays the spool into the repository at startup and then every interval, until ctx is cancelled.
+func (r *RecorderImpl) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			r.replay(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// replay takes over the spool file and writes its entries to the repository. Entries that still cannot be written
+// are spooled again for the next replay.
+func (r *RecorderImpl) replay(ctx context.Context) {
+	replayPath := r.spoolPath + ".replay"
+	r.spoolMu.Lock()
+	// A replay file left over by an interrupted replay is finished before taking the spool over again.
+	if _, err := os.Stat(replayPath); errors.Is(err, os.ErrNotExist) {
+		err = os.Rename(r.spoolPath, replayPath)
+		if errors.Is(err, os.ErrNotExist) {
+			r.spoolMu.Unlock()
+			return
+		} else if err != nil {
+			r.spoolMu.Unlock()
+			log.Printf("Failed to take over audit spool: %v", err)
+			return
+		}
+	}
+	r.spoolMu.Unlock()
+
+	f, err := os.Open(replayPath)
+	if err != nil {
+		log.Printf("Failed to open audit spool: %v", err)
+		return
+	}
+	replayed, respooled := 0, 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("Skipping malformed audit spool line: %v; line: %s", err, scanner.Text())
+			continue
+		}
+		if err := r.auditRepo.InsertEntry(ctx, &entry); err != nil {
+			if err := r.spool(&entry); err != nil {
+				f.Close()
+				log.Printf("Failed to respool audit entry %s, keeping %s: %v", entry.ID.Hex(), replayPath, err)
+				return
+			}
+			respooled++
+			continue
+		}
+		replayed++
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		log.Printf("Failed to read audit spool, keeping %s: %v", replayPath, err)
+		return
+	}
+	if err := os.Remove(replayPath); err != nil {
+		log.Printf("Failed to remove replayed audit spool: %v", err)
+	}
+	if replayed > 0 || respooled > 0 {
+		log.Printf("Replayed %d spooled audit entries, %d still waiting", replayed, respooled)
+	}
+}
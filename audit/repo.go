@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"os"
+	"time"
+)
+
+// AuditRepo defines an interface for appending to and querying the audit log. It offers no way to change or
+// remove entries.
+type AuditRepo interface {
+	InsertEntry(ctx context.Context, entry *Entry) error
+	GetEntries(ctx context.Context, q Query, beforeID primitive.ObjectID, limit int64) ([]Entry, error)
+	ExportEntries(ctx context.Context, q Query, fn func(*Entry) error) error
+}
+
+// AuditRepoImpl is a concrete implementation of the AuditRepo interface backed by MongoDB.
+type AuditRepoImpl struct {
+	auditCollection *mongo.Collection
+}
+
+// NewAuditRepository initializes and returns a new instance of AuditRepo, indexing the fields the audit log is
+// queried by.
+func NewAuditRepository(client *mongo.Client) AuditRepo {
+	r := &AuditRepoImpl{
+		auditCollection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("audit_log"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.auditCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "room_id", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create audit log indexes: %v", err)
+	}
+	return r
+}
+
+// InsertEntry appends an entry under its own ID. Inserting an entry that is already stored succeeds without
+// storing it twice, so that failed writes can be retried.
+func (r *AuditRepoImpl) InsertEntry(ctx context.Context, entry *Entry) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.auditCollection.InsertOne(timeoutCtx, entry)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// GetEntries retrieves at most limit entries matching the query, newest first, starting before beforeID unless it
+// is the zero ID.
+func (r *AuditRepoImpl) GetEntries(ctx context.Context, q Query, beforeID primitive.ObjectID, limit int64) ([]Entry, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := queryFilter(q)
+	if !beforeID.IsZero() {
+		filter["_id"] = bson.M{"$lt": beforeID}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	cursor, err := r.auditCollection.Find(timeoutCtx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			log.Default().Println(err.Error())
+		}
+	}(cursor, timeoutCtx)
+
+	entries := []Entry{}
+	if err := cursor.All(timeoutCtx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ExportEntries calls fn with every entry matching the query, oldest first, stopping at the first error. The
+// caller bounds the export through ctx.
+func (r *AuditRepoImpl) ExportEntries(ctx context.Context, q Query, fn func(*Entry) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.auditCollection.Find(ctx, queryFilter(q), opts)
+	if err != nil {
+		return err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			log.Default().Println(err.Error())
+		}
+	}(cursor, ctx)
+
+	for cursor.Next(ctx) {
+		var entry Entry
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// queryFilter builds the filter matching the entries of a query.
+func queryFilter(q Query) bson.M {
+	filter := bson.M{}
+	if q.Action != "" {
+		filter["action"] = q.Action
+	}
+	if q.ActorID != "" {
+		filter["actor_id"] = q.ActorID
+	}
+	if q.TargetID != "" {
+		filter["target_id"] = q.TargetID
+	}
+	if q.RoomID != "" {
+		filter["room_id"] = q.RoomID
+	}
+	at := bson.M{}
+	if !q.Since.IsZero() {
+		at["$gte"] = q.Since
+	}
+	if !q.Until.IsZero() {
+		at["$lt"] = q.Until
+	}
+	if len(at) > 0 {
+		filter["at"] = at
+	}
+	return filter
+}
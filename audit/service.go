@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"messages-go/models/errormodel"
+	"slices"
+)
+
+// AuditService defines the interface for administrators to query and export the audit log.
+type AuditService interface {
+	Authorize(callerId string) error
+	GetEntries(ctx context.Context, callerId string, q Query, before string, limit int) ([]Entry, error)
+	Export(ctx context.Context, callerId string, q Query, w io.Writer) error
+}
+
+// AuditServiceImpl serves the audit log to the administrators configured for the server. There are no other
+// readers: room owners and moderators read their rooms' moderation logs instead.
+type AuditServiceImpl struct {
+	auditRepo AuditRepo
+	adminIds  []string
+}
+
+// NewAuditService initializes and returns a new instance of AuditServiceImpl for the given administrators.
+func NewAuditService(auditRepo AuditRepo, adminIds []string) *AuditServiceImpl {
+	return &AuditServiceImpl{auditRepo: auditRepo, adminIds: adminIds}
+}
+
+// Authorize checks the caller is an administrator.
+func (as *AuditServiceImpl) Authorize(callerId string) error {
+	if callerId == "" {
+		return errormodel.ErrUnauthenticated
+	}
+	if !slices.Contains(as.adminIds, callerId) {
+		return errormodel.ErrForbidden
+	}
+	return nil
+}
+
+// GetEntries returns at most limit entries matching the query, newest first, starting before the entry with ID
+// before unless it is empty.
+func (as *AuditServiceImpl) GetEntries(ctx context.Context, callerId string, q Query, before string, limit int) ([]Entry, error) {
+	if err := as.Authorize(callerId); err != nil {
+		return nil, err
+	}
+	var beforeID primitive.ObjectID
+	if before != "" {
+		var err error
+		if beforeID, err = primitive.ObjectIDFromHex(before); err != nil {
+			return nil, errormodel.ErrInvalidCursor
+		}
+	}
+	return as.auditRepo.GetEntries(ctx, q, beforeID, int64(limit))
+}
+
+// Export writes every entry matching the query to w as JSON Lines, oldest first.
+func (as *AuditServiceImpl) Export(ctx context.Context, callerId string, q Query, w io.Writer) error {
+	if err := as.Authorize(callerId); err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	return as.auditRepo.ExportEntries(ctx, q, func(entry *Entry) error {
+		return encoder.Encode(entry)
+	})
+}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"messages-go/auth"
+)
+
+// sourceKey is the fiber Locals key under which Middleware stores the Source of a request. Fiber keeps Locals on
+// the request context, so services find the source in the context handlers pass them.
+type sourceKey struct{}
+
+// Source identifies who made a request and from where.
+type Source struct {
+	ActorID string
+	IP      string
+}
+
+// Middleware records the Source of each request, so that audit entries written while serving it name the actor
+// and address even when the service is not told the caller.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(sourceKey{}, Source{ActorID: auth.CallerID(c), IP: c.IP()})
+		return c.Next()
+	}
+}
+
+// SourceFrom returns the Source recorded for the request ctx belongs to, or the zero Source outside a request.
+func SourceFrom(ctx context.Context) Source {
+	if source, ok := ctx.Value(sourceKey{}).(Source); ok {
+		return source
+	}
+	return Source{}
+}
@@ -0,0 +1,19 @@
+package audit
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/utils"
+	"time"
+)
+
+// InitAudit wires the audit handler and the recorder the other services write the audit log through, and starts
+// replaying the recorder's spool. Administrators are listed in AUDIT_ADMIN_IDS.
+func InitAudit(ctx context.Context, client *mongo.Client) (AuditHandler, Recorder) {
+	repo := NewAuditRepository(client)
+	recorder := NewRecorder(repo, utils.GetEnv("AUDIT_SPOOL_PATH", "audit-spool.jsonl"))
+	recorder.Start(ctx, utils.GetEnvDuration("AUDIT_SPOOL_REPLAY_INTERVAL", time.Minute))
+	service := NewAuditService(repo, utils.GetEnvList("AUDIT_ADMIN_IDS", nil))
+	handler := NewAuditHandler(service)
+	return handler, recorder
+}
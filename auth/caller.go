@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"messages-go/models/response"
+	"strings"
+)
+
+// SessionCookie carries the session token of browser clients, which cannot set headers on WebSocket and
+// EventSource connections.
+const SessionCookie = "session"
+
+// LocalsUserID is the fiber Locals key under which Middleware stores the verified caller ID. WebSocket connections
+// read it from the same key.
+const LocalsUserID = "user_id"
+
+// Middleware verifies the session token of each request, given as an "Authorization: Bearer" header or in the
+// session cookie, and records the user it was issued to as the caller. Requests without a token are anonymous;
+// requests with an invalid or expired token are rejected.
+func Middleware(signer *Signer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := bearerToken(c)
+		if token == "" {
+			token = c.Cookies(SessionCookie)
+		}
+		if token == "" {
+			return c.Next()
+		}
+		userId, err := signer.Verify(token)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+				Error:   err.Error(),
+				Status:  fiber.StatusUnauthorized,
+				Message: "Session Token Is Invalid Or Expired.",
+			})
+		}
+		c.Locals(LocalsUserID, userId)
+		return c.Next()
+	}
+}
+
+// CallerID returns the ID of the user whose session token Middleware verified for the request, or an empty string
+// for anonymous callers.
+func CallerID(c *fiber.Ctx) string {
+	id, _ := c.Locals(LocalsUserID).(string)
+	return id
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header, or an empty string without one.
+func bearerToken(c *fiber.Ctx) string {
+	header := c.Get(fiber.HeaderAuthorization)
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[len("Bearer "):])
+}
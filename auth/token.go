@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"messages-go/models/errormodel"
+	"strings"
+	"time"
+)
+
+// minSecretSize is the shortest signing secret accepted from the environment.
+const minSecretSize = 32
+
+// Signer issues and verifies session tokens. A token is the base64url encoding of its claims and of their
+// HMAC-SHA256 under the signer's secret, joined by a dot.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// claims are the contents of a session token.
+type claims struct {
+	// Subject is the ID of the user the token was issued to
+	Subject string `json:"sub"`
+	// ExpiresAt is the Unix time after which the token is rejected
+	ExpiresAt int64 `json:"exp"`
+}
+
+// NewSigner initializes and returns a Signer issuing tokens valid for ttl. A secret shorter than 32 bytes is
+// replaced by a random one, so that tokens cannot be forged, at the cost of sessions not surviving a restart.
+func NewSigner(secret string, ttl time.Duration) *Signer {
+	key := []byte(secret)
+	if len(key) < minSecretSize {
+		log.Printf("Session secret is shorter than %d bytes, signing sessions with a random secret", minSecretSize)
+		key = make([]byte, minSecretSize)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("Failed to generate session secret: %v", err)
+		}
+	}
+	return &Signer{secret: key, ttl: ttl, now: time.Now}
+}
+
+// Issue returns a session token for a user and when it expires.
+func (s *Signer) Issue(userId string) (string, time.Time, error) {
+	expiresAt := s.now().Add(s.ttl).UTC().Truncate(time.Second)
+	payload, err := json.Marshal(claims{Subject: userId, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded)), expiresAt, nil
+}
+
+// Verify returns the ID of the user a token was issued to, or ErrInvalidToken when the token is malformed, was
+// not signed by this signer or has expired.
+func (s *Signer) Verify(token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errormodel.ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.sign(encoded)) {
+		return "", errormodel.ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errormodel.ErrInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Subject == "" {
+		return "", errormodel.ErrInvalidToken
+	}
+	if !s.now().Before(time.Unix(c.ExpiresAt, 0)) {
+		return "", errormodel.ErrInvalidToken
+	}
+	return c.Subject, nil
+}
+
+func (s *Signer) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
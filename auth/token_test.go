@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	signer := NewSigner(strings.Repeat("s", 32), time.Hour)
+	token, expiresAt, err := signer.Issue("user-1")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if !expiresAt.After(time.Now()) {
+		t.Fatalf("token expires in the past: %v", expiresAt)
+	}
+	userId, err := signer.Verify(token)
+	if err != nil || userId != "user-1" {
+		t.Fatalf("Verify = %q, %v; want user-1", userId, err)
+	}
+}
+
+func TestSignerRejects(t *testing.T) {
+	signer := NewSigner(strings.Repeat("s", 32), time.Hour)
+	other := NewSigner(strings.Repeat("o", 32), time.Hour)
+	token, _, _ := signer.Issue("user-1")
+	forged, _, _ := other.Issue("user-2")
+	payload, signature, _ := strings.Cut(token, ".")
+	otherPayload, _, _ := strings.Cut(forged, ".")
+
+	expired := NewSigner(strings.Repeat("s", 32), time.Hour)
+	expired.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	stale, _, _ := expired.Issue("user-1")
+
+	for name, token := range map[string]string{
+		"empty":            "",
+		"no signature":     payload,
+		"other secret":     forged,
+		"swapped payload":  otherPayload + "." + signature,
+		"garbage":          "a.b",
+		"expired":          stale,
+		"truncated":        token[:len(token)-2],
+		"tampered payload": "x" + token[1:],
+	} {
+		if userId, err := signer.Verify(token); err == nil {
+			t.Errorf("%s: Verify accepted the token for %q", name, userId)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !CheckPassword(hash, "correct horse") {
+		t.Error("CheckPassword rejected the password")
+	}
+	if CheckPassword(hash, "correct horsf") {
+		t.Error("CheckPassword accepted a wrong password")
+	}
+	if CheckPassword("", "") {
+		t.Error("CheckPassword accepted an empty hash")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	signer := NewSigner(strings.Repeat("s", 32), time.Hour)
+	token, _, _ := signer.Issue("user-1")
+
+	app := fiber.New()
+	app.Use(Middleware(signer))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(CallerID(c))
+	})
+
+	tests := []struct {
+		name       string
+		header     string
+		cookie     string
+		query      string
+		wantStatus int
+		wantCaller string
+	}{
+		{name: "anonymous", wantStatus: http.StatusOK},
+		{name: "bearer", header: "Bearer " + token, wantStatus: http.StatusOK, wantCaller: "user-1"},
+		{name: "cookie", cookie: token, wantStatus: http.StatusOK, wantCaller: "user-1"},
+		{name: "invalid", header: "Bearer " + token + "x", wantStatus: http.StatusUnauthorized},
+		{name: "query is ignored", query: "?user_id=user-2", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+			}
+			req.Header.Set("X-User-ID", "user-3")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			body := make([]byte, 64)
+			n, _ := resp.Body.Read(body)
+			if got := string(body[:n]); got != tt.wantCaller {
+				t.Errorf("caller = %q, want %q", got, tt.wantCaller)
+			}
+		})
+	}
+}
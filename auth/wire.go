@@ -0,0 +1,12 @@
+package auth
+
+import (
+	"messages-go/utils"
+	"time"
+)
+
+// InitSigner returns the signer of session tokens, keyed by AUTH_TOKEN_SECRET and issuing tokens valid for
+// AUTH_TOKEN_TTL. Every instance of the service must share the secret.
+func InitSigner() *Signer {
+	return NewSigner(utils.GetEnv("AUTH_TOKEN_SECRET", ""), utils.GetEnvDuration("AUTH_TOKEN_TTL", 24*time.Hour))
+}
@@ -0,0 +1,177 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"messages-go/message"
+	"messages-go/message/format"
+	"messages-go/models/errormodel"
+	"messages-go/room"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// commandPattern matches the names of commands, such as roll in /roll.
+var commandPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// rollPattern matches the dice of /roll, such as 2d6 or d20.
+var rollPattern = regexp.MustCompile(`^(\d*)d(\d+)$`)
+
+// Limits on the dice rolled by /roll.
+const (
+	maxDice  = 20
+	maxSides = 1000
+)
+
+// shrug is appended to messages by /shrug.
+const shrug = `¯\_(ツ)_/¯`
+
+// Command is a command issued in a room: the message starting with it, split into the name of the command and the
+// text following it.
+type Command struct {
+	Name string
+	Args string
+	Msg  *message.Message
+	Room *room.Room
+}
+
+// ParseCommand splits a message body starting with a command, such as "/roll 2d6", into the command's name and
+// its arguments. Bodies that do not start with a well-formed command name, such as paths, are not commands.
+func ParseCommand(body string) (string, string, bool) {
+	if !strings.HasPrefix(body, "/") {
+		return "", "", false
+	}
+	name, args, _ := strings.Cut(body[1:], " ")
+	name = strings.ToLower(name)
+	if !commandPattern.MatchString(name) {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+// builtin is a command handled by the server itself.
+type builtin struct {
+	usage string
+	run   func(ctx context.Context, cmd *Command) (*message.Message, error)
+}
+
+// builtins returns the built-in commands, which take precedence over the commands of bots.
+func (r *Router) builtins() map[string]builtin {
+	return map[string]builtin{
+		"help":  {usage: "/help lists the commands of this room", run: r.help},
+		"me":    {usage: "/me <action> describes what you are doing", run: me},
+		"shrug": {usage: "/shrug [message] appends " + shrug, run: shrugCommand},
+		"roll":  {usage: "/roll [NdM] rolls N dice with M sides, 1d6 by default", run: roll},
+		"topic": {usage: "/topic [topic] shows or changes the topic of the room", run: r.topic},
+	}
+}
+
+// IsBuiltin reports whether a command is handled by the server itself, so that bots cannot register it.
+func IsBuiltin(name string) bool {
+	_, ok := (&Router{}).builtins()[name]
+	return ok
+}
+
+// help lists the built-in commands and those of the bots installed in the room.
+func (r *Router) help(ctx context.Context, cmd *Command) (*message.Message, error) {
+	var lines []string
+	for _, b := range r.builtins() {
+		lines = append(lines, b.usage)
+	}
+	sort.Strings(lines)
+	installations, err := r.botRepo.GetInstallations(ctx, cmd.Room.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	for _, inst := range installations {
+		if !inst.HasScope(ScopeCommands) {
+			continue
+		}
+		for _, name := range inst.Commands {
+			lines = append(lines, "/"+name+" is handled by a bot")
+		}
+	}
+	return reply(cmd, strings.Join(lines, "\n"))
+}
+
+// me turns the message into an emote of its sender.
+func me(_ context.Context, cmd *Command) (*message.Message, error) {
+	if cmd.Args == "" {
+		return reply(cmd, "Usage: /me <action>")
+	}
+	cmd.Msg.Kind = message.KindEmote
+	cmd.Msg.Body = cmd.Args
+	return cmd.Msg, nil
+}
+
+// shrugCommand appends a shrug to the message, escaped for Markdown messages.
+func shrugCommand(_ context.Context, cmd *Command) (*message.Message, error) {
+	s := shrug
+	if cmd.Msg.Format == format.Markdown {
+		s = `¯\\\_(ツ)\_/¯`
+	}
+	cmd.Msg.Body = strings.TrimSpace(cmd.Args + " " + s)
+	return cmd.Msg, nil
+}
+
+// roll replaces the message with the outcome of rolling dice, posted as an emote of its sender.
+func roll(_ context.Context, cmd *Command) (*message.Message, error) {
+	dice, sides := 1, 6
+	if cmd.Args != "" {
+		m := rollPattern.FindStringSubmatch(strings.ToLower(cmd.Args))
+		if m == nil {
+			return reply(cmd, "Usage: /roll [NdM], such as /roll 2d6")
+		}
+		if m[1] != "" {
+			dice, _ = strconv.Atoi(m[1])
+		}
+		sides, _ = strconv.Atoi(m[2])
+	}
+	if dice < 1 || dice > maxDice || sides < 2 || sides > maxSides {
+		return reply(cmd, fmt.Sprintf("Roll 1 to %d dice with 2 to %d sides.", maxDice, maxSides))
+	}
+
+	rolls := make([]string, dice)
+	total := 0
+	for i := range rolls {
+		n := rand.IntN(sides) + 1
+		rolls[i] = strconv.Itoa(n)
+		total += n
+	}
+	cmd.Msg.Kind = message.KindEmote
+	cmd.Msg.Format = ""
+	cmd.Msg.Body = fmt.Sprintf("rolled %dd%d: %s (total %d)", dice, sides, strings.Join(rolls, ", "), total)
+	return cmd.Msg, nil
+}
+
+// topic shows the topic of the room, or changes it and posts the change as an emote of the sender.
+func (r *Router) topic(ctx context.Context, cmd *Command) (*message.Message, error) {
+	if cmd.Args == "" {
+		if cmd.Room.Topic == "" {
+			return reply(cmd, "This room has no topic.")
+		}
+		return reply(cmd, "The topic is: "+cmd.Room.Topic)
+	}
+	updated, err := r.roomService.UpdateTopic(ctx, cmd.Room.ID.Hex(), cmd.Msg.SenderID, cmd.Args)
+	if errors.Is(err, errormodel.ErrInvalidTopic) {
+		return reply(cmd, "Topics are a single line of at most 250 characters.")
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return reply(cmd, "Only members of the room can change its topic.")
+	} else if err != nil {
+		return nil, err
+	}
+	cmd.Msg.Kind = message.KindEmote
+	cmd.Msg.Format = ""
+	cmd.Msg.Body = "changed the topic to: " + updated.Topic
+	return cmd.Msg, nil
+}
+
+// reply returns an ephemeral reply of a built-in command.
+func reply(cmd *Command, text string) (*message.Message, error) {
+	integration := &message.Integration{Kind: message.IntegrationCommand, ID: cmd.Name}
+	return message.NewEphemeralReply(cmd.Msg.RoomID, "", integration, text, "")
+}
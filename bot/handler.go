@@ -0,0 +1,240 @@
+package bot
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/models/response"
+	"strings"
+)
+
+// BotHandler defines the interface for handling HTTP requests to register bots, install them in rooms, and
+// authenticate the connections of WebSocket bots.
+type BotHandler interface {
+	RegisterBot(c *fiber.Ctx) error
+	ListBots(c *fiber.Ctx) error
+	DeleteBot(c *fiber.Ctx) error
+	InstallBot(c *fiber.Ctx) error
+	ListInstallations(c *fiber.Ctx) error
+	UninstallBot(c *fiber.Ctx) error
+	AuthenticateSocket(c *fiber.Ctx) error
+}
+
+// BotHandlerImpl implements the BotHandler interface.
+type BotHandlerImpl struct {
+	botService BotService
+}
+
+// NewBotHandler initializes and returns a new BotHandler with the provided BotService implementation.
+func NewBotHandler(botService BotService) BotHandler {
+	return &BotHandlerImpl{botService: botService}
+}
+
+// RegisterBot handles the caller registering a bot. The response carries the token of a WebSocket bot, which is
+// not shown again.
+func (bh *BotHandlerImpl) RegisterBot(c *fiber.Ctx) error {
+	var req request.RegisterBotRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+	req.Transport = strings.TrimSpace(req.Transport)
+	req.URL = strings.TrimSpace(req.URL)
+
+	log.Println("Register Bot ", req.Username, " Request Received.")
+
+	registered, err := bh.botService.RegisterBot(c.Context(), auth.CallerID(c), req)
+	if errors.Is(err, errormodel.ErrInvalidUsername) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Username Must Be 2 To 32 Lowercase Letters, Digits, '.', '_' Or '-'.",
+		})
+	} else if errors.Is(err, errormodel.ErrUsernameTaken) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "Username Already Taken.",
+		})
+	} else if err != nil {
+		return botError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Bot Registered",
+		Data:    registered,
+	})
+}
+
+// ListBots handles the caller reading the bots they registered.
+func (bh *BotHandlerImpl) ListBots(c *fiber.Ctx) error {
+	log.Println("List Bots Request Received.")
+
+	bots, err := bh.botService.ListBots(c.Context(), auth.CallerID(c))
+	if err != nil {
+		return botError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Bots Found",
+		Data:    bots,
+	})
+}
+
+// DeleteBot handles the caller removing one of their bots.
+func (bh *BotHandlerImpl) DeleteBot(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	log.Println("Delete Bot ", id, " Request Received.")
+
+	if err := bh.botService.DeleteBot(c.Context(), id, auth.CallerID(c)); err != nil {
+		return botError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Bot Deleted",
+	})
+}
+
+// InstallBot handles a room owner installing a bot in the room.
+func (bh *BotHandlerImpl) InstallBot(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	var req request.InstallBotRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	log.Println("Install Bot ", req.BotID, " in Room with id ", roomId, " Request Received.")
+
+	inst, err := bh.botService.InstallBot(c.Context(), roomId, auth.CallerID(c), req)
+	if errors.Is(err, errormodel.ErrBotInstalled) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "The Bot Is Already Installed In This Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrCommandTaken) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "Another Bot In This Room Handles One Of The Bot's Commands.",
+		})
+	} else if err != nil {
+		return botError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Bot Installed",
+		Data:    inst,
+	})
+}
+
+// ListInstallations handles a room owner reading the bots installed in the room.
+func (bh *BotHandlerImpl) ListInstallations(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+
+	log.Println("List Bots of Room with id ", roomId, " Request Received.")
+
+	installations, err := bh.botService.ListInstallations(c.Context(), roomId, auth.CallerID(c))
+	if err != nil {
+		return botError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Bots Found",
+		Data:    installations,
+	})
+}
+
+// UninstallBot handles a room owner removing a bot from the room.
+func (bh *BotHandlerImpl) UninstallBot(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	botId := c.Params("botId")
+
+	log.Println("Uninstall Bot ", botId, " from Room with id ", roomId, " Request Received.")
+
+	if err := bh.botService.UninstallBot(c.Context(), roomId, botId, auth.CallerID(c)); err != nil {
+		return botError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Bot Uninstalled",
+	})
+}
+
+// AuthenticateSocket authenticates the bot upgrading to a WebSocket connection by the token in its Authorization
+// header, or in the token query parameter for clients that cannot set headers.
+func (bh *BotHandlerImpl) AuthenticateSocket(c *fiber.Ctx) error {
+	token := strings.TrimSpace(strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "))
+	if token == "" {
+		token = c.Query("token")
+	}
+	b, err := bh.botService.Authenticate(c.Context(), token)
+	if errors.Is(err, errormodel.ErrBotNotFound) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "A Valid Bot Token Is Required.",
+		})
+	} else if err != nil {
+		return botError(c, err)
+	}
+	c.Locals(LocalsBotID, b.ID.Hex())
+	return c.Next()
+}
+
+// botError maps the service errors shared by the bot endpoints to API responses.
+func botError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Bots Require A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Only The Room Owner Can Manage Its Bots.",
+		})
+	} else if errors.Is(err, errormodel.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Room Found with given id.",
+		})
+	} else if errors.Is(err, errormodel.ErrBotNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Bot Found with given id.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidBot) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Bots Need 1 To 10 Commands That Are Not Built In, transport http With A url And A 16 To 256 Character secret Or transport websocket, And Known scopes.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidRoomID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Room id is malformed.",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed To Process Bot Request",
+	})
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"messages-go/unfurl"
+	"messages-go/webhook"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxReplySize bounds the replies read from bots.
+const maxReplySize = 64 << 10
+
+// HTTPInvoker posts invocations to HTTP bots, signed like webhook deliveries with the bot's secret, and reads the
+// reply from the response.
+type HTTPInvoker struct {
+	client    *http.Client
+	userAgent string
+	now       func() time.Time
+}
+
+// NewHTTPInvoker initializes and returns a new HTTPInvoker. Bots on private networks are unreachable unless
+// allowPrivateNetworks is set.
+func NewHTTPInvoker(timeout time.Duration, userAgent string, allowPrivateNetworks bool) *HTTPInvoker {
+	return &HTTPInvoker{
+		client:    unfurl.NewClient(unfurl.Config{Timeout: timeout, AllowPrivateNetworks: allowPrivateNetworks}),
+		userAgent: userAgent,
+		now:       time.Now,
+	}
+}
+
+// Invoke posts an invocation to a bot and returns its reply, or nil when the bot answered with no content.
+func (h *HTTPInvoker) Invoke(ctx context.Context, b *Bot, inv *Invocation) (*Reply, error) {
+	body, err := json.Marshal(inv)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(h.now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", h.userAgent)
+	req.Header.Set("X-Bot-Invocation", inv.ID)
+	req.Header.Set("X-Bot-Timestamp", timestamp)
+	req.Header.Set("X-Bot-Signature", webhook.Sign(b.Secret, timestamp, body))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxReplySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxReplySize {
+		return nil, fmt.Errorf("reply exceeds %d bytes", maxReplySize)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var r Reply
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("malformed reply: %w", err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"time"
+)
+
+// Transports bots receive their commands over.
+const (
+	// TransportHTTP bots are posted each invocation and answer it in the response
+	TransportHTTP = "http"
+	// TransportWebSocket bots hold a connection to /ws/bot, receive invocations as frames and answer with frames
+	TransportWebSocket = "websocket"
+)
+
+// Scopes granted to a bot when it is installed in a room.
+const (
+	// ScopeCommands lets the bot receive the invocations of its commands in the room
+	ScopeCommands = "commands"
+	// ScopeMessagesWrite lets the bot reply to everyone in the room; without it every reply is ephemeral
+	ScopeMessagesWrite = "messages:write"
+	// ScopeRoomRead includes the room's name, topic and members in the invocations the bot receives
+	ScopeRoomRead = "room:read"
+)
+
+// Scopes is the set of scopes bots may be granted.
+var Scopes = []string{ScopeCommands, ScopeMessagesWrite, ScopeRoomRead}
+
+// Bot is an external command handler registered by a user. It posts its replies from a bot account of its own.
+// The secret signs the invocations of HTTP bots and the token authenticates the connections of WebSocket bots;
+// neither is returned.
+type Bot struct {
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	// UserID is the bot account the bot replies from
+	UserID    string   `bson:"user_id" json:"user_id"`
+	Username  string   `bson:"username" json:"username"`
+	OwnerID   string   `bson:"owner_id" json:"owner_id"`
+	Commands  []string `bson:"commands" json:"commands"`
+	Transport string   `bson:"transport" json:"transport"`
+	// URL receives the invocations of HTTP bots
+	URL       string    `bson:"url,omitempty" json:"url,omitempty"`
+	Secret    string    `bson:"secret,omitempty" json:"-"`
+	TokenHash string    `bson:"token_hash,omitempty" json:"-"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// RegisteredBot is a newly registered bot together with the token of a WebSocket bot.
+type RegisteredBot struct {
+	*Bot
+	Token string `json:"token,omitempty"`
+}
+
+// Installation grants a bot scopes in a room.
+type Installation struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BotID  primitive.ObjectID `bson:"bot_id" json:"bot_id"`
+	RoomID string             `bson:"room_id" json:"room_id"`
+	// Commands are copied from the bot when it is installed, so a room's commands can be looked up at once
+	Commands    []string  `bson:"commands" json:"commands"`
+	Scopes      []string  `bson:"scopes" json:"scopes"`
+	InstalledBy string    `bson:"installed_by" json:"installed_by"`
+	InstalledAt time.Time `bson:"installed_at" json:"installed_at"`
+}
+
+// HasScope reports whether the installation grants a scope.
+func (i *Installation) HasScope(scope string) bool {
+	return slices.Contains(i.Scopes, scope)
+}
+
+// Invocation is sent to a bot when a user issues one of its commands.
+type Invocation struct {
+	ID      string `json:"id"`
+	BotID   string `json:"bot_id"`
+	Command string `json:"command"`
+	// Args is the text following the command
+	Args     string    `json:"args"`
+	RoomID   string    `json:"room_id"`
+	UserID   string    `json:"user_id"`
+	Room     *RoomInfo `json:"room,omitempty"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// RoomInfo describes the room of an invocation to bots granted ScopeRoomRead.
+type RoomInfo struct {
+	Name    string   `json:"name"`
+	Topic   string   `json:"topic,omitempty"`
+	Private bool     `json:"private"`
+	Members []string `json:"members,omitempty"`
+}
+
+// Reply is a bot's answer to an invocation. Ephemeral replies are shown only to the user who issued the command.
+type Reply struct {
+	Text      string `json:"text"`
+	Format    string `json:"format,omitempty"`
+	Ephemeral bool   `json:"ephemeral,omitempty"`
+}
@@ -0,0 +1,211 @@
+package bot
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"messages-go/models/errormodel"
+	"os"
+	"time"
+)
+
+// BotRepo defines an interface for persisting bots and their installations in rooms.
+type BotRepo interface {
+	CreateBot(ctx context.Context, b *Bot) (*Bot, error)
+	GetBot(ctx context.Context, id primitive.ObjectID) (*Bot, error)
+	GetBotByTokenHash(ctx context.Context, tokenHash string) (*Bot, error)
+	GetBotsByOwner(ctx context.Context, ownerID string) ([]Bot, error)
+	DeleteBot(ctx context.Context, id primitive.ObjectID) error
+	CreateInstallation(ctx context.Context, inst *Installation) (*Installation, error)
+	GetInstallations(ctx context.Context, roomID string) ([]Installation, error)
+	GetInstallationByCommand(ctx context.Context, roomID string, command string) (*Installation, error)
+	DeleteInstallation(ctx context.Context, roomID string, botID primitive.ObjectID) (*Installation, error)
+	DeleteInstallationsOfBot(ctx context.Context, botID primitive.ObjectID) error
+}
+
+// BotRepoImpl is a concrete implementation of the BotRepo interface backed by MongoDB.
+type BotRepoImpl struct {
+	botCollection          *mongo.Collection
+	installationCollection *mongo.Collection
+}
+
+// NewBotRepository initializes and returns a new instance of BotRepo. A bot is installed in a room at most once,
+// and tokens are looked up by their hash.
+func NewBotRepository(client *mongo.Client) BotRepo {
+	db := client.Database(os.Getenv("MONGO_DB_NAME"))
+	r := &BotRepoImpl{
+		botCollection:          db.Collection("bots"),
+		installationCollection: db.Collection("bot_installations"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.botCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"token_hash": bson.M{"$type": "string"}}),
+		},
+		{Keys: bson.D{{Key: "owner_id", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create bot indexes: %v", err)
+	}
+	_, err = r.installationCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "room_id", Value: 1}, {Key: "bot_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "room_id", Value: 1}, {Key: "commands", Value: 1}}},
+		{Keys: bson.D{{Key: "bot_id", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create bot installation indexes: %v", err)
+	}
+	return r
+}
+
+// CreateBot stores a bot and returns it with its generated ID.
+func (r *BotRepoImpl) CreateBot(ctx context.Context, b *Bot) (*Bot, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.botCollection.InsertOne(timeoutCtx, b)
+	if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		b.ID = oid
+	}
+	return b, nil
+}
+
+// GetBot retrieves a bot, returning mongo.ErrNoDocuments when it does not exist.
+func (r *BotRepoImpl) GetBot(ctx context.Context, id primitive.ObjectID) (*Bot, error) {
+	return r.findBot(ctx, bson.M{"_id": id})
+}
+
+// GetBotByTokenHash retrieves the bot of a token, returning mongo.ErrNoDocuments when there is none.
+func (r *BotRepoImpl) GetBotByTokenHash(ctx context.Context, tokenHash string) (*Bot, error) {
+	return r.findBot(ctx, bson.M{"token_hash": tokenHash})
+}
+
+func (r *BotRepoImpl) findBot(ctx context.Context, filter bson.M) (*Bot, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var b Bot
+	if err := r.botCollection.FindOne(timeoutCtx, filter).Decode(&b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetBotsByOwner retrieves the bots registered by a user in the order they were registered.
+func (r *BotRepoImpl) GetBotsByOwner(ctx context.Context, ownerID string) ([]Bot, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.botCollection.Find(timeoutCtx, bson.M{"owner_id": ownerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	bots := []Bot{}
+	if err := cursor.All(timeoutCtx, &bots); err != nil {
+		return nil, err
+	}
+	return bots, nil
+}
+
+// DeleteBot removes a bot, returning mongo.ErrNoDocuments when it does not exist.
+func (r *BotRepoImpl) DeleteBot(ctx context.Context, id primitive.ObjectID) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.botCollection.DeleteOne(timeoutCtx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// CreateInstallation stores an installation and returns it with its generated ID, or ErrBotInstalled when the
+// bot is installed in the room already.
+func (r *BotRepoImpl) CreateInstallation(ctx context.Context, inst *Installation) (*Installation, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.installationCollection.InsertOne(timeoutCtx, inst)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, errormodel.ErrBotInstalled
+	} else if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		inst.ID = oid
+	}
+	return inst, nil
+}
+
+// GetInstallations retrieves the installations of a room in the order the bots were installed.
+func (r *BotRepoImpl) GetInstallations(ctx context.Context, roomID string) ([]Installation, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.installationCollection.Find(timeoutCtx, bson.M{"room_id": roomID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	installations := []Installation{}
+	if err := cursor.All(timeoutCtx, &installations); err != nil {
+		return nil, err
+	}
+	return installations, nil
+}
+
+// GetInstallationByCommand retrieves the installation of the bot handling a command in a room, returning
+// mongo.ErrNoDocuments when no installed bot does.
+func (r *BotRepoImpl) GetInstallationByCommand(ctx context.Context, roomID string, command string) (*Installation, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var inst Installation
+	if err := r.installationCollection.FindOne(timeoutCtx, bson.M{"room_id": roomID, "commands": command}).Decode(&inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// DeleteInstallation removes a bot from a room and returns its installation, or mongo.ErrNoDocuments when it was
+// not installed there.
+func (r *BotRepoImpl) DeleteInstallation(ctx context.Context, roomID string, botID primitive.ObjectID) (*Installation, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var inst Installation
+	if err := r.installationCollection.FindOneAndDelete(timeoutCtx, bson.M{"room_id": roomID, "bot_id": botID}).Decode(&inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// DeleteInstallationsOfBot removes a bot from every room it is installed in.
+func (r *BotRepoImpl) DeleteInstallationsOfBot(ctx context.Context, botID primitive.ObjectID) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.installationCollection.DeleteMany(timeoutCtx, bson.M{"bot_id": botID})
+	return err
+}
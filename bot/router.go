@@ -0,0 +1,131 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/message"
+	"messages-go/room"
+	"strings"
+	"time"
+)
+
+// Invoker delivers an invocation to a bot and returns its reply, or nil for none.
+type Invoker interface {
+	Invoke(ctx context.Context, b *Bot, inv *Invocation) (*Reply, error)
+}
+
+// Router is the message service's CommandRouter. Messages starting with a command are handled by the built-in
+// command of that name or else by the bot installed in the room to handle it; messages starting with // are
+// posted with the first slash removed.
+type Router struct {
+	botRepo     BotRepo
+	roomService room.RoomService
+	// invokers deliver invocations by the transport of the bot
+	invokers map[string]Invoker
+	// timeout bounds how long a bot may take to reply
+	timeout  time.Duration
+	commands map[string]builtin
+	now      func() time.Time
+}
+
+// NewRouter initializes and returns a new Router delivering invocations to HTTP and WebSocket bots.
+func NewRouter(botRepo BotRepo, roomService room.RoomService, httpInvoker Invoker, socketInvoker Invoker, timeout time.Duration) *Router {
+	r := &Router{
+		botRepo:     botRepo,
+		roomService: roomService,
+		invokers:    map[string]Invoker{TransportHTTP: httpInvoker, TransportWebSocket: socketInvoker},
+		timeout:     timeout,
+		now:         time.Now,
+	}
+	r.commands = r.builtins()
+	return r
+}
+
+// RouteCommand handles the command a text message starts with. Messages posted by integrations and messages
+// forwarding or quoting another are never commands.
+func (r *Router) RouteCommand(ctx context.Context, msg *message.Message, roomData *room.Room) (*message.Message, error) {
+	if msg.Integration != nil || msg.Reference != nil || msg.Kind != message.KindText {
+		return msg, nil
+	}
+	if strings.HasPrefix(msg.Body, "//") {
+		msg.Body = msg.Body[1:]
+		return msg, nil
+	}
+	name, args, ok := ParseCommand(msg.Body)
+	if !ok {
+		return msg, nil
+	}
+	cmd := &Command{Name: name, Args: args, Msg: msg, Room: roomData}
+	if b, ok := r.commands[name]; ok {
+		return b.run(ctx, cmd)
+	}
+	return r.dispatch(ctx, cmd)
+}
+
+// dispatch invokes the bot installed in the room to handle a command and turns its reply into the message to
+// post, under the client message ID of the command, or into an ephemeral reply when the bot asked for one or may
+// not reply to everyone.
+func (r *Router) dispatch(ctx context.Context, cmd *Command) (*message.Message, error) {
+	roomId := cmd.Room.ID.Hex()
+	inst, err := r.botRepo.GetInstallationByCommand(ctx, roomId, cmd.Name)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !inst.HasScope(ScopeCommands)) {
+		return reply(cmd, "Unknown command /"+cmd.Name+". Start the message with // to post it as it is.")
+	} else if err != nil {
+		return nil, err
+	}
+	b, err := r.botRepo.GetBot(ctx, inst.BotID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return reply(cmd, "Unknown command /"+cmd.Name+". Start the message with // to post it as it is.")
+	} else if err != nil {
+		return nil, err
+	}
+
+	inv := &Invocation{
+		ID:       primitive.NewObjectID().Hex(),
+		BotID:    b.ID.Hex(),
+		Command:  cmd.Name,
+		Args:     cmd.Args,
+		RoomID:   roomId,
+		UserID:   cmd.Msg.SenderID,
+		IssuedAt: r.now().UTC(),
+	}
+	if inst.HasScope(ScopeRoomRead) {
+		inv.Room = &RoomInfo{Name: cmd.Room.Name, Topic: cmd.Room.Topic, Private: cmd.Room.Private, Members: cmd.Room.Members}
+	}
+	invoker := r.invokers[b.Transport]
+	if invoker == nil {
+		log.Printf("Bot %s has unknown transport %q", b.ID.Hex(), b.Transport)
+		return reply(cmd, "@"+b.Username+" is not available.")
+	}
+	invokeCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	answer, err := invoker.Invoke(invokeCtx, b, inv)
+	if err != nil {
+		log.Printf("Failed to invoke /%s of bot %s: %v", cmd.Name, b.ID.Hex(), err)
+		return reply(cmd, "@"+b.Username+" did not respond to /"+cmd.Name+".")
+	}
+	if answer == nil || strings.TrimSpace(answer.Text) == "" {
+		return reply(cmd, "/"+cmd.Name+" was sent to @"+b.Username+".")
+	}
+
+	integration := &message.Integration{Kind: message.IntegrationBot, ID: b.ID.Hex(), Username: b.Username, InvokerID: cmd.Msg.SenderID}
+	if answer.Ephemeral || !inst.HasScope(ScopeMessagesWrite) {
+		ephemeral, err := message.NewEphemeralReply(roomId, b.UserID, integration, answer.Text, answer.Format)
+		if err != nil {
+			// Shown as it is rather than dropped when the bot's markup cannot be rendered.
+			return message.NewEphemeralReply(roomId, b.UserID, integration, answer.Text, "")
+		}
+		return ephemeral, nil
+	}
+	return &message.Message{
+		RoomID:      roomId,
+		SenderID:    b.UserID,
+		Body:        answer.Text,
+		Format:      answer.Format,
+		ClientMsgID: cmd.Msg.ClientMsgID,
+		Integration: integration,
+	}, nil
+}
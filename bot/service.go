@@ -0,0 +1,363 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/audit"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/room"
+	"messages-go/user"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Limits on the bots users register.
+const (
+	maxCommands   = 10
+	maxURLSize    = 2048
+	minSecretSize = 16
+	maxSecretSize = 256
+)
+
+// BotService defines the interface for users to register bots and for room owners to install them.
+type BotService interface {
+	RegisterBot(ctx context.Context, callerId string, req request.RegisterBotRequest) (*RegisteredBot, error)
+	ListBots(ctx context.Context, callerId string) ([]Bot, error)
+	DeleteBot(ctx context.Context, id string, callerId string) error
+	InstallBot(ctx context.Context, roomId string, callerId string, req request.InstallBotRequest) (*Installation, error)
+	ListInstallations(ctx context.Context, roomId string, callerId string) ([]Installation, error)
+	UninstallBot(ctx context.Context, roomId string, botId string, callerId string) error
+	Authenticate(ctx context.Context, token string) (*Bot, error)
+}
+
+// BotServiceImpl registers bots together with their bot accounts and installs them in rooms with the scopes
+// granted by the rooms' owners.
+type BotServiceImpl struct {
+	botRepo  BotRepo
+	roomRepo room.RoomRepo
+	userRepo user.UserRepo
+	sockets  *Sockets
+	// auditor records bots being installed in and removed from rooms in the audit log
+	auditor audit.Recorder
+	now     func() time.Time
+}
+
+// NewBotService initializes and returns a new instance of BotServiceImpl.
+func NewBotService(botRepo BotRepo, roomRepo room.RoomRepo, userRepo user.UserRepo, sockets *Sockets, auditor audit.Recorder) *BotServiceImpl {
+	return &BotServiceImpl{
+		botRepo:  botRepo,
+		roomRepo: roomRepo,
+		userRepo: userRepo,
+		sockets:  sockets,
+		auditor:  auditor,
+		now:      time.Now,
+	}
+}
+
+// RegisterBot creates a bot account under the requested username and registers the caller's bot for it. The
+// token authenticating a WebSocket bot is returned once and not shown again.
+func (bs *BotServiceImpl) RegisterBot(ctx context.Context, callerId string, req request.RegisterBotRequest) (*RegisteredBot, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	username := strings.ToLower(strings.TrimSpace(req.Username))
+	if !user.UsernamePattern.MatchString(username) || user.ReservedUsernames[username] {
+		return nil, errormodel.ErrInvalidUsername
+	}
+	commands, ok := validCommands(req.Commands)
+	if !ok {
+		return nil, errormodel.ErrInvalidBot
+	}
+	b := &Bot{
+		Username:  username,
+		OwnerID:   callerId,
+		Commands:  commands,
+		Transport: req.Transport,
+		CreatedAt: bs.now().UTC(),
+	}
+	var token string
+	switch req.Transport {
+	case TransportHTTP:
+		if !validURL(req.URL) || len(req.Secret) < minSecretSize || len(req.Secret) > maxSecretSize {
+			return nil, errormodel.ErrInvalidBot
+		}
+		b.URL, b.Secret = req.URL, req.Secret
+	case TransportWebSocket:
+		var err error
+		if token, b.TokenHash, err = newToken(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errormodel.ErrInvalidBot
+	}
+
+	account, err := bs.userRepo.CreateUser(ctx, &user.User{
+		Username:    username,
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		Bot:         true,
+		CreatedAt:   b.CreatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.UserID = account.ID.Hex()
+	registered, err := bs.botRepo.CreateBot(ctx, b)
+	if err != nil {
+		log.Printf("Failed to register bot for account %s: %v", b.UserID, err)
+		return nil, err
+	}
+	bs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionBotRegistered,
+		ActorID:    callerId,
+		TargetType: audit.TargetBot,
+		TargetID:   registered.ID.Hex(),
+		After:      botValues(registered),
+	})
+	return &RegisteredBot{Bot: registered, Token: token}, nil
+}
+
+// validCommands checks the commands of a bot, returning them lower-cased and without duplicates. Built-in
+// commands cannot be taken over.
+func validCommands(names []string) ([]string, bool) {
+	if len(names) == 0 || len(names) > maxCommands {
+		return nil, false
+	}
+	commands := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "/"))
+		if !commandPattern.MatchString(name) || IsBuiltin(name) {
+			return nil, false
+		}
+		if !slices.Contains(commands, name) {
+			commands = append(commands, name)
+		}
+	}
+	return commands, true
+}
+
+func validURL(rawURL string) bool {
+	if len(rawURL) > maxURLSize {
+		return false
+	}
+	parsed, err := url.Parse(rawURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" && parsed.User == nil
+}
+
+// ListBots returns the bots registered by the caller.
+func (bs *BotServiceImpl) ListBots(ctx context.Context, callerId string) ([]Bot, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	return bs.botRepo.GetBotsByOwner(ctx, callerId)
+}
+
+// DeleteBot removes one of the caller's bots from every room and disconnects it. The bot account is kept, so
+// the bot's earlier replies still name their sender.
+func (bs *BotServiceImpl) DeleteBot(ctx context.Context, id string, callerId string) error {
+	b, err := bs.ownedBot(ctx, id, callerId)
+	if err != nil {
+		return err
+	}
+	if err := bs.botRepo.DeleteInstallationsOfBot(ctx, b.ID); err != nil {
+		return err
+	}
+	if err := bs.botRepo.DeleteBot(ctx, b.ID); errors.Is(err, mongo.ErrNoDocuments) {
+		return errormodel.ErrBotNotFound
+	} else if err != nil {
+		return err
+	}
+	bs.sockets.Disconnect(b.ID.Hex())
+	bs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionBotDeleted,
+		ActorID:    callerId,
+		TargetType: audit.TargetBot,
+		TargetID:   b.ID.Hex(),
+		Before:     botValues(b),
+	})
+	return nil
+}
+
+// botValues returns the settings of a bot recorded in the audit log. Its credentials are left out.
+func botValues(b *Bot) map[string]interface{} {
+	values := map[string]interface{}{
+		"username":  b.Username,
+		"user_id":   b.UserID,
+		"transport": b.Transport,
+		"commands":  b.Commands,
+	}
+	if b.URL != "" {
+		values["url"] = b.URL
+	}
+	return values
+}
+
+// ownedBot returns one of the caller's bots. Other users' bots are reported as not found.
+func (bs *BotServiceImpl) ownedBot(ctx context.Context, id string, callerId string) (*Bot, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrBotNotFound
+	}
+	b, err := bs.botRepo.GetBot(ctx, oid)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && b.OwnerID != callerId) {
+		return nil, errormodel.ErrBotNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// InstallBot installs a bot in a room with the requested scopes, letting it handle its commands there. Only the
+// room's owner may install bots, and only bots whose commands no other bot in the room handles.
+func (bs *BotServiceImpl) InstallBot(ctx context.Context, roomId string, callerId string, req request.InstallBotRequest) (*Installation, error) {
+	if _, err := bs.ownedRoom(ctx, roomId, callerId); err != nil {
+		return nil, err
+	}
+	scopes := []string{ScopeCommands}
+	if req.Scopes != nil {
+		scopes = make([]string, 0, len(req.Scopes))
+		for _, scope := range req.Scopes {
+			if !slices.Contains(Scopes, scope) {
+				return nil, errormodel.ErrInvalidBot
+			}
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	oid, err := primitive.ObjectIDFromHex(req.BotID)
+	if err != nil {
+		return nil, errormodel.ErrBotNotFound
+	}
+	b, err := bs.botRepo.GetBot(ctx, oid)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrBotNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	for _, command := range b.Commands {
+		_, err := bs.botRepo.GetInstallationByCommand(ctx, roomId, command)
+		if err == nil {
+			return nil, errormodel.ErrCommandTaken
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+	}
+
+	inst, err := bs.botRepo.CreateInstallation(ctx, &Installation{
+		BotID:       b.ID,
+		RoomID:      roomId,
+		Commands:    b.Commands,
+		Scopes:      scopes,
+		InstalledBy: callerId,
+		InstalledAt: bs.now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	bs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionBotInstalled,
+		ActorID:    callerId,
+		TargetType: audit.TargetBot,
+		TargetID:   b.ID.Hex(),
+		RoomID:     roomId,
+		After:      map[string]interface{}{"commands": inst.Commands, "scopes": inst.Scopes},
+	})
+	return inst, nil
+}
+
+// ListInstallations returns the bots installed in a room to its owner.
+func (bs *BotServiceImpl) ListInstallations(ctx context.Context, roomId string, callerId string) ([]Installation, error) {
+	if _, err := bs.ownedRoom(ctx, roomId, callerId); err != nil {
+		return nil, err
+	}
+	return bs.botRepo.GetInstallations(ctx, roomId)
+}
+
+// UninstallBot removes a bot from a room.
+func (bs *BotServiceImpl) UninstallBot(ctx context.Context, roomId string, botId string, callerId string) error {
+	if _, err := bs.ownedRoom(ctx, roomId, callerId); err != nil {
+		return err
+	}
+	oid, err := primitive.ObjectIDFromHex(botId)
+	if err != nil {
+		return errormodel.ErrBotNotFound
+	}
+	inst, err := bs.botRepo.DeleteInstallation(ctx, roomId, oid)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errormodel.ErrBotNotFound
+	} else if err != nil {
+		return err
+	}
+	bs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionBotUninstalled,
+		ActorID:    callerId,
+		TargetType: audit.TargetBot,
+		TargetID:   botId,
+		RoomID:     roomId,
+		Before:     map[string]interface{}{"commands": inst.Commands, "scopes": inst.Scopes},
+	})
+	return nil
+}
+
+// Authenticate returns the WebSocket bot of a token, or ErrBotNotFound when the token is unknown.
+func (bs *BotServiceImpl) Authenticate(ctx context.Context, token string) (*Bot, error) {
+	if token == "" {
+		return nil, errormodel.ErrBotNotFound
+	}
+	b, err := bs.botRepo.GetBotByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrBotNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ownedRoom returns the room when the caller owns it. Rooms the caller cannot see are reported as not found.
+func (bs *BotServiceImpl) ownedRoom(ctx context.Context, roomId string, callerId string) (*room.Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	roomData, err := bs.roomRepo.GetRoomByID(ctx, roomId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !roomData.VisibleTo(callerId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	if roomData.OwnerID != callerId {
+		return nil, errormodel.ErrForbidden
+	}
+	return roomData, nil
+}
+
+// newToken returns a random token for a WebSocket bot and the hash it is stored under.
+func newToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the hash a bot token is stored and looked up under.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
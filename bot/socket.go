@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// LocalsBotID is the fiber.Ctx locals key of the bot authenticated for a WebSocket connection.
+const LocalsBotID = "bot_id"
+
+// ErrBotNotConnected is returned when a WebSocket bot has no connection to receive an invocation.
+var ErrBotNotConnected = errors.New("bot is not connected")
+
+// maxBotFrameSize bounds the frames read from bots.
+const maxBotFrameSize = 64 << 10
+
+// Frames exchanged with WebSocket bots.
+const (
+	frameTypeInvocation = "invocation"
+	frameTypeReply      = "reply"
+)
+
+type socketFrame struct {
+	Type         string      `json:"type"`
+	Invocation   *Invocation `json:"invocation,omitempty"`
+	InvocationID string      `json:"invocation_id,omitempty"`
+	Reply        *Reply      `json:"reply,omitempty"`
+}
+
+// Sockets holds the connections of WebSocket bots, one per bot, and matches the replies read from them to the
+// invocations waiting for them.
+type Sockets struct {
+	mu    sync.Mutex
+	conns map[string]*botConn
+}
+
+type botConn struct {
+	conn *websocket.Conn
+	// writeMu serializes writes to conn
+	writeMu sync.Mutex
+	// pending holds the invocations waiting for a reply by ID, guarded by Sockets.mu
+	pending map[string]chan *Reply
+	// closed is closed when the connection ends, failing the invocations still waiting
+	closed chan struct{}
+}
+
+// NewSockets initializes and returns an empty Sockets.
+func NewSockets() *Sockets {
+	return &Sockets{conns: make(map[string]*botConn)}
+}
+
+// HandleConnection serves the connection of the bot authenticated before the upgrade. A newer connection of the
+// same bot replaces the older one.
+func (s *Sockets) HandleConnection(c *websocket.Conn) {
+	botID, _ := c.Locals(LocalsBotID).(string)
+	if botID == "" {
+		log.Println("Bot ID not provided")
+		c.Close()
+		return
+	}
+
+	bc := &botConn{conn: c, pending: make(map[string]chan *Reply), closed: make(chan struct{})}
+	s.mu.Lock()
+	previous := s.conns[botID]
+	s.conns[botID] = bc
+	s.mu.Unlock()
+	if previous != nil {
+		previous.conn.Close()
+	}
+	log.Printf("Bot %s connected", botID)
+
+	defer func() {
+		s.mu.Lock()
+		if s.conns[botID] == bc {
+			delete(s.conns, botID)
+		}
+		close(bc.closed)
+		s.mu.Unlock()
+		c.Close()
+		log.Printf("Bot %s disconnected", botID)
+	}()
+
+	c.SetReadLimit(maxBotFrameSize)
+	for {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("Bot WebSocket error: %v", err)
+			}
+			return
+		}
+		var frame socketFrame
+		if err := json.Unmarshal(data, &frame); err != nil || frame.Type != frameTypeReply {
+			continue
+		}
+		s.mu.Lock()
+		waiting := bc.pending[frame.InvocationID]
+		delete(bc.pending, frame.InvocationID)
+		s.mu.Unlock()
+		if waiting != nil {
+			waiting <- frame.Reply
+		}
+	}
+}
+
+// Invoke sends an invocation to a connected bot and waits for its reply until ctx is done.
+func (s *Sockets) Invoke(ctx context.Context, b *Bot, inv *Invocation) (*Reply, error) {
+	waiting := make(chan *Reply, 1)
+	s.mu.Lock()
+	bc := s.conns[b.ID.Hex()]
+	if bc != nil {
+		bc.pending[inv.ID] = waiting
+	}
+	s.mu.Unlock()
+	if bc == nil {
+		return nil, ErrBotNotConnected
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(bc.pending, inv.ID)
+		s.mu.Unlock()
+	}()
+
+	bc.writeMu.Lock()
+	err := bc.conn.WriteJSON(socketFrame{Type: frameTypeInvocation, Invocation: inv})
+	bc.writeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case r := <-waiting:
+		return r, nil
+	case <-bc.closed:
+		return nil, ErrBotNotConnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Disconnect closes the connection of a bot, if it has one.
+func (s *Sockets) Disconnect(botID string) {
+	s.mu.Lock()
+	bc := s.conns[botID]
+	s.mu.Unlock()
+	if bc != nil {
+		bc.conn.Close()
+	}
+}
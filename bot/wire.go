@@ -0,0 +1,30 @@
+package bot
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	"messages-go/message"
+	"messages-go/room"
+	"messages-go/user"
+	"messages-go/utils"
+	"time"
+)
+
+// InitBotHandler wires the bot handler and installs a Router in front of the message service, so that commands
+// in posted messages reach the built-in commands and the bots installed in their rooms. The returned Sockets
+// serves the connections of WebSocket bots.
+func InitBotHandler(client *mongo.Client, roomRepo room.RoomRepo, roomService room.RoomService, userRepo user.UserRepo, messageService message.MessageService, auditor audit.Recorder) (BotHandler, *Sockets, BotService) {
+	timeout := utils.GetEnvDuration("BOT_TIMEOUT", 5*time.Second)
+	httpInvoker := NewHTTPInvoker(
+		timeout,
+		utils.GetEnv("BOT_USER_AGENT", "messages-go-bots/1.0"),
+		utils.GetEnv("BOT_ALLOW_PRIVATE_NETWORKS", "false") == "true",
+	)
+	sockets := NewSockets()
+
+	repo := NewBotRepository(client)
+	messageService.SetCommandRouter(NewRouter(repo, roomService, httpInvoker, sockets, timeout))
+	service := NewBotService(repo, roomRepo, userRepo, sockets, auditor)
+	handler := NewBotHandler(service)
+	return handler, sockets, service
+}
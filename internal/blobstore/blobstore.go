@@ -0,0 +1,39 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"messages-go/utils"
+	"os"
+)
+
+// ErrNotFound is returned when a blob does not exist in the store.
+var ErrNotFound = errors.New("blob not found")
+
+// Store defines the interface for storing opaque binary objects under string keys.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFromEnv builds the Store selected by the BLOB_STORE environment variable: "fs" (the default) stores blobs
+// under BLOB_DIR, "s3" stores them in an S3-compatible bucket configured through the S3_* variables.
+func NewFromEnv() (Store, error) {
+	switch kind := utils.GetEnv("BLOB_STORE", "fs"); kind {
+	case "fs":
+		return NewFileStore(utils.GetEnv("BLOB_DIR", "data/blobs"))
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          utils.GetEnv("S3_REGION", "us-east-1"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", kind)
+	}
+}
@@ -0,0 +1,123 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestFileStore(t *testing.T) (*FileStore, string) {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := NewFileStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	return s, dir
+}
+
+func TestFileStorePutGetDelete(t *testing.T) {
+	s, _ := newTestFileStore(t)
+	ctx := context.Background()
+	key := "attachments/r1/65f0c0ffee"
+
+	for _, content := range []string{"first version", "second"} {
+		if err := s.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		r, err := s.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(got) != content {
+			t.Errorf("Get = %q, %v; want %q", got, err, content)
+		}
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing blob = %v", err)
+	}
+}
+
+func TestFileStoreShortWriteLeavesNothingBehind(t *testing.T) {
+	s, _ := newTestFileStore(t)
+	ctx := context.Background()
+	key := "attachments/r1/short"
+
+	if err := s.Put(ctx, key, bytes.NewReader([]byte("abc")), 10, ""); err == nil {
+		t.Fatal("Put of fewer bytes than announced succeeded")
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after a short write = %v, want ErrNotFound", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(s.root, "attachments", "r1"))
+	for _, entry := range entries {
+		t.Errorf("left %s behind", entry.Name())
+	}
+}
+
+func TestFileStoreRejectsPathTraversal(t *testing.T) {
+	s, dir := newTestFileStore(t)
+	ctx := context.Background()
+	outside := filepath.Join(dir, "outside")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{
+		"",
+		".",
+		"..",
+		"../outside",
+		"../../outside",
+		"attachments/../../outside",
+		"attachments/r1/../../../outside",
+		"/../outside",
+		"./../outside",
+		"../blobs-sibling/x",
+		"attachments/..",
+	} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if r, err := s.Get(ctx, key); err == nil {
+			r.Close()
+			t.Errorf("Get(%q) succeeded", key)
+		} else if errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = ErrNotFound, want the key rejected", key)
+		}
+		if err := s.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+	}
+
+	if content, err := os.ReadFile(outside); err != nil || string(content) != "secret" {
+		t.Errorf("file outside the root = %q, %v; want it untouched", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blobs-sibling")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("directory beside the root was created: %v", err)
+	}
+
+	// Keys that only look like traversal stay below the root.
+	for _, key := range []string{"/attachments/r1/x", "attachments/r1/../r2/x", "attachments/..x"} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, ""); err != nil {
+			t.Errorf("Put(%q) = %v", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.root, "attachments", "r2", "x")); err != nil {
+		t.Errorf("attachments/r1/../r2/x was not stored below the root: %v", err)
+	}
+}
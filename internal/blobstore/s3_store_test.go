@@ -0,0 +1,315 @@
+package blobstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKeyID     = "AKIDEXAMPLE"
+	testSecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion          = "eu-west-1"
+	testBucket          = "blobs"
+)
+
+var authorizationPattern = regexp.MustCompile(
+	`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`)
+
+// signingKey derives the Signature Version 4 signing key of a day, region and service.
+func signingKey(secret string, day string, region string, service string) []byte {
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{day, region, service, "aws4_request"} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	return key
+}
+
+// canonicalURI escapes a decoded path as S3 does before checking a signature.
+func canonicalURI(path string) string {
+	const unreserved = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_.~/"
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		if strings.IndexByte(unreserved, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
+
+// verifySignature checks the Signature Version 4 signature of a request as S3 would, independently of S3Store.
+func verifySignature(r *http.Request, secret string, now time.Time) error {
+	m := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil {
+		return fmt.Errorf("malformed authorization %q", r.Header.Get("Authorization"))
+	}
+	accessKeyID, day, region, signedHeaders, signature := m[1], m[2], m[3], m[4], m[5]
+	if accessKeyID != testAccessKeyID || region != testRegion {
+		return fmt.Errorf("unexpected credential %s in %s", accessKeyID, region)
+	}
+	amzDate, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil || amzDate.Format("20060102") != day {
+		return fmt.Errorf("date %q does not match scope %s", r.Header.Get("X-Amz-Date"), day)
+	}
+	if d := now.Sub(amzDate); d > 15*time.Minute || d < -15*time.Minute {
+		return fmt.Errorf("request time %v too skewed", amzDate)
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return errors.New("missing payload hash")
+	}
+
+	names := strings.Split(signedHeaders, ";")
+	if !sort.StringsAreSorted(names) {
+		return fmt.Errorf("signed headers %s not sorted", signedHeaders)
+	}
+	var headers strings.Builder
+	for _, name := range names {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		if !strings.Contains(";"+signedHeaders+";", ";"+required+";") {
+			return fmt.Errorf("%s not signed", required)
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method, canonicalURI(r.URL.Path), r.URL.Query().Encode(), headers.String(), signedHeaders, payloadHash,
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" + day + "/" + region + "/s3/aws4_request\n" +
+		hex.EncodeToString(hashed[:])
+	mac := hmac.New(sha256.New, signingKey(secret, day, region, "s3"))
+	mac.Write([]byte(stringToSign))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+type s3Object struct {
+	body        []byte
+	contentType string
+}
+
+// fakeS3 is an S3 stand-in serving path-style requests to one bucket, rejecting requests whose signature does not
+// check out with its secret.
+type fakeS3 struct {
+	mu      sync.Mutex
+	secret  string
+	objects map[string]s3Object
+	// requests records the method and key of every accepted request
+	requests []string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{secret: testSecretAccessKey, objects: make(map[string]s3Object)}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := verifySignature(r, f.secret, time.Now().UTC()); err != nil {
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code><Message>"+err.Error()+"</Message></Error>", http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok || key == "" {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+key)
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(body)) != r.ContentLength {
+			http.Error(w, "<Error><Code>IncompleteBody</Code></Error>", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = s3Object{body: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		obj, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.body)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) object(key string) (s3Object, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[key]
+	return obj, ok
+}
+
+func newTestS3Store(t *testing.T, endpoint string, secret string) *S3Store {
+	t.Helper()
+	s, err := NewS3Store(S3Config{
+		Endpoint:        endpoint + "/",
+		Bucket:          testBucket,
+		Region:          testRegion,
+		AccessKeyID:     testAccessKeyID,
+		SecretAccessKey: secret,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	return s
+}
+
+func TestSigningKey(t *testing.T) {
+	// The key derivation example of the Signature Version 4 documentation.
+	got := hex.EncodeToString(signingKey(testSecretAccessKey, "20120215", "us-east-1", "iam"))
+	if want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"; got != want {
+		t.Fatalf("signingKey = %s, want %s", got, want)
+	}
+}
+
+func TestS3StoreSign(t *testing.T) {
+	s := newTestS3Store(t, "https://s3.eu-west-1.amazonaws.com", testSecretAccessKey)
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, key := range []string{"attachments/r1/65f0c0ffee", "dir/a b+c=d&e(1)ü.txt", "/leading/slash"} {
+		req, err := s.newRequest(context.Background(), http.MethodGet, key, nil)
+		if err != nil {
+			t.Fatalf("newRequest(%q): %v", key, err)
+		}
+		s.sign(req, now)
+
+		wantPrefix := "AWS4-HMAC-SHA256 Credential=" + testAccessKeyID + "/20260304/" + testRegion + "/s3/aws4_request, " +
+			"SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+		if auth := req.Header.Get("Authorization"); !strings.HasPrefix(auth, wantPrefix) {
+			t.Errorf("Authorization = %q, want prefix %q", auth, wantPrefix)
+		}
+		if req.Header.Get("X-Amz-Date") != "20260304T050607Z" || req.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+			t.Errorf("signed headers = %v", req.Header)
+		}
+
+		// The server sees the path and host as sent on the wire.
+		received, err := http.ReadRequest(bufioRequest(t, req))
+		if err != nil {
+			t.Fatalf("ReadRequest: %v", err)
+		}
+		if want := "/" + testBucket + "/" + strings.TrimLeft(key, "/"); received.URL.Path != want {
+			t.Errorf("path = %q, want %q", received.URL.Path, want)
+		}
+		if err := verifySignature(received, testSecretAccessKey, now); err != nil {
+			t.Errorf("signature of %q: %v", key, err)
+		}
+		if err := verifySignature(received, "another-secret", now); err == nil {
+			t.Errorf("signature of %q verified with the wrong secret", key)
+		}
+		if err := verifySignature(received, testSecretAccessKey, now.Add(time.Hour)); err == nil {
+			t.Errorf("signature of %q verified an hour later", key)
+		}
+		received.Method = http.MethodDelete
+		if err := verifySignature(received, testSecretAccessKey, now); err == nil {
+			t.Errorf("signature of %q verified another method", key)
+		}
+	}
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	fake, server := newFakeS3(t)
+	s := newTestS3Store(t, server.URL, testSecretAccessKey)
+	ctx := context.Background()
+
+	for _, key := range []string{"attachments/r1/65f0c0ffee", "thumbnails/r1/a b+c=d&ü-320"} {
+		content := []byte("content of " + key)
+		if err := s.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "image/png"); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+		if obj, ok := fake.object(key); !ok || !bytes.Equal(obj.body, content) || obj.contentType != "image/png" {
+			t.Fatalf("stored %q = %+v, %v", key, obj, ok)
+		}
+
+		r, err := s.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("Get(%q) = %q, %v", key, got, err)
+		}
+
+		if err := s.Delete(ctx, key); err != nil {
+			t.Fatalf("Delete(%q): %v", key, err)
+		}
+		if _, ok := fake.object(key); ok {
+			t.Errorf("%q still stored after Delete", key)
+		}
+		if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) after Delete = %v, want ErrNotFound", key, err)
+		}
+		if err := s.Delete(ctx, key); err != nil {
+			t.Errorf("Delete(%q) of a missing blob = %v", key, err)
+		}
+	}
+}
+
+func TestS3StoreReportsErrors(t *testing.T) {
+	fake, server := newFakeS3(t)
+	s := newTestS3Store(t, server.URL, "not-the-secret")
+	ctx := context.Background()
+
+	err := s.Put(ctx, "attachments/r1/x", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put with the wrong secret = %v, want the 403 reported", err)
+	}
+	if _, err := s.Get(ctx, "attachments/r1/x"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get with the wrong secret = %v, want an error other than ErrNotFound", err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.requests) != 0 {
+		t.Errorf("requests with the wrong secret were accepted: %v", fake.requests)
+	}
+
+	if _, err := NewS3Store(S3Config{Endpoint: server.URL}); err == nil {
+		t.Error("NewS3Store without a bucket succeeded")
+	}
+}
+
+// bufioRequest writes a request as it would be sent and returns a reader of the wire format.
+func bufioRequest(t *testing.T, req *http.Request) *bufio.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return bufio.NewReader(&buf)
+}
@@ -7,6 +7,8 @@ import (
 	"log"
 	"messages-go/internal/databases/mongo/messager"
 	"messages-go/routes"
+	"messages-go/utils"
+	ws "messages-go/websocket"
 	"os"
 	"os/signal"
 	"syscall"
@@ -18,16 +20,22 @@ func main() {
 	// Initialize database
 	var db = messager.ConnectDB()
 
-	// Create a new Fiber instance
-	app := fiber.New()
+	// Create a new Fiber instance; the body limit must leave room for attachment uploads
+	app := fiber.New(fiber.Config{
+		BodyLimit: utils.GetEnvInt("HTTP_BODY_LIMIT", 16<<20),
+	})
 
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowMethods: "GET,POST,OPTIONS",
-		AllowHeaders: "Content-Type",
+		AllowMethods: "GET,POST,PATCH,DELETE,OPTIONS",
+		AllowHeaders: "Content-Type, Last-Event-ID, Authorization",
 	}))
+	// Context for background workers, cancelled on shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
 	// Set up routes
-	routes.SetupRoutes(app, db)
+	routes.SetupRoutes(workerCtx, app, db)
 
 	// Create a channel to listen for termination signals
 	quit := make(chan os.Signal, 1)
@@ -48,6 +56,10 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Stop background workers and the hub so open event streams are released before the server drains connections
+	stopWorkers()
+	ws.GlobalHub.Stop()
+
 	// Gracefully shutdown the Fiber app
 	if err := app.ShutdownWithContext(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
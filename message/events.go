@@ -0,0 +1,98 @@
+package message
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"messages-go/models/errormodel"
+	"messages-go/ratelimit"
+)
+
+// NewMessageEvent builds the payload broadcast to room subscribers when a message is posted, whether by a request
+// or by a background delivery.
+func NewMessageEvent(msg *Message) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "new_message",
+		"message": msg,
+	}
+}
+
+// NewMessageDeletedEvent builds the payload broadcast to room subscribers when a message is removed.
+func NewMessageDeletedEvent(roomId string, id primitive.ObjectID) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "message_deleted",
+		"room_id":    roomId,
+		"message_id": id.Hex(),
+	}
+}
+
+// NewMessageHiddenEvent builds the payload broadcast to room subscribers when a message is hidden pending review.
+func NewMessageHiddenEvent(roomId string, id primitive.ObjectID) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "message_hidden",
+		"room_id":    roomId,
+		"message_id": id.Hex(),
+	}
+}
+
+// NewMessageRestoredEvent builds the payload broadcast to room subscribers when a hidden message is shown again.
+func NewMessageRestoredEvent(msg *Message) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "message_restored",
+		"message": msg,
+	}
+}
+
+// newMessageExpiredEvent builds the payload broadcast to room subscribers when an ephemeral message expires.
+func newMessageExpiredEvent(roomId string, id primitive.ObjectID) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "message_expired",
+		"room_id":    roomId,
+		"message_id": id.Hex(),
+	}
+}
+
+// newPollUpdatedEvent builds the payload broadcast to room subscribers when the tallies of a poll change.
+func newPollUpdatedEvent(msg *Message) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "poll_updated",
+		"room_id":    msg.RoomID,
+		"message_id": msg.ID.Hex(),
+		"poll":       msg.Poll,
+	}
+}
+
+// newMessageAckEvent builds the reply acknowledging a message posted over a WebSocket connection to its sender,
+// whether newly posted or answered from an earlier post with the same client message ID.
+func newMessageAckEvent(msg *Message) map[string]interface{} {
+	return map[string]interface{}{
+		"type":          "message_ack",
+		"client_msg_id": msg.ClientMsgID,
+		"replayed":      msg.Replayed(),
+		"message":       msg,
+	}
+}
+
+// newSocketErrorEvent builds the reply telling a WebSocket client why the frame it sent was rejected, and how
+// many seconds to wait before retrying when waiting helps.
+func newSocketErrorEvent(clientMsgId string, err error) map[string]interface{} {
+	event := map[string]interface{}{
+		"type":          "error",
+		"client_msg_id": clientMsgId,
+		"error":         err.Error(),
+	}
+	var retryErr *errormodel.RetryError
+	if errors.As(err, &retryErr) {
+		event["retry_after"] = ratelimit.RetryAfterSeconds(retryErr.RetryAfter)
+	}
+	return event
+}
+
+// newReplayEvent builds the reply carrying the messages of a room after a sequence number to a WebSocket client.
+func newReplayEvent(roomId string, afterSeq int64, messages []Message) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "replay",
+		"room_id":   roomId,
+		"after_seq": afterSeq,
+		"messages":  messages,
+	}
+}
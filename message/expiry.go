@@ -0,0 +1,62 @@
+package message
+
+import (
+	"context"
+	"log"
+	ws "messages-go/websocket"
+	"time"
+)
+
+// expiryBatchSize bounds the messages removed by one pass of the expiry sweeper.
+const expiryBatchSize = 500
+
+// ExpirySweeper periodically deletes expired messages and announces each expiry to its room with a
+// message_expired event. Reads already hide expired messages, so the sweeper only has to catch up eventually.
+type ExpirySweeper struct {
+	messageService *MessageServiceImpl
+	wsHandler      *ws.Handler
+	interval       time.Duration
+}
+
+// NewExpirySweeper initializes and returns a new ExpirySweeper running every interval once started.
+func NewExpirySweeper(messageService *MessageServiceImpl, wsHandler *ws.Handler, interval time.Duration) *ExpirySweeper {
+	return &ExpirySweeper{messageService: messageService, wsHandler: wsHandler, interval: interval}
+}
+
+// Start sweeps expired messages every interval until ctx is cancelled.
+func (s *ExpirySweeper) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.Sweep(ctx)
+			}
+		}
+	}()
+}
+
+// Sweep deletes every message that has expired by now, in batches, and returns how many were deleted.
+func (s *ExpirySweeper) Sweep(ctx context.Context) int {
+	removed := 0
+	for ctx.Err() == nil {
+		expired, err := s.messageService.DeleteExpired(ctx, time.Now(), expiryBatchSize)
+		if err != nil {
+			log.Printf("Failed to delete expired messages: %v", err)
+			break
+		}
+		for _, msg := range expired {
+			if s.wsHandler != nil {
+				s.wsHandler.BroadcastToRoom(msg.RoomID, newMessageExpiredEvent(msg.RoomID, msg.ID))
+			}
+		}
+		removed += len(expired)
+		if len(expired) < expiryBatchSize {
+			break
+		}
+	}
+	return removed
+}
@@ -0,0 +1,221 @@
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Profanity masks listed words with asterisks.
+type Profanity struct {
+	pattern *regexp.Regexp
+}
+
+// NewProfanity returns a filter masking the given words, matched case-insensitively as whole words. Words are
+// delimited by any character other than a letter, digit or underscore, so that words with accents or symbols at
+// their edges are matched too, which the ASCII \b of regexp would miss.
+func NewProfanity(words []string) *Profanity {
+	var quoted []string
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return &Profanity{}
+	}
+	return &Profanity{pattern: regexp.MustCompile(`(?i)(?:` + strings.Join(quoted, "|") + `)`)}
+}
+
+func (f *Profanity) Name() string { return NameProfanity }
+
+func (f *Profanity) Apply(body string) Result {
+	if f.pattern == nil {
+		return Result{Action: Allow}
+	}
+	var masked strings.Builder
+	count, last := 0, 0
+	for _, match := range f.pattern.FindAllStringIndex(body, -1) {
+		if !isWholeWord(body, match[0], match[1]) {
+			continue
+		}
+		count++
+		masked.WriteString(body[last:match[0]])
+		masked.WriteString(strings.Repeat("*", utf8.RuneCountInString(body[match[0]:match[1]])))
+		last = match[1]
+	}
+	if count == 0 {
+		return Result{Action: Allow}
+	}
+	masked.WriteString(body[last:])
+	return Result{Action: Modify, Body: masked.String(), Reason: fmt.Sprintf("masked %d words", count)}
+}
+
+// isWholeWord reports whether body[start:end] is not preceded or followed by a word character.
+func isWholeWord(body string, start int, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(body[:start])
+	after, _ := utf8.DecodeRuneInString(body[end:])
+	return !isWordRune(before) && !isWordRune(after)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// MaxLength rejects bodies longer than a number of characters.
+type MaxLength struct {
+	max int
+}
+
+// NewMaxLength returns a filter rejecting bodies of more than max characters; it allows everything when max is
+// not positive.
+func NewMaxLength(max int) *MaxLength {
+	return &MaxLength{max: max}
+}
+
+func (f *MaxLength) Name() string { return NameMaxLength }
+
+func (f *MaxLength) Apply(body string) Result {
+	if f.max <= 0 {
+		return Result{Action: Allow}
+	}
+	if n := utf8.RuneCountInString(body); n > f.max {
+		return Result{Action: Reject, Reason: fmt.Sprintf("message is %d characters long, the limit is %d", n, f.max)}
+	}
+	return Result{Action: Allow}
+}
+
+// linkPattern finds the http and https URLs in a message body.
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'()\[\]]+`)
+
+// Links rejects bodies linking to blocked hosts and flags those linking outside the allowed hosts.
+type Links struct {
+	blocked []string
+	allowed []string
+}
+
+// NewLinks returns a filter for the given host lists. Hosts match themselves and their subdomains.
+func NewLinks(blocked []string, allowed []string) *Links {
+	return &Links{blocked: normalizeHosts(blocked), allowed: normalizeHosts(allowed)}
+}
+
+func (f *Links) Name() string { return NameLinks }
+
+func (f *Links) Apply(body string) Result {
+	var unlisted []string
+	for _, match := range linkPattern.FindAllString(body, -1) {
+		parsed, err := url.Parse(strings.TrimRight(match, ".,:;!?"))
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		host := strings.ToLower(parsed.Hostname())
+		if matchesHost(host, f.blocked) {
+			return Result{Action: Reject, Reason: "links to blocked host " + host}
+		}
+		if len(f.allowed) > 0 && !matchesHost(host, f.allowed) {
+			unlisted = append(unlisted, host)
+		}
+	}
+	if len(unlisted) > 0 {
+		return Result{Action: Flag, Reason: "links to unlisted hosts " + strings.Join(unlisted, ", ")}
+	}
+	return Result{Action: Allow}
+}
+
+func normalizeHosts(hosts []string) []string {
+	var normalized []string
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.Trim(strings.TrimSpace(h), ".")); h != "" {
+			normalized = append(normalized, h)
+		}
+	}
+	return normalized
+}
+
+func matchesHost(host string, hosts []string) bool {
+	for _, h := range hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted replaces the secrets removed from bodies.
+const redacted = "[REDACTED]"
+
+// secretPatterns match credentials with a recognizable shape, most specific first.
+var secretPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+	{"aws access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{"stripe key", regexp.MustCompile(`\b[sr]k_(?:live|test)_[A-Za-z0-9]{16,}\b`)},
+	{"bearer token", regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`)},
+	{"generic api key", regexp.MustCompile(`(?i)\b(?:api[_-]?key|secret|password|token)\s*[:=]\s*['"]?[A-Za-z0-9._~+/-]{12,}['"]?`)},
+}
+
+// cardPattern matches candidate payment card numbers, which are redacted when they pass the Luhn check.
+var cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+// Secrets redacts credentials and payment card numbers.
+type Secrets struct{}
+
+// NewSecrets returns a filter redacting secrets.
+func NewSecrets() *Secrets {
+	return &Secrets{}
+}
+
+func (f *Secrets) Name() string { return NameSecrets }
+
+func (f *Secrets) Apply(body string) Result {
+	var found []string
+	for _, secret := range secretPatterns {
+		if secret.pattern.MatchString(body) {
+			body = secret.pattern.ReplaceAllString(body, redacted)
+			found = append(found, secret.kind)
+		}
+	}
+	cards := 0
+	body = cardPattern.ReplaceAllStringFunc(body, func(candidate string) string {
+		if !luhnValid(candidate) {
+			return candidate
+		}
+		cards++
+		return redacted
+	})
+	if cards > 0 {
+		found = append(found, "card number")
+	}
+	if len(found) == 0 {
+		return Result{Action: Allow}
+	}
+	return Result{Action: Modify, Body: body, Reason: "redacted " + strings.Join(found, ", ")}
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum used by payment card numbers.
+func luhnValid(s string) bool {
+	sum, double, digits := 0, false, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
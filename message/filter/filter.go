@@ -0,0 +1,158 @@
+// Package filter runs the bodies of messages about to be posted through a chain of content filters.
+//
+// Each filter allows a body, rewrites it, rejects it or flags it for moderators. Filters run in order, each on
+// the body left by the ones before it, and a rejection stops the chain. Every decision other than allow is
+// returned so that it can be recorded on the message.
+package filter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Action is the outcome of a filter for a message body.
+type Action string
+
+const (
+	Allow  Action = "allow"
+	Modify Action = "modify"
+	Reject Action = "reject"
+	Flag   Action = "flag"
+)
+
+// Filter inspects, and may rewrite, the body of a message about to be posted.
+type Filter interface {
+	Name() string
+	Apply(body string) Result
+}
+
+// Result is what a filter decided about a body. Body is the rewritten body of a Modify result.
+type Result struct {
+	Action Action
+	Body   string
+	Reason string
+}
+
+// Decision records the result of a filter on a message, for audit.
+type Decision struct {
+	Filter string `bson:"filter" json:"filter"`
+	Action Action `bson:"action" json:"action"`
+	Reason string `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// Outcome is the result of running a body through a chain.
+type Outcome struct {
+	Body      string
+	Decisions []Decision
+	// Rejected is the decision that stopped the chain, if any
+	Rejected *Decision
+	Flagged  bool
+}
+
+// Chain is an ordered list of filters.
+type Chain []Filter
+
+// Run passes a body through the filters of the chain in order, stopping at the first rejection.
+func (c Chain) Run(body string) Outcome {
+	outcome := Outcome{Body: body}
+	for _, f := range c {
+		result := f.Apply(outcome.Body)
+		if result.Action == Allow || result.Action == "" {
+			continue
+		}
+		decision := Decision{Filter: f.Name(), Action: result.Action, Reason: result.Reason}
+		outcome.Decisions = append(outcome.Decisions, decision)
+		switch result.Action {
+		case Modify:
+			outcome.Body = result.Body
+		case Flag:
+			outcome.Flagged = true
+		case Reject:
+			outcome.Rejected = &decision
+			return outcome
+		}
+	}
+	return outcome
+}
+
+// Names of the built-in filters.
+const (
+	NameProfanity = "profanity"
+	NameMaxLength = "max_length"
+	NameLinks     = "links"
+	NameSecrets   = "secrets"
+)
+
+// Config holds the server-wide parameters of the built-in filters.
+type Config struct {
+	// Words are masked by the profanity filter, case-insensitively and as whole words
+	Words     []string
+	MaxLength int
+	// BlockedHosts are hosts, including their subdomains, that links may not point to. Links to hosts outside a
+	// non-empty AllowedHosts are flagged.
+	BlockedHosts []string
+	AllowedHosts []string
+	// Defaults names the filters of rooms that do not choose their own
+	Defaults []string
+}
+
+// Registry builds the chains of rooms from the built-in filters.
+type Registry struct {
+	cfg     Config
+	secrets Filter
+	links   Filter
+}
+
+// NewRegistry initializes and returns a Registry building filters with the given configuration. Unknown default
+// filters are dropped, so that a typo in the configuration cannot stop every post.
+func NewRegistry(cfg Config) *Registry {
+	defaults := make([]string, 0, len(cfg.Defaults))
+	for _, name := range cfg.Defaults {
+		if Known(name) {
+			defaults = append(defaults, name)
+		} else {
+			log.Printf("Ignoring unknown default message filter %q", name)
+		}
+	}
+	cfg.Defaults = defaults
+	return &Registry{
+		cfg:     cfg,
+		secrets: NewSecrets(),
+		links:   NewLinks(cfg.BlockedHosts, cfg.AllowedHosts),
+	}
+}
+
+// Known reports whether name is the name of a built-in filter.
+func Known(name string) bool {
+	switch name {
+	case NameProfanity, NameMaxLength, NameLinks, NameSecrets:
+		return true
+	}
+	return false
+}
+
+// Chain returns the chain of a room running the named filters in order, or the default filters when names is
+// empty. The room's extra words are masked by its profanity filter along with the server-wide ones.
+func (r *Registry) Chain(names []string, extraWords []string) (Chain, error) {
+	if len(names) == 0 {
+		names = r.cfg.Defaults
+	}
+	chain := make(Chain, 0, len(names))
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case NameProfanity:
+			words := append(append([]string{}, r.cfg.Words...), extraWords...)
+			chain = append(chain, NewProfanity(words))
+		case NameMaxLength:
+			chain = append(chain, NewMaxLength(r.cfg.MaxLength))
+		case NameLinks:
+			chain = append(chain, r.links)
+		case NameSecrets:
+			chain = append(chain, r.secrets)
+		default:
+			return nil, fmt.Errorf("unknown filter %q", name)
+		}
+	}
+	return chain, nil
+}
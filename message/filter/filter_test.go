@@ -0,0 +1,125 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+// stubFilter returns a fixed result, rewriting bodies by appending its name when it modifies them.
+type stubFilter struct {
+	name   string
+	action Action
+	calls  *[]string
+}
+
+func (f stubFilter) Name() string { return f.name }
+
+func (f stubFilter) Apply(body string) Result {
+	if f.calls != nil {
+		*f.calls = append(*f.calls, f.name+":"+body)
+	}
+	if f.action == Modify {
+		return Result{Action: Modify, Body: body + "+" + f.name, Reason: f.name + " modified"}
+	}
+	return Result{Action: f.action, Reason: f.name + " " + string(f.action)}
+}
+
+func TestChainRun(t *testing.T) {
+	var calls []string
+	chain := Chain{
+		stubFilter{name: "a", action: Allow, calls: &calls},
+		stubFilter{name: "b", action: Modify, calls: &calls},
+		stubFilter{name: "c", action: Flag, calls: &calls},
+		stubFilter{name: "d", action: "", calls: &calls},
+		stubFilter{name: "e", action: Modify, calls: &calls},
+	}
+	got := chain.Run("x")
+	want := Outcome{
+		Body: "x+b+e",
+		Decisions: []Decision{
+			{Filter: "b", Action: Modify, Reason: "b modified"},
+			{Filter: "c", Action: Flag, Reason: "c flag"},
+			{Filter: "e", Action: Modify, Reason: "e modified"},
+		},
+		Flagged: true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Run = %+v, want %+v", got, want)
+	}
+	// Each filter sees the body left by the ones before it.
+	if wantCalls := []string{"a:x", "b:x", "c:x+b", "d:x+b", "e:x+b"}; !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("filters saw %q, want %q", calls, wantCalls)
+	}
+}
+
+func TestChainRunStopsAtRejection(t *testing.T) {
+	var calls []string
+	chain := Chain{
+		stubFilter{name: "a", action: Flag, calls: &calls},
+		stubFilter{name: "b", action: Modify, calls: &calls},
+		stubFilter{name: "c", action: Reject, calls: &calls},
+		stubFilter{name: "d", action: Modify, calls: &calls},
+	}
+	got := chain.Run("x")
+	if got.Rejected == nil || *got.Rejected != (Decision{Filter: "c", Action: Reject, Reason: "c reject"}) {
+		t.Fatalf("Rejected = %+v, want the decision of c", got.Rejected)
+	}
+	if len(got.Decisions) != 3 || got.Decisions[2] != *got.Rejected || !got.Flagged || got.Body != "x+b" {
+		t.Errorf("Run = %+v, want the decisions up to the rejection", got)
+	}
+	if len(calls) != 3 {
+		t.Errorf("filters after a rejection ran: %q", calls)
+	}
+}
+
+func TestChainRunAllowsByDefault(t *testing.T) {
+	for _, chain := range []Chain{nil, {stubFilter{name: "a", action: Allow}}} {
+		if got := chain.Run("x"); !reflect.DeepEqual(got, Outcome{Body: "x"}) {
+			t.Errorf("Run = %+v, want the body unchanged and nothing recorded", got)
+		}
+	}
+}
+
+func TestRegistryChain(t *testing.T) {
+	r := NewRegistry(Config{
+		Words:     []string{"darn"},
+		MaxLength: 10,
+		Defaults:  []string{NameSecrets, "typo", NameMaxLength},
+	})
+
+	defaults, err := r.Chain(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := chainNames(defaults); !reflect.DeepEqual(names, []string{NameSecrets, NameMaxLength}) {
+		t.Errorf("default chain = %q, want the known defaults in order", names)
+	}
+
+	chain, err := r.Chain([]string{" profanity ", NameLinks}, []string{"heck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := chainNames(chain); !reflect.DeepEqual(names, []string{NameProfanity, NameLinks}) {
+		t.Errorf("room chain = %q", names)
+	}
+	if got := chain.Run("darn heck").Body; got != "**** ****" {
+		t.Errorf("room chain masked %q, want the server and room words", got)
+	}
+	// Room words stay in their room.
+	other, _ := r.Chain([]string{NameProfanity}, nil)
+	if got := other.Run("darn heck").Body; got != "**** heck" {
+		t.Errorf("other room chain masked %q, want only the server words", got)
+	}
+
+	if _, err := r.Chain([]string{NameProfanity, "typo"}, nil); err == nil {
+		t.Error("Chain accepted an unknown filter")
+	}
+}
+
+func chainNames(chain Chain) []string {
+	names := make([]string, 0, len(chain))
+	for _, f := range chain {
+		names = append(names, f.Name())
+	}
+	return names
+}
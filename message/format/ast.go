@@ -0,0 +1,43 @@
+package format
+
+// NodeType identifies the kind of an AST node.
+type NodeType int
+
+const (
+	DocumentNode NodeType = iota
+	ParagraphNode
+	HeadingNode
+	CodeBlockNode
+	BlockquoteNode
+	ListNode
+	ListItemNode
+	ThematicBreakNode
+	TextNode
+	EmphasisNode
+	StrongNode
+	StrikethroughNode
+	CodeSpanNode
+	LinkNode
+	LineBreakNode
+)
+
+// Node is an element of a parsed Markdown document. Only the fields relevant to its Type are set.
+type Node struct {
+	Type     NodeType
+	Children []*Node
+
+	// Literal is the text of Text, CodeSpan and CodeBlock nodes.
+	Literal string
+	// Level is the level of a Heading, from 1 to 6.
+	Level int
+	// Ordered and Start describe a List; Start is the number of the first item of an ordered list.
+	Ordered bool
+	Start   int
+	// Href is the sanitized target of a Link.
+	Href string
+	// Lang is the sanitized language of a fenced CodeBlock.
+	Lang string
+}
+
+// maxDepth bounds the nesting of block containers and inline spans, so that hostile input cannot blow the stack.
+const maxDepth = 16
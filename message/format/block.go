@@ -0,0 +1,192 @@
+package format
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingPattern   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	hrPattern        = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fencePattern     = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`]*)$")
+	bulletPattern    = regexp.MustCompile(`^ {0,3}([-*+])([ \t]+|$)`)
+	orderedPattern   = regexp.MustCompile(`^ {0,3}(\d{1,9})([.)])([ \t]+|$)`)
+	quotePattern     = regexp.MustCompile(`^ {0,3}> ?`)
+	langPattern      = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`)
+	leadingIndention = regexp.MustCompile(`^[ \t]*`)
+)
+
+// Parse parses a Markdown source into a document node.
+func Parse(source string) *Node {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "�")
+	return &Node{Type: DocumentNode, Children: parseBlocks(strings.Split(source, "\n"), 0)}
+}
+
+// parseBlocks groups lines into block nodes.
+func parseBlocks(lines []string, depth int) []*Node {
+	var blocks []*Node
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fencePattern.MatchString(line):
+			var block *Node
+			block, i = parseFence(lines, i)
+			blocks = append(blocks, block)
+
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			blocks = append(blocks, &Node{Type: HeadingNode, Level: len(m[1]), Children: parseInline(m[2], depth)})
+			i++
+
+		case hrPattern.MatchString(line):
+			blocks = append(blocks, &Node{Type: ThematicBreakNode})
+			i++
+
+		case quotePattern.MatchString(line) && depth < maxDepth:
+			var inner []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				inner = append(inner, quotePattern.ReplaceAllString(lines[i], ""))
+			}
+			blocks = append(blocks, &Node{Type: BlockquoteNode, Children: parseBlocks(inner, depth+1)})
+
+		case isListItem(line) && depth < maxDepth:
+			var block *Node
+			block, i = parseList(lines, i, depth)
+			blocks = append(blocks, block)
+
+		default:
+			start := i
+			for i++; i < len(lines) && !interruptsParagraph(lines[i]); i++ {
+			}
+			text := strings.Join(trimLines(lines[start:i]), "\n")
+			blocks = append(blocks, &Node{Type: ParagraphNode, Children: parseInline(text, depth)})
+		}
+	}
+	return blocks
+}
+
+// parseFence parses a fenced code block starting at lines[i] and returns it with the index of the next line.
+// An unterminated fence runs to the end of the input.
+func parseFence(lines []string, i int) (*Node, int) {
+	m := fencePattern.FindStringSubmatch(lines[i])
+	fence := m[1]
+	lang := ""
+	if fields := strings.Fields(m[2]); len(fields) > 0 && langPattern.MatchString(fields[0]) {
+		lang = fields[0]
+	}
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+	return &Node{Type: CodeBlockNode, Lang: lang, Literal: strings.Join(code, "\n")}, i
+}
+
+// parseList parses consecutive items of the same list kind starting at lines[i] and returns the list with the
+// index of the next line. Lines indented past the marker, and blank lines followed by such lines, continue the
+// current item.
+func parseList(lines []string, i int, depth int) (*Node, int) {
+	ordered := !bulletPattern.MatchString(lines[i])
+	list := &Node{Type: ListNode, Ordered: ordered}
+	if ordered {
+		list.Start, _ = strconv.Atoi(orderedPattern.FindStringSubmatch(lines[i])[1])
+	}
+
+	for i < len(lines) && isListItem(lines[i]) && !bulletPattern.MatchString(lines[i]) == ordered {
+		marker, content := splitListMarker(lines[i])
+		itemLines := []string{content}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				if i+1 < len(lines) && indentWidth(lines[i+1]) >= marker {
+					itemLines = append(itemLines, "")
+					continue
+				}
+				break
+			}
+			if indentWidth(line) >= marker {
+				itemLines = append(itemLines, dedent(line, marker))
+				continue
+			}
+			if isListItem(line) || interruptsParagraph(line) {
+				break
+			}
+			// Lazy continuation of the item's paragraph.
+			itemLines = append(itemLines, line)
+		}
+		list.Children = append(list.Children, &Node{Type: ListItemNode, Children: parseBlocks(itemLines, depth+1)})
+	}
+	return list, i
+}
+
+// splitListMarker returns the width of a list item's marker, including the following spaces, and the item's
+// first line of content.
+func splitListMarker(line string) (int, string) {
+	var m []int
+	if m = bulletPattern.FindStringIndex(line); m == nil {
+		m = orderedPattern.FindStringIndex(line)
+	}
+	return m[1], line[m[1]:]
+}
+
+func isListItem(line string) bool {
+	return bulletPattern.MatchString(line) || orderedPattern.MatchString(line)
+}
+
+// interruptsParagraph reports whether a line ends a paragraph because it starts another block.
+func interruptsParagraph(line string) bool {
+	return strings.TrimSpace(line) == "" ||
+		fencePattern.MatchString(line) ||
+		headingPattern.MatchString(line) ||
+		hrPattern.MatchString(line) ||
+		quotePattern.MatchString(line) ||
+		isListItem(line)
+}
+
+// indentWidth returns the number of leading columns of whitespace, counting tabs as four columns.
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range leadingIndention.FindString(line) {
+		if r == '\t' {
+			width += 4
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// dedent removes up to n columns of leading whitespace.
+func dedent(line string, n int) string {
+	width := 0
+	for i, r := range line {
+		if width >= n || (r != ' ' && r != '\t') {
+			return line[i:]
+		}
+		if r == '\t' {
+			width += 4
+		} else {
+			width++
+		}
+	}
+	return ""
+}
+
+func trimLines(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	return trimmed
+}
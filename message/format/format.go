@@ -0,0 +1,50 @@
+// Package format renders message bodies written in a markup format into sanitized HTML.
+//
+// Markdown is parsed into an AST that can only express a fixed set of elements, and the renderer emits every
+// piece of text escaped. Raw HTML in the source is kept as literal text, link targets are restricted to a small
+// set of URL schemes, and no element carries attributes other than the ones the renderer sets itself.
+package format
+
+import "errors"
+
+// Supported message formats.
+const (
+	Plain    = "plain"
+	Markdown = "markdown"
+)
+
+// MaxSourceBytes bounds the size of the sources that are rendered.
+const MaxSourceBytes = 64 << 10
+
+var (
+	ErrUnknownFormat  = errors.New("unknown message format")
+	ErrSourceTooLarge = errors.New("message too large to render")
+)
+
+// Normalize maps an empty format to Plain and rejects formats that are not supported.
+func Normalize(format string) (string, error) {
+	switch format {
+	case "", Plain:
+		return Plain, nil
+	case Markdown:
+		return Markdown, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// Render returns the sanitized HTML for a source in the given format. Plain sources have no rendered form and
+// yield an empty string.
+func Render(format string, source string) (string, error) {
+	format, err := Normalize(format)
+	if err != nil {
+		return "", err
+	}
+	if format == Plain {
+		return "", nil
+	}
+	if len(source) > MaxSourceBytes {
+		return "", ErrSourceTooLarge
+	}
+	return RenderHTML(Parse(source)), nil
+}
@@ -0,0 +1,243 @@
+package format
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		ok   bool
+	}{
+		{raw: "https://example.com/a?b=c#d", want: "https://example.com/a?b=c#d", ok: true},
+		{raw: "HTTP://example.com", want: "http://example.com", ok: true},
+		{raw: "mailto:someone@example.com", want: "mailto:someone@example.com", ok: true},
+		{raw: "/rooms/general", want: "/rooms/general", ok: true},
+		{raw: "page#section", want: "page#section", ok: true},
+		{raw: "?q=1", want: "?q=1", ok: true},
+		{raw: "./a:b", want: "./a:b", ok: true},
+		{raw: "javascript%3Aalert(1)", want: "javascript%3Aalert(1)", ok: true},
+		{raw: "javascript&colon;alert(1)", want: "javascript&colon;alert(1)", ok: true},
+		{raw: ""},
+		{raw: " \t\n"},
+		{raw: "javascript:alert(1)"},
+		{raw: "JaVaScRiPt:alert(1)"},
+		{raw: " javascript:alert(1)"},
+		{raw: "java\tscript:alert(1)"},
+		{raw: "java\nscript:alert(1)"},
+		{raw: "java\x00script:alert(1)"},
+		{raw: "\x01javascript:alert(1)"},
+		{raw: "java script:alert(1)"},
+		{raw: "vbscript:msgbox(1)"},
+		{raw: "data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg=="},
+		{raw: "DATA:text/html,<script>alert(1)</script>"},
+		{raw: "file:///etc/passwd"},
+		// Entities are not decoded, so a # before the colon makes these relative URLs with a fragment.
+		{raw: "jav&#x09;ascript:alert(1)", want: "jav&#x09;ascript:alert(1)", ok: true},
+		{raw: "javascript&#58;alert(1):", want: "javascript&#58;alert(1):", ok: true},
+		{raw: "x:alert(1)"},
+		{raw: "http://[::1"},
+		{raw: "//"},
+	}
+	for _, tt := range tests {
+		got, ok := SanitizeURL(tt.raw)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("SanitizeURL(%q) = %q, %v; want %q, %v", tt.raw, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "raw html",
+			source: `<script>alert(1)</script>`,
+			want:   `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+		},
+		{
+			name:   "raw html attributes",
+			source: `<img src=x onerror="alert(1)">`,
+			want:   `<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>`,
+		},
+		{
+			name:   "javascript link",
+			source: `[click](javascript:alert(1))`,
+			want:   `<p>click</p>`,
+		},
+		{
+			name:   "javascript link split by whitespace",
+			source: "[click](<java\tscript:alert(1)>)",
+			want:   `<p><a href="java" rel="nofollow noopener noreferrer">click</a></p>`,
+		},
+		{
+			name:   "data link",
+			source: `[click](data:text/html;base64,PHNjcmlwdD4=)`,
+			want:   `<p>click</p>`,
+		},
+		{
+			name:   "javascript autolink",
+			source: `<javascript:alert(1)>`,
+			want:   `<p>&lt;javascript:alert(1)&gt;</p>`,
+		},
+		{
+			name:   "entity encoded scheme stays escaped",
+			source: `[click](javascript&#58;alert(1))`,
+			want:   `<p><a href="javascript&amp;#58;alert(1)" rel="nofollow noopener noreferrer">click</a></p>`,
+		},
+		{
+			name:   "entity in relative link stays escaped",
+			source: `[click](javascript&colon;alert(1))`,
+			want:   `<p><a href="javascript&amp;colon;alert(1)" rel="nofollow noopener noreferrer">click</a></p>`,
+		},
+		{
+			name:   "attribute injection through href",
+			source: `[x](https://example.com/"onmouseover="alert(1))`,
+			want:   `<p><a href="https://example.com/%22onmouseover=%22alert%281%29" rel="nofollow noopener noreferrer">x</a></p>`,
+		},
+		{
+			name:   "attribute injection through autolink",
+			source: `https://example.com/'onmouseover='alert(1)`,
+			want:   `<p><a href="https://example.com/&#39;onmouseover=&#39;alert(1" rel="nofollow noopener noreferrer">https://example.com/&#39;onmouseover=&#39;alert(1</a>)</p>`,
+		},
+		{
+			name:   "attribute injection through code language",
+			source: "```js\" onclick=\"alert(1)\nx\n```",
+			want:   `<pre><code>x</code></pre>`,
+		},
+		{
+			name:   "entities are shown as written",
+			source: `&lt;script&gt; &amp; &#x3C;b&#x3E;`,
+			want:   `<p>&amp;lt;script&amp;gt; &amp;amp; &amp;#x3C;b&amp;#x3E;</p>`,
+		},
+		{
+			name:   "html in link text",
+			source: `[<b>bold</b>](https://example.com)`,
+			want:   `<p><a href="https://example.com" rel="nofollow noopener noreferrer">&lt;b&gt;bold&lt;/b&gt;</a></p>`,
+		},
+		{
+			name:   "html in code span",
+			source: "`<script>`",
+			want:   `<p><code>&lt;script&gt;</code></p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(Markdown, tt.source)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render(%q)\n got %s\nwant %s", tt.source, got, tt.want)
+			}
+			checkSafeHTML(t, tt.source, got)
+		})
+	}
+}
+
+func TestRenderPlainAndLimits(t *testing.T) {
+	if got, err := Render("", "<script>"); err != nil || got != "" {
+		t.Errorf("Render of plain text = %q, %v; want no HTML", got, err)
+	}
+	if _, err := Render("html", "<b>"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Render of html = %v, want ErrUnknownFormat", err)
+	}
+	if _, err := Render(Markdown, strings.Repeat("a", MaxSourceBytes+1)); !errors.Is(err, ErrSourceTooLarge) {
+		t.Errorf("Render of an oversized source = %v, want ErrSourceTooLarge", err)
+	}
+}
+
+func FuzzRender(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"plain text",
+		"*em* **strong** ~~del~~ `code`",
+		"# heading\n\n> quote\n\n- a\n- b\n\n3. c\n4. d\n\n---",
+		"```go\nfunc main() {}\n```",
+		"[link](https://example.com \"title\")",
+		"[x](javascript:alert(1))",
+		"[x](<data:text/html,<script>alert(1)</script>>)",
+		"<https://example.com/a?b=\"c\">",
+		"https://example.com/<script>",
+		"<img src=x onerror=alert(1)>",
+		"[x](https://a.com/\"onmouseover=\"alert(1))",
+		"[[[[x](y)](z)](javascript:w)]",
+		"&#106;avascript:alert(1) &lt;b&gt;",
+		"```\" onclick=\"x\ny\n```",
+		"***__~~`nested`~~__***",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		got, err := Render(Markdown, source)
+		if err != nil {
+			if !errors.Is(err, ErrSourceTooLarge) {
+				t.Fatalf("Render: %v", err)
+			}
+			return
+		}
+		checkSafeHTML(t, source, got)
+	})
+}
+
+// tagPattern matches the tags of rendered HTML, with their attributes.
+var tagPattern = regexp.MustCompile(`<(/?)([a-z0-9]+)([^<>]*)>`)
+
+// attributePatterns are the only attributes each tag may be rendered with.
+var attributePatterns = map[string]*regexp.Regexp{
+	"a":    regexp.MustCompile(`^ href="([^"]*)" rel="nofollow noopener noreferrer"$`),
+	"code": regexp.MustCompile(`^( class="language-[^"]*")?$`),
+	"ol":   regexp.MustCompile(`^( start="-?[0-9]+")?$`),
+}
+
+var allowedTags = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "pre": true, "code": true,
+	"blockquote": true, "ul": true, "ol": true, "li": true, "hr": true, "em": true, "strong": true, "del": true,
+	"a": true, "br": true,
+}
+
+// checkSafeHTML fails unless the rendered HTML consists of allowed tags with allowed attributes, links only to
+// safe URLs and escapes everything else.
+func checkSafeHTML(t *testing.T, source string, rendered string) {
+	t.Helper()
+	for _, m := range tagPattern.FindAllStringSubmatch(rendered, -1) {
+		closing, tag, attributes := m[1] == "/", m[2], m[3]
+		if !allowedTags[tag] {
+			t.Fatalf("Render(%q) produced a <%s> tag: %s", source, tag, rendered)
+		}
+		if closing {
+			if attributes != "" {
+				t.Fatalf("Render(%q) produced a closing tag with attributes: %s", source, rendered)
+			}
+			continue
+		}
+		pattern, ok := attributePatterns[tag]
+		if !ok {
+			if attributes != "" {
+				t.Fatalf("Render(%q) produced <%s> with attributes %q", source, tag, attributes)
+			}
+			continue
+		}
+		attr := pattern.FindStringSubmatch(attributes)
+		if attr == nil {
+			t.Fatalf("Render(%q) produced <%s> with attributes %q", source, tag, attributes)
+		}
+		if tag == "a" {
+			href := html.UnescapeString(attr[1])
+			if sanitized, ok := SanitizeURL(href); !ok || sanitized != href {
+				t.Fatalf("Render(%q) links to unsafe %q", source, href)
+			}
+		}
+	}
+	if text := tagPattern.ReplaceAllString(rendered, ""); strings.ContainsAny(text, "<>\"") {
+		t.Fatalf("Render(%q) left markup unescaped: %s", source, rendered)
+	}
+}
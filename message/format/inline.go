@@ -0,0 +1,296 @@
+package format
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	autolinkPattern = regexp.MustCompile(`^<((?i:https?://|mailto:)[^\s<>]+)>`)
+	bareURLPattern  = regexp.MustCompile(`^(?i:https?://)[^\s<>]+`)
+)
+
+// inlineParser holds the state of parsing one span of inline content.
+type inlineParser struct {
+	src   string
+	depth int
+	// noLinks is set inside link text, where links may not nest.
+	noLinks bool
+	// unclosed records, per delimiter, the offset from which no closing delimiter exists, so that runs of
+	// unmatched delimiters do not trigger repeated scans of the rest of the input.
+	unclosed map[string]int
+	// brackets and parens map the offsets of opening brackets and parentheses to their closing ones; they are
+	// computed on first use.
+	brackets map[int]int
+	parens   map[int]int
+	nodes    []*Node
+	text     strings.Builder
+}
+
+// parseInline parses inline Markdown: code spans, emphasis, strong, strikethrough, links and line breaks.
+func parseInline(src string, depth int) []*Node {
+	return newInlineParser(src, depth, false).parse()
+}
+
+func newInlineParser(src string, depth int, noLinks bool) *inlineParser {
+	return &inlineParser{src: src, depth: depth, noLinks: noLinks, unclosed: make(map[string]int)}
+}
+
+func (p *inlineParser) parse() []*Node {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			p.text.WriteByte(s[i+1])
+			i += 2
+
+		case c == '\n':
+			p.emit(&Node{Type: LineBreakNode})
+			i++
+
+		case c == '`':
+			i = p.codeSpan(i)
+
+		case c == '*' || c == '_' || c == '~':
+			i = p.emphasis(i)
+
+		case c == '[' && !p.noLinks:
+			i = p.link(i)
+
+		case c == '<' && !p.noLinks:
+			if m := autolinkPattern.FindStringSubmatch(s[i:]); m != nil {
+				p.autolink(m[1])
+				i += len(m[0])
+			} else {
+				p.text.WriteByte(c)
+				i++
+			}
+
+		case (c == 'h' || c == 'H') && !p.noLinks && wordBoundaryBefore(s, i):
+			if m := bareURLPattern.FindString(s[i:]); m != "" {
+				url := strings.TrimRightFunc(m, func(r rune) bool { return strings.ContainsRune(".,:;!?'\")", r) })
+				p.autolink(url)
+				i += len(url)
+			} else {
+				p.text.WriteByte(c)
+				i++
+			}
+
+		default:
+			p.text.WriteByte(c)
+			i++
+		}
+	}
+	p.flush()
+	return p.nodes
+}
+
+// codeSpan parses a code span opened by a run of backticks at i. A run without a matching closing run is text.
+func (p *inlineParser) codeSpan(i int) int {
+	s := p.src
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+	fence := s[i : i+n]
+
+	for j := i + n; j < len(s); {
+		k := strings.Index(s[j:], fence)
+		if k < 0 {
+			break
+		}
+		k += j
+		end := k + n
+		if end < len(s) && s[end] == '`' {
+			// Longer run; skip it entirely.
+			for end < len(s) && s[end] == '`' {
+				end++
+			}
+			j = end
+			continue
+		}
+		code := strings.ReplaceAll(s[i+n:k], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+			code = code[1 : len(code)-1]
+		}
+		p.emit(&Node{Type: CodeSpanNode, Literal: code})
+		return end
+	}
+
+	p.text.WriteString(fence)
+	return i + n
+}
+
+// emphasis parses emphasis (*x*, _x_), strong (**x**, __x__) or strikethrough (~~x~~) opened at i. Delimiters
+// without a valid closing delimiter are text.
+func (p *inlineParser) emphasis(i int) int {
+	s := p.src
+	c := s[i]
+	run := 1
+	for i+run < len(s) && s[i+run] == c {
+		run++
+	}
+
+	var delim string
+	var kind NodeType
+	switch {
+	case c == '~' && run >= 2:
+		delim, kind = "~~", StrikethroughNode
+	case c != '~' && run >= 2:
+		delim, kind = s[i:i+2], StrongNode
+	case c != '~':
+		delim, kind = s[i:i+1], EmphasisNode
+	}
+
+	start := i + len(delim)
+	canOpen := delim != "" && p.depth < maxDepth &&
+		start < len(s) && !unicode.IsSpace(runeAt(s, start)) &&
+		!(c == '_' && !wordBoundaryBefore(s, i))
+	if canOpen {
+		if end := p.findCloser(delim, start); end >= 0 {
+			inner := newInlineParser(s[start:end], p.depth+1, p.noLinks).parse()
+			p.emit(&Node{Type: kind, Children: inner})
+			return end + len(delim)
+		}
+	}
+
+	p.text.WriteString(s[i : i+run])
+	return i + run
+}
+
+// findCloser returns the offset of the delimiter closing a span whose content starts at start, or -1.
+func (p *inlineParser) findCloser(delim string, start int) int {
+	s := p.src
+	if from, ok := p.unclosed[delim]; ok && start >= from {
+		return -1
+	}
+	for j := start; j < len(s); {
+		k := strings.Index(s[j:], delim)
+		if k < 0 {
+			break
+		}
+		k += j
+		after := k + len(delim)
+		valid := k > start && !unicode.IsSpace(lastRune(s[:k])) &&
+			!(delim[0] == '_' && after < len(s) && isWordRune(runeAt(s, after))) &&
+			!(len(delim) == 1 && after < len(s) && s[after] == delim[0])
+		if valid {
+			return k
+		}
+		j = k + 1
+	}
+	p.unclosed[delim] = start
+	return -1
+}
+
+// link parses an inline link [text](destination "title") opened at i. Anything else starting with "[" is text.
+func (p *inlineParser) link(i int) int {
+	s := p.src
+	if p.brackets == nil {
+		p.brackets = matchPairs(s, '[', ']', false)
+		p.parens = matchPairs(s, '(', ')', true)
+	}
+	closeText, ok := p.brackets[i]
+	if !ok || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		p.text.WriteByte('[')
+		return i + 1
+	}
+	closeDest, ok := p.parens[closeText+1]
+	if !ok {
+		p.text.WriteByte('[')
+		return i + 1
+	}
+
+	dest := strings.TrimSpace(s[closeText+2 : closeDest])
+	if fields := strings.Fields(dest); len(fields) > 0 {
+		// Drop an optional title; it is never rendered.
+		dest = fields[0]
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+
+	label := s[i+1 : closeText]
+	children := newInlineParser(label, p.depth+1, true).parse()
+	if href, ok := SanitizeURL(dest); ok && p.depth < maxDepth {
+		p.emit(&Node{Type: LinkNode, Href: href, Children: children})
+	} else {
+		// Unsafe destinations keep their text but lose the link.
+		p.emit(children...)
+	}
+	return closeDest + 1
+}
+
+// autolink emits a link whose text is the URL itself, or the URL as text when it is not safe.
+func (p *inlineParser) autolink(url string) {
+	text := &Node{Type: TextNode, Literal: url}
+	if href, ok := SanitizeURL(url); ok {
+		p.emit(&Node{Type: LinkNode, Href: href, Children: []*Node{text}})
+	} else {
+		p.emit(text)
+	}
+}
+
+// emit appends nodes after any pending text.
+func (p *inlineParser) emit(nodes ...*Node) {
+	p.flush()
+	p.nodes = append(p.nodes, nodes...)
+}
+
+// flush turns the pending text into a text node.
+func (p *inlineParser) flush() {
+	if p.text.Len() > 0 {
+		p.nodes = append(p.nodes, &Node{Type: TextNode, Literal: p.text.String()})
+		p.text.Reset()
+	}
+}
+
+// matchPairs matches every opening character of s with its balancing closing character, skipping escaped ones,
+// and returns the offsets of the closing characters keyed by the offsets of the opening ones. Pairs may not span
+// lines when singleLine is set.
+func matchPairs(s string, open byte, close byte, singleLine bool) map[int]int {
+	pairs := make(map[int]int)
+	var stack []int
+	for j := 0; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '\n':
+			if singleLine {
+				stack = stack[:0]
+			}
+		case open:
+			stack = append(stack, j)
+		case close:
+			if len(stack) > 0 {
+				pairs[stack[len(stack)-1]] = j
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return pairs
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordBoundaryBefore reports whether the character before offset i is not part of a word.
+func wordBoundaryBefore(s string, i int) bool {
+	return i == 0 || !isWordRune(lastRune(s[:i]))
+}
+
+func runeAt(s string, i int) rune {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return r
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
@@ -0,0 +1,175 @@
+package format
+
+import (
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// safeSchemes are the URL schemes links may use. Relative URLs are allowed too.
+var safeSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// SanitizeURL returns the URL to use as a link target, and whether the URL is safe to link to at all.
+// Control characters and whitespace are removed before parsing so they cannot hide a scheme.
+func SanitizeURL(raw string) (string, bool) {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, raw)
+	if cleaned == "" {
+		return "", false
+	}
+
+	u, err := url.Parse(cleaned)
+	if err != nil || u.String() == "" {
+		// URLs such as "//" parse to nothing, which would link to the page itself.
+		return "", false
+	}
+	if u.Scheme == "" {
+		// A colon before any slash would be read as a scheme by browsers even when url.Parse disagrees.
+		if i := strings.IndexByte(cleaned, ':'); i >= 0 && !strings.ContainsAny(cleaned[:i], "/?#") {
+			return "", false
+		}
+		return u.String(), true
+	}
+	if !safeSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	return u.String(), true
+}
+
+// RenderHTML renders a parsed document as HTML, escaping all text.
+func RenderHTML(doc *Node) string {
+	var b strings.Builder
+	renderChildren(&b, doc, false)
+	return b.String()
+}
+
+func renderChildren(b *strings.Builder, n *Node, tight bool) {
+	for i, child := range n.Children {
+		if i > 0 && isBlock(child) {
+			b.WriteByte('\n')
+		}
+		renderNode(b, child, tight)
+	}
+}
+
+// renderNode writes a node. Paragraphs directly inside tight list items are written without <p> tags.
+func renderNode(b *strings.Builder, n *Node, tight bool) {
+	switch n.Type {
+	case DocumentNode:
+		renderChildren(b, n, false)
+
+	case ParagraphNode:
+		if tight {
+			renderChildren(b, n, false)
+			return
+		}
+		b.WriteString("<p>")
+		renderChildren(b, n, false)
+		b.WriteString("</p>")
+
+	case HeadingNode:
+		tag := "h" + strconv.Itoa(min(max(n.Level, 1), 6))
+		b.WriteString("<" + tag + ">")
+		renderChildren(b, n, false)
+		b.WriteString("</" + tag + ">")
+
+	case CodeBlockNode:
+		b.WriteString("<pre><code")
+		if n.Lang != "" {
+			b.WriteString(` class="language-` + html.EscapeString(n.Lang) + `"`)
+		}
+		b.WriteString(">")
+		b.WriteString(html.EscapeString(n.Literal))
+		b.WriteString("</code></pre>")
+
+	case BlockquoteNode:
+		b.WriteString("<blockquote>\n")
+		renderChildren(b, n, false)
+		b.WriteString("\n</blockquote>")
+
+	case ListNode:
+		tag := "ul"
+		if n.Ordered {
+			tag = "ol"
+		}
+		b.WriteString("<" + tag)
+		if n.Ordered && n.Start != 1 {
+			b.WriteString(` start="` + strconv.Itoa(n.Start) + `"`)
+		}
+		b.WriteString(">\n")
+		for _, item := range n.Children {
+			renderNode(b, item, false)
+			b.WriteByte('\n')
+		}
+		b.WriteString("</" + tag + ">")
+
+	case ListItemNode:
+		b.WriteString("<li>")
+		renderChildren(b, n, isTightItem(n))
+		b.WriteString("</li>")
+
+	case ThematicBreakNode:
+		b.WriteString("<hr>")
+
+	case TextNode:
+		b.WriteString(html.EscapeString(n.Literal))
+
+	case EmphasisNode:
+		renderWrapped(b, n, "em")
+
+	case StrongNode:
+		renderWrapped(b, n, "strong")
+
+	case StrikethroughNode:
+		renderWrapped(b, n, "del")
+
+	case CodeSpanNode:
+		b.WriteString("<code>" + html.EscapeString(n.Literal) + "</code>")
+
+	case LinkNode:
+		b.WriteString(`<a href="` + html.EscapeString(n.Href) + `" rel="nofollow noopener noreferrer">`)
+		renderChildren(b, n, false)
+		b.WriteString("</a>")
+
+	case LineBreakNode:
+		b.WriteString("<br>\n")
+	}
+}
+
+func renderWrapped(b *strings.Builder, n *Node, tag string) {
+	b.WriteString("<" + tag + ">")
+	renderChildren(b, n, false)
+	b.WriteString("</" + tag + ">")
+}
+
+// isTightItem reports whether a list item is a single paragraph, optionally followed by nested lists, whose text
+// is then written without <p> tags.
+func isTightItem(n *Node) bool {
+	if len(n.Children) == 0 || n.Children[0].Type != ParagraphNode {
+		return false
+	}
+	for _, child := range n.Children[1:] {
+		if child.Type != ListNode {
+			return false
+		}
+	}
+	return true
+}
+
+func isBlock(n *Node) bool {
+	switch n.Type {
+	case ParagraphNode, HeadingNode, CodeBlockNode, BlockquoteNode, ListNode, ListItemNode, ThematicBreakNode:
+		return true
+	}
+	return false
+}
@@ -3,16 +3,36 @@ package message
 import (
 	"errors"
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"log"
+	"messages-go/auth"
 	"messages-go/models/errormodel"
+	"messages-go/models/request"
 	"messages-go/models/response"
+	"messages-go/ratelimit"
 	ws "messages-go/websocket"
+	"strconv"
 	"strings"
 )
 
+const (
+	// defaultMessagePageSize is used when a paged request for messages does not specify a limit.
+	defaultMessagePageSize = 50
+	// maxMessagePageSize caps the number of messages returned by a paged request.
+	maxMessagePageSize = 200
+)
+
+// IdempotencyKeyHeader carries the client message ID of a post when the body does not.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type MessageHandler interface {
 	PostMessage(c *fiber.Ctx) error
 	GetMessages(c *fiber.Ctx) error
+	StreamRoomEvents(c *fiber.Ctx) error
+	PollMessages(c *fiber.Ctx) error
+	DeleteMessage(c *fiber.Ctx) error
+	Vote(c *fiber.Ctx) error
+	Forward(c *fiber.Ctx) error
 }
 
 type MessageHandlerImpl struct {
@@ -33,10 +53,153 @@ func (mh *MessageHandlerImpl) PostMessage(c *fiber.Ctx) error {
 			Message: "Invalid Request Body.",
 		})
 	}
+	// Message IDs are assigned on insert; only deliveries from within the server choose their own.
+	postMessageRequest.ID = primitive.NilObjectID
+	postMessageRequest.Integration = nil
+	// Only the command router makes replies ephemeral, and only moderation and the filters hide or flag messages.
+	postMessageRequest.Ephemeral = false
+	postMessageRequest.Hidden, postMessageRequest.Flagged = false, false
+	if postMessageRequest.ClientMsgID == "" {
+		postMessageRequest.ClientMsgID = c.Get(IdempotencyKeyHeader)
+	}
+	// Anonymous callers post without a sender, which private rooms reject.
+	postMessageRequest.SenderID = auth.CallerID(c)
 	log.Println("Post Message Request Received:", postMessageRequest)
-	message, err := mh.messageService.PostMessage(c.Context(), &postMessageRequest)
+	return mh.post(c, &postMessageRequest)
+}
+
+// Forward handles the caller forwarding a message of a room into another room they belong to, optionally with a
+// comment of their own.
+func (mh *MessageHandlerImpl) Forward(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	id := c.Params("id")
+	var req request.ForwardMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body.",
+		})
+	}
+	callerId := auth.CallerID(c)
+	if callerId == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   errormodel.ErrUnauthenticated.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Forwarding Messages Requires A Caller Identity.",
+		})
+	}
+	msg := &Message{
+		RoomID:      req.RoomID,
+		SenderID:    callerId,
+		Body:        req.Body,
+		Format:      req.Format,
+		ClientMsgID: req.ClientMsgID,
+		Reference:   &Reference{Kind: ReferenceForward, RoomID: roomId, MessageID: id},
+	}
+	if msg.ClientMsgID == "" {
+		msg.ClientMsgID = c.Get(IdempotencyKeyHeader)
+	}
+
+	log.Println("Forward Message ", id, " from Room with id ", roomId, " to Room with id ", req.RoomID, " Request Received.")
+	return mh.post(c, msg)
+}
+
+// post posts a message on behalf of the caller, answers with the posted message and broadcasts it to its room.
+func (mh *MessageHandlerImpl) post(c *fiber.Ctx, msg *Message) error {
+	message, err := mh.messageService.PostMessage(c.Context(), msg)
 
-	if err != nil {
+	if errors.Is(err, errormodel.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Room found given roomId.",
+		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Only Members May Post Or Forward To This Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrMessageRejected) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnprocessableEntity,
+			Message: "Message Rejected By The Room's Content Filters.",
+		})
+	} else if errors.Is(err, errormodel.ErrBanned) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "You Are Banned From This Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrMuted) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "You Are Muted In This Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrSlowMode) || errors.Is(err, errormodel.ErrDuplicateMessage) {
+		var retryErr *errormodel.RetryError
+		seconds := 1
+		if errors.As(err, &retryErr) {
+			seconds = ratelimit.RetryAfterSeconds(retryErr.RetryAfter)
+		}
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return c.Status(fiber.StatusTooManyRequests).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusTooManyRequests,
+			Message: "Slow Down, You May Post Again In " + strconv.Itoa(seconds) + " Seconds.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidFormat) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Format Must Be plain Or markdown, With A Body Small Enough To Render.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidPoll) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Polls Need A Question, 2 To 10 Distinct Options And A Future Close Time.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidEmbed) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Embeds Need A Title Or Text, An http Or https URL And A Hex Color.",
+		})
+	} else if errors.Is(err, errormodel.ErrMessageNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Message Found To Forward Or Quote.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidReference) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "References Need Kind forward Or quote, A room_id And A message_id; Quotes Need A Body And Polls Cannot Reference.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidClientMsgID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Client Message ID Must Be At Most 128 Characters.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidTTL) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "TTL Must Be A Positive Number Of Seconds Within The Allowed Maximum.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidAttachment) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Attachments Must Be Unused Uploads By The Sender To This Room.",
+		})
+	} else if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
 			Error:   err.Error(),
 			Status:  fiber.StatusInternalServerError,
@@ -44,12 +207,22 @@ func (mh *MessageHandlerImpl) PostMessage(c *fiber.Ctx) error {
 		})
 	}
 
-	if mh.wsHandler != nil {
-		mh.wsHandler.BroadcastToRoom(message.RoomID, map[string]interface{}{
-			"type":    "new_message",
-			"message": message,
+	if message.Replayed() {
+		return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+			Data:    message,
+			Status:  fiber.StatusOK,
+			Message: "Message Already Posted.",
+		})
+	} else if message.Ephemeral {
+		return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+			Data:    message,
+			Status:  fiber.StatusOK,
+			Message: "Command Handled.",
 		})
 	}
+	if mh.wsHandler != nil {
+		mh.wsHandler.BroadcastToRoom(message.RoomID, NewMessageEvent(message))
+	}
 
 	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
 		Data:    message,
@@ -70,9 +243,33 @@ func (mh *MessageHandlerImpl) GetMessages(c *fiber.Ctx) error {
 	}
 
 	log.Println("Get Messages from Room with id: ", roomId, " Request Received.")
-	getMessageResp, err := mh.messageService.GetMessages(c.Context(), roomId)
+	var getMessageResp []Message
+	var err error
+	if c.Query("after_seq") != "" || c.Query("before_seq") != "" || c.Query("limit") != "" {
+		var afterSeq, beforeSeq int64
+		afterSeq, err = parseSeqParam(c.Query("after_seq"))
+		if err == nil {
+			beforeSeq, err = parseSeqParam(c.Query("before_seq"))
+		}
+		if err == nil {
+			limit := c.QueryInt("limit", defaultMessagePageSize)
+			if limit > maxMessagePageSize {
+				err = errormodel.ErrInvalidCursor
+			} else {
+				getMessageResp, err = mh.messageService.GetMessagePage(c.Context(), roomId, afterSeq, beforeSeq, limit)
+			}
+		}
+	} else {
+		getMessageResp, err = mh.messageService.GetMessages(c.Context(), roomId)
+	}
 
-	if errors.Is(err, errormodel.ErrMessagesNotFound) {
+	if errors.Is(err, errormodel.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "after_seq and before_seq must be sequence numbers and limit between 1 and 200.",
+		})
+	} else if errors.Is(err, errormodel.ErrMessagesNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
 			Error:   err.Error(),
 			Status:  fiber.StatusNotFound,
@@ -98,3 +295,130 @@ func (mh *MessageHandlerImpl) GetMessages(c *fiber.Ctx) error {
 		Data:    getMessageResp,
 	})
 }
+
+// DeleteMessage handles the sender or a moderator removing a message from a room.
+func (mh *MessageHandlerImpl) DeleteMessage(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	id := c.Params("id")
+
+	log.Println("Delete Message ", id, " from Room with id ", roomId, " Request Received.")
+
+	deleted, err := mh.messageService.DeleteMessage(c.Context(), roomId, id, auth.CallerID(c))
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Deleting Messages Requires A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Only The Sender Or A Moderator May Delete This Message.",
+		})
+	} else if errors.Is(err, errormodel.ErrRoomNotFound) || errors.Is(err, errormodel.ErrMessageNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Message Found with given id in this Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidMessageID) || errors.Is(err, errormodel.ErrInvalidRoomID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Message or Room id is malformed.",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Delete Message.",
+		})
+	}
+
+	if mh.wsHandler != nil {
+		mh.wsHandler.BroadcastToRoom(deleted.RoomID, NewMessageDeletedEvent(deleted.RoomID, deleted.ID))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Message Deleted.",
+		Data:    deleted,
+	})
+}
+
+// Vote handles the caller voting in a poll and broadcasts the updated tallies to the poll's room.
+func (mh *MessageHandlerImpl) Vote(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req request.VoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body.",
+		})
+	}
+
+	log.Println("Vote in Poll ", id, " Request Received.")
+
+	updated, err := mh.messageService.Vote(c.Context(), id, auth.CallerID(c), req.OptionIDs)
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Voting Requires A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrBanned) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "You Are Banned From This Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrMessageNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Poll Found with given id.",
+		})
+	} else if errors.Is(err, errormodel.ErrPollClosed) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "This Poll Does Not Accept Your Vote.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidVote) || errors.Is(err, errormodel.ErrInvalidMessageID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Vote For One Option, Or Several In Multiple Choice Polls.",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Vote.",
+		})
+	}
+
+	if mh.wsHandler != nil {
+		mh.wsHandler.BroadcastToRoom(updated.RoomID, newPollUpdatedEvent(updated))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Vote Counted.",
+		Data:    updated,
+	})
+}
+
+// parseSeqParam parses an optional sequence number query parameter, which is 0 when absent.
+func parseSeqParam(raw string) (int64, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, errormodel.ErrInvalidCursor
+	}
+	return seq, nil
+}
@@ -0,0 +1,105 @@
+package message
+
+import (
+	"messages-go/models/errormodel"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Limits on the embeds of messages posted by integrations.
+const (
+	maxEmbeds         = 5
+	maxEmbedTitleSize = 256
+	maxEmbedTextSize  = 2000
+	maxEmbedURLSize   = 2048
+	maxUsernameSize   = 80
+)
+
+// embedColorPattern matches the hex colors of embeds, such as #2eb886.
+var embedColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Integration marks a message posted on behalf of an integration rather than typed by a user. It is set by the
+// server alone; clients posting messages cannot.
+type Integration struct {
+	// Kind is the kind of integration, such as incoming_webhook
+	Kind string `bson:"kind" json:"kind"`
+	// ID identifies the integration, such as the incoming webhook the message was posted through
+	ID string `bson:"id" json:"id"`
+	// Username replaces the integration's name as the sender shown with the message
+	Username string `bson:"username,omitempty" json:"username,omitempty"`
+	// InvokerID is the user whose command a bot replied to. The reply carries the client message ID of the command,
+	// so that retries of the command are answered with it.
+	InvokerID string `bson:"invoker_id,omitempty" json:"invoker_id,omitempty"`
+}
+
+// Integration kinds.
+const (
+	IntegrationIncomingWebhook = "incoming_webhook"
+	// IntegrationBot messages are replies of bots to their commands
+	IntegrationBot = "bot"
+	// IntegrationCommand messages are replies of the built-in commands
+	IntegrationCommand = "command"
+)
+
+// Embed is a card of structured content shown below the body of a message posted by an integration, such as a
+// build result linking to its log.
+type Embed struct {
+	Title string `bson:"title,omitempty" json:"title,omitempty"`
+	URL   string `bson:"url,omitempty" json:"url,omitempty"`
+	Text  string `bson:"text,omitempty" json:"text,omitempty"`
+	// Color is the hex color of the card's edge
+	Color string `bson:"color,omitempty" json:"color,omitempty"`
+}
+
+// prepareIntegration validates the integration and embeds of a message. Embeds are only kept on messages posted
+// by integrations.
+func (m *Message) prepareIntegration() error {
+	if m.Integration == nil {
+		m.Embeds = nil
+		return nil
+	}
+	m.Integration.Username = strings.TrimSpace(m.Integration.Username)
+	if len(m.Integration.Username) > maxUsernameSize || len(m.Embeds) > maxEmbeds {
+		return errormodel.ErrInvalidEmbed
+	}
+	for i := range m.Embeds {
+		e := &m.Embeds[i]
+		e.Title, e.URL, e.Text, e.Color = strings.TrimSpace(e.Title), strings.TrimSpace(e.URL), strings.TrimSpace(e.Text), strings.TrimSpace(e.Color)
+		if e.Title == "" && e.Text == "" {
+			return errormodel.ErrInvalidEmbed
+		}
+		if len(e.Title) > maxEmbedTitleSize || len(e.Text) > maxEmbedTextSize || len(e.URL) > maxEmbedURLSize {
+			return errormodel.ErrInvalidEmbed
+		}
+		if e.URL != "" {
+			parsed, err := url.Parse(e.URL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return errormodel.ErrInvalidEmbed
+			}
+		}
+		if e.Color != "" && !embedColorPattern.MatchString(e.Color) {
+			return errormodel.ErrInvalidEmbed
+		}
+	}
+	return nil
+}
+
+// NewEphemeralReply returns a reply to a command that is shown only to the user who issued it, rendered the way a
+// posted message would be.
+func NewEphemeralReply(roomID string, senderID string, integration *Integration, body string, format string) (*Message, error) {
+	m := &Message{
+		RoomID:      roomID,
+		SenderID:    senderID,
+		Body:        body,
+		Format:      format,
+		Integration: integration,
+		Ephemeral:   true,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := m.render(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,107 @@
+package message
+
+import (
+	"context"
+	"log"
+	"messages-go/room"
+	ws "messages-go/websocket"
+	"regexp"
+	"strings"
+)
+
+// Mention kinds.
+const (
+	MentionUser = "user"
+	MentionRoom = "room"
+	MentionHere = "here"
+)
+
+// mentionPattern matches an @-token that is not part of a word or an email address.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9_][A-Za-z0-9_.-]{0,31})`)
+
+// codePattern matches fenced code blocks, including one left open at the end of the body, and code spans.
+var codePattern = regexp.MustCompile("(?s)```.*?(?:```|$)|`[^`\n]+`")
+
+// ParseMentions extracts the distinct usernames mentioned in a message body, lower-cased and in order of first
+// appearance, and reports whether @room or @here was used. Tokens quoted as code between backticks are not
+// mentions, so that pasted snippets and decorators notify nobody.
+func ParseMentions(body string) (usernames []string, room bool, here bool) {
+	body = codePattern.ReplaceAllString(body, " ")
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		switch {
+		case name == MentionRoom:
+			room = true
+		case name == MentionHere:
+			here = true
+		case name != "" && !seen[name]:
+			seen[name] = true
+			usernames = append(usernames, name)
+		}
+	}
+	return usernames, room, here
+}
+
+// MentionNotifier is a PostObserver that delivers a "mention" event on the personal channel of every member
+// mentioned in a posted message, whether or not they are connected to the room.
+type MentionNotifier struct {
+	roomRepo  room.RoomRepo
+	wsHandler *ws.Handler
+}
+
+// NewMentionNotifier initializes a MentionNotifier broadcasting through the given WebSocket handler.
+func NewMentionNotifier(roomRepo room.RoomRepo, wsHandler *ws.Handler) *MentionNotifier {
+	return &MentionNotifier{roomRepo: roomRepo, wsHandler: wsHandler}
+}
+
+// MessagePosted notifies the recipients of the message's mentions. Non-members flagged during resolution and the
+// sender are never notified; @room reaches every member and @here the members currently connected to the room.
+func (mn *MentionNotifier) MessagePosted(ctx context.Context, msg *Message) {
+	if len(msg.Mentions) == 0 {
+		return
+	}
+
+	var recipients []string
+	seen := map[string]bool{msg.SenderID: true}
+	add := func(userID string) {
+		if userID != "" && !seen[userID] {
+			seen[userID] = true
+			recipients = append(recipients, userID)
+		}
+	}
+
+	for _, mention := range msg.Mentions {
+		switch mention.Kind {
+		case MentionUser:
+			if !mention.NotMember {
+				add(mention.UserID)
+			}
+		case MentionRoom, MentionHere:
+			roomData, err := mn.roomRepo.GetRoomByID(ctx, msg.RoomID)
+			if err != nil {
+				log.Printf("Failed to load room %s for mentions: %v", msg.RoomID, err)
+				continue
+			}
+			if mention.Kind == MentionRoom {
+				for _, member := range roomData.Members {
+					add(member)
+				}
+			} else {
+				for _, userID := range mn.wsHandler.ConnectedUserIDs(msg.RoomID) {
+					if roomData.IsMember(userID) {
+						add(userID)
+					}
+				}
+			}
+		}
+	}
+
+	for _, userID := range recipients {
+		mn.wsHandler.BroadcastToUser(userID, map[string]interface{}{
+			"type":    "mention",
+			"room_id": msg.RoomID,
+			"message": msg,
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package message
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		usernames []string
+		room      bool
+		here      bool
+	}{
+		{name: "none", body: "hello there"},
+		{name: "user", body: "@alice hi", usernames: []string{"alice"}},
+		{name: "several users in order", body: "hi @bob and @alice", usernames: []string{"bob", "alice"}},
+		{name: "lower-cased", body: "@Alice", usernames: []string{"alice"}},
+		{name: "room", body: "@room meeting now", room: true},
+		{name: "here", body: "anyone @here?", here: true},
+		{name: "room and here", body: "@here @room", room: true, here: true},
+		{name: "room mention is case-insensitive", body: "@ROOM", room: true},
+		{name: "names starting with room", body: "@roomie @heresy", usernames: []string{"roomie", "heresy"}},
+		{name: "trailing period", body: "thanks @alice.", usernames: []string{"alice"}},
+		{name: "trailing punctuation", body: "@alice, @bob! @carol? @dave: @erin; (@frank) @grace's", usernames: []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace"}},
+		{name: "trailing dashes and dots", body: "@alice-- @bob...", usernames: []string{"alice", "bob"}},
+		{name: "dots and dashes inside names", body: "@first.last @a-b_c", usernames: []string{"first.last", "a-b_c"}},
+		{name: "room with punctuation", body: "@room.", room: true},
+		{name: "email", body: "write to a@b.com"},
+		{name: "email with dotted local part", body: "first.last@example.com"},
+		{name: "inside a word", body: "foo@alice"},
+		{name: "double at", body: "@@alice"},
+		{name: "bare at", body: "@ alice @"},
+		{name: "duplicates", body: "@alice @bob @Alice @alice.", usernames: []string{"alice", "bob"}},
+		{name: "duplicate room", body: "@room @room", room: true},
+		{name: "line start", body: "hi\n@alice", usernames: []string{"alice"}},
+		{name: "name length bound", body: "@" + "a23456789012345678901234567890123", usernames: []string{"a2345678901234567890123456789012"}},
+		{name: "code span", body: "use `@Override` here, @alice", usernames: []string{"alice"}},
+		{name: "code span room", body: "type `@room` to notify everyone"},
+		{name: "fenced code", body: "see\n```\n@bob\n@here\n```\n@alice", usernames: []string{"alice"}},
+		{name: "unclosed fence", body: "```\n@bob"},
+		{name: "unclosed backtick", body: "a ` @alice", usernames: []string{"alice"}},
+		{name: "after code span", body: "`x`@alice", usernames: []string{"alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usernames, room, here := ParseMentions(tt.body)
+			if !reflect.DeepEqual(usernames, tt.usernames) || room != tt.room || here != tt.here {
+				t.Errorf("ParseMentions(%q) = %q, %v, %v; want %q, %v, %v", tt.body, usernames, room, here, tt.usernames, tt.room, tt.here)
+			}
+		})
+	}
+}
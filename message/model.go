@@ -1,10 +1,147 @@
 package message
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"messages-go/attachment"
+	"messages-go/message/filter"
+	"messages-go/message/format"
+	"messages-go/models/errormodel"
+	"time"
+)
+
+// Kinds of messages. Plain text messages leave Kind empty.
+const (
+	KindText = ""
+	KindPoll = "poll"
+	// KindEmote messages describe an action of their sender, such as those posted with /me
+	KindEmote = "emote"
+)
 
 type Message struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Body     string             `bson:"body,omitempty" json:"body"`
-	RoomID   string             `bson:"room_id,omitempty" json:"room_id"`
-	SenderID string             `bson:"sender_id,omitempty" json:"sender_id"`
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	// Seq orders the messages of a room. It is assigned on insert and strictly increases within the room, but is not
+	// contiguous: failed inserts and deleted, hidden or expired messages leave gaps. Clients resuming with after_seq
+	// set to the last Seq they received get every later message they may read, since reads by sequence number
+	// never skip a message still being inserted, so gaps are harmless and need no refetch. Messages from before
+	// sequencing have none.
+	Seq int64 `bson:"seq,omitempty" json:"seq,omitempty"`
+	// CreatedAt is when the message was stored, assigned together with Seq
+	CreatedAt time.Time `bson:"created_at,omitempty" json:"created_at"`
+	// Kind selects how the message is shown, with its kind-specific content in the field of the same name
+	Kind     string `bson:"kind,omitempty" json:"kind,omitempty"`
+	Poll     *Poll  `bson:"poll,omitempty" json:"poll,omitempty"`
+	Body     string `bson:"body,omitempty" json:"body"`
+	RoomID   string `bson:"room_id,omitempty" json:"room_id"`
+	SenderID string `bson:"sender_id,omitempty" json:"sender_id"`
+	// ClientMsgID is an idempotency key chosen by the sender's client. Posting again with the same key returns
+	// the message first posted with it instead of creating another.
+	ClientMsgID string `bson:"client_msg_id,omitempty" json:"client_msg_id,omitempty"`
+	// Format is the markup Body is written in, plain when empty
+	Format string `bson:"format,omitempty" json:"format,omitempty"`
+	// HTML is the sanitized rendering of a formatted Body, stored alongside it. It is never accepted from clients.
+	HTML string `bson:"html,omitempty" json:"html,omitempty"`
+	// AttachmentIDs references attachments uploaded to the room by the sender before posting
+	AttachmentIDs []string `bson:"attachment_ids,omitempty" json:"attachment_ids,omitempty"`
+	// Attachments is resolved from AttachmentIDs when messages are returned or broadcast, so clients can render
+	// thumbnails without another request. It is never stored.
+	Attachments []attachment.Attachment `bson:"-" json:"attachments,omitempty"`
+	// Mentions is parsed from Body when the message is posted
+	Mentions []Mention `bson:"mentions,omitempty" json:"mentions,omitempty"`
+	// Previews are unfurled in the background from the URLs in Body after the message is posted
+	Previews []Preview `bson:"previews,omitempty" json:"previews,omitempty"`
+	// TTL is the number of seconds after which the message expires, overriding the room's setting when posting
+	TTL int `bson:"-" json:"ttl,omitempty"`
+	// ExpiresAt is when an ephemeral message is deleted; expired messages are never returned
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	// FilterDecisions records what the room's content filters did to the message when it was posted
+	FilterDecisions []filter.Decision `bson:"filter_decisions,omitempty" json:"filter_decisions,omitempty"`
+	// Flagged marks messages a content filter held up for moderators' attention
+	Flagged bool `bson:"flagged,omitempty" json:"flagged,omitempty"`
+	// Hidden messages are held back from room history and search pending moderator review
+	Hidden bool `bson:"hidden,omitempty" json:"hidden,omitempty"`
+	// Integration is set on messages posted by an integration, which may carry Embeds
+	Integration *Integration `bson:"integration,omitempty" json:"integration,omitempty"`
+	Embeds      []Embed      `bson:"embeds,omitempty" json:"embeds,omitempty"`
+	// Reference is set on messages forwarding or quoting another message
+	Reference *Reference `bson:"reference,omitempty" json:"reference,omitempty"`
+	// Ephemeral marks replies to commands shown only to the user who issued the command. They are never stored
+	// or broadcast.
+	Ephemeral bool `bson:"-" json:"ephemeral,omitempty"`
+	// replayed is set on messages returned for a retried post, which must not be broadcast again
+	replayed bool
+}
+
+// Replayed reports whether the message was returned for a retry of an earlier post rather than newly posted.
+func (m *Message) Replayed() bool {
+	return m.replayed
+}
+
+// render normalizes the message's format and replaces its HTML with the rendering of its body.
+func (m *Message) render() error {
+	f, err := format.Normalize(m.Format)
+	if err != nil {
+		return errormodel.ErrInvalidFormat
+	}
+	html, err := format.Render(f, m.Body)
+	if err != nil {
+		return errormodel.ErrInvalidFormat
+	}
+	m.Format, m.HTML = f, html
+	if f == format.Plain {
+		// Plain messages are stored as they were before formats existed.
+		m.Format = ""
+	}
+	return nil
+}
+
+// Mention is an @-mention resolved when a message is posted. User mentions carry the mentioned user's ID and are
+// flagged with NotMember, instead of notifying the user, when they are not a member of the room.
+type Mention struct {
+	Kind      string `bson:"kind" json:"kind"`
+	Username  string `bson:"username,omitempty" json:"username,omitempty"`
+	UserID    string `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	NotMember bool   `bson:"not_member,omitempty" json:"not_member,omitempty"`
+}
+
+// Preview is the OpenGraph or Twitter card metadata of a URL linked from a message.
+type Preview struct {
+	URL         string `bson:"url" json:"url"`
+	Title       string `bson:"title,omitempty" json:"title,omitempty"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	SiteName    string `bson:"site_name,omitempty" json:"site_name,omitempty"`
+	ImageURL    string `bson:"image_url,omitempty" json:"image_url,omitempty"`
+}
+
+// Poll is the content of a poll message. Tallies are kept on the options and updated as votes come in.
+type Poll struct {
+	Question string       `bson:"question" json:"question"`
+	Options  []PollOption `bson:"options" json:"options"`
+	// MultipleChoice lets a ballot choose several options; otherwise exactly one
+	MultipleChoice bool `bson:"multiple_choice,omitempty" json:"multiple_choice,omitempty"`
+	// Anonymous polls only reveal counts, never who voted for what
+	Anonymous bool `bson:"anonymous,omitempty" json:"anonymous,omitempty"`
+	// ClosesAt is when the poll stops accepting votes; polls without it stay open
+	ClosesAt *time.Time `bson:"closes_at,omitempty" json:"closes_at,omitempty"`
+}
+
+// PollOption is a choice of a poll with its tally. Voters lists who chose it unless the poll is anonymous.
+type PollOption struct {
+	ID     string   `bson:"id" json:"id"`
+	Text   string   `bson:"text" json:"text"`
+	Votes  int      `bson:"votes" json:"votes"`
+	Voters []string `bson:"voters,omitempty" json:"voters,omitempty"`
+}
+
+// ClosedAt reports whether the poll no longer accepts votes at now.
+func (p *Poll) ClosedAt(now time.Time) bool {
+	return p.ClosesAt != nil && !now.Before(*p.ClosesAt)
+}
+
+// Ballot records the options a user voted for in a poll. At most one ballot exists per user and poll.
+type Ballot struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	MessageID primitive.ObjectID `bson:"message_id"`
+	UserID    string             `bson:"user_id"`
+	OptionIDs []string           `bson:"option_ids"`
+	CastAt    time.Time          `bson:"cast_at"`
 }
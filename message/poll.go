@@ -0,0 +1,136 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/response"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPollTimeout is used when a poll request does not specify a timeout.
+	defaultPollTimeout = 30 * time.Second
+	// maxPollTimeout caps how long a single poll request may be parked.
+	maxPollTimeout = 60 * time.Second
+)
+
+// PollMessages serves a long-poll for clients limited to plain request/response HTTP. Messages newer than the
+// "after" query parameter, a sequence number or message ID, are returned immediately; otherwise the request is parked until the next message is
+// broadcast to the room, the timeout elapses, or the server shuts down.
+func (mh *MessageHandlerImpl) PollMessages(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	if strings.TrimSpace(roomId) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   "Missing room id in path",
+			Status:  fiber.StatusBadRequest,
+			Message: "roomId Path Variable is required to be not empty.",
+		})
+	}
+
+	timeout := defaultPollTimeout
+	if raw := c.Query("timeout"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+				Error:   "invalid timeout",
+				Status:  fiber.StatusBadRequest,
+				Message: "timeout must be a positive duration such as 30s.",
+			})
+		}
+		timeout = min(parsed, maxPollTimeout)
+	}
+	if mh.wsHandler == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(response.APIResponse{
+			Error:   "event hub unavailable",
+			Status:  fiber.StatusServiceUnavailable,
+			Message: "Polling Is Not Available.",
+		})
+	}
+
+	after := c.Query("after")
+	log.Println("Poll Messages from Room with id: ", roomId, " after: ", after, " Request Received.")
+
+	// Subscribe before checking for newer messages so a post in between still wakes the poll.
+	sub := mh.wsHandler.Subscribe(roomId, auth.CallerID(c))
+	defer mh.wsHandler.Unsubscribe(sub)
+
+	messages, err := mh.messageService.GetMessagesAfter(c.Context(), roomId, after)
+	if err != nil {
+		return pollError(c, err)
+	}
+
+	if len(messages) == 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+	wait:
+		for {
+			select {
+			case data, ok := <-sub.Send:
+				if !ok {
+					break wait
+				}
+				var event streamEvent
+				if err := json.Unmarshal(data, &event); err != nil || event.Type != "new_message" {
+					continue
+				}
+				if after == "" {
+					var payload struct {
+						Message Message `json:"message"`
+					}
+					if err := json.Unmarshal(data, &payload); err != nil {
+						continue
+					}
+					messages = []Message{payload.Message}
+				} else if messages, err = mh.messageService.GetMessagesAfter(c.Context(), roomId, after); err != nil {
+					return pollError(c, err)
+				}
+				break wait
+			case <-timer.C:
+				break wait
+			case <-c.Context().Done():
+				break wait
+			}
+		}
+	}
+
+	if len(messages) == 0 {
+		return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+			Status:  fiber.StatusOK,
+			Message: "No New Messages",
+			Data:    []Message{},
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Messages Found",
+		Data:    messages,
+	})
+}
+
+// pollError maps service errors raised while polling to API responses.
+func pollError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errormodel.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Room found given roomId.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "after must be a sequence number or message id.",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed To Poll Messages",
+	})
+}
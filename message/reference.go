@@ -0,0 +1,135 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/models/errormodel"
+	"messages-go/room"
+	"time"
+)
+
+// Kinds of references.
+const (
+	// ReferenceForward messages repost their source, with an optional comment of the forwarder as their body
+	ReferenceForward = "forward"
+	// ReferenceQuote messages quote their source inline above their own body
+	ReferenceQuote = "quote"
+)
+
+// Reference points a forwarded or quoting message at its source message. Senders only choose the Kind, RoomID and
+// MessageID; the rest is filled in when the message is posted.
+type Reference struct {
+	Kind      string `bson:"kind" json:"kind"`
+	RoomID    string `bson:"room_id" json:"room_id"`
+	MessageID string `bson:"message_id" json:"message_id"`
+	// SenderID is the sender of the source message
+	SenderID string `bson:"sender_id,omitempty" json:"sender_id,omitempty"`
+	// Snapshot is the source as it was when referenced, shown even after the source is edited or deleted
+	Snapshot *Snapshot `bson:"snapshot,omitempty" json:"snapshot,omitempty"`
+}
+
+// Snapshot is a copy of the content of a referenced message. Attachments stay with the source message and are
+// not copied.
+type Snapshot struct {
+	Kind        string       `bson:"kind,omitempty" json:"kind,omitempty"`
+	Poll        *Poll        `bson:"poll,omitempty" json:"poll,omitempty"`
+	Body        string       `bson:"body,omitempty" json:"body"`
+	Format      string       `bson:"format,omitempty" json:"format,omitempty"`
+	HTML        string       `bson:"html,omitempty" json:"html,omitempty"`
+	Integration *Integration `bson:"integration,omitempty" json:"integration,omitempty"`
+	Embeds      []Embed      `bson:"embeds,omitempty" json:"embeds,omitempty"`
+	CreatedAt   time.Time    `bson:"created_at,omitempty" json:"created_at"`
+}
+
+// newSnapshot copies the content of a message. Who voted for what in a poll is left out, as the copy cannot be
+// voted on.
+func newSnapshot(m *Message) *Snapshot {
+	s := &Snapshot{
+		Kind:        m.Kind,
+		Body:        m.Body,
+		Format:      m.Format,
+		HTML:        m.HTML,
+		Integration: m.Integration,
+		Embeds:      m.Embeds,
+		CreatedAt:   m.CreatedAt,
+	}
+	if m.Poll != nil {
+		poll := *m.Poll
+		poll.Options = make([]PollOption, len(m.Poll.Options))
+		for i, option := range m.Poll.Options {
+			option.Voters = nil
+			poll.Options[i] = option
+		}
+		s.Poll = &poll
+	}
+	return s
+}
+
+// resolveReference checks the reference of a message about to be posted and fills in its source's sender and
+// snapshot. Forwards may only go to rooms the sender belongs to, and sources must be in rooms the sender may
+// read; sources the sender cannot read are reported as not found. Referencing a forward references its source
+// instead, so snapshots never nest. A message never outlives an expiring source.
+func (ms *MessageServiceImpl) resolveReference(ctx context.Context, msg *Message, roomData *room.Room) error {
+	if msg.Reference == nil {
+		return nil
+	}
+	ref := &Reference{Kind: msg.Reference.Kind, RoomID: msg.Reference.RoomID, MessageID: msg.Reference.MessageID}
+	msg.Reference = ref
+	switch ref.Kind {
+	case ReferenceForward:
+		if !roomData.IsMember(msg.SenderID) {
+			return errormodel.ErrForbidden
+		}
+	case ReferenceQuote:
+		if msg.Body == "" {
+			return errormodel.ErrInvalidReference
+		}
+	default:
+		return errormodel.ErrInvalidReference
+	}
+	if msg.Kind == KindPoll || msg.Integration != nil {
+		return errormodel.ErrInvalidReference
+	}
+	oid, err := primitive.ObjectIDFromHex(ref.MessageID)
+	if err != nil || ref.RoomID == "" {
+		return errormodel.ErrInvalidReference
+	}
+
+	sourceRoom, err := ms.roomRepo.GetRoomByID(ctx, ref.RoomID)
+	if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, errormodel.ErrInvalidRoomID) || (err == nil && !sourceRoom.VisibleTo(msg.SenderID)) {
+		return errormodel.ErrMessageNotFound
+	} else if err != nil {
+		return err
+	}
+	if ms.accessGuard != nil && msg.SenderID != "" {
+		if err := ms.accessGuard.CheckAccess(ctx, ref.RoomID, msg.SenderID); errors.Is(err, errormodel.ErrBanned) {
+			return errormodel.ErrMessageNotFound
+		} else if err != nil {
+			return err
+		}
+	}
+	found, err := ms.messageRepo.GetMessagesByIDs(ctx, []primitive.ObjectID{oid})
+	if err != nil {
+		return err
+	}
+	// Expired sources are not found even before the sweeper removes them, so forwards never inherit a past expiry.
+	if len(found) == 0 || found[0].RoomID != ref.RoomID || found[0].Hidden ||
+		(found[0].ExpiresAt != nil && !found[0].ExpiresAt.After(time.Now())) {
+		return errormodel.ErrMessageNotFound
+	}
+	source := &found[0]
+
+	if source.Reference != nil && source.Reference.Kind == ReferenceForward && source.Reference.Snapshot != nil {
+		ref.RoomID, ref.MessageID = source.Reference.RoomID, source.Reference.MessageID
+		ref.SenderID, ref.Snapshot = source.Reference.SenderID, source.Reference.Snapshot
+	} else {
+		ref.SenderID, ref.Snapshot = source.SenderID, newSnapshot(source)
+	}
+	if source.ExpiresAt != nil && (msg.ExpiresAt == nil || msg.ExpiresAt.After(*source.ExpiresAt)) {
+		expiresAt := *source.ExpiresAt
+		msg.ExpiresAt = &expiresAt
+	}
+	return nil
+}
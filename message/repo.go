@@ -2,33 +2,110 @@ package message
 
 import (
 	"context"
+	"errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"messages-go/models/errormodel"
 	"os"
+	"slices"
 	"time"
 )
 
 type MessageRepo interface {
 	PostMessage(ctx context.Context, msg *Message) (*Message, error)
 	GetMessagesByRoomId(ctx context.Context, roomID primitive.ObjectID) ([]Message, error)
+	GetMessagesAfter(ctx context.Context, roomID primitive.ObjectID, afterID primitive.ObjectID) ([]Message, error)
+	GetMessagesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Message, error)
+	SetPreviews(ctx context.Context, id primitive.ObjectID, previews []Preview) (*Message, error)
+	DeleteMessage(ctx context.Context, id primitive.ObjectID) error
+	GetExpiredMessages(ctx context.Context, now time.Time, limit int64) ([]Message, error)
+	DeleteMessages(ctx context.Context, ids []primitive.ObjectID) (int64, error)
+	GetMessageIDsBefore(ctx context.Context, roomID string, afterID primitive.ObjectID, beforeID primitive.ObjectID, exclude []primitive.ObjectID, limit int64) ([]primitive.ObjectID, error)
+	GetNthNewestMessageID(ctx context.Context, roomID string, n int64) (primitive.ObjectID, error)
+	GetMessagesBySeq(ctx context.Context, roomID string, afterSeq int64, beforeSeq int64, limit int64) ([]Message, error)
+	GetRecentDuplicate(ctx context.Context, roomID string, senderID string, body string, since time.Time) (*Message, error)
+	GetMessageByClientMsgID(ctx context.Context, senderID string, clientMsgID string) (*Message, error)
+	CreateBallot(ctx context.Context, ballot *Ballot) error
+	ReplaceBallot(ctx context.Context, ballot *Ballot) (*Ballot, error)
+	DeleteBallot(ctx context.Context, messageID primitive.ObjectID, userID string) error
+	AddPollVotes(ctx context.Context, ballot *Ballot, retracted []string, recordVoter bool) (*Message, error)
+	SetHidden(ctx context.Context, id primitive.ObjectID, hidden bool) (*Message, error)
 }
 
 type MessageRepoImpl struct {
 	messageCollection *mongo.Collection
+	ballotCollection  *mongo.Collection
+	// sequenceCollection holds the last sequence number assigned in each room, keyed by room ID
+	sequenceCollection *mongo.Collection
 }
 
+// NewMessageRepository initializes and returns a new instance of MessageRepo, ensuring sequence numbers are unique
+// within a room, each sender uses a client message ID once, each command gets one bot reply and each user casts at
+// most one ballot per poll.
 func NewMessageRepository(client *mongo.Client) MessageRepo {
-	return &MessageRepoImpl{
-		messageCollection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("messages"),
+	db := client.Database(os.Getenv("MONGO_DB_NAME"))
+	r := &MessageRepoImpl{
+		messageCollection: db.Collection("messages"),
+		ballotCollection:  db.Collection("poll_ballots"),
+
+		sequenceCollection: db.Collection("message_sequences"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.messageCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "room_id", Value: 1}, {Key: "seq", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"seq": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		log.Printf("Failed to create message sequence index: %v", err)
+	}
+	_, err = r.messageCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "room_id", Value: 1}, {Key: "sender_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Failed to create message sender index: %v", err)
 	}
+	_, err = r.messageCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sender_id", Value: 1}, {Key: "client_msg_id", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"client_msg_id": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		log.Printf("Failed to create client message id index: %v", err)
+	}
+	_, err = r.messageCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "integration.invoker_id", Value: 1}, {Key: "client_msg_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"integration.invoker_id": bson.M{"$exists": true},
+			"client_msg_id":          bson.M{"$exists": true},
+		}),
+	})
+	if err != nil {
+		log.Printf("Failed to create command reply index: %v", err)
+	}
+	_, err = r.ballotCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "message_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create poll ballot index: %v", err)
+	}
+	return r
 }
 
+// PostMessage stores a message under the next sequence number of its room. The number is taken before the insert,
+// so concurrent posts can be stored out of order, and a failed insert leaves its number unused; see commitBarrier.
 func (r *MessageRepoImpl) PostMessage(ctx context.Context, msg *Message) (*Message, error) {
 	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	if err := r.assignSeq(timeoutCtx, msg); err != nil {
+		return nil, err
+	}
 	log.Println("Post Message: ", msg)
 	result, err := r.messageCollection.InsertOne(timeoutCtx, msg)
 	if err != nil {
@@ -41,15 +118,65 @@ func (r *MessageRepoImpl) PostMessage(ctx context.Context, msg *Message) (*Messa
 	return msg, nil
 }
 
+// assignSeq takes the next sequence number of the message's room, together with the time it was taken as the
+// message's creation time. Both come from a single update of the room's counter on the database server, so
+// that creation times follow sequence numbers however many servers post to the room.
+func (r *MessageRepoImpl) assignSeq(ctx context.Context, msg *Message) error {
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"seq": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$seq", 0}}, 1}},
+		"at":  "$$NOW",
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter struct {
+		Seq int64     `bson:"seq"`
+		At  time.Time `bson:"at"`
+	}
+	if err := r.sequenceCollection.FindOneAndUpdate(ctx, bson.M{"_id": msg.RoomID}, update, opts).Decode(&counter); err != nil {
+		return err
+	}
+	msg.Seq, msg.CreatedAt = counter.Seq, counter.At.UTC()
+	return nil
+}
+
 // GetMessagesByRoomId retrieves all messages for a given room ID from the database.
 // Returns the list of messages or an error if any issue occurs during the operation.
 func (r *MessageRepoImpl) GetMessagesByRoomId(ctx context.Context, roomID primitive.ObjectID) ([]Message, error) {
 	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"room_id": roomID.Hex()}
+	filter := bson.M{"room_id": roomID.Hex(), "hidden": notHidden, "$or": notExpired(time.Now())}
 
-	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	opts := options.Find().SetSort(bySeq)
+	cursor, err := r.messageCollection.Find(timeoutCtx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			log.Default().Println(err.Error())
+		}
+	}(cursor, timeoutCtx)
+
+	var messages []Message
+	if err := cursor.All(timeoutCtx, &messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// GetMessagesAfter retrieves the messages of a room whose ID sorts after afterID, oldest first. It serves cursors
+// of messages from before sequencing; GetMessagesBySeq serves the rest.
+// Returns the list of messages or an error if any issue occurs during the operation.
+func (r *MessageRepoImpl) GetMessagesAfter(ctx context.Context, roomID primitive.ObjectID, afterID primitive.ObjectID) ([]Message, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"room_id": roomID.Hex(), "_id": bson.M{"$gt": afterID}, "hidden": notHidden, "$or": notExpired(time.Now())}
+
+	opts := options.Find().SetSort(bySeq)
 	cursor, err := r.messageCollection.Find(timeoutCtx, filter, opts)
 	if err != nil {
 		return nil, err
@@ -68,3 +195,401 @@ func (r *MessageRepoImpl) GetMessagesByRoomId(ctx context.Context, roomID primit
 
 	return messages, nil
 }
+
+// SetPreviews replaces the link previews of a message and returns the updated message.
+func (r *MessageRepoImpl) SetPreviews(ctx context.Context, id primitive.ObjectID, previews []Preview) (*Message, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated Message
+	err := r.messageCollection.FindOneAndUpdate(timeoutCtx, bson.M{"_id": id}, bson.M{"$set": bson.M{"previews": previews}}, opts).Decode(&updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// GetMessagesByIDs retrieves the messages with the given IDs, oldest first. IDs without a message are skipped.
+func (r *MessageRepoImpl) GetMessagesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Message, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	filter := bson.M{"_id": bson.M{"$in": ids}, "$or": notExpired(time.Now())}
+	cursor, err := r.messageCollection.Find(timeoutCtx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var messages []Message
+	if err := cursor.All(timeoutCtx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// DeleteMessage removes a message, returning mongo.ErrNoDocuments when it does not exist.
+func (r *MessageRepoImpl) DeleteMessage(ctx context.Context, id primitive.ObjectID) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.messageCollection.DeleteOne(timeoutCtx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// GetExpiredMessages returns up to limit messages whose expiry time has passed, earliest expiry first.
+func (r *MessageRepoImpl) GetExpiredMessages(ctx context.Context, now time.Time, limit int64) ([]Message, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "expires_at", Value: 1}}).
+		SetLimit(limit).
+		SetProjection(bson.M{"_id": 1, "room_id": 1, "expires_at": 1})
+	cursor, err := r.messageCollection.Find(timeoutCtx, bson.M{"expires_at": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var messages []Message
+	if err := cursor.All(timeoutCtx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// DeleteMessages removes the messages with the given IDs and returns how many were removed.
+func (r *MessageRepoImpl) DeleteMessages(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.messageCollection.DeleteMany(timeoutCtx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// GetMessageIDsBefore returns, in ID order, up to limit IDs of messages of a room that sort after afterID and
+// before beforeID, leaving out the excluded IDs. Only IDs are read, keeping the scan light for batch jobs.
+func (r *MessageRepoImpl) GetMessageIDsBefore(ctx context.Context, roomID string, afterID primitive.ObjectID, beforeID primitive.ObjectID, exclude []primitive.ObjectID, limit int64) ([]primitive.ObjectID, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	idRange := bson.M{"$gt": afterID, "$lt": beforeID}
+	if len(exclude) > 0 {
+		idRange["$nin"] = exclude
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(limit).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := r.messageCollection.Find(timeoutCtx, bson.M{"room_id": roomID, "_id": idRange}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var messages []Message
+	if err := cursor.All(timeoutCtx, &messages); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+	return ids, nil
+}
+
+// GetNthNewestMessageID returns the ID of the nth newest message of a room, counting from 1, or
+// mongo.ErrNoDocuments when the room has fewer messages.
+func (r *MessageRepoImpl) GetNthNewestMessageID(ctx context.Context, roomID string, n int64) (primitive.ObjectID, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip(n - 1).
+		SetProjection(bson.M{"_id": 1})
+	var msg Message
+	if err := r.messageCollection.FindOne(timeoutCtx, bson.M{"room_id": roomID}, opts).Decode(&msg); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return msg.ID, nil
+}
+
+// GetMessagesBySeq retrieves at most limit messages of a room, oldest first, with sequence numbers after afterSeq
+// and, unless beforeSeq is 0, before beforeSeq. When only beforeSeq is given, the page ending right before it is
+// returned, for paging backwards through history. Reading forwards stops at the first gap that may still be
+// filled, so that clients moving their cursor past the page do not skip a message committed after it was read.
+func (r *MessageRepoImpl) GetMessagesBySeq(ctx context.Context, roomID string, afterSeq int64, beforeSeq int64, limit int64) ([]Message, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	seqFilter := bson.M{"$gt": afterSeq}
+	if beforeSeq > 0 {
+		seqFilter["$lt"] = beforeSeq
+	}
+	filter := bson.M{"room_id": roomID, "seq": seqFilter, "hidden": notHidden, "$or": notExpired(time.Now())}
+
+	backwards := afterSeq == 0 && beforeSeq > 0
+	sort := bson.D{{Key: "seq", Value: 1}}
+	if backwards {
+		sort = bson.D{{Key: "seq", Value: -1}}
+	}
+	cursor, err := r.messageCollection.Find(timeoutCtx, filter, options.Find().SetSort(sort).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			log.Default().Println(err.Error())
+		}
+	}(cursor, timeoutCtx)
+
+	var messages []Message
+	if err := cursor.All(timeoutCtx, &messages); err != nil {
+		return nil, err
+	}
+	if backwards {
+		slices.Reverse(messages)
+		return messages, nil
+	}
+	return commitBarrier(messages, afterSeq, time.Now()), nil
+}
+
+// seqCommitWindow bounds how long after taking its sequence number a message can still be inserted: the timeout
+// of PostMessage, with room for clock skew between the database server, which timestamps messages, and ours.
+const seqCommitWindow = 10 * time.Second
+
+// commitBarrier returns the messages up to the first gap in their sequence numbers that was left within
+// seqCommitWindow. Sequence numbers are taken before their message is inserted, so a message posted concurrently
+// can be stored after one posted later and is missing from a read in between. Older gaps are final: they are left
+// by deleted, hidden or expired messages and by inserts that failed.
+func commitBarrier(messages []Message, afterSeq int64, now time.Time) []Message {
+	prev := afterSeq
+	for i, msg := range messages {
+		if msg.Seq > prev+1 && msg.CreatedAt.After(now.Add(-seqCommitWindow)) {
+			return messages[:i]
+		}
+		prev = msg.Seq
+	}
+	return messages
+}
+
+// GetRecentDuplicate retrieves the latest message of a sender in a room with the given body posted after since.
+func (r *MessageRepoImpl) GetRecentDuplicate(ctx context.Context, roomID string, senderID string, body string, since time.Time) (*Message, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"room_id": roomID, "body": body, "created_at": bson.M{"$gt": since}}
+	if senderID != "" {
+		filter["sender_id"] = senderID
+	} else {
+		filter["sender_id"] = bson.M{"$exists": false}
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	var msg Message
+	if err := r.messageCollection.FindOne(timeoutCtx, filter, opts).Decode(&msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// GetMessageByClientMsgID retrieves the message a sender posted with a client message ID, or the bot reply to
+// the sender's command posted with it, whether or not it has expired, as the ID stays taken until the message is
+// deleted.
+func (r *MessageRepoImpl) GetMessageByClientMsgID(ctx context.Context, senderID string, clientMsgID string) (*Message, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"client_msg_id": clientMsgID}
+	if senderID != "" {
+		filter["$or"] = bson.A{bson.M{"sender_id": senderID}, bson.M{"integration.invoker_id": senderID}}
+	} else {
+		filter["sender_id"] = bson.M{"$exists": false}
+	}
+	var msg Message
+	if err := r.messageCollection.FindOne(timeoutCtx, filter).Decode(&msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// CreateBallot records a user's ballot in a poll, returning ErrAlreadyVoted when the user has voted before. The
+// unique index on the ballots is what makes one vote per user hold under concurrent requests.
+func (r *MessageRepoImpl) CreateBallot(ctx context.Context, ballot *Ballot) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.ballotCollection.InsertOne(timeoutCtx, ballot)
+	if mongo.IsDuplicateKeyError(err) {
+		return errormodel.ErrAlreadyVoted
+	} else if err != nil {
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		ballot.ID = oid
+	}
+	return nil
+}
+
+// ReplaceBallot replaces a user's ballot in a poll and returns the ballot it replaced, or nil when the user had
+// none. Replacing the ballot document in one operation hands each of concurrent re-votes the ballot it displaced,
+// so that their tally corrections add up.
+func (r *MessageRepoImpl) ReplaceBallot(ctx context.Context, ballot *Ballot) (*Ballot, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"message_id": ballot.MessageID, "user_id": ballot.UserID}
+	opts := options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.Before)
+	var previous Ballot
+	err := r.ballotCollection.FindOneAndReplace(timeoutCtx, filter, ballot, opts).Decode(&previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ballot.ID = previous.ID
+	return &previous, nil
+}
+
+// DeleteBallot removes a user's ballot in a poll, undoing a vote that could not be counted.
+func (r *MessageRepoImpl) DeleteBallot(ctx context.Context, messageID primitive.ObjectID, userID string) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.ballotCollection.DeleteOne(timeoutCtx, bson.M{"message_id": messageID, "user_id": userID})
+	return err
+}
+
+// AddPollVotes counts a ballot in the tallies of its poll, taking back the votes for the retracted options of the
+// ballot it replaced, and returns the updated message. The voter is added to each newly chosen option and removed
+// from each dropped one when recordVoter is set. Polls that have closed or expired by the ballot's cast time, and
+// hidden polls, yield mongo.ErrNoDocuments.
+func (r *MessageRepoImpl) AddPollVotes(ctx context.Context, ballot *Ballot, retracted []string, recordVoter bool) (*Message, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":    ballot.MessageID,
+		"kind":   KindPoll,
+		"hidden": notHidden,
+		"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"poll.closes_at": bson.M{"$exists": false}},
+				bson.M{"poll.closes_at": bson.M{"$gt": ballot.CastAt}},
+			}},
+			bson.M{"$or": notExpired(ballot.CastAt)},
+		},
+	}
+	chosen, dropped := ballotChanges(ballot.OptionIDs, retracted)
+	if len(chosen) == 0 && len(dropped) == 0 {
+		var msg Message
+		if err := r.messageCollection.FindOne(timeoutCtx, filter).Decode(&msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+
+	// Each array filter must be used by the update, so only the non-empty sides of the change are included.
+	inc, push, pull := bson.M{}, bson.M{}, bson.M{}
+	var arrayFilters []interface{}
+	if len(chosen) > 0 {
+		inc["poll.options.$[chosen].votes"] = 1
+		push["poll.options.$[chosen].voters"] = ballot.UserID
+		arrayFilters = append(arrayFilters, bson.M{"chosen.id": bson.M{"$in": chosen}})
+	}
+	if len(dropped) > 0 {
+		inc["poll.options.$[dropped].votes"] = -1
+		pull["poll.options.$[dropped].voters"] = ballot.UserID
+		arrayFilters = append(arrayFilters, bson.M{"dropped.id": bson.M{"$in": dropped}})
+	}
+	update := bson.M{"$inc": inc}
+	if recordVoter && len(push) > 0 {
+		update["$push"] = push
+	}
+	if recordVoter && len(pull) > 0 {
+		update["$pull"] = pull
+	}
+	opts := options.FindOneAndUpdate().
+		SetArrayFilters(options.ArrayFilters{Filters: arrayFilters}).
+		SetReturnDocument(options.After)
+
+	var updated Message
+	if err := r.messageCollection.FindOneAndUpdate(timeoutCtx, filter, update, opts).Decode(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// ballotChanges returns the options a ballot chooses that the ballot it replaced did not, and the options the
+// replaced ballot chose that the new one drops.
+func ballotChanges(optionIDs []string, retracted []string) (chosen []string, dropped []string) {
+	for _, id := range optionIDs {
+		if !slices.Contains(retracted, id) {
+			chosen = append(chosen, id)
+		}
+	}
+	for _, id := range retracted {
+		if !slices.Contains(optionIDs, id) {
+			dropped = append(dropped, id)
+		}
+	}
+	return chosen, dropped
+}
+
+// SetHidden hides a message from room history, or shows it again, and returns the updated message.
+func (r *MessageRepoImpl) SetHidden(ctx context.Context, id primitive.ObjectID, hidden bool) (*Message, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"hidden": true}}
+	if !hidden {
+		update = bson.M{"$unset": bson.M{"hidden": ""}}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated Message
+	if err := r.messageCollection.FindOneAndUpdate(timeoutCtx, bson.M{"_id": id}, update, opts).Decode(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// EnsureExpiryIndex creates a TTL index that has MongoDB remove expired messages grace after they expire, as a
+// backstop for the expiry sweeper, which normally removes them first so that their expiry can be announced.
+func (r *MessageRepoImpl) EnsureExpiryIndex(ctx context.Context, grace time.Duration) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := r.messageCollection.Indexes().CreateOne(timeoutCtx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(int32(grace.Seconds())),
+	})
+	return err
+}
+
+// bySeq sorts messages in room order. Messages from before sequencing have no seq and sort first, by ID.
+var bySeq = bson.D{{Key: "seq", Value: 1}, {Key: "_id", Value: 1}}
+
+// notHidden matches the messages not held back for review. Room history leaves hidden messages out; lookups by ID
+// still find them, so that moderators can review them.
+var notHidden = bson.M{"$ne": true}
+
+// notExpired returns the alternatives of an $or filter matching the messages that have not expired at now.
+func notExpired(now time.Time) bson.A {
+	return bson.A{
+		bson.M{"expires_at": bson.M{"$exists": false}},
+		bson.M{"expires_at": bson.M{"$gt": now}},
+	}
+}
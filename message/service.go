@@ -3,36 +3,233 @@ package message
 import (
 	"context"
 	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"log"
+	"messages-go/attachment"
+	"messages-go/audit"
+	"messages-go/message/filter"
 	"messages-go/models/errormodel"
+	"messages-go/ratelimit"
 	"messages-go/room"
+	"messages-go/user"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// maxClientMsgIDSize bounds the length of the idempotency keys chosen by clients.
+const maxClientMsgIDSize = 128
+
 type MessageService interface {
 	PostMessage(ctx context.Context, msg *Message) (*Message, error)
 	GetMessages(ctx context.Context, roomId string) ([]Message, error)
+	GetMessagesAfter(ctx context.Context, roomId string, after string) ([]Message, error)
+	GetMessagePage(ctx context.Context, roomId string, afterSeq int64, beforeSeq int64, limit int) ([]Message, error)
+	GetMessage(ctx context.Context, roomId string, id string) (*Message, error)
+	GetMessagesByIDs(ctx context.Context, roomId string, ids []primitive.ObjectID) ([]Message, error)
+	DeleteMessage(ctx context.Context, roomId string, id string, callerId string) (*Message, error)
+	SetPreviews(ctx context.Context, id primitive.ObjectID, previews []Preview) (*Message, error)
+	AddPostObserver(observer PostObserver)
+	PruneMessages(ctx context.Context, roomId string, ids []primitive.ObjectID) (int64, error)
+	Vote(ctx context.Context, id string, callerId string, optionIds []string) (*Message, error)
+	AddDeleteObserver(observer DeleteObserver)
+	AddHideObserver(observer HideObserver)
+	AddPostGuard(guard PostGuard)
+	SetHidden(ctx context.Context, roomId string, id string, hidden bool) (*Message, error)
+	SetCommandRouter(router CommandRouter)
+	SetAccessGuard(guard room.AccessGuard)
+}
+
+// PostObserver is notified after a message has been persisted by PostMessage.
+type PostObserver interface {
+	MessagePosted(ctx context.Context, msg *Message)
+}
+
+// PostGuard may veto a message before PostMessage stores it, such as one from a sender muted in the room.
+type PostGuard interface {
+	CheckPost(ctx context.Context, roomID string, senderID string) error
+}
+
+// CommandRouter handles the commands of messages, such as /me, after PostMessage has vetted the message and charged
+// it to the sender's flood control and before it stores the message, so that commands only take effect for messages
+// that would be posted. It returns the message to post in place of msg: msg itself, possibly rewritten, a reply on
+// the sender's behalf, or an Ephemeral reply that PostMessage returns to the sender without storing it.
+type CommandRouter interface {
+	RouteCommand(ctx context.Context, msg *Message, roomData *room.Room) (*Message, error)
+}
+
+// DeleteObserver is notified after messages of a room have been removed.
+type DeleteObserver interface {
+	MessagesDeleted(ctx context.Context, roomID string, ids []primitive.ObjectID)
+}
+
+// HideObserver is notified after a message has been hidden pending review or shown again.
+type HideObserver interface {
+	MessageHidden(ctx context.Context, msg *Message)
+}
+
+// Config holds the limits applied to posted messages.
+type Config struct {
+	// MaxTTL bounds how long after posting an ephemeral message may expire.
+	MaxTTL time.Duration
+	// Filters builds the content filter chain of each room; messages are not filtered without it.
+	Filters *filter.Registry
 }
 
 type MessageServiceImpl struct {
-	messageRepo MessageRepo
-	roomRepo    room.RoomRepo
+	messageRepo    MessageRepo
+	roomRepo       room.RoomRepo
+	attachmentRepo attachment.AttachmentRepo
+	userRepo       user.UserRepo
+	observers      []PostObserver
+	// deleteObservers are notified of removed messages
+	deleteObservers []DeleteObserver
+	// hideObservers are notified of messages hidden or shown again
+	hideObservers []HideObserver
+	// guards may veto messages before they are posted
+	guards []PostGuard
+	// floodStore keeps the buckets of room slow modes
+	floodStore ratelimit.Store
+	// auditor records messages deleted on request in the audit log
+	auditor audit.Recorder
+	// commands routes the commands of posted messages; messages are posted as they are without it
+	commands CommandRouter
+	// accessGuard, when set, vets senders reading the messages they forward or quote, and voters
+	accessGuard room.AccessGuard
+	cfg         Config
+	now         func() time.Time
 }
 
-func NewMessageService(messageRepo MessageRepo, roomRepo room.RoomRepo) *MessageServiceImpl {
+func NewMessageService(messageRepo MessageRepo, roomRepo room.RoomRepo, attachmentRepo attachment.AttachmentRepo, userRepo user.UserRepo, floodStore ratelimit.Store, auditor audit.Recorder, cfg Config) *MessageServiceImpl {
 	return &MessageServiceImpl{
-		messageRepo: messageRepo,
-		roomRepo:    roomRepo,
+		messageRepo:    messageRepo,
+		roomRepo:       roomRepo,
+		attachmentRepo: attachmentRepo,
+		userRepo:       userRepo,
+		floodStore:     floodStore,
+		auditor:        auditor,
+		cfg:            cfg,
+		now:            time.Now,
 	}
 }
 
+// AddPostObserver registers an observer to be notified of every message posted through the service.
+// Observers must be registered during wiring, before the service starts handling requests.
+func (ms *MessageServiceImpl) AddPostObserver(observer PostObserver) {
+	ms.observers = append(ms.observers, observer)
+}
+
+// AddDeleteObserver registers an observer to be notified of every message removed through the service.
+// Observers must be registered during wiring, before the service starts handling requests.
+func (ms *MessageServiceImpl) AddDeleteObserver(observer DeleteObserver) {
+	ms.deleteObservers = append(ms.deleteObservers, observer)
+}
+
+// AddHideObserver registers an observer to be notified of every message hidden or shown again through the
+// service. Observers must be registered during wiring, before the service starts handling requests.
+func (ms *MessageServiceImpl) AddHideObserver(observer HideObserver) {
+	ms.hideObservers = append(ms.hideObservers, observer)
+}
+
+// SetCommandRouter installs the router handling the commands of posted messages. It must be installed during
+// wiring, before the service starts handling requests.
+func (ms *MessageServiceImpl) SetCommandRouter(router CommandRouter) {
+	ms.commands = router
+}
+
+// SetAccessGuard installs the guard vetting senders reading the messages they forward or quote and users voting in
+// polls, such as a user banned from the room. It must be installed during wiring, before the service starts
+// handling requests.
+func (ms *MessageServiceImpl) SetAccessGuard(guard room.AccessGuard) {
+	ms.accessGuard = guard
+}
+
+// AddPostGuard registers a guard to vet every message before it is posted. Guards must be registered during
+// wiring, before the service starts handling requests.
+func (ms *MessageServiceImpl) AddPostGuard(guard PostGuard) {
+	ms.guards = append(ms.guards, guard)
+}
+
 func (ms *MessageServiceImpl) PostMessage(ctx context.Context, msg *Message) (*Message, error) {
-	_, err := ms.roomRepo.GetRoomByID(ctx, msg.RoomID)
+	roomData, err := ms.roomRepo.GetRoomByID(ctx, msg.RoomID)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	// Integrations were authorized for the room by whoever set them up.
+	if roomData.Private && msg.Integration == nil && !roomData.IsMember(msg.SenderID) {
+		return nil, errormodel.ErrForbidden
+	}
+	msg.ClientMsgID = strings.TrimSpace(msg.ClientMsgID)
+	if len(msg.ClientMsgID) > maxClientMsgIDSize {
+		return nil, errormodel.ErrInvalidClientMsgID
+	}
+	// Retries are answered before validation, which would reject the attachments already bound to the original.
+	if original, err := ms.findRetried(ctx, msg); err != nil || original != nil {
+		return original, err
+	}
+	for _, guard := range ms.guards {
+		if err := guard.CheckPost(ctx, msg.RoomID, msg.SenderID); err != nil {
+			return nil, err
+		}
+	}
+	if err := ms.vet(ctx, msg, roomData); err != nil {
+		return nil, err
+	}
+	issued := msg
+	if ms.commands != nil {
+		routed, err := ms.commands.RouteCommand(ctx, msg, roomData)
+		if err != nil {
+			return nil, err
+		}
+		if routed.Ephemeral {
+			return routed, nil
+		}
+		if routed != msg {
+			// A reply on the sender's behalf, vetted like the message it replaces apart from flood control.
+			if err := ms.vetReply(routed, roomData); err != nil {
+				return nil, err
+			}
+		}
+		msg = routed
+	}
+	// Commands may have rewritten the body.
+	if err := msg.render(); err != nil {
+		return nil, err
+	}
+	if err := ms.resolveMentions(ctx, msg, roomData); err != nil {
+		return nil, err
 	}
 	log.Println("Posting Message: ", msg)
-	return ms.messageRepo.PostMessage(ctx, msg)
+	posted, err := ms.messageRepo.PostMessage(ctx, msg)
+	if mongo.IsDuplicateKeyError(err) && msg.ClientMsgID != "" {
+		// A concurrent retry got there first, looked up as the sender issuing the message even for command replies
+		if original, findErr := ms.findRetried(ctx, issued); findErr != nil || original != nil {
+			return original, findErr
+		}
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+	if len(posted.AttachmentIDs) > 0 {
+		bound, err := ms.attachmentRepo.BindToMessage(ctx, posted.AttachmentIDs, posted.ID.Hex())
+		if err != nil {
+			return nil, err
+		} else if bound != int64(len(posted.AttachmentIDs)) {
+			log.Printf("Message %s bound %d of %d attachments", posted.ID.Hex(), bound, len(posted.AttachmentIDs))
+		}
+		for i := range posted.Attachments {
+			posted.Attachments[i].MessageID = posted.ID.Hex()
+		}
+	}
+	for _, observer := range ms.observers {
+		observer.MessagePosted(ctx, posted)
+	}
+	return posted, nil
 }
 
 func (ms *MessageServiceImpl) GetMessages(ctx context.Context, roomId string) ([]Message, error) {
@@ -44,6 +241,497 @@ func (ms *MessageServiceImpl) GetMessages(ctx context.Context, roomId string) ([
 	messageList, err := ms.messageRepo.GetMessagesByRoomId(ctx, roomData.ID)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, errormodel.ErrMessagesNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return messageList, ms.resolveAttachments(ctx, messageList)
+}
+
+// GetMessagesAfter returns the messages of a room posted after a cursor, which is either a sequence number or,
+// for clients that tracked messages before sequencing, a message ID. An empty cursor only validates the room and
+// yields no messages.
+func (ms *MessageServiceImpl) GetMessagesAfter(ctx context.Context, roomId string, after string) ([]Message, error) {
+	roomData, err := ms.roomRepo.GetRoomByID(ctx, roomId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if after == "" {
+		return []Message{}, nil
+	}
+
+	afterSeq, err := ms.parseCursor(ctx, roomId, after)
+	if err != nil {
+		return nil, err
+	}
+	var messageList []Message
+	if afterSeq >= 0 {
+		messageList, err = ms.messageRepo.GetMessagesBySeq(ctx, roomId, afterSeq, 0, 0)
+	} else {
+		afterOID, _ := primitive.ObjectIDFromHex(after)
+		messageList, err = ms.messageRepo.GetMessagesAfter(ctx, roomData.ID, afterOID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return messageList, ms.resolveAttachments(ctx, messageList)
+}
+
+// parseCursor returns the sequence number a cursor stands for: the number itself, or the sequence number of the
+// message it identifies. It is -1 for messages from before sequencing, which are paged through by ID, and for
+// messages that no longer exist.
+func (ms *MessageServiceImpl) parseCursor(ctx context.Context, roomId string, cursor string) (int64, error) {
+	if seq, err := strconv.ParseInt(cursor, 10, 64); err == nil {
+		if seq < 0 {
+			return 0, errormodel.ErrInvalidCursor
+		}
+		return seq, nil
+	}
+	oid, err := primitive.ObjectIDFromHex(cursor)
+	if err != nil {
+		return 0, errormodel.ErrInvalidCursor
+	}
+	found, err := ms.messageRepo.GetMessagesByIDs(ctx, []primitive.ObjectID{oid})
+	if err != nil {
+		return 0, err
+	}
+	if len(found) == 0 || found[0].RoomID != roomId || found[0].Seq == 0 {
+		return -1, nil
+	}
+	return found[0].Seq, nil
+}
+
+// GetMessagePage returns at most limit messages of a room, oldest first, with sequence numbers between afterSeq
+// and beforeSeq, exclusive. A zero beforeSeq leaves the page open-ended; giving only beforeSeq pages backwards.
+func (ms *MessageServiceImpl) GetMessagePage(ctx context.Context, roomId string, afterSeq int64, beforeSeq int64, limit int) ([]Message, error) {
+	if afterSeq < 0 || beforeSeq < 0 || limit < 1 {
+		return nil, errormodel.ErrInvalidCursor
+	}
+	if _, err := ms.roomRepo.GetRoomByID(ctx, roomId); errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	messageList, err := ms.messageRepo.GetMessagesBySeq(ctx, roomId, afterSeq, beforeSeq, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+	if messageList == nil {
+		messageList = []Message{}
+	}
+	return messageList, ms.resolveAttachments(ctx, messageList)
+}
+
+// GetMessage returns a message of the given room with its attachments resolved.
+func (ms *MessageServiceImpl) GetMessage(ctx context.Context, roomId string, id string) (*Message, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrInvalidMessageID
+	}
+	messages, err := ms.GetMessagesByIDs(ctx, roomId, []primitive.ObjectID{oid})
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, errormodel.ErrMessageNotFound
+	}
+	return &messages[0], nil
+}
+
+// GetMessagesByIDs returns the messages with the given IDs that belong to the given room, oldest first, with
+// their attachments resolved.
+func (ms *MessageServiceImpl) GetMessagesByIDs(ctx context.Context, roomId string, ids []primitive.ObjectID) ([]Message, error) {
+	if len(ids) == 0 {
+		return []Message{}, nil
+	}
+	found, err := ms.messageRepo.GetMessagesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	messageList := make([]Message, 0, len(found))
+	for _, msg := range found {
+		if msg.RoomID == roomId {
+			messageList = append(messageList, msg)
+		}
+	}
+	return messageList, ms.resolveAttachments(ctx, messageList)
+}
+
+// DeleteMessage removes a message of a room and returns it. Senders may delete their own messages; moderators may
+// delete any message of their room.
+func (ms *MessageServiceImpl) DeleteMessage(ctx context.Context, roomId string, id string, callerId string) (*Message, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	roomData, err := ms.roomRepo.GetRoomByID(ctx, roomId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !roomData.VisibleTo(callerId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	msg, err := ms.GetMessage(ctx, roomId, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != callerId && !roomData.IsModerator(callerId) {
+		return nil, errormodel.ErrForbidden
+	}
+
+	if err := ms.messageRepo.DeleteMessage(ctx, msg.ID); errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrMessageNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	ms.notifyDeleted(ctx, roomId, []primitive.ObjectID{msg.ID})
+	ms.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionMessageDeleted,
+		ActorID:    callerId,
+		TargetType: audit.TargetMessage,
+		TargetID:   msg.ID.Hex(),
+		RoomID:     roomId,
+		Before:     map[string]interface{}{"sender_id": msg.SenderID, "body": msg.Body},
+	})
+	return msg, nil
+}
+
+// DeleteExpired removes up to limit messages that have expired at now, notifies the delete observers, and returns
+// the removed messages so that their expiry can be announced.
+func (ms *MessageServiceImpl) DeleteExpired(ctx context.Context, now time.Time, limit int64) ([]Message, error) {
+	expired, err := ms.messageRepo.GetExpiredMessages(ctx, now, limit)
+	if err != nil || len(expired) == 0 {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(expired))
+	byRoom := make(map[string][]primitive.ObjectID)
+	for _, msg := range expired {
+		ids = append(ids, msg.ID)
+		byRoom[msg.RoomID] = append(byRoom[msg.RoomID], msg.ID)
+	}
+	if _, err := ms.messageRepo.DeleteMessages(ctx, ids); err != nil {
+		return nil, err
+	}
+	for roomId, roomIds := range byRoom {
+		ms.notifyDeleted(ctx, roomId, roomIds)
+	}
+	return expired, nil
+}
+
+// PruneMessages removes messages of a room on behalf of a background job, notifies the delete observers, and
+// returns how many were removed. Nothing is broadcast; clients drop pruned history on their next fetch.
+func (ms *MessageServiceImpl) PruneMessages(ctx context.Context, roomId string, ids []primitive.ObjectID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	removed, err := ms.messageRepo.DeleteMessages(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	ms.notifyDeleted(ctx, roomId, ids)
+	return removed, nil
+}
+
+// notifyDeleted reports removed messages to the delete observers.
+func (ms *MessageServiceImpl) notifyDeleted(ctx context.Context, roomId string, ids []primitive.ObjectID) {
+	for _, observer := range ms.deleteObservers {
+		observer.MessagesDeleted(ctx, roomId, ids)
+	}
+}
+
+// SetPreviews records the link previews unfurled for a message and returns the message with its attachments
+// resolved, ready to be broadcast.
+func (ms *MessageServiceImpl) SetPreviews(ctx context.Context, id primitive.ObjectID, previews []Preview) (*Message, error) {
+	updated, err := ms.messageRepo.SetPreviews(ctx, id, previews)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrMessagesNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	messages := []Message{*updated}
+	if err := ms.resolveAttachments(ctx, messages); err != nil {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+// SetHidden hides a message of a room from its history pending review, or shows it again, notifies the hide
+// observers, and returns the message with its attachments resolved. Callers are trusted to have checked the caller
+// may moderate the room.
+func (ms *MessageServiceImpl) SetHidden(ctx context.Context, roomId string, id string, hidden bool) (*Message, error) {
+	msg, err := ms.GetMessage(ctx, roomId, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Hidden == hidden {
+		return msg, nil
+	}
+	updated, err := ms.messageRepo.SetHidden(ctx, msg.ID, hidden)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrMessageNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	for _, observer := range ms.hideObservers {
+		observer.MessageHidden(ctx, updated)
+	}
+	messages := []Message{*updated}
+	if err := ms.resolveAttachments(ctx, messages); err != nil {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+// applyTTL sets when the message expires, from its own TTL or else the room's. Expiry times sent by the client are
+// ignored.
+func (ms *MessageServiceImpl) applyTTL(msg *Message, roomData *room.Room) error {
+	msg.ExpiresAt = nil
+	ttl := time.Duration(msg.TTL) * time.Second
+	if ttl < 0 || (ms.cfg.MaxTTL > 0 && ttl > ms.cfg.MaxTTL) {
+		return errormodel.ErrInvalidTTL
+	}
+	if ttl == 0 {
+		ttl = time.Duration(roomData.Settings.MessageTTLSeconds) * time.Second
+	}
+	if ttl > 0 {
+		expiresAt := ms.now().Add(ttl).UTC()
+		msg.ExpiresAt = &expiresAt
+	}
+	return nil
+}
+
+// validateAttachments checks that every attachment referenced by a message exists, was uploaded to the same room
+// by the sender, and is not already part of another message.
+func (ms *MessageServiceImpl) validateAttachments(ctx context.Context, msg *Message) error {
+	msg.Attachments = nil
+	if len(msg.AttachmentIDs) == 0 {
+		return nil
+	}
+	slices.Sort(msg.AttachmentIDs)
+	msg.AttachmentIDs = slices.Compact(msg.AttachmentIDs)
+
+	attachments, err := ms.attachmentRepo.GetAttachmentsByIDs(ctx, msg.AttachmentIDs)
+	if err != nil {
+		return err
+	}
+	if len(attachments) != len(msg.AttachmentIDs) {
+		return errormodel.ErrInvalidAttachment
+	}
+	for _, a := range attachments {
+		if a.RoomID != msg.RoomID || a.UploaderID != msg.SenderID || a.MessageID != "" {
+			return errormodel.ErrInvalidAttachment
+		}
+	}
+	msg.Attachments = attachments
+	return nil
+}
+
+// resolveAttachments fills in the attachments referenced by each message with a single lookup.
+func (ms *MessageServiceImpl) resolveAttachments(ctx context.Context, messages []Message) error {
+	var ids []string
+	for _, msg := range messages {
+		ids = append(ids, msg.AttachmentIDs...)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	attachments, err := ms.attachmentRepo.GetAttachmentsByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]attachment.Attachment, len(attachments))
+	for _, a := range attachments {
+		byID[a.ID.Hex()] = a
+	}
+	for i := range messages {
+		messages[i].Attachments = nil
+		for _, id := range messages[i].AttachmentIDs {
+			if a, ok := byID[id]; ok {
+				messages[i].Attachments = append(messages[i].Attachments, a)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveMentions parses the mentions in the message body and resolves mentioned usernames to user IDs, flagging
+// users who are not members of the room. Unknown usernames are not treated as mentions.
+func (ms *MessageServiceImpl) resolveMentions(ctx context.Context, msg *Message, roomData *room.Room) error {
+	msg.Mentions = nil
+	usernames, roomMention, hereMention := ParseMentions(msg.Body)
+	if roomMention {
+		msg.Mentions = append(msg.Mentions, Mention{Kind: MentionRoom})
+	}
+	if hereMention {
+		msg.Mentions = append(msg.Mentions, Mention{Kind: MentionHere})
+	}
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	users, err := ms.userRepo.GetUsersByUsernames(ctx, usernames)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]user.User, len(users))
+	for _, u := range users {
+		byName[u.Username] = u
+	}
+	for _, name := range usernames {
+		u, ok := byName[name]
+		if !ok {
+			continue
+		}
+		userId := u.ID.Hex()
+		msg.Mentions = append(msg.Mentions, Mention{
+			Kind:      MentionUser,
+			Username:  u.Username,
+			UserID:    userId,
+			NotMember: !roomData.IsMember(userId),
+		})
+	}
+	return nil
+}
+
+// vet checks a message about to be posted and prepares it for storage, then charges it to the room's flood
+// control. Everything that can reject the message runs here, before any command it issues takes effect.
+func (ms *MessageServiceImpl) vet(ctx context.Context, msg *Message, roomData *room.Room) error {
+	// Previews are only ever set by the unfurler, only the command router makes replies ephemeral, and only
+	// SetHidden and the filters hide or flag messages
+	msg.Previews = nil
+	msg.Ephemeral = false
+	msg.Hidden, msg.Flagged = false, false
+	if err := msg.preparePoll(ms.now()); err != nil {
+		return err
+	}
+	if err := msg.prepareIntegration(); err != nil {
+		return err
+	}
+	if err := ms.applyFilters(msg, roomData); err != nil {
+		return err
+	}
+	if err := msg.render(); err != nil {
+		return err
+	}
+	if err := ms.applyTTL(msg, roomData); err != nil {
+		return err
+	}
+	if err := ms.resolveReference(ctx, msg, roomData); err != nil {
+		return err
+	}
+	if err := ms.validateAttachments(ctx, msg); err != nil {
+		return err
+	}
+	return ms.checkFlood(ctx, msg, roomData)
+}
+
+// vetReply checks the reply posted in place of a command and prepares it for storage.
+func (ms *MessageServiceImpl) vetReply(reply *Message, roomData *room.Room) error {
+	reply.Previews = nil
+	if err := reply.prepareIntegration(); err != nil {
+		return err
+	}
+	if err := ms.applyFilters(reply, roomData); err != nil {
+		return err
+	}
+	return ms.applyTTL(reply, roomData)
+}
+
+// findRetried returns the message the sender already posted with the client message ID of msg, or the bot reply
+// to the sender's command posted with it, marked as replayed, or nil when msg has no client message ID or it has
+// not been used.
+func (ms *MessageServiceImpl) findRetried(ctx context.Context, msg *Message) (*Message, error) {
+	if msg.ClientMsgID == "" {
+		return nil, nil
+	}
+	original, err := ms.messageRepo.GetMessageByClientMsgID(ctx, msg.SenderID, msg.ClientMsgID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	messages := []Message{*original}
+	if err := ms.resolveAttachments(ctx, messages); err != nil {
+		return nil, err
+	}
+	messages[0].replayed = true
+	return &messages[0], nil
+}
+
+// checkFlood enforces the slow mode and duplicate suppression of a room on a message about to be posted, which
+// is rejected with a RetryError saying how long to wait. Moderators are exempt. Repeated messages are rejected
+// before slow mode counts them.
+func (ms *MessageServiceImpl) checkFlood(ctx context.Context, msg *Message, roomData *room.Room) error {
+	if roomData.IsModerator(msg.SenderID) {
+		return nil
+	}
+	settings := roomData.Settings
+	now := ms.now()
+
+	if settings.DuplicateWindowSeconds > 0 && msg.Body != "" {
+		window := time.Duration(settings.DuplicateWindowSeconds) * time.Second
+		duplicate, err := ms.messageRepo.GetRecentDuplicate(ctx, msg.RoomID, msg.SenderID, msg.Body, now.Add(-window))
+		if err == nil {
+			return &errormodel.RetryError{Err: errormodel.ErrDuplicateMessage, RetryAfter: duplicate.CreatedAt.Add(window).Sub(now)}
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+	}
+
+	if settings.SlowModeSeconds > 0 && ms.floodStore != nil {
+		burst := max(1, settings.SlowModeBurst)
+		limit := ratelimit.Limit{Burst: burst, Per: time.Duration(settings.SlowModeSeconds*burst) * time.Second}
+		decision, err := ms.floodStore.Take(ctx, "slow_mode:"+msg.RoomID+":"+msg.SenderID, limit)
+		if err != nil {
+			log.Printf("Slow mode store failed for room %s, allowing post: %v", msg.RoomID, err)
+		} else if !decision.Allowed {
+			return &errormodel.RetryError{Err: errormodel.ErrSlowMode, RetryAfter: decision.RetryAfter}
+		}
+	}
+	return nil
+}
+
+// applyFilters runs the text of a message about to be posted through the room's content filters: its body, the
+// question and options of its poll and the titles and text of its embeds. The rewritten text is kept and the
+// decisions are recorded on the message. Rejected messages fail with the rejecting filter's reason.
+func (ms *MessageServiceImpl) applyFilters(msg *Message, roomData *room.Room) error {
+	msg.FilterDecisions, msg.Flagged = nil, false
+	if ms.cfg.Filters == nil {
+		return nil
+	}
+	chain, err := ms.cfg.Filters.Chain(roomData.Settings.Filters, roomData.Settings.FilterWords)
+	if err != nil {
+		return err
+	}
+	fields := []*string{&msg.Body}
+	if msg.Poll != nil {
+		fields = append(fields, &msg.Poll.Question)
+		for i := range msg.Poll.Options {
+			fields = append(fields, &msg.Poll.Options[i].Text)
+		}
+	}
+	for i := range msg.Embeds {
+		fields = append(fields, &msg.Embeds[i].Title, &msg.Embeds[i].Text)
+	}
+	for _, field := range fields {
+		if *field == "" {
+			continue
+		}
+		outcome := chain.Run(*field)
+		if outcome.Rejected != nil {
+			return fmt.Errorf("%w: %s", errormodel.ErrMessageRejected, outcome.Rejected.Reason)
+		}
+		*field = outcome.Body
+		msg.Flagged = msg.Flagged || outcome.Flagged
+		for _, decision := range outcome.Decisions {
+			if !slices.Contains(msg.FilterDecisions, decision) {
+				msg.FilterDecisions = append(msg.FilterDecisions, decision)
+			}
+		}
 	}
-	return messageList, err
+	return nil
 }
@@ -0,0 +1,211 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/message/filter"
+	"messages-go/models/errormodel"
+	"messages-go/ratelimit"
+	"messages-go/room"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryMessages is a MessageRepo keeping messages in memory, implementing the queries the tests need the way
+// MessageRepoImpl does.
+type memoryMessages struct {
+	MessageRepo
+	messages []Message
+}
+
+func (r *memoryMessages) GetRecentDuplicate(_ context.Context, roomID string, senderID string, body string, since time.Time) (*Message, error) {
+	var latest *Message
+	for i, msg := range r.messages {
+		if msg.RoomID == roomID && msg.SenderID == senderID && msg.Body == body && msg.CreatedAt.After(since) &&
+			(latest == nil || msg.CreatedAt.After(latest.CreatedAt)) {
+			latest = &r.messages[i]
+		}
+	}
+	if latest == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	found := *latest
+	return &found, nil
+}
+
+// stubFloodStore answers every Take with the same decision and records the keys and limits it was asked for.
+type stubFloodStore struct {
+	decision ratelimit.Decision
+	err      error
+	keys     []string
+	limits   []ratelimit.Limit
+}
+
+func (s *stubFloodStore) Take(_ context.Context, key string, limit ratelimit.Limit) (ratelimit.Decision, error) {
+	s.keys = append(s.keys, key)
+	s.limits = append(s.limits, limit)
+	return s.decision, s.err
+}
+
+func (s *stubFloodStore) Refund(context.Context, string, ratelimit.Limit) error {
+	return nil
+}
+
+// testClock is a settable clock for services.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func TestApplyFilters(t *testing.T) {
+	ms := &MessageServiceImpl{cfg: Config{Filters: filter.NewRegistry(filter.Config{
+		Words:        []string{"darn"},
+		MaxLength:    40,
+		AllowedHosts: []string{"example.com"},
+	})}}
+	roomData := &room.Room{Settings: room.Settings{Filters: []string{filter.NameProfanity, filter.NameLinks, filter.NameMaxLength}}}
+
+	msg := &Message{
+		Body: "darn, see https://other.net",
+		Poll: &Poll{Question: "darn?", Options: []PollOption{{ID: "a", Text: "yes"}, {ID: "b", Text: "darn no"}}},
+		// Stale results from the client or an earlier pass are replaced.
+		FilterDecisions: []filter.Decision{{Filter: "forged", Action: filter.Allow}},
+	}
+	if err := ms.applyFilters(msg, roomData); err != nil {
+		t.Fatalf("applyFilters: %v", err)
+	}
+	if msg.Body != "****, see https://other.net" || msg.Poll.Question != "****?" || msg.Poll.Options[0].Text != "yes" || msg.Poll.Options[1].Text != "**** no" {
+		t.Errorf("filtered text = %q, %q, %+v", msg.Body, msg.Poll.Question, msg.Poll.Options)
+	}
+	// Fields masked for the same reason record the decision once.
+	want := []filter.Decision{
+		{Filter: filter.NameProfanity, Action: filter.Modify, Reason: "masked 1 words"},
+		{Filter: filter.NameLinks, Action: filter.Flag, Reason: "links to unlisted hosts other.net"},
+	}
+	if !reflect.DeepEqual(msg.FilterDecisions, want) {
+		t.Errorf("FilterDecisions = %+v, want %+v", msg.FilterDecisions, want)
+	}
+	if !msg.Flagged {
+		t.Error("a flag on the body was not kept")
+	}
+
+	// A later field that is not flagged does not clear the flag of an earlier one, and a clean pass clears both.
+	msg = &Message{Body: "https://other.net", Poll: &Poll{Question: "ok", Options: []PollOption{{ID: "a", Text: "fine"}}}, Flagged: true}
+	if err := ms.applyFilters(msg, roomData); err != nil || !msg.Flagged {
+		t.Errorf("applyFilters = %v, Flagged = %v; want flagged", err, msg.Flagged)
+	}
+	msg = &Message{Body: "hello", Flagged: true, FilterDecisions: want}
+	if err := ms.applyFilters(msg, roomData); err != nil || msg.Flagged || msg.FilterDecisions != nil {
+		t.Errorf("applyFilters = %v, Flagged = %v, FilterDecisions = %+v; want a clean message", err, msg.Flagged, msg.FilterDecisions)
+	}
+
+	msg = &Message{Body: "fine", Poll: &Poll{Question: strings.Repeat("?", 41)}}
+	if err := ms.applyFilters(msg, roomData); !errors.Is(err, errormodel.ErrMessageRejected) || !strings.Contains(err.Error(), "the limit is 40") {
+		t.Errorf("applyFilters of a long poll question = %v, want a rejection with the filter's reason", err)
+	}
+
+	ms.cfg.Filters = nil
+	msg = &Message{Body: "darn", Flagged: true, FilterDecisions: want}
+	if err := ms.applyFilters(msg, roomData); err != nil || msg.Body != "darn" || msg.Flagged || msg.FilterDecisions != nil {
+		t.Errorf("applyFilters without filters = %v, %+v; want the body kept and the marks cleared", err, msg)
+	}
+}
+
+func TestCheckFloodDuplicateWindow(t *testing.T) {
+	clock := newTestClock()
+	repo := &memoryMessages{}
+	ms := &MessageServiceImpl{messageRepo: repo, now: clock.Now}
+	roomData := &room.Room{OwnerID: "owner", Settings: room.Settings{DuplicateWindowSeconds: 30}}
+	ctx := context.Background()
+	post := func(senderID string, body string) error {
+		msg := &Message{RoomID: "r1", SenderID: senderID, Body: body}
+		if err := ms.checkFlood(ctx, msg, roomData); err != nil {
+			return err
+		}
+		msg.CreatedAt = clock.now
+		repo.messages = append(repo.messages, *msg)
+		return nil
+	}
+
+	if err := post("alice", "hello"); err != nil {
+		t.Fatalf("first post: %v", err)
+	}
+	clock.now = clock.now.Add(10 * time.Second)
+	err := post("alice", "hello")
+	var retry *errormodel.RetryError
+	if !errors.As(err, &retry) || !errors.Is(err, errormodel.ErrDuplicateMessage) {
+		t.Fatalf("duplicate within the window = %v, want a RetryError for ErrDuplicateMessage", err)
+	}
+	if retry.RetryAfter != 20*time.Second {
+		t.Errorf("RetryAfter = %v, want the 20s left of the window", retry.RetryAfter)
+	}
+
+	// Other bodies, senders and moderators are not duplicates.
+	for _, p := range []struct{ sender, body string }{{"alice", "hello again"}, {"bob", "hello"}, {"owner", "hello"}, {"owner", "hello"}} {
+		if err := post(p.sender, p.body); err != nil {
+			t.Errorf("post of %q by %s = %v, want it allowed", p.body, p.sender, err)
+		}
+	}
+
+	clock.now = clock.now.Add(19 * time.Second)
+	if err := post("alice", "hello"); !errors.As(err, &retry) || retry.RetryAfter != time.Second {
+		t.Errorf("duplicate a second before the window ends = %v, want a retry in 1s", err)
+	}
+	clock.now = clock.now.Add(time.Second)
+	if err := post("alice", "hello"); err != nil {
+		t.Errorf("repeat once the window has passed = %v, want it allowed", err)
+	}
+	// The window restarts from the latest copy.
+	clock.now = clock.now.Add(5 * time.Second)
+	if err := post("alice", "hello"); !errors.As(err, &retry) || retry.RetryAfter != 25*time.Second {
+		t.Errorf("duplicate of the repeat = %v, want a retry in 25s", err)
+	}
+
+	roomData.Settings.DuplicateWindowSeconds = 0
+	if err := post("alice", "hello"); err != nil {
+		t.Errorf("duplicate without a window = %v, want it allowed", err)
+	}
+}
+
+func TestCheckFloodSlowMode(t *testing.T) {
+	store := &stubFloodStore{decision: ratelimit.Decision{RetryAfter: 7 * time.Second}}
+	ms := &MessageServiceImpl{messageRepo: &memoryMessages{}, floodStore: store, now: newTestClock().Now}
+	roomData := &room.Room{OwnerID: "owner", Moderators: []string{"mod"}, Settings: room.Settings{SlowModeSeconds: 10, SlowModeBurst: 3, DuplicateWindowSeconds: 30}}
+	ctx := context.Background()
+
+	err := ms.checkFlood(ctx, &Message{RoomID: "r1", SenderID: "alice", Body: "hi"}, roomData)
+	var retry *errormodel.RetryError
+	if !errors.As(err, &retry) || !errors.Is(err, errormodel.ErrSlowMode) || retry.RetryAfter != 7*time.Second {
+		t.Fatalf("checkFlood = %v, want a retry in 7s for ErrSlowMode", err)
+	}
+	if want := []string{"slow_mode:r1:alice"}; !reflect.DeepEqual(store.keys, want) {
+		t.Errorf("charged %q, want %q", store.keys, want)
+	}
+	if want := (ratelimit.Limit{Burst: 3, Per: 30 * time.Second}); store.limits[0] != want {
+		t.Errorf("limit = %v, want %v", store.limits[0], want)
+	}
+
+	for _, sender := range []string{"owner", "mod"} {
+		if err := ms.checkFlood(ctx, &Message{RoomID: "r1", SenderID: sender, Body: "hi"}, roomData); err != nil {
+			t.Errorf("checkFlood for %s = %v, want moderators exempt", sender, err)
+		}
+	}
+	if len(store.keys) != 1 {
+		t.Errorf("moderators were charged: %q", store.keys)
+	}
+
+	store.err = errors.New("store unavailable")
+	if err := ms.checkFlood(ctx, &Message{RoomID: "r1", SenderID: "alice", Body: "hi"}, roomData); err != nil {
+		t.Errorf("checkFlood while the store fails = %v, want the post allowed", err)
+	}
+}
@@ -0,0 +1,71 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"messages-go/models/errormodel"
+	ws "messages-go/websocket"
+	"strconv"
+)
+
+// Types of the frames clients send over a room's WebSocket connection.
+const (
+	frameTypePostMessage = "post_message"
+	// frameTypeReplay asks for the messages after a sequence number, to fill the gap left by a reconnect
+	frameTypeReplay = "replay"
+)
+
+// socketFrame is a request sent by a client over its room's WebSocket connection.
+type socketFrame struct {
+	Type     string  `json:"type"`
+	Message  Message `json:"message"`
+	AfterSeq int64   `json:"after_seq"`
+}
+
+// NewSocketReceiver returns the receiver of the frames sent over room WebSocket connections. Each post is
+// acknowledged to its sender and broadcast like posts through the REST API, except retries of an earlier post
+// with the same client message ID, which are only acknowledged. Replays are answered to the requester alone.
+func NewSocketReceiver(messageService MessageService, wsHandler *ws.Handler) ws.MessageReceiver {
+	return func(ctx context.Context, roomID string, userID string, data []byte) interface{} {
+		var frame socketFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			return newSocketErrorEvent("", errormodel.ErrInvalidFrame)
+		}
+		switch frame.Type {
+		case frameTypePostMessage:
+		case frameTypeReplay:
+			if frame.AfterSeq < 0 {
+				return newSocketErrorEvent("", errormodel.ErrInvalidCursor)
+			}
+			messages, err := messageService.GetMessagesAfter(ctx, roomID, strconv.FormatInt(frame.AfterSeq, 10))
+			if err != nil {
+				return newSocketErrorEvent("", err)
+			}
+			return newReplayEvent(roomID, frame.AfterSeq, messages)
+		default:
+			return newSocketErrorEvent("", errormodel.ErrInvalidFrame)
+		}
+		msg := frame.Message
+		if userID == "" {
+			return newSocketErrorEvent(msg.ClientMsgID, errormodel.ErrUnauthenticated)
+		}
+		msg.ID = primitive.NilObjectID
+		msg.Integration = nil
+		msg.Ephemeral = false
+		msg.Hidden, msg.Flagged = false, false
+		msg.RoomID = roomID
+		msg.SenderID = userID
+
+		posted, err := messageService.PostMessage(ctx, &msg)
+		if err != nil {
+			log.Printf("Failed to post message from %s over WebSocket: %v", userID, err)
+			return newSocketErrorEvent(msg.ClientMsgID, err)
+		}
+		if !posted.Replayed() && !posted.Ephemeral {
+			wsHandler.BroadcastToRoom(posted.RoomID, NewMessageEvent(posted))
+		}
+		return newMessageAckEvent(posted)
+	}
+}
@@ -0,0 +1,153 @@
+package message
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/response"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseKeepAliveInterval is how often a comment line is written to idle event streams
+// so that proxies do not time the connection out.
+const sseKeepAliveInterval = 15 * time.Second
+
+// streamEvent is the subset of a hub payload inspected by the HTTP streaming transports.
+type streamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		ID  string `json:"id"`
+		Seq int64  `json:"seq"`
+	} `json:"message"`
+}
+
+// StreamRoomEvents serves the events of a room as a Server-Sent Events stream, for clients that cannot
+// upgrade to WebSocket. Messages posted after the Last-Event-ID header are replayed before live events. New
+// message events carry the message's sequence number as their ID, or its message ID for messages from before
+// sequencing; other events carry no ID.
+func (mh *MessageHandlerImpl) StreamRoomEvents(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	if strings.TrimSpace(roomId) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   "Missing room id in path",
+			Status:  fiber.StatusBadRequest,
+			Message: "id Path Variable is required to be not empty.",
+		})
+	}
+	if mh.wsHandler == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(response.APIResponse{
+			Error:   "event hub unavailable",
+			Status:  fiber.StatusServiceUnavailable,
+			Message: "Room Events Are Not Available.",
+		})
+	}
+
+	log.Println("Stream Events for Room with id: ", roomId, " Request Received.")
+
+	// Subscribe before loading the backlog so nothing posted in between is lost.
+	sub := mh.wsHandler.Subscribe(roomId, auth.CallerID(c))
+	backlog, err := mh.messageService.GetMessagesAfter(c.Context(), roomId, c.Get("Last-Event-ID"))
+	if err != nil {
+		mh.wsHandler.Unsubscribe(sub)
+		if errors.Is(err, errormodel.ErrRoomNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+				Error:   err.Error(),
+				Status:  fiber.StatusNotFound,
+				Message: "No Room found given roomId.",
+			})
+		} else if errors.Is(err, errormodel.ErrInvalidCursor) {
+			return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+				Error:   err.Error(),
+				Status:  fiber.StatusBadRequest,
+				Message: "Last-Event-ID must be a sequence number or message id.",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Get Messages",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer mh.wsHandler.Unsubscribe(sub)
+
+		var lastID string
+		var lastSeq int64
+		for i := range backlog {
+			data, err := json.Marshal(NewMessageEvent(&backlog[i]))
+			if err != nil {
+				log.Printf("Error marshaling message: %v", err)
+				continue
+			}
+			lastID, lastSeq = backlog[i].ID.Hex(), max(lastSeq, backlog[i].Seq)
+			writeSSEEvent(w, eventID(backlog[i].ID.Hex(), backlog[i].Seq), data)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(sseKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case data, ok := <-sub.Send:
+				if !ok {
+					return
+				}
+				var event streamEvent
+				_ = json.Unmarshal(data, &event)
+				if event.Type != "new_message" {
+					// Other events concern messages that may predate the cursor, so they are always sent and
+					// carry no ID that would move it back.
+					writeSSEEvent(w, "", data)
+					break
+				}
+				// Messages already replayed from the backlog are not sent twice.
+				if event.Message.Seq > 0 && event.Message.Seq <= lastSeq {
+					continue
+				} else if event.Message.Seq == 0 && event.Message.ID != "" && lastID != "" && event.Message.ID <= lastID {
+					continue
+				}
+				writeSSEEvent(w, eventID(event.Message.ID, event.Message.Seq), data)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				log.Printf("Event stream for room %s closed: %v", roomId, err)
+				return
+			}
+		}
+	}))
+	return nil
+}
+
+// eventID returns the SSE event ID of a message event, which clients send back as Last-Event-ID when reconnecting.
+func eventID(messageId string, seq int64) string {
+	if seq > 0 {
+		return strconv.FormatInt(seq, 10)
+	}
+	return messageId
+}
+
+// writeSSEEvent writes a single event in text/event-stream framing. The id line is omitted when id is empty.
+func writeSSEEvent(w *bufio.Writer, id string, data []byte) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
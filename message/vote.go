@@ -0,0 +1,160 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/models/errormodel"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on the content of polls.
+const (
+	maxPollOptions      = 10
+	maxPollQuestionSize = 300
+	maxPollOptionSize   = 100
+)
+
+// preparePoll validates the kind of a message being posted and, for polls, the poll itself. Option IDs are
+// assigned and tallies cleared, whatever the client sent. Polls without a body use their question as the body,
+// so that they show up in search.
+func (m *Message) preparePoll(now time.Time) error {
+	switch m.Kind {
+	case KindText, KindEmote:
+		m.Poll = nil
+		return nil
+	case KindPoll:
+	default:
+		return errormodel.ErrInvalidPoll
+	}
+
+	p := m.Poll
+	if p == nil {
+		return errormodel.ErrInvalidPoll
+	}
+	p.Question = strings.TrimSpace(p.Question)
+	if p.Question == "" || len(p.Question) > maxPollQuestionSize {
+		return errormodel.ErrInvalidPoll
+	}
+	if len(p.Options) < 2 || len(p.Options) > maxPollOptions {
+		return errormodel.ErrInvalidPoll
+	}
+	seen := make(map[string]bool, len(p.Options))
+	for i := range p.Options {
+		text := strings.TrimSpace(p.Options[i].Text)
+		if text == "" || len(text) > maxPollOptionSize || seen[text] {
+			return errormodel.ErrInvalidPoll
+		}
+		seen[text] = true
+		p.Options[i] = PollOption{ID: strconv.Itoa(i + 1), Text: text}
+	}
+	if p.ClosesAt != nil {
+		if !p.ClosesAt.After(now) {
+			return errormodel.ErrInvalidPoll
+		}
+		closesAt := p.ClosesAt.UTC()
+		p.ClosesAt = &closesAt
+	}
+	if strings.TrimSpace(m.Body) == "" {
+		m.Body = p.Question
+	}
+	return nil
+}
+
+// Vote casts the caller's ballot in a poll and returns the message with the updated tallies. Each user has one
+// ballot; voting again replaces it, moving the caller's votes to the newly chosen options. Single-choice polls
+// take exactly one option. Votes on closed polls are rejected, and hidden or expired
+// polls are not found. Callers banned from the poll's room are rejected with ErrBanned.
+func (ms *MessageServiceImpl) Vote(ctx context.Context, id string, callerId string, optionIds []string) (*Message, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrInvalidMessageID
+	}
+	found, err := ms.messageRepo.GetMessagesByIDs(ctx, []primitive.ObjectID{oid})
+	if err != nil {
+		return nil, err
+	}
+	now := ms.now().UTC()
+	if len(found) == 0 || found[0].Hidden || (found[0].ExpiresAt != nil && !found[0].ExpiresAt.After(now)) {
+		return nil, errormodel.ErrMessageNotFound
+	}
+	msg := &found[0]
+
+	roomData, err := ms.roomRepo.GetRoomByID(ctx, msg.RoomID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrMessageNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !roomData.VisibleTo(callerId) {
+		return nil, errormodel.ErrMessageNotFound
+	}
+	if ms.accessGuard != nil {
+		if err := ms.accessGuard.CheckAccess(ctx, msg.RoomID, callerId); err != nil {
+			return nil, err
+		}
+	}
+	if msg.Kind != KindPoll || msg.Poll == nil {
+		return nil, errormodel.ErrInvalidVote
+	}
+	if msg.Poll.ClosedAt(now) {
+		return nil, errormodel.ErrPollClosed
+	}
+
+	slices.Sort(optionIds)
+	optionIds = slices.Compact(optionIds)
+	if len(optionIds) == 0 || (!msg.Poll.MultipleChoice && len(optionIds) > 1) {
+		return nil, errormodel.ErrInvalidVote
+	}
+	for _, optionId := range optionIds {
+		if !slices.ContainsFunc(msg.Poll.Options, func(o PollOption) bool { return o.ID == optionId }) {
+			return nil, errormodel.ErrInvalidVote
+		}
+	}
+
+	ballot := &Ballot{MessageID: oid, UserID: callerId, OptionIDs: optionIds, CastAt: now}
+	var previous *Ballot
+	err = ms.messageRepo.CreateBallot(ctx, ballot)
+	if errors.Is(err, errormodel.ErrAlreadyVoted) {
+		previous, err = ms.messageRepo.ReplaceBallot(ctx, ballot)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var retracted []string
+	if previous != nil {
+		retracted = previous.OptionIDs
+	}
+	updated, err := ms.messageRepo.AddPollVotes(ctx, ballot, retracted, !msg.Poll.Anonymous)
+	if err != nil {
+		// The ballot must match the tallies: a ballot that was never counted must not block a later vote, and a
+		// replaced one must be put back.
+		var rollbackErr error
+		if previous != nil {
+			_, rollbackErr = ms.messageRepo.ReplaceBallot(ctx, previous)
+		} else {
+			rollbackErr = ms.messageRepo.DeleteBallot(ctx, oid, callerId)
+		}
+		if rollbackErr != nil {
+			log.Printf("Failed to roll back ballot of %s in poll %s: %v", callerId, id, rollbackErr)
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errormodel.ErrPollClosed
+		}
+		return nil, err
+	}
+
+	messages := []Message{*updated}
+	if err := ms.resolveAttachments(ctx, messages); err != nil {
+		return nil, err
+	}
+	return &messages[0], nil
+}
@@ -0,0 +1,425 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/models/errormodel"
+	"messages-go/room"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// pollMessages is a MessageRepo keeping polls and ballots in memory, with the semantics of MessageRepoImpl: one
+// ballot per user and poll, and tallies only changed on open, visible, unexpired polls.
+type pollMessages struct {
+	MessageRepo
+	messages map[primitive.ObjectID]*Message
+	ballots  map[string]Ballot
+}
+
+func newPollMessages(messages ...*Message) *pollMessages {
+	r := &pollMessages{messages: make(map[primitive.ObjectID]*Message), ballots: make(map[string]Ballot)}
+	for _, msg := range messages {
+		r.messages[msg.ID] = msg
+	}
+	return r
+}
+
+func ballotKey(messageID primitive.ObjectID, userID string) string {
+	return messageID.Hex() + ":" + userID
+}
+
+func (r *pollMessages) GetMessagesByIDs(_ context.Context, ids []primitive.ObjectID) ([]Message, error) {
+	var found []Message
+	for _, id := range ids {
+		if msg, ok := r.messages[id]; ok {
+			found = append(found, copyPoll(msg))
+		}
+	}
+	return found, nil
+}
+
+func (r *pollMessages) CreateBallot(_ context.Context, ballot *Ballot) error {
+	key := ballotKey(ballot.MessageID, ballot.UserID)
+	if _, ok := r.ballots[key]; ok {
+		return errormodel.ErrAlreadyVoted
+	}
+	ballot.ID = primitive.NewObjectID()
+	r.ballots[key] = *ballot
+	return nil
+}
+
+func (r *pollMessages) ReplaceBallot(_ context.Context, ballot *Ballot) (*Ballot, error) {
+	key := ballotKey(ballot.MessageID, ballot.UserID)
+	previous, ok := r.ballots[key]
+	r.ballots[key] = *ballot
+	if !ok {
+		return nil, nil
+	}
+	return &previous, nil
+}
+
+func (r *pollMessages) DeleteBallot(_ context.Context, messageID primitive.ObjectID, userID string) error {
+	delete(r.ballots, ballotKey(messageID, userID))
+	return nil
+}
+
+func (r *pollMessages) AddPollVotes(_ context.Context, ballot *Ballot, retracted []string, recordVoter bool) (*Message, error) {
+	msg, ok := r.messages[ballot.MessageID]
+	if !ok || msg.Kind != KindPoll || msg.Hidden || msg.Poll.ClosedAt(ballot.CastAt) ||
+		(msg.ExpiresAt != nil && !msg.ExpiresAt.After(ballot.CastAt)) {
+		return nil, mongo.ErrNoDocuments
+	}
+	chosen, dropped := ballotChanges(ballot.OptionIDs, retracted)
+	for i := range msg.Poll.Options {
+		option := &msg.Poll.Options[i]
+		if slices.Contains(chosen, option.ID) {
+			option.Votes++
+			if recordVoter {
+				option.Voters = append(option.Voters, ballot.UserID)
+			}
+		}
+		if slices.Contains(dropped, option.ID) {
+			option.Votes--
+			if recordVoter {
+				option.Voters = slices.DeleteFunc(option.Voters, func(v string) bool { return v == ballot.UserID })
+			}
+		}
+	}
+	updated := copyPoll(msg)
+	return &updated, nil
+}
+
+// copyPoll returns a copy of a message that does not share its poll, as messages decoded from the database don't.
+func copyPoll(msg *Message) Message {
+	c := *msg
+	if msg.Poll != nil {
+		poll := *msg.Poll
+		poll.Options = make([]PollOption, len(msg.Poll.Options))
+		for i, option := range msg.Poll.Options {
+			option.Voters = slices.Clone(option.Voters)
+			poll.Options[i] = option
+		}
+		c.Poll = &poll
+	}
+	return c
+}
+
+// fakeRooms is a RoomRepo serving rooms from a map.
+type fakeRooms struct {
+	room.RoomRepo
+	rooms map[string]*room.Room
+}
+
+func (r *fakeRooms) GetRoomByID(_ context.Context, id string) (*room.Room, error) {
+	if roomData, ok := r.rooms[id]; ok {
+		return roomData, nil
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+// denyGuard is an AccessGuard rejecting the listed users as banned.
+type denyGuard map[string]bool
+
+func (g denyGuard) CheckAccess(_ context.Context, _ string, userID string) error {
+	if g[userID] {
+		return errormodel.ErrBanned
+	}
+	return nil
+}
+
+func newPoll(roomID string, multipleChoice bool, anonymous bool) *Message {
+	return &Message{
+		ID:     primitive.NewObjectID(),
+		RoomID: roomID,
+		Kind:   KindPoll,
+		Poll: &Poll{
+			Question:       "Lunch?",
+			Options:        []PollOption{{ID: "1", Text: "Pizza"}, {ID: "2", Text: "Sushi"}, {ID: "3", Text: "Salad"}},
+			MultipleChoice: multipleChoice,
+			Anonymous:      anonymous,
+		},
+	}
+}
+
+func newVoteService(clock *testClock, polls ...*Message) (*MessageServiceImpl, *pollMessages) {
+	repo := newPollMessages(polls...)
+	rooms := &fakeRooms{rooms: map[string]*room.Room{
+		"public":  {},
+		"private": {Private: true, Members: []string{"alice"}},
+	}}
+	return &MessageServiceImpl{messageRepo: repo, roomRepo: rooms, now: clock.Now}, repo
+}
+
+// tallies returns the votes and voters of each option of a poll.
+func tallies(msg *Message) ([]int, [][]string) {
+	var votes []int
+	var voters [][]string
+	for _, option := range msg.Poll.Options {
+		votes = append(votes, option.Votes)
+		voters = append(voters, option.Voters)
+	}
+	return votes, voters
+}
+
+func TestVoteValidatesChoices(t *testing.T) {
+	single := newPoll("public", false, false)
+	multiple := newPoll("public", true, false)
+	ms, _ := newVoteService(newTestClock(), single, multiple)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		poll    *Message
+		options []string
+		wantErr error
+	}{
+		{"single choice", single, []string{"1"}, nil},
+		{"single choice with two options", single, []string{"1", "2"}, errormodel.ErrInvalidVote},
+		{"no option", single, nil, errormodel.ErrInvalidVote},
+		{"unknown option", single, []string{"4"}, errormodel.ErrInvalidVote},
+		{"multiple choice", multiple, []string{"3", "1"}, nil},
+		{"multiple choice with an unknown option", multiple, []string{"1", "9"}, errormodel.ErrInvalidVote},
+		{"repeated option counts once", multiple, []string{"2", "2"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ms.Vote(ctx, tt.poll.ID.Hex(), "voter-"+tt.name, tt.options)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Vote(%q) = %v, want %v", tt.options, err, tt.wantErr)
+			}
+		})
+	}
+
+	if votes, _ := tallies(multiple); !reflect.DeepEqual(votes, []int{1, 1, 1}) {
+		t.Errorf("multiple choice tallies = %v, want each accepted option counted once", votes)
+	}
+	if votes, _ := tallies(single); !reflect.DeepEqual(votes, []int{1, 0, 0}) {
+		t.Errorf("single choice tallies = %v, want only the valid vote", votes)
+	}
+}
+
+func TestVoteRejectsWhatIsNotAnOpenPoll(t *testing.T) {
+	clock := newTestClock()
+	closesAt := clock.now.Add(time.Hour)
+	closing := newPoll("public", false, false)
+	closing.Poll.ClosesAt = &closesAt
+	expiresAt := clock.now.Add(time.Minute)
+	expiring := newPoll("public", false, false)
+	expiring.ExpiresAt = &expiresAt
+	hidden := newPoll("public", false, false)
+	hidden.Hidden = true
+	private := newPoll("private", false, false)
+	text := &Message{ID: primitive.NewObjectID(), RoomID: "public", Body: "not a poll"}
+	ms, _ := newVoteService(clock, closing, expiring, hidden, private, text)
+	ctx := context.Background()
+
+	if _, err := ms.Vote(ctx, closing.ID.Hex(), "alice", []string{"1"}); err != nil {
+		t.Fatalf("Vote before the poll closes: %v", err)
+	}
+	clock.now = clock.now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		id      string
+		caller  string
+		wantErr error
+	}{
+		{"closed", closing.ID.Hex(), "bob", errormodel.ErrPollClosed},
+		{"closed re-vote", closing.ID.Hex(), "alice", errormodel.ErrPollClosed},
+		{"expired", expiring.ID.Hex(), "bob", errormodel.ErrMessageNotFound},
+		{"hidden", hidden.ID.Hex(), "bob", errormodel.ErrMessageNotFound},
+		{"missing", primitive.NewObjectID().Hex(), "bob", errormodel.ErrMessageNotFound},
+		{"invisible room", private.ID.Hex(), "bob", errormodel.ErrMessageNotFound},
+		{"not a poll", text.ID.Hex(), "bob", errormodel.ErrInvalidVote},
+		{"invalid id", "nope", "bob", errormodel.ErrInvalidMessageID},
+		{"anonymous caller", closing.ID.Hex(), "", errormodel.ErrUnauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ms.Vote(ctx, tt.id, tt.caller, []string{"1"}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Vote = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if votes, _ := tallies(closing); !reflect.DeepEqual(votes, []int{1, 0, 0}) {
+		t.Errorf("closed poll tallies = %v, want only the vote cast while open", votes)
+	}
+
+	if _, err := ms.Vote(ctx, private.ID.Hex(), "alice", []string{"1"}); err != nil {
+		t.Errorf("Vote by a member of a private room: %v", err)
+	}
+	ms.accessGuard = denyGuard{"alice": true}
+	if _, err := ms.Vote(ctx, private.ID.Hex(), "alice", []string{"2"}); !errors.Is(err, errormodel.ErrBanned) {
+		t.Errorf("Vote by a banned member = %v, want ErrBanned", err)
+	}
+}
+
+func TestRevoteReplacesBallot(t *testing.T) {
+	clock := newTestClock()
+	single := newPoll("public", false, false)
+	multiple := newPoll("public", true, false)
+	ms, repo := newVoteService(clock, single, multiple)
+	ctx := context.Background()
+
+	vote := func(poll *Message, caller string, options ...string) *Message {
+		t.Helper()
+		clock.now = clock.now.Add(time.Second)
+		updated, err := ms.Vote(ctx, poll.ID.Hex(), caller, options)
+		if err != nil {
+			t.Fatalf("Vote(%s, %q): %v", caller, options, err)
+		}
+		return updated
+	}
+
+	vote(single, "alice", "1")
+	vote(single, "bob", "1")
+	updated := vote(single, "alice", "2")
+	votes, voters := tallies(updated)
+	if !reflect.DeepEqual(votes, []int{1, 1, 0}) || !reflect.DeepEqual(voters, [][]string{{"bob"}, {"alice"}, nil}) {
+		t.Errorf("tallies after re-voting = %v %q, want alice moved from Pizza to Sushi", votes, voters)
+	}
+	// Voting the same again changes nothing.
+	if votes, _ := tallies(vote(single, "alice", "2")); !reflect.DeepEqual(votes, []int{1, 1, 0}) {
+		t.Errorf("tallies after repeating a vote = %v", votes)
+	}
+	if ballot := repo.ballots[ballotKey(single.ID, "alice")]; !reflect.DeepEqual(ballot.OptionIDs, []string{"2"}) || !ballot.CastAt.Equal(clock.now) {
+		t.Errorf("alice's ballot = %+v, want the latest", ballot)
+	}
+	if len(repo.ballots) != 2 {
+		t.Errorf("%d ballots, want one per voter", len(repo.ballots))
+	}
+
+	vote(multiple, "alice", "1", "2")
+	votes, voters = tallies(vote(multiple, "alice", "2", "3"))
+	if !reflect.DeepEqual(votes, []int{0, 1, 1}) || !reflect.DeepEqual(voters, [][]string{{}, {"alice"}, {"alice"}}) {
+		t.Errorf("multiple choice tallies after re-voting = %v %q, want Pizza dropped and Salad added", votes, voters)
+	}
+
+	// A re-vote rejected as invalid keeps the ballot and tallies.
+	if _, err := ms.Vote(ctx, single.ID.Hex(), "alice", []string{"1", "3"}); !errors.Is(err, errormodel.ErrInvalidVote) {
+		t.Fatalf("invalid re-vote = %v", err)
+	}
+	if votes, _ := tallies(single); !reflect.DeepEqual(votes, []int{1, 1, 0}) || !reflect.DeepEqual(repo.ballots[ballotKey(single.ID, "alice")].OptionIDs, []string{"2"}) {
+		t.Errorf("invalid re-vote changed the tallies to %v", votes)
+	}
+}
+
+func TestRevoteRollsBackWhenNotCounted(t *testing.T) {
+	clock := newTestClock()
+	poll := newPoll("public", false, false)
+	ms, repo := newVoteService(clock, poll)
+	ctx := context.Background()
+
+	if _, err := ms.Vote(ctx, poll.ID.Hex(), "alice", []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	// The poll is hidden between the lookup and the count.
+	ms.messageRepo = &hidingRepo{pollMessages: repo, poll: poll}
+	if _, err := ms.Vote(ctx, poll.ID.Hex(), "alice", []string{"2"}); !errors.Is(err, errormodel.ErrPollClosed) {
+		t.Fatalf("uncounted re-vote = %v, want ErrPollClosed", err)
+	}
+	if got := repo.ballots[ballotKey(poll.ID, "alice")].OptionIDs; !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("ballot after an uncounted re-vote = %q, want the previous one put back", got)
+	}
+	if _, err := ms.Vote(ctx, poll.ID.Hex(), "bob", []string{"2"}); !errors.Is(err, errormodel.ErrPollClosed) {
+		t.Fatalf("uncounted vote = %v, want ErrPollClosed", err)
+	}
+	if _, ok := repo.ballots[ballotKey(poll.ID, "bob")]; ok {
+		t.Error("an uncounted first vote left its ballot behind")
+	}
+}
+
+// hidingRepo hides its poll right before counting votes, as a moderator acting concurrently would.
+type hidingRepo struct {
+	*pollMessages
+	poll *Message
+}
+
+func (r *hidingRepo) AddPollVotes(ctx context.Context, ballot *Ballot, retracted []string, recordVoter bool) (*Message, error) {
+	r.poll.Hidden = true
+	defer func() { r.poll.Hidden = false }()
+	return r.pollMessages.AddPollVotes(ctx, ballot, retracted, recordVoter)
+}
+
+func TestAnonymousPollOmitsVoters(t *testing.T) {
+	poll := newPoll("public", true, true)
+	ms, _ := newVoteService(newTestClock(), poll)
+	ctx := context.Background()
+
+	if _, err := ms.Vote(ctx, poll.ID.Hex(), "alice", []string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := ms.Vote(ctx, poll.ID.Hex(), "bob", []string{"2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err = ms.Vote(ctx, poll.ID.Hex(), "alice", []string{"3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	votes, voters := tallies(updated)
+	if !reflect.DeepEqual(votes, []int{0, 1, 1}) {
+		t.Errorf("anonymous tallies = %v", votes)
+	}
+	for i, v := range voters {
+		if len(v) != 0 {
+			t.Errorf("option %d of an anonymous poll lists voters %q", i+1, v)
+		}
+	}
+}
+
+func TestPreparePoll(t *testing.T) {
+	now := newTestClock().now
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	valid := func() *Message {
+		return &Message{Kind: KindPoll, Poll: &Poll{Question: " Lunch? ", Options: []PollOption{{ID: "x", Text: " Pizza ", Votes: 9, Voters: []string{"mallory"}}, {Text: "Sushi"}}}}
+	}
+
+	msg := valid()
+	msg.Poll.ClosesAt = &future
+	if err := msg.preparePoll(now); err != nil {
+		t.Fatalf("preparePoll: %v", err)
+	}
+	want := []PollOption{{ID: "1", Text: "Pizza"}, {ID: "2", Text: "Sushi"}}
+	if !reflect.DeepEqual(msg.Poll.Options, want) || msg.Poll.Question != "Lunch?" || msg.Body != "Lunch?" {
+		t.Errorf("prepared poll = %+v with body %q, want IDs assigned, tallies cleared and the question as body", msg.Poll, msg.Body)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(m *Message)
+	}{
+		{"no poll", func(m *Message) { m.Poll = nil }},
+		{"unknown kind", func(m *Message) { m.Kind = "survey" }},
+		{"blank question", func(m *Message) { m.Poll.Question = "  " }},
+		{"one option", func(m *Message) { m.Poll.Options = m.Poll.Options[:1] }},
+		{"too many options", func(m *Message) {
+			for i := 0; i < maxPollOptions; i++ {
+				m.Poll.Options = append(m.Poll.Options, PollOption{Text: string(rune('a' + i))})
+			}
+		}},
+		{"blank option", func(m *Message) { m.Poll.Options[1].Text = " " }},
+		{"duplicate option", func(m *Message) { m.Poll.Options[1].Text = "Pizza" }},
+		{"closed already", func(m *Message) { m.Poll.ClosesAt = &past }},
+		{"closing now", func(m *Message) { m.Poll.ClosesAt = &now }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := valid()
+			tt.modify(msg)
+			if err := msg.preparePoll(now); !errors.Is(err, errormodel.ErrInvalidPoll) {
+				t.Errorf("preparePoll = %v, want ErrInvalidPoll", err)
+			}
+		})
+	}
+
+	text := &Message{Body: "hi", Poll: valid().Poll}
+	if err := text.preparePoll(now); err != nil || text.Poll != nil {
+		t.Errorf("preparePoll of a text message = %v with poll %+v, want the poll dropped", err, text.Poll)
+	}
+}
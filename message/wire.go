@@ -1,14 +1,46 @@
 package message
 
 import (
+	"context"
 	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/attachment"
+	"messages-go/audit"
+	"messages-go/message/filter"
+	"messages-go/ratelimit"
 	"messages-go/room"
+	"messages-go/user"
+	"messages-go/utils"
 	ws "messages-go/websocket"
+	"time"
 )
 
-func InitMessageHandler(client *mongo.Client, roomRepo room.RoomRepo, wsHandler *ws.Handler) (MessageHandler, MessageRepo, MessageService) {
+// InitMessageHandler wires the message handler and starts the sweeper deleting expired messages until ctx is
+// cancelled. When messages are stored in MongoDB, a TTL index backs the sweeper up.
+func InitMessageHandler(ctx context.Context, client *mongo.Client, roomRepo room.RoomRepo, attachmentRepo attachment.AttachmentRepo, userRepo user.UserRepo, wsHandler *ws.Handler, floodStore ratelimit.Store, auditor audit.Recorder) (MessageHandler, MessageRepo, MessageService) {
+	cfg := Config{
+		MaxTTL: utils.GetEnvDuration("MESSAGE_MAX_TTL", 30*24*time.Hour),
+		Filters: filter.NewRegistry(filter.Config{
+			Words:        utils.GetEnvList("MESSAGE_FILTER_WORDS", nil),
+			MaxLength:    utils.GetEnvInt("MESSAGE_FILTER_MAX_LENGTH", 4000),
+			BlockedHosts: utils.GetEnvList("MESSAGE_FILTER_BLOCKED_HOSTS", nil),
+			AllowedHosts: utils.GetEnvList("MESSAGE_FILTER_ALLOWED_HOSTS", nil),
+			Defaults:     utils.GetEnvList("MESSAGE_FILTERS", []string{filter.NameMaxLength, filter.NameSecrets}),
+		}),
+	}
+
 	repo := NewMessageRepository(client)
-	service := NewMessageService(repo, roomRepo)
+	if mongoRepo, ok := repo.(*MessageRepoImpl); ok {
+		if err := mongoRepo.EnsureExpiryIndex(ctx, utils.GetEnvDuration("MESSAGE_EXPIRY_GRACE", 10*time.Minute)); err != nil {
+			log.Printf("Failed to create message expiry index: %v", err)
+		}
+	}
+	service := NewMessageService(repo, roomRepo, attachmentRepo, userRepo, floodStore, auditor, cfg)
+	if wsHandler != nil {
+		service.AddPostObserver(NewMentionNotifier(roomRepo, wsHandler))
+		wsHandler.SetMessageReceiver(NewSocketReceiver(service, wsHandler))
+	}
+	NewExpirySweeper(service, wsHandler, utils.GetEnvDuration("MESSAGE_EXPIRY_INTERVAL", 15*time.Second)).Start(ctx)
 	handler := NewMessageHandler(service, wsHandler)
 	return handler, repo, service
 }
@@ -1,9 +1,97 @@
 package errormodel
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	ErrRoomNotFound     = errors.New("room not found")
 	ErrMessagesNotFound = errors.New("no messages found")
+	ErrMessageNotFound  = errors.New("message not found")
 	ErrMongoWriteFailed = errors.New("mongo write failed")
+	ErrInvalidMessageID = errors.New("invalid message id")
+	ErrInvalidCursor    = errors.New("invalid message cursor")
+	ErrInvalidSettings  = errors.New("invalid room settings")
+	ErrInvalidTopic     = errors.New("invalid room topic")
+	ErrInvalidRoomID    = errors.New("invalid room id")
+	ErrUnauthenticated  = errors.New("caller identity required")
+	ErrForbidden        = errors.New("operation not permitted")
+	ErrRateLimited      = errors.New("rate limit exceeded")
+	ErrSlowMode         = errors.New("room is in slow mode")
+	ErrDuplicateMessage = errors.New("duplicate message")
+
+	ErrInvalidSearchQuery = errors.New("invalid search query")
+	ErrInvalidFormat      = errors.New("invalid message format")
+	ErrInvalidTTL         = errors.New("invalid message ttl")
+	ErrInvalidPoll        = errors.New("invalid poll")
+	ErrPollClosed         = errors.New("poll is closed")
+	ErrAlreadyVoted       = errors.New("already voted in this poll")
+	ErrInvalidVote        = errors.New("invalid poll vote")
+	ErrInvalidClientMsgID = errors.New("invalid client message id")
+	ErrInvalidFrame       = errors.New("invalid websocket frame")
+	ErrMessageRejected    = errors.New("message rejected by content filter")
+	ErrInvalidEmbed       = errors.New("invalid message embed")
+	ErrInvalidReference   = errors.New("invalid message reference")
+
+	ErrSanctionNotFound = errors.New("no such sanction")
+	ErrInvalidSanction  = errors.New("invalid sanction")
+	ErrMuted            = errors.New("muted in this room")
+	ErrBanned           = errors.New("banned from this room")
+
+	ErrReportNotFound  = errors.New("report not found")
+	ErrInvalidReport   = errors.New("invalid report")
+	ErrAlreadyReported = errors.New("message already reported by this user")
+	ErrReportResolved  = errors.New("report is already resolved")
+
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrInvalidWebhook      = errors.New("invalid webhook")
+	ErrWebhookLimitReached = errors.New("room has reached its webhook limit")
+	ErrDeliveryNotFound    = errors.New("webhook delivery not found")
+
+	ErrIncomingWebhookNotFound = errors.New("incoming webhook not found")
+	ErrInvalidIncomingWebhook  = errors.New("invalid incoming webhook")
+
+	ErrBotNotFound  = errors.New("bot not found")
+	ErrInvalidBot   = errors.New("invalid bot")
+	ErrCommandTaken = errors.New("command already taken in this room")
+	ErrBotInstalled = errors.New("bot already installed in this room")
+
+	ErrPinNotFound     = errors.New("message is not pinned")
+	ErrAlreadyPinned   = errors.New("message is already pinned")
+	ErrPinLimitReached = errors.New("room has reached its pin limit")
+
+	ErrScheduledNotFound = errors.New("scheduled message not found")
+	ErrInvalidSendAt     = errors.New("send_at must be in the future, within the scheduling window")
+
+	ErrAttachmentNotFound   = errors.New("attachment not found")
+	ErrAttachmentTooLarge   = errors.New("attachment too large")
+	ErrUnsupportedMediaType = errors.New("unsupported attachment type")
+	ErrChecksumMismatch     = errors.New("attachment checksum mismatch")
+	ErrInvalidAttachment    = errors.New("invalid attachment reference")
+	ErrInvalidImage         = errors.New("malformed image")
+	ErrThumbnailNotReady    = errors.New("thumbnail is still processing")
+
+	ErrUserNotFound    = errors.New("user not found")
+	ErrUsernameTaken   = errors.New("username already taken")
+	ErrInvalidUsername = errors.New("invalid username")
+	ErrInvalidPassword = errors.New("invalid password")
+
+	ErrInvalidToken       = errors.New("invalid or expired session token")
+	ErrInvalidCredentials = errors.New("invalid username or password")
 )
+
+// RetryError wraps the sentinel rejecting a request that may succeed after waiting RetryAfter.
+type RetryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v, retry in %s", e.Err, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,6 @@
+package request
+
+// AddMemberRequest represents a request to add a user to a room. The caller is added when UserID is omitted.
+type AddMemberRequest struct {
+	UserID *string `json:"user_id"`
+}
@@ -0,0 +1,6 @@
+package request
+
+// AddModeratorRequest represents a request to appoint a user as a moderator of a room.
+type AddModeratorRequest struct {
+	UserID *string `json:"user_id"`
+}
@@ -0,0 +1,18 @@
+package request
+
+// RegisterBotRequest represents a user registering a bot handling the commands in Commands. HTTP bots are posted
+// their invocations at URL, signed with Secret; WebSocket bots connect with the token returned on registration.
+type RegisterBotRequest struct {
+	Username    string   `json:"username"`
+	DisplayName string   `json:"display_name"`
+	Commands    []string `json:"commands"`
+	Transport   string   `json:"transport"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`
+}
+
+// InstallBotRequest represents a room owner installing a bot in the room with the scopes in Scopes.
+type InstallBotRequest struct {
+	BotID  string   `json:"bot_id"`
+	Scopes []string `json:"scopes"`
+}
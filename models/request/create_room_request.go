@@ -1,6 +1,8 @@
 package request
 
-// CreateRoomRequest represents the structure for requests to create a new room, optionally specifying a room name.
+// CreateRoomRequest represents the structure for requests to create a new room, optionally specifying a room name
+// and whether the room is private to its members.
 type CreateRoomRequest struct {
-	Name *string `json:"name"`
+	Name    *string `json:"name"`
+	Private *bool   `json:"private"`
 }
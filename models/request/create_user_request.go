@@ -0,0 +1,15 @@
+package request
+
+// CreateUserRequest represents a request to register a user with a unique username, the password they log in
+// with and an optional display name.
+type CreateUserRequest struct {
+	Username    *string `json:"username"`
+	Password    *string `json:"password"`
+	DisplayName *string `json:"display_name"`
+}
+
+// LoginRequest represents a user logging in to receive a session token.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
@@ -0,0 +1,10 @@
+package request
+
+// ForwardMessageRequest represents a user forwarding a message into the room with RoomID. Body is an optional
+// comment shown with the forwarded message, written in Format.
+type ForwardMessageRequest struct {
+	RoomID      string `json:"room_id"`
+	Body        string `json:"body"`
+	Format      string `json:"format"`
+	ClientMsgID string `json:"client_msg_id"`
+}
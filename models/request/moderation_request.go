@@ -0,0 +1,9 @@
+package request
+
+// ModerationRequest represents a moderator kicking, muting or banning a user of a room. DurationSeconds bounds
+// mutes and bans, which last until lifted when it is zero; kicks ignore it.
+type ModerationRequest struct {
+	UserID          string `json:"user_id"`
+	Reason          string `json:"reason"`
+	DurationSeconds int    `json:"duration_seconds"`
+}
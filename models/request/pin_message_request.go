@@ -0,0 +1,6 @@
+package request
+
+// PinMessageRequest represents a request to pin a message in a room.
+type PinMessageRequest struct {
+	MessageID *string `json:"message_id"`
+}
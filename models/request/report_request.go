@@ -0,0 +1,15 @@
+package request
+
+// ReportMessageRequest represents a user reporting a message to the moderators of its room.
+type ReportMessageRequest struct {
+	Category string `json:"category"`
+	Comment  string `json:"comment"`
+}
+
+// ResolveReportRequest represents a moderator resolving the reports of a message with an action. Reason is passed
+// on to warnings and bans, and DurationSeconds bounds bans as it does for ModerationRequest.
+type ResolveReportRequest struct {
+	Action          string `json:"action"`
+	Reason          string `json:"reason"`
+	DurationSeconds int    `json:"duration_seconds"`
+}
@@ -0,0 +1,19 @@
+package request
+
+import "time"
+
+// ScheduleMessageRequest represents a request to post a message to a room at a future time.
+type ScheduleMessageRequest struct {
+	RoomID *string    `json:"room_id"`
+	Body   *string    `json:"body"`
+	Format *string    `json:"format"`
+	SendAt *time.Time `json:"send_at"`
+}
+
+// UpdateScheduledMessageRequest represents a request to edit a pending scheduled message. Omitted fields are left
+// unchanged.
+type UpdateScheduledMessageRequest struct {
+	Body   *string    `json:"body"`
+	Format *string    `json:"format"`
+	SendAt *time.Time `json:"send_at"`
+}
@@ -0,0 +1,17 @@
+package request
+
+// UpdateRoomSettingsRequest represents a request to change the settings of a room. Omitted settings are left
+// unchanged; zero values restore the server-wide defaults.
+type UpdateRoomSettingsRequest struct {
+	PinLimit          *int `json:"pin_limit"`
+	MessageTTLSeconds *int `json:"message_ttl_seconds"`
+	RetentionDays     *int `json:"retention_days"`
+	RetentionMessages *int `json:"retention_messages"`
+
+	SlowModeSeconds        *int `json:"slow_mode_seconds"`
+	SlowModeBurst          *int `json:"slow_mode_burst"`
+	DuplicateWindowSeconds *int `json:"duplicate_window_seconds"`
+
+	Filters     *[]string `json:"filters"`
+	FilterWords *[]string `json:"filter_words"`
+}
@@ -0,0 +1,6 @@
+package request
+
+// VoteRequest represents a ballot in a poll: the IDs of the chosen options.
+type VoteRequest struct {
+	OptionIDs []string `json:"option_ids"`
+}
@@ -0,0 +1,32 @@
+package request
+
+// CreateWebhookRequest represents a room owner registering a URL to receive the room's events. Deliveries are
+// signed with Secret, and only the events named in Events are delivered.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// CreateIncomingWebhookRequest represents a room owner creating a token for integrations to post into the room.
+// Name is shown as the sender of their messages unless a post overrides it.
+type CreateIncomingWebhookRequest struct {
+	Name string `json:"name"`
+}
+
+// IncomingMessageRequest represents an integration posting a message through an incoming webhook. Username
+// overrides the name of the webhook shown as the sender, and Attachments are shown as cards below the text.
+type IncomingMessageRequest struct {
+	Text        string               `json:"text"`
+	Format      string               `json:"format"`
+	Username    string               `json:"username"`
+	Attachments []IncomingAttachment `json:"attachments"`
+}
+
+// IncomingAttachment is a card of an IncomingMessageRequest.
+type IncomingAttachment struct {
+	Title     string `json:"title"`
+	TitleLink string `json:"title_link"`
+	Text      string `json:"text"`
+	Color     string `json:"color"`
+}
@@ -0,0 +1,226 @@
+package moderation
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/models/response"
+	"strings"
+)
+
+const (
+	// defaultLogPageSize is used when a request for the moderation log does not specify a limit.
+	defaultLogPageSize = 50
+	// maxLogPageSize caps the number of log entries returned by a single request.
+	maxLogPageSize = 200
+)
+
+// ModerationHandler defines the interface for handling HTTP requests to kick, mute and ban users of a room and
+// to read its moderation log.
+type ModerationHandler interface {
+	Kick(c *fiber.Ctx) error
+	Mute(c *fiber.Ctx) error
+	Unmute(c *fiber.Ctx) error
+	Ban(c *fiber.Ctx) error
+	Unban(c *fiber.Ctx) error
+	GetLog(c *fiber.Ctx) error
+}
+
+// ModerationHandlerImpl implements the ModerationHandler interface.
+type ModerationHandlerImpl struct {
+	moderationService ModerationService
+}
+
+// NewModerationHandler initializes and returns a new ModerationHandler with the provided ModerationService
+// implementation.
+func NewModerationHandler(moderationService ModerationService) ModerationHandler {
+	return &ModerationHandlerImpl{moderationService: moderationService}
+}
+
+// Kick handles a moderator disconnecting the user given in the request body from the room.
+func (mh *ModerationHandlerImpl) Kick(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	req, err := parseModerationRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	log.Println("Kick User ", req.UserID, " from Room with id ", roomId, " Request Received.")
+
+	entry, err := mh.moderationService.Kick(c.Context(), roomId, auth.CallerID(c), req)
+	if err != nil {
+		return moderationError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "User Kicked",
+		Data:    entry,
+	})
+}
+
+// Mute handles a moderator muting the user given in the request body in the room.
+func (mh *ModerationHandlerImpl) Mute(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	req, err := parseModerationRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	log.Println("Mute User ", req.UserID, " in Room with id ", roomId, " Request Received.")
+
+	sanction, err := mh.moderationService.Mute(c.Context(), roomId, auth.CallerID(c), req)
+	if err != nil {
+		return moderationError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "User Muted",
+		Data:    sanction,
+	})
+}
+
+// Unmute handles a moderator lifting the mute of a user in the room.
+func (mh *ModerationHandlerImpl) Unmute(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	userId := c.Params("userId")
+
+	log.Println("Unmute User ", userId, " in Room with id ", roomId, " Request Received.")
+
+	if err := mh.moderationService.Unmute(c.Context(), roomId, auth.CallerID(c), userId); err != nil {
+		return moderationError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "User Unmuted",
+	})
+}
+
+// Ban handles a moderator banning the user given in the request body from the room.
+func (mh *ModerationHandlerImpl) Ban(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	req, err := parseModerationRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	log.Println("Ban User ", req.UserID, " from Room with id ", roomId, " Request Received.")
+
+	sanction, err := mh.moderationService.Ban(c.Context(), roomId, auth.CallerID(c), req)
+	if err != nil {
+		return moderationError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "User Banned",
+		Data:    sanction,
+	})
+}
+
+// Unban handles a moderator lifting the ban of a user from the room.
+func (mh *ModerationHandlerImpl) Unban(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	userId := c.Params("userId")
+
+	log.Println("Unban User ", userId, " from Room with id ", roomId, " Request Received.")
+
+	if err := mh.moderationService.Unban(c.Context(), roomId, auth.CallerID(c), userId); err != nil {
+		return moderationError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "User Unbanned",
+	})
+}
+
+// GetLog handles a moderator reading the room's moderation log, newest first, paged with the before and limit
+// query parameters.
+func (mh *ModerationHandlerImpl) GetLog(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	limit := c.QueryInt("limit", defaultLogPageSize)
+	if limit < 1 || limit > maxLogPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   "Invalid paging parameters",
+			Status:  fiber.StatusBadRequest,
+			Message: "limit must be between 1 and 200.",
+		})
+	}
+
+	log.Println("Get Moderation Log of Room with id ", roomId, " Request Received.")
+
+	entries, err := mh.moderationService.GetLog(c.Context(), roomId, auth.CallerID(c), strings.TrimSpace(c.Query("before")), limit)
+	if err != nil {
+		return moderationError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Moderation Log Found",
+		Data:    entries,
+	})
+}
+
+// parseModerationRequest parses the body of a kick, mute or ban.
+func parseModerationRequest(c *fiber.Ctx) (request.ModerationRequest, error) {
+	var req request.ModerationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return req, err
+	}
+	req.UserID = strings.TrimSpace(req.UserID)
+	req.Reason = strings.TrimSpace(req.Reason)
+	return req, nil
+}
+
+// moderationError maps the service errors shared by the moderation endpoints to API responses.
+func moderationError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Moderation Requires A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Only Moderators May Do This, And Not To Other Moderators.",
+		})
+	} else if errors.Is(err, errormodel.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Room Found with given id.",
+		})
+	} else if errors.Is(err, errormodel.ErrSanctionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "The User Is Not Under This Sanction.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidSanction) || errors.Is(err, errormodel.ErrInvalidCursor) ||
+		errors.Is(err, errormodel.ErrInvalidRoomID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "user_id Is Required, duration_seconds Must Not Be Negative And reason At Most 500 Characters.",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed To Moderate Room",
+	})
+}
@@ -0,0 +1,53 @@
+package moderation
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Kinds of sanctions. A user has at most one sanction of each kind per room.
+const (
+	KindMute = "mute"
+	KindBan  = "ban"
+)
+
+// Actions recorded in the moderation log.
+const (
+	ActionKick   = "kick"
+	ActionWarn   = "warn"
+	ActionMute   = "mute"
+	ActionUnmute = "unmute"
+	ActionBan    = "ban"
+	ActionUnban  = "unban"
+)
+
+// Sanction restricts a user in a room until it expires or is lifted: muted users can read but not post, banned
+// users can neither join, read, connect nor post.
+type Sanction struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	RoomID      string             `bson:"room_id" json:"room_id"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	Kind        string             `bson:"kind" json:"kind"`
+	Reason      string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	ModeratorID string             `bson:"moderator_id" json:"moderator_id"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	// ExpiresAt is when the sanction lapses; sanctions without it last until lifted
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// ActiveAt reports whether the sanction is in force at now.
+func (s *Sanction) ActiveAt(now time.Time) bool {
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
+
+// LogEntry records a moderation action taken in a room.
+type LogEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RoomID      string             `bson:"room_id" json:"room_id"`
+	Action      string             `bson:"action" json:"action"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	ModeratorID string             `bson:"moderator_id" json:"moderator_id"`
+	Reason      string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	ExpiresAt   *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
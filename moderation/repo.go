@@ -0,0 +1,166 @@
+package moderation
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"messages-go/models/errormodel"
+	"os"
+	"time"
+)
+
+// ModerationRepo defines an interface for persisting the sanctions of room members and the moderation log.
+type ModerationRepo interface {
+	PutSanction(ctx context.Context, s *Sanction) (*Sanction, error)
+	DeleteSanction(ctx context.Context, roomID string, userID string, kind string) error
+	GetActiveSanctions(ctx context.Context, roomID string, userID string, now time.Time) ([]Sanction, error)
+	CreateLogEntry(ctx context.Context, entry *LogEntry) (*LogEntry, error)
+	GetLogEntries(ctx context.Context, roomID string, beforeID primitive.ObjectID, limit int64) ([]LogEntry, error)
+}
+
+// ModerationRepoImpl is a concrete implementation of the ModerationRepo interface backed by MongoDB.
+type ModerationRepoImpl struct {
+	sanctionCollection *mongo.Collection
+	logCollection      *mongo.Collection
+}
+
+// NewModerationRepository initializes and returns a new instance of ModerationRepo, ensuring a user has one
+// sanction of each kind per room and that MongoDB removes sanctions once they expire.
+func NewModerationRepository(client *mongo.Client) ModerationRepo {
+	db := client.Database(os.Getenv("MONGO_DB_NAME"))
+	r := &ModerationRepoImpl{
+		sanctionCollection: db.Collection("sanctions"),
+		logCollection:      db.Collection("moderation_log"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.sanctionCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "room_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "kind", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create sanction indexes: %v", err)
+	}
+	_, err = r.logCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "room_id", Value: 1}, {Key: "_id", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Failed to create moderation log index: %v", err)
+	}
+	return r
+}
+
+// PutSanction stores a sanction, replacing the user's sanction of the same kind in the room if there is one.
+func (r *ModerationRepoImpl) PutSanction(ctx context.Context, s *Sanction) (*Sanction, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"room_id": s.RoomID, "user_id": s.UserID, "kind": s.Kind}
+	opts := options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.After)
+	var stored Sanction
+	if err := r.sanctionCollection.FindOneAndReplace(timeoutCtx, filter, s, opts).Decode(&stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// DeleteSanction lifts a user's sanction of a kind in a room, returning ErrSanctionNotFound when there is none.
+func (r *ModerationRepoImpl) DeleteSanction(ctx context.Context, roomID string, userID string, kind string) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.sanctionCollection.DeleteOne(timeoutCtx, bson.M{"room_id": roomID, "user_id": userID, "kind": kind})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errormodel.ErrSanctionNotFound
+	}
+	return nil
+}
+
+// GetActiveSanctions retrieves the sanctions of a user in a room in force at now. Expired sanctions linger until
+// MongoDB removes them and are filtered out here.
+func (r *ModerationRepoImpl) GetActiveSanctions(ctx context.Context, roomID string, userID string, now time.Time) ([]Sanction, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"room_id": roomID,
+		"user_id": userID,
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": now}},
+		},
+	}
+	cursor, err := r.sanctionCollection.Find(timeoutCtx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			log.Default().Println(err.Error())
+		}
+	}(cursor, timeoutCtx)
+
+	var sanctions []Sanction
+	if err := cursor.All(timeoutCtx, &sanctions); err != nil {
+		return nil, err
+	}
+	return sanctions, nil
+}
+
+// CreateLogEntry appends an entry to the moderation log and returns it with its generated ID.
+func (r *ModerationRepoImpl) CreateLogEntry(ctx context.Context, entry *LogEntry) (*LogEntry, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.logCollection.InsertOne(timeoutCtx, entry)
+	if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		entry.ID = oid
+	}
+	return entry, nil
+}
+
+// GetLogEntries retrieves at most limit entries of a room's moderation log, newest first, starting before
+// beforeID unless it is the zero ID.
+func (r *ModerationRepoImpl) GetLogEntries(ctx context.Context, roomID string, beforeID primitive.ObjectID, limit int64) ([]LogEntry, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"room_id": roomID}
+	if !beforeID.IsZero() {
+		filter["_id"] = bson.M{"$lt": beforeID}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	cursor, err := r.logCollection.Find(timeoutCtx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			log.Default().Println(err.Error())
+		}
+	}(cursor, timeoutCtx)
+
+	entries := []LogEntry{}
+	if err := cursor.All(timeoutCtx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
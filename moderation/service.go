@@ -0,0 +1,325 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/audit"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/room"
+	ws "messages-go/websocket"
+	"strings"
+	"time"
+)
+
+// maxReasonSize bounds the reasons moderators give for their actions.
+const maxReasonSize = 500
+
+// ModerationService defines the interface for kicking, muting and banning the users of a room and reading its
+// moderation log.
+type ModerationService interface {
+	Kick(ctx context.Context, roomId string, callerId string, req request.ModerationRequest) (*LogEntry, error)
+	Warn(ctx context.Context, roomId string, callerId string, req request.ModerationRequest) (*LogEntry, error)
+	Mute(ctx context.Context, roomId string, callerId string, req request.ModerationRequest) (*Sanction, error)
+	Unmute(ctx context.Context, roomId string, callerId string, userId string) error
+	Ban(ctx context.Context, roomId string, callerId string, req request.ModerationRequest) (*Sanction, error)
+	Unban(ctx context.Context, roomId string, callerId string, userId string) error
+	GetLog(ctx context.Context, roomId string, callerId string, before string, limit int) ([]LogEntry, error)
+	CheckPost(ctx context.Context, roomID string, senderID string) error
+	CheckAccess(ctx context.Context, roomID string, userID string) error
+}
+
+// ModerationServiceImpl carries out the actions of room moderators, records them in the moderation log and
+// announces them to the room. It also guards posting and access against the sanctions in force.
+type ModerationServiceImpl struct {
+	moderationRepo ModerationRepo
+	roomRepo       room.RoomRepo
+	// roomService removes banned members, so that their leaving is recorded and announced
+	roomService room.RoomService
+	wsHandler   *ws.Handler
+	// auditor records every moderation action in the audit log as well as the room's moderation log
+	auditor audit.Recorder
+	now     func() time.Time
+}
+
+// NewModerationService initializes and returns a new instance of ModerationServiceImpl.
+func NewModerationService(moderationRepo ModerationRepo, roomRepo room.RoomRepo, roomService room.RoomService, wsHandler *ws.Handler, auditor audit.Recorder) *ModerationServiceImpl {
+	return &ModerationServiceImpl{
+		moderationRepo: moderationRepo,
+		roomRepo:       roomRepo,
+		roomService:    roomService,
+		wsHandler:      wsHandler,
+		auditor:        auditor,
+		now:            time.Now,
+	}
+}
+
+// Kick disconnects a user from the room's live connections. The user may reconnect right away; kicks that
+// should stick are bans.
+func (ms *ModerationServiceImpl) Kick(ctx context.Context, roomId string, callerId string, req request.ModerationRequest) (*LogEntry, error) {
+	if _, err := ms.targetRoom(ctx, roomId, callerId, req); err != nil {
+		return nil, err
+	}
+	entry, err := ms.record(ctx, roomId, ActionKick, req.UserID, callerId, req.Reason, nil)
+	if err != nil {
+		return nil, err
+	}
+	ms.disconnect(roomId, req.UserID, newModerationEvent("user_kicked", entry))
+	ms.broadcast(roomId, newModerationEvent("user_kicked", entry))
+	return entry, nil
+}
+
+// Warn records a warning against a user and delivers it on the user's personal channel. The room is not told.
+func (ms *ModerationServiceImpl) Warn(ctx context.Context, roomId string, callerId string, req request.ModerationRequest) (*LogEntry, error) {
+	if _, err := ms.targetRoom(ctx, roomId, callerId, req); err != nil {
+		return nil, err
+	}
+	entry, err := ms.record(ctx, roomId, ActionWarn, req.UserID, callerId, req.Reason, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ms.wsHandler != nil {
+		ms.wsHandler.BroadcastToUser(req.UserID, newModerationEvent("user_warned", entry))
+	}
+	return entry, nil
+}
+
+// Mute stops a user from posting to the room, for DurationSeconds or until unmuted. Muted users can still read.
+func (ms *ModerationServiceImpl) Mute(ctx context.Context, roomId string, callerId string, req request.ModerationRequest) (*Sanction, error) {
+	if _, err := ms.targetRoom(ctx, roomId, callerId, req); err != nil {
+		return nil, err
+	}
+	sanction, err := ms.sanction(ctx, roomId, KindMute, callerId, req)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := ms.record(ctx, roomId, ActionMute, req.UserID, callerId, req.Reason, sanction.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	ms.broadcast(roomId, newModerationEvent("user_muted", entry))
+	return sanction, nil
+}
+
+// Unmute lifts a user's mute in the room.
+func (ms *ModerationServiceImpl) Unmute(ctx context.Context, roomId string, callerId string, userId string) error {
+	return ms.lift(ctx, roomId, callerId, userId, KindMute, ActionUnmute, "user_unmuted")
+}
+
+// Ban keeps a user out of the room, for DurationSeconds or until unbanned: the user loses membership, their live
+// connections are closed at once, and they can no longer join, connect, read or post.
+func (ms *ModerationServiceImpl) Ban(ctx context.Context, roomId string, callerId string, req request.ModerationRequest) (*Sanction, error) {
+	roomData, err := ms.targetRoom(ctx, roomId, callerId, req)
+	if err != nil {
+		return nil, err
+	}
+	sanction, err := ms.sanction(ctx, roomId, KindBan, callerId, req)
+	if err != nil {
+		return nil, err
+	}
+	if roomData.IsMember(req.UserID) {
+		if _, err := ms.roomService.EvictMember(ctx, roomId, callerId, req.UserID); err != nil {
+			log.Printf("Failed to remove banned user %s from room %s: %v", req.UserID, roomId, err)
+		}
+	}
+	entry, err := ms.record(ctx, roomId, ActionBan, req.UserID, callerId, req.Reason, sanction.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	ms.disconnect(roomId, req.UserID, newModerationEvent("user_banned", entry))
+	ms.broadcast(roomId, newModerationEvent("user_banned", entry))
+	return sanction, nil
+}
+
+// Unban lifts a user's ban from the room. Membership is not restored; the user may join again.
+func (ms *ModerationServiceImpl) Unban(ctx context.Context, roomId string, callerId string, userId string) error {
+	return ms.lift(ctx, roomId, callerId, userId, KindBan, ActionUnban, "user_unbanned")
+}
+
+// GetLog returns at most limit entries of the room's moderation log, newest first, starting before the entry
+// with ID before unless it is empty. Only moderators may read the log.
+func (ms *ModerationServiceImpl) GetLog(ctx context.Context, roomId string, callerId string, before string, limit int) ([]LogEntry, error) {
+	if _, err := ms.moderatedRoom(ctx, roomId, callerId); err != nil {
+		return nil, err
+	}
+	var beforeID primitive.ObjectID
+	if before != "" {
+		var err error
+		if beforeID, err = primitive.ObjectIDFromHex(before); err != nil {
+			return nil, errormodel.ErrInvalidCursor
+		}
+	}
+	return ms.moderationRepo.GetLogEntries(ctx, roomId, beforeID, int64(limit))
+}
+
+// CheckPost rejects posts from users banned or muted in the room. Timed mutes say when they end.
+func (ms *ModerationServiceImpl) CheckPost(ctx context.Context, roomID string, senderID string) error {
+	if senderID == "" {
+		return nil
+	}
+	now := ms.now()
+	sanctions, err := ms.moderationRepo.GetActiveSanctions(ctx, roomID, senderID, now)
+	if err != nil {
+		return err
+	}
+	for _, s := range sanctions {
+		if s.Kind == KindBan {
+			return errormodel.ErrBanned
+		}
+	}
+	for _, s := range sanctions {
+		if s.Kind == KindMute {
+			if s.ExpiresAt == nil {
+				return errormodel.ErrMuted
+			}
+			return &errormodel.RetryError{Err: errormodel.ErrMuted, RetryAfter: s.ExpiresAt.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// CheckAccess keeps users banned from the room out of it.
+func (ms *ModerationServiceImpl) CheckAccess(ctx context.Context, roomID string, userID string) error {
+	sanctions, err := ms.moderationRepo.GetActiveSanctions(ctx, roomID, userID, ms.now())
+	if err != nil {
+		return err
+	}
+	for _, s := range sanctions {
+		if s.Kind == KindBan {
+			return errormodel.ErrBanned
+		}
+	}
+	return nil
+}
+
+// targetRoom validates a moderation request and returns the room when the caller may moderate it. Moderators
+// cannot be acted against, nor can callers act against themselves.
+func (ms *ModerationServiceImpl) targetRoom(ctx context.Context, roomId string, callerId string, req request.ModerationRequest) (*room.Room, error) {
+	roomData, err := ms.moderatedRoom(ctx, roomId, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.UserID) == "" || req.DurationSeconds < 0 || len(req.Reason) > maxReasonSize {
+		return nil, errormodel.ErrInvalidSanction
+	}
+	if req.UserID == callerId || roomData.IsModerator(req.UserID) {
+		return nil, errormodel.ErrForbidden
+	}
+	return roomData, nil
+}
+
+// moderatedRoom returns the room when the caller may moderate it. Rooms the caller cannot see are reported as
+// not found.
+func (ms *ModerationServiceImpl) moderatedRoom(ctx context.Context, roomId string, callerId string) (*room.Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	roomData, err := ms.roomRepo.GetRoomByID(ctx, roomId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !roomData.VisibleTo(callerId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	if !roomData.IsModerator(callerId) {
+		return nil, errormodel.ErrForbidden
+	}
+	return roomData, nil
+}
+
+// sanction stores a sanction of the given kind against the request's user, replacing any earlier one.
+func (ms *ModerationServiceImpl) sanction(ctx context.Context, roomId string, kind string, callerId string, req request.ModerationRequest) (*Sanction, error) {
+	now := ms.now().UTC()
+	s := &Sanction{
+		RoomID:      roomId,
+		UserID:      req.UserID,
+		Kind:        kind,
+		Reason:      req.Reason,
+		ModeratorID: callerId,
+		CreatedAt:   now,
+	}
+	if req.DurationSeconds > 0 {
+		expiresAt := now.Add(time.Duration(req.DurationSeconds) * time.Second)
+		s.ExpiresAt = &expiresAt
+	}
+	return ms.moderationRepo.PutSanction(ctx, s)
+}
+
+// lift removes a sanction, records it and tells the room.
+func (ms *ModerationServiceImpl) lift(ctx context.Context, roomId string, callerId string, userId string, kind string, action string, eventType string) error {
+	if _, err := ms.moderatedRoom(ctx, roomId, callerId); err != nil {
+		return err
+	}
+	if err := ms.moderationRepo.DeleteSanction(ctx, roomId, userId, kind); err != nil {
+		return err
+	}
+	entry, err := ms.record(ctx, roomId, action, userId, callerId, "", nil)
+	if err != nil {
+		return err
+	}
+	ms.broadcast(roomId, newModerationEvent(eventType, entry))
+	return nil
+}
+
+// record appends an action to the room's moderation log and to the audit log.
+func (ms *ModerationServiceImpl) record(ctx context.Context, roomId string, action string, userId string, moderatorId string, reason string, expiresAt *time.Time) (*LogEntry, error) {
+	entry, err := ms.moderationRepo.CreateLogEntry(ctx, &LogEntry{
+		RoomID:      roomId,
+		Action:      action,
+		UserID:      userId,
+		ModeratorID: moderatorId,
+		Reason:      reason,
+		ExpiresAt:   expiresAt,
+		CreatedAt:   ms.now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	auditEntry := audit.Entry{
+		Action:     audit.ActionModerationPrefix + action,
+		ActorID:    moderatorId,
+		TargetType: audit.TargetUser,
+		TargetID:   userId,
+		RoomID:     roomId,
+	}
+	if reason != "" || expiresAt != nil {
+		auditEntry.After = map[string]interface{}{"reason": reason, "expires_at": expiresAt}
+	}
+	ms.auditor.Record(ctx, auditEntry)
+	return entry, nil
+}
+
+func (ms *ModerationServiceImpl) disconnect(roomId string, userId string, event map[string]interface{}) {
+	if ms.wsHandler != nil {
+		if closed := ms.wsHandler.DisconnectUser(roomId, userId, event); closed > 0 {
+			log.Printf("Closed %d connections of %s to room %s", closed, userId, roomId)
+		}
+	}
+}
+
+func (ms *ModerationServiceImpl) broadcast(roomId string, event map[string]interface{}) {
+	if ms.wsHandler != nil {
+		ms.wsHandler.BroadcastToRoom(roomId, event)
+	}
+}
+
+// newModerationEvent builds the payload telling a room, and the user concerned, about a moderation action.
+func newModerationEvent(eventType string, entry *LogEntry) map[string]interface{} {
+	event := map[string]interface{}{
+		"type":    eventType,
+		"room_id": entry.RoomID,
+		"user_id": entry.UserID,
+	}
+	if entry.Reason != "" {
+		event["reason"] = entry.Reason
+	}
+	if entry.ExpiresAt != nil {
+		event["expires_at"] = entry.ExpiresAt
+	}
+	return event
+}
@@ -0,0 +1,351 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/room"
+	ws "messages-go/websocket"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryRepo is a ModerationRepo keeping sanctions and the log in memory, with one sanction of each kind per user
+// and room as the unique index of ModerationRepoImpl enforces.
+type memoryRepo struct {
+	sanctions map[string]Sanction
+	log       []LogEntry
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{sanctions: make(map[string]Sanction)}
+}
+
+func sanctionKey(roomID string, userID string, kind string) string {
+	return roomID + ":" + userID + ":" + kind
+}
+
+func (r *memoryRepo) PutSanction(_ context.Context, s *Sanction) (*Sanction, error) {
+	s.ID = primitive.NewObjectID()
+	r.sanctions[sanctionKey(s.RoomID, s.UserID, s.Kind)] = *s
+	return s, nil
+}
+
+func (r *memoryRepo) DeleteSanction(_ context.Context, roomID string, userID string, kind string) error {
+	delete(r.sanctions, sanctionKey(roomID, userID, kind))
+	return nil
+}
+
+func (r *memoryRepo) GetActiveSanctions(_ context.Context, roomID string, userID string, now time.Time) ([]Sanction, error) {
+	var active []Sanction
+	// Mutes come first, so that the ban check cannot rely on the order of the sanctions.
+	for _, kind := range []string{KindMute, KindBan} {
+		if s, ok := r.sanctions[sanctionKey(roomID, userID, kind)]; ok && s.ActiveAt(now) {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+func (r *memoryRepo) CreateLogEntry(_ context.Context, entry *LogEntry) (*LogEntry, error) {
+	entry.ID = primitive.NewObjectID()
+	r.log = append(r.log, *entry)
+	return entry, nil
+}
+
+func (r *memoryRepo) GetLogEntries(context.Context, string, primitive.ObjectID, int64) ([]LogEntry, error) {
+	return r.log, nil
+}
+
+// fakeRooms is a RoomRepo serving rooms from a map.
+type fakeRooms struct {
+	room.RoomRepo
+	rooms map[string]*room.Room
+}
+
+func (r *fakeRooms) GetRoomByID(_ context.Context, id string) (*room.Room, error) {
+	if roomData, ok := r.rooms[id]; ok {
+		return roomData, nil
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+// evictingRooms is a RoomService removing evicted members from the rooms of a fakeRooms.
+type evictingRooms struct {
+	room.RoomService
+	rooms   *fakeRooms
+	evicted []string
+}
+
+func (s *evictingRooms) EvictMember(_ context.Context, id string, _ string, userId string) (*room.Room, error) {
+	roomData := s.rooms.rooms[id]
+	roomData.Members = slices.DeleteFunc(roomData.Members, func(m string) bool { return m == userId })
+	s.evicted = append(s.evicted, userId)
+	return roomData, nil
+}
+
+// recordingAuditor keeps the entries it is given.
+type recordingAuditor struct {
+	entries []audit.Entry
+}
+
+func (a *recordingAuditor) Record(_ context.Context, entry audit.Entry) {
+	a.entries = append(a.entries, entry)
+}
+
+type fixture struct {
+	service *ModerationServiceImpl
+	repo    *memoryRepo
+	rooms   *evictingRooms
+	auditor *recordingAuditor
+	now     time.Time
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	rooms := &fakeRooms{rooms: map[string]*room.Room{
+		"lobby":  {OwnerID: "owner", Moderators: []string{"mod"}, Members: []string{"owner", "mod", "bob", "carol"}},
+		"secret": {OwnerID: "owner", Private: true, Members: []string{"owner", "bob"}},
+	}}
+	f := &fixture{
+		repo:    newMemoryRepo(),
+		rooms:   &evictingRooms{rooms: rooms},
+		auditor: &recordingAuditor{},
+		now:     time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	f.service = NewModerationService(f.repo, rooms, f.rooms, nil, f.auditor)
+	f.service.now = func() time.Time { return f.now }
+	return f
+}
+
+func TestModerationGuards(t *testing.T) {
+	actions := map[string]func(ms *ModerationServiceImpl, roomId string, callerId string, req request.ModerationRequest) error{
+		"kick": func(ms *ModerationServiceImpl, roomId string, callerId string, req request.ModerationRequest) error {
+			_, err := ms.Kick(context.Background(), roomId, callerId, req)
+			return err
+		},
+		"warn": func(ms *ModerationServiceImpl, roomId string, callerId string, req request.ModerationRequest) error {
+			_, err := ms.Warn(context.Background(), roomId, callerId, req)
+			return err
+		},
+		"mute": func(ms *ModerationServiceImpl, roomId string, callerId string, req request.ModerationRequest) error {
+			_, err := ms.Mute(context.Background(), roomId, callerId, req)
+			return err
+		},
+		"ban": func(ms *ModerationServiceImpl, roomId string, callerId string, req request.ModerationRequest) error {
+			_, err := ms.Ban(context.Background(), roomId, callerId, req)
+			return err
+		},
+	}
+	tests := []struct {
+		name    string
+		roomId  string
+		caller  string
+		req     request.ModerationRequest
+		wantErr error
+	}{
+		{"member", "lobby", "carol", request.ModerationRequest{UserID: "bob"}, errormodel.ErrForbidden},
+		{"stranger", "lobby", "stranger", request.ModerationRequest{UserID: "bob"}, errormodel.ErrForbidden},
+		{"anonymous", "lobby", "", request.ModerationRequest{UserID: "bob"}, errormodel.ErrUnauthenticated},
+		{"self", "lobby", "mod", request.ModerationRequest{UserID: "mod"}, errormodel.ErrForbidden},
+		{"owner on self", "lobby", "owner", request.ModerationRequest{UserID: "owner"}, errormodel.ErrForbidden},
+		{"moderator", "lobby", "owner", request.ModerationRequest{UserID: "mod"}, errormodel.ErrForbidden},
+		{"owner", "lobby", "mod", request.ModerationRequest{UserID: "owner"}, errormodel.ErrForbidden},
+		{"missing room", "nowhere", "owner", request.ModerationRequest{UserID: "bob"}, errormodel.ErrRoomNotFound},
+		{"invisible room", "secret", "mod", request.ModerationRequest{UserID: "bob"}, errormodel.ErrRoomNotFound},
+		{"no user", "lobby", "mod", request.ModerationRequest{UserID: " "}, errormodel.ErrInvalidSanction},
+		{"negative duration", "lobby", "mod", request.ModerationRequest{UserID: "bob", DurationSeconds: -1}, errormodel.ErrInvalidSanction},
+		{"long reason", "lobby", "mod", request.ModerationRequest{UserID: "bob", Reason: strings.Repeat("x", maxReasonSize+1)}, errormodel.ErrInvalidSanction},
+	}
+	for name, act := range actions {
+		for _, tt := range tests {
+			t.Run(name+" "+tt.name, func(t *testing.T) {
+				f := newFixture(t)
+				if err := act(f.service, tt.roomId, tt.caller, tt.req); !errors.Is(err, tt.wantErr) {
+					t.Errorf("%s = %v, want %v", name, err, tt.wantErr)
+				}
+				if len(f.repo.sanctions) != 0 || len(f.repo.log) != 0 || len(f.auditor.entries) != 0 || len(f.rooms.evicted) != 0 {
+					t.Errorf("a rejected %s took effect", name)
+				}
+			})
+		}
+	}
+
+	f := newFixture(t)
+	if err := f.service.Unban(context.Background(), "lobby", "carol", "bob"); !errors.Is(err, errormodel.ErrForbidden) {
+		t.Errorf("Unban by a member = %v, want ErrForbidden", err)
+	}
+	if _, err := f.service.GetLog(context.Background(), "lobby", "carol", "", 10); !errors.Is(err, errormodel.ErrForbidden) {
+		t.Errorf("GetLog by a member = %v, want ErrForbidden", err)
+	}
+}
+
+func TestTimedMute(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	sanction, err := f.service.Mute(ctx, "lobby", "mod", request.ModerationRequest{UserID: "bob", Reason: "spam", DurationSeconds: 600})
+	if err != nil {
+		t.Fatalf("Mute: %v", err)
+	}
+	if want := f.now.Add(10 * time.Minute); sanction.ExpiresAt == nil || !sanction.ExpiresAt.Equal(want) {
+		t.Fatalf("mute expires at %v, want %v", sanction.ExpiresAt, want)
+	}
+	if len(f.repo.log) != 1 || f.repo.log[0].Action != ActionMute || len(f.auditor.entries) != 1 {
+		t.Errorf("mute recorded %+v in the log and %d audit entries", f.repo.log, len(f.auditor.entries))
+	}
+
+	f.now = f.now.Add(100 * time.Second)
+	err = f.service.CheckPost(ctx, "lobby", "bob")
+	var retry *errormodel.RetryError
+	if !errors.As(err, &retry) || !errors.Is(err, errormodel.ErrMuted) || retry.RetryAfter != 500*time.Second {
+		t.Fatalf("CheckPost while muted = %v, want a retry in 500s for ErrMuted", err)
+	}
+	if err := f.service.CheckPost(ctx, "lobby", "carol"); err != nil {
+		t.Errorf("CheckPost for another member = %v", err)
+	}
+	if err := f.service.CheckPost(ctx, "other", "bob"); err != nil {
+		t.Errorf("CheckPost in another room = %v", err)
+	}
+	if err := f.service.CheckAccess(ctx, "lobby", "bob"); err != nil {
+		t.Errorf("CheckAccess while muted = %v, want muted users to read", err)
+	}
+
+	f.now = f.now.Add(500 * time.Second)
+	if err := f.service.CheckPost(ctx, "lobby", "bob"); err != nil {
+		t.Errorf("CheckPost once the mute expired = %v", err)
+	}
+
+	if _, err := f.service.Mute(ctx, "lobby", "mod", request.ModerationRequest{UserID: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	f.now = f.now.Add(24 * time.Hour)
+	if err := f.service.CheckPost(ctx, "lobby", "bob"); !errors.Is(err, errormodel.ErrMuted) || errors.As(err, &retry) {
+		t.Errorf("CheckPost while muted until lifted = %v, want ErrMuted without a retry time", err)
+	}
+	if err := f.service.Unmute(ctx, "lobby", "mod", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.CheckPost(ctx, "lobby", "bob"); err != nil {
+		t.Errorf("CheckPost after Unmute = %v", err)
+	}
+}
+
+func TestBanTakesPrecedenceOverMute(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	if _, err := f.service.Mute(ctx, "lobby", "mod", request.ModerationRequest{UserID: "bob", DurationSeconds: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.Ban(ctx, "lobby", "mod", request.ModerationRequest{UserID: "bob", DurationSeconds: 3600}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.CheckPost(ctx, "lobby", "bob"); !errors.Is(err, errormodel.ErrBanned) {
+		t.Errorf("CheckPost while banned and muted = %v, want ErrBanned", err)
+	}
+	if err := f.service.CheckAccess(ctx, "lobby", "bob"); !errors.Is(err, errormodel.ErrBanned) {
+		t.Errorf("CheckAccess while banned = %v, want ErrBanned", err)
+	}
+
+	f.now = f.now.Add(time.Hour)
+	if err := f.service.CheckAccess(ctx, "lobby", "bob"); err != nil {
+		t.Errorf("CheckAccess once the ban expired = %v", err)
+	}
+
+	if _, err := f.service.Ban(ctx, "lobby", "mod", request.ModerationRequest{UserID: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.Unban(ctx, "lobby", "owner", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.CheckPost(ctx, "lobby", "bob"); err != nil {
+		t.Errorf("CheckPost after Unban = %v", err)
+	}
+}
+
+// receive returns the next event delivered to a client, and whether its channel is still open afterwards.
+func receive(t *testing.T, client *ws.Client) (map[string]interface{}, bool) {
+	t.Helper()
+	select {
+	case data, ok := <-client.Send:
+		if !ok {
+			t.Fatalf("connection of %s closed without an event", client.UserID)
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case _, ok := <-client.Send:
+			return event, ok
+		case <-time.After(50 * time.Millisecond):
+			return event, true
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no event for %s", client.UserID)
+		return nil, false
+	}
+}
+
+func TestBanEvictsAndDisconnects(t *testing.T) {
+	f := newFixture(t)
+	hub := ws.NewHub()
+	hub.Start()
+	defer hub.Stop()
+	wsHandler := ws.NewHandler(hub)
+	f.service.wsHandler = wsHandler
+	ctx := context.Background()
+
+	bob := wsHandler.Subscribe("lobby", "bob")
+	carol := wsHandler.Subscribe("lobby", "carol")
+	for deadline := time.Now().Add(time.Second); wsHandler.GetRoomConnections("lobby") < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("clients did not register")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := f.service.Ban(ctx, "lobby", "mod", request.ModerationRequest{UserID: "bob", Reason: "abuse"}); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if !slices.Equal(f.rooms.evicted, []string{"bob"}) || slices.Contains(f.rooms.rooms.rooms["lobby"].Members, "bob") {
+		t.Errorf("evicted %q, want bob removed from the members", f.rooms.evicted)
+	}
+
+	event, open := receive(t, bob)
+	if event["type"] != "user_banned" || event["user_id"] != "bob" || event["reason"] != "abuse" {
+		t.Errorf("bob received %v, want the ban", event)
+	}
+	if open {
+		t.Error("the connection of the banned user stayed open")
+	}
+	event, open = receive(t, carol)
+	if event["type"] != "user_banned" || event["user_id"] != "bob" {
+		t.Errorf("carol received %v, want the ban announced", event)
+	}
+	if !open {
+		t.Error("the connection of another member was closed")
+	}
+	if got := wsHandler.ConnectedUserIDs("lobby"); !slices.Equal(got, []string{"carol"}) {
+		t.Errorf("connected users = %q, want only carol", got)
+	}
+
+	// Banning someone who is not a member keeps them out without an eviction.
+	if _, err := f.service.Ban(ctx, "lobby", "mod", request.ModerationRequest{UserID: "stranger"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.rooms.evicted) != 1 {
+		t.Errorf("evicted %q, want no eviction for a non-member", f.rooms.evicted)
+	}
+	if err := f.service.CheckAccess(ctx, "lobby", "stranger"); !errors.Is(err, errormodel.ErrBanned) {
+		t.Errorf("CheckAccess for the banned stranger = %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package moderation
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	"messages-go/message"
+	"messages-go/room"
+	ws "messages-go/websocket"
+)
+
+// InitModerationHandler wires the moderation handler and installs the moderation service as a guard of the room
+// and message services, so that sanctions keep banned users out of rooms and their messages and muted users from posting.
+func InitModerationHandler(client *mongo.Client, roomRepo room.RoomRepo, roomService room.RoomService, messageService message.MessageService, wsHandler *ws.Handler, auditor audit.Recorder) (ModerationHandler, ModerationRepo, ModerationService) {
+	repo := NewModerationRepository(client)
+	service := NewModerationService(repo, roomRepo, roomService, wsHandler, auditor)
+	roomService.SetAccessGuard(service)
+	messageService.AddPostGuard(service)
+	messageService.SetAccessGuard(service)
+	handler := NewModerationHandler(service)
+	return handler, repo, service
+}
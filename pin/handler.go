@@ -0,0 +1,151 @@
+package pin
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/models/response"
+	"strings"
+)
+
+// PinHandler defines the interface for handling HTTP requests to pin, unpin and list the pinned messages of a room.
+type PinHandler interface {
+	PinMessage(c *fiber.Ctx) error
+	UnpinMessage(c *fiber.Ctx) error
+	ListPins(c *fiber.Ctx) error
+}
+
+// PinHandlerImpl implements the PinHandler interface.
+type PinHandlerImpl struct {
+	pinService PinService
+}
+
+// NewPinHandler initializes and returns a new PinHandler with the provided PinService implementation.
+func NewPinHandler(pinService PinService) PinHandler {
+	return &PinHandlerImpl{pinService: pinService}
+}
+
+// PinMessage handles a moderator pinning the message given in the request body.
+func (ph *PinHandlerImpl) PinMessage(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	var req request.PinMessageRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+	if req.MessageID == nil || strings.TrimSpace(*req.MessageID) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   "Missing message id",
+			Status:  fiber.StatusBadRequest,
+			Message: "message_id is required to be not empty.",
+		})
+	}
+
+	log.Println("Pin Message ", *req.MessageID, " in Room with id ", roomId, " Request Received.")
+
+	pinned, err := ph.pinService.PinMessage(c.Context(), roomId, strings.TrimSpace(*req.MessageID), auth.CallerID(c))
+	if errors.Is(err, errormodel.ErrAlreadyPinned) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "Message Is Already Pinned.",
+		})
+	} else if errors.Is(err, errormodel.ErrPinLimitReached) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "Unpin A Message Before Pinning Another.",
+		})
+	} else if err != nil {
+		return pinError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Message Pinned",
+		Data:    pinned,
+	})
+}
+
+// UnpinMessage handles a moderator unpinning a message of a room.
+func (ph *PinHandlerImpl) UnpinMessage(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	messageId := c.Params("messageId")
+
+	log.Println("Unpin Message ", messageId, " in Room with id ", roomId, " Request Received.")
+
+	err := ph.pinService.UnpinMessage(c.Context(), roomId, messageId, auth.CallerID(c))
+	if errors.Is(err, errormodel.ErrPinNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "Message Is Not Pinned.",
+		})
+	} else if err != nil {
+		return pinError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Message Unpinned",
+	})
+}
+
+// ListPins handles listing the pinned messages of a room in pin order.
+func (ph *PinHandlerImpl) ListPins(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+
+	log.Println("List Pins of Room with id ", roomId, " Request Received.")
+
+	pins, err := ph.pinService.ListPins(c.Context(), roomId)
+	if err != nil {
+		return pinError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Pins Found",
+		Data:    pins,
+	})
+}
+
+// pinError maps the service errors shared by the pin endpoints to API responses.
+func pinError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Pinning Requires A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Only Moderators May Pin Messages In This Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrRoomNotFound) || errors.Is(err, errormodel.ErrMessageNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Message Found with given id in this Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidRoomID) || errors.Is(err, errormodel.ErrInvalidMessageID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Message or Room id is malformed.",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed To Update Pins",
+	})
+}
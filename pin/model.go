@@ -0,0 +1,22 @@
+package pin
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"messages-go/message"
+	"time"
+)
+
+// Pin records that a message was pinned in its room by a moderator.
+type Pin struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	RoomID    string             `bson:"room_id" json:"room_id"`
+	MessageID string             `bson:"message_id" json:"message_id"`
+	PinnedBy  string             `bson:"pinned_by" json:"pinned_by"`
+	PinnedAt  time.Time          `bson:"pinned_at" json:"pinned_at"`
+}
+
+// PinnedMessage is a pin together with the message it pins, as listed for a room.
+type PinnedMessage struct {
+	Pin     `bson:",inline"`
+	Message *message.Message `bson:"-" json:"message"`
+}
@@ -0,0 +1,102 @@
+package pin
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"messages-go/models/errormodel"
+	"os"
+	"time"
+)
+
+// PinRepo defines an interface for persisting the pins of rooms.
+type PinRepo interface {
+	CreatePin(ctx context.Context, p *Pin) (*Pin, error)
+	DeletePin(ctx context.Context, roomID string, messageID string) error
+	GetPinsByRoom(ctx context.Context, roomID string) ([]Pin, error)
+	CountPinsByRoom(ctx context.Context, roomID string) (int64, error)
+}
+
+// PinRepoImpl is a concrete implementation of the PinRepo interface backed by MongoDB.
+type PinRepoImpl struct {
+	pinCollection *mongo.Collection
+}
+
+// NewPinRepository initializes and returns a new instance of PinRepo, ensuring a message is pinned at most once.
+func NewPinRepository(client *mongo.Client) PinRepo {
+	r := &PinRepoImpl{
+		pinCollection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("pins"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.pinCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "room_id", Value: 1}, {Key: "message_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create pin index: %v", err)
+	}
+	return r
+}
+
+// CreatePin inserts a pin and returns it with its generated ID, or ErrAlreadyPinned when the message is pinned.
+func (r *PinRepoImpl) CreatePin(ctx context.Context, p *Pin) (*Pin, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.pinCollection.InsertOne(timeoutCtx, p)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, errormodel.ErrAlreadyPinned
+	} else if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		p.ID = oid
+	}
+	return p, nil
+}
+
+// DeletePin removes the pin of a message, returning ErrPinNotFound when the message is not pinned.
+func (r *PinRepoImpl) DeletePin(ctx context.Context, roomID string, messageID string) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.pinCollection.DeleteOne(timeoutCtx, bson.M{"room_id": roomID, "message_id": messageID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errormodel.ErrPinNotFound
+	}
+	return nil
+}
+
+// GetPinsByRoom returns the pins of a room in the order they were pinned.
+func (r *PinRepoImpl) GetPinsByRoom(ctx context.Context, roomID string) ([]Pin, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "pinned_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.pinCollection.Find(timeoutCtx, bson.M{"room_id": roomID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	pins := []Pin{}
+	if err := cursor.All(timeoutCtx, &pins); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+// CountPinsByRoom returns the number of messages pinned in a room.
+func (r *PinRepoImpl) CountPinsByRoom(ctx context.Context, roomID string) (int64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return r.pinCollection.CountDocuments(timeoutCtx, bson.M{"room_id": roomID})
+}
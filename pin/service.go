@@ -0,0 +1,186 @@
+package pin
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/message"
+	"messages-go/models/errormodel"
+	"messages-go/room"
+	ws "messages-go/websocket"
+	"time"
+)
+
+// PinService defines the interface for pinning messages in rooms and listing the pins of a room.
+type PinService interface {
+	PinMessage(ctx context.Context, roomId string, messageId string, callerId string) (*PinnedMessage, error)
+	UnpinMessage(ctx context.Context, roomId string, messageId string, callerId string) error
+	ListPins(ctx context.Context, roomId string) ([]PinnedMessage, error)
+}
+
+// PinServiceImpl pins messages on behalf of room moderators, enforcing the per-room pin limit, and broadcasts
+// pin changes to the room.
+type PinServiceImpl struct {
+	pinRepo        PinRepo
+	roomRepo       room.RoomRepo
+	messageService message.MessageService
+	wsHandler      *ws.Handler
+	// defaultLimit caps the pins of rooms that do not set their own limit
+	defaultLimit int
+	now          func() time.Time
+}
+
+// NewPinService initializes and returns a new instance of PinServiceImpl.
+func NewPinService(pinRepo PinRepo, roomRepo room.RoomRepo, messageService message.MessageService, wsHandler *ws.Handler, defaultLimit int) *PinServiceImpl {
+	return &PinServiceImpl{
+		pinRepo:        pinRepo,
+		roomRepo:       roomRepo,
+		messageService: messageService,
+		wsHandler:      wsHandler,
+		defaultLimit:   defaultLimit,
+		now:            time.Now,
+	}
+}
+
+// PinMessage pins a message of a room. Only moderators may pin, and a room cannot hold more pins than its limit.
+func (ps *PinServiceImpl) PinMessage(ctx context.Context, roomId string, messageId string, callerId string) (*PinnedMessage, error) {
+	roomData, err := ps.moderatedRoom(ctx, roomId, callerId)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := ps.messageService.GetMessage(ctx, roomId, messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	pin, err := ps.pinRepo.CreatePin(ctx, &Pin{
+		RoomID:    roomId,
+		MessageID: msg.ID.Hex(),
+		PinnedBy:  callerId,
+		PinnedAt:  ps.now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The limit is checked after inserting so that concurrent pins cannot both slip under it.
+	count, err := ps.pinRepo.CountPinsByRoom(ctx, roomId)
+	if err == nil && count > int64(ps.limit(roomData)) {
+		err = errormodel.ErrPinLimitReached
+	}
+	if err != nil {
+		if deleteErr := ps.pinRepo.DeletePin(ctx, roomId, pin.MessageID); deleteErr != nil {
+			log.Printf("Failed to roll back pin of message %s: %v", pin.MessageID, deleteErr)
+		}
+		return nil, err
+	}
+
+	pinned := &PinnedMessage{Pin: *pin, Message: msg}
+	ps.broadcast(roomId, map[string]interface{}{
+		"type": "message_pinned",
+		"pin":  pinned,
+	})
+	return pinned, nil
+}
+
+// UnpinMessage removes the pin of a message. Only moderators may unpin.
+func (ps *PinServiceImpl) UnpinMessage(ctx context.Context, roomId string, messageId string, callerId string) error {
+	if _, err := ps.moderatedRoom(ctx, roomId, callerId); err != nil {
+		return err
+	}
+	if err := ps.pinRepo.DeletePin(ctx, roomId, messageId); err != nil {
+		return err
+	}
+	ps.broadcastUnpinned(roomId, messageId)
+	return nil
+}
+
+// ListPins returns the pins of a room in the order they were pinned, each with its message.
+func (ps *PinServiceImpl) ListPins(ctx context.Context, roomId string) ([]PinnedMessage, error) {
+	pins, err := ps.pinRepo.GetPinsByRoom(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(pins))
+	for _, p := range pins {
+		if oid, err := primitive.ObjectIDFromHex(p.MessageID); err == nil {
+			ids = append(ids, oid)
+		}
+	}
+	messages, err := ps.messageService.GetMessagesByIDs(ctx, roomId, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*message.Message, len(messages))
+	for i := range messages {
+		byID[messages[i].ID.Hex()] = &messages[i]
+	}
+
+	pinned := make([]PinnedMessage, 0, len(pins))
+	for _, p := range pins {
+		// Pins whose message is gone are skipped until MessagesDeleted catches up.
+		if msg, ok := byID[p.MessageID]; ok {
+			pinned = append(pinned, PinnedMessage{Pin: p, Message: msg})
+		}
+	}
+	return pinned, nil
+}
+
+// MessagesDeleted removes the pins of deleted messages and tells the room they were unpinned.
+func (ps *PinServiceImpl) MessagesDeleted(ctx context.Context, roomID string, ids []primitive.ObjectID) {
+	for _, id := range ids {
+		err := ps.pinRepo.DeletePin(ctx, roomID, id.Hex())
+		if errors.Is(err, errormodel.ErrPinNotFound) {
+			continue
+		} else if err != nil {
+			log.Printf("Failed to remove pin of deleted message %s: %v", id.Hex(), err)
+			continue
+		}
+		ps.broadcastUnpinned(roomID, id.Hex())
+	}
+}
+
+// moderatedRoom returns the room when the caller may moderate it. Rooms the caller cannot see are reported as
+// not found.
+func (ps *PinServiceImpl) moderatedRoom(ctx context.Context, roomId string, callerId string) (*room.Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	roomData, err := ps.roomRepo.GetRoomByID(ctx, roomId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !roomData.VisibleTo(callerId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	if !roomData.IsModerator(callerId) {
+		return nil, errormodel.ErrForbidden
+	}
+	return roomData, nil
+}
+
+// limit returns the maximum number of pins of a room.
+func (ps *PinServiceImpl) limit(roomData *room.Room) int {
+	if roomData.Settings.PinLimit > 0 {
+		return roomData.Settings.PinLimit
+	}
+	return ps.defaultLimit
+}
+
+func (ps *PinServiceImpl) broadcastUnpinned(roomId string, messageId string) {
+	ps.broadcast(roomId, map[string]interface{}{
+		"type":       "message_unpinned",
+		"room_id":    roomId,
+		"message_id": messageId,
+	})
+}
+
+func (ps *PinServiceImpl) broadcast(roomId string, event map[string]interface{}) {
+	if ps.wsHandler != nil {
+		ps.wsHandler.BroadcastToRoom(roomId, event)
+	}
+}
@@ -0,0 +1,19 @@
+package pin
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/message"
+	"messages-go/room"
+	"messages-go/utils"
+	ws "messages-go/websocket"
+)
+
+// InitPinHandler wires the pin handler and registers the pin service with the message service, so that deleted
+// messages lose their pins.
+func InitPinHandler(client *mongo.Client, roomRepo room.RoomRepo, messageService message.MessageService, wsHandler *ws.Handler) (PinHandler, PinRepo, PinService) {
+	repo := NewPinRepository(client)
+	service := NewPinService(repo, roomRepo, messageService, wsHandler, utils.GetEnvInt("PINS_MAX_PER_ROOM", 50))
+	messageService.AddDeleteObserver(service)
+	handler := NewPinHandler(service)
+	return handler, repo, service
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is the budget of a token bucket: Burst requests at once, refilled at Burst per Per. The zero Limit is not
+// enforced.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// Enabled reports whether the limit is enforced.
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Per > 0
+}
+
+// rate returns the number of tokens the bucket regains per second.
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// String formats the limit the way ParseLimit reads it.
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Burst, l.Per)
+}
+
+// ParseLimit parses a limit written as burst/period, such as 30/1m. An empty string, "0" or "off" disables the
+// limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" || s == "off" {
+		return Limit{}, nil
+	}
+	burstPart, perPart, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q is not of the form burst/period", s)
+	}
+	burst, err := strconv.Atoi(burstPart)
+	if err != nil || burst < 0 {
+		return Limit{}, fmt.Errorf("limit %q has an invalid burst", s)
+	}
+	per, err := time.ParseDuration(perPart)
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("limit %q has an invalid period", s)
+	}
+	return Limit{Burst: burst, Per: per}, nil
+}
+
+// Decision is the outcome of taking a token from a bucket. RetryAfter is how long until a token is available when
+// the request was not allowed.
+type Decision struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. Implementations shared between instances, such as MongoStore, make the limits hold
+// for a deployment as a whole rather than per instance.
+type Store interface {
+	// Take takes a token from the bucket of key, which starts full and refills at the rate of limit.
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+	// Refund returns a token taken from the bucket of key, up to its burst.
+	Refund(ctx context.Context, key string, limit Limit) error
+}
+
+// refill brings a bucket holding tokens at updated up to date at now and takes a token if one is available,
+// returning the tokens left and the decision.
+func refill(tokens float64, updated time.Time, now time.Time, limit Limit) (float64, Decision) {
+	elapsed := max(now.Sub(updated), 0)
+	tokens = min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.rate())
+	if tokens >= 1 {
+		return tokens - 1, Decision{Allowed: true}
+	}
+	return tokens, Decision{RetryAfter: time.Duration((1 - tokens) / limit.rate() * float64(time.Second))}
+}
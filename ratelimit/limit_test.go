@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "30/1m", want: Limit{Burst: 30, Per: time.Minute}},
+		{in: " 5/10s ", want: Limit{Burst: 5, Per: 10 * time.Second}},
+		{in: "1/1h30m", want: Limit{Burst: 1, Per: 90 * time.Minute}},
+		{in: "", want: Limit{}},
+		{in: "0", want: Limit{}},
+		{in: "off", want: Limit{}},
+		{in: "0/1m", want: Limit{Per: time.Minute}},
+		{in: "30", wantErr: true},
+		{in: "30/", wantErr: true},
+		{in: "/1m", wantErr: true},
+		{in: "x/1m", wantErr: true},
+		{in: "-1/1m", wantErr: true},
+		{in: "30/1", wantErr: true},
+		{in: "30/0s", wantErr: true},
+		{in: "30/-1m", wantErr: true},
+		{in: "30/1m/1m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	for _, limit := range []Limit{{Burst: 30, Per: time.Minute}, {Burst: 2, Per: 1500 * time.Millisecond}} {
+		if got, err := ParseLimit(limit.String()); err != nil || got != limit {
+			t.Errorf("ParseLimit(%q) = %+v, %v; want %+v", limit.String(), got, err, limit)
+		}
+	}
+	if (Limit{}).Enabled() || (Limit{Per: time.Minute}).Enabled() || (Limit{}).String() != "off" {
+		t.Error("the zero limit is enforced")
+	}
+}
+
+func TestRefill(t *testing.T) {
+	limit := Limit{Burst: 4, Per: 4 * time.Second}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		wantTokens float64
+		want       Decision
+	}{
+		{name: "full", tokens: 4, wantTokens: 3, want: Decision{Allowed: true}},
+		{name: "last token", tokens: 1, wantTokens: 0, want: Decision{Allowed: true}},
+		{name: "empty", tokens: 0, wantTokens: 0, want: Decision{RetryAfter: time.Second}},
+		{name: "half a token", tokens: 0, elapsed: 500 * time.Millisecond, wantTokens: 0.5, want: Decision{RetryAfter: 500 * time.Millisecond}},
+		{name: "refilled a token", tokens: 0, elapsed: time.Second, wantTokens: 0, want: Decision{Allowed: true}},
+		{name: "capped at burst", tokens: 2, elapsed: time.Hour, wantTokens: 3, want: Decision{Allowed: true}},
+		{name: "clock going back", tokens: 0, elapsed: -time.Minute, wantTokens: 0, want: Decision{RetryAfter: time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, decision := refill(tt.tokens, start, start.Add(tt.elapsed), limit)
+			if tokens != tt.wantTokens || decision != tt.want {
+				t.Errorf("refill = %v, %+v; want %v, %+v", tokens, decision, tt.wantTokens, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"math"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/response"
+	ws "messages-go/websocket"
+	"strconv"
+	"time"
+)
+
+// Rule is the budget of a route: a limit per caller, per client IP and per room. Each has buckets of its own, so
+// the same user is limited separately on different routes. Zero limits are not enforced.
+type Rule struct {
+	Name string
+	User Limit
+	IP   Limit
+	Room Limit
+	// RoomOf extracts the room a request targets; without it requests are only limited per user and IP
+	RoomOf func(c *fiber.Ctx) string
+}
+
+// Limiter enforces Rules against the buckets of a Store.
+type Limiter struct {
+	store Store
+}
+
+// NewLimiter initializes and returns a new Limiter keeping its buckets in store.
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Allow takes a token from each bucket of the rule the request falls in. When any bucket is empty the request is
+// denied and the tokens already taken from the others are refunded, so that a request rejected for its IP or room
+// does not also spend its user's budget. userID must be a verified identity, such as auth.CallerID, or clients could
+// dodge their bucket by claiming another. Anonymous requests have no user bucket and requests outside a room no room
+// bucket. Requests are allowed when the store fails, so that an outage of a shared store does not take posting down
+// with it.
+func (l *Limiter) Allow(ctx context.Context, rule Rule, userID string, ip string, roomID string) Decision {
+	checks := []struct {
+		kind  string
+		value string
+		limit Limit
+	}{
+		{"user", userID, rule.User},
+		{"ip", ip, rule.IP},
+		{"room", roomID, rule.Room},
+	}
+	type charge struct {
+		key   string
+		limit Limit
+	}
+	var charged []charge
+	for _, check := range checks {
+		if check.value == "" || !check.limit.Enabled() {
+			continue
+		}
+		key := rule.Name + ":" + check.kind + ":" + check.value
+		decision, err := l.store.Take(ctx, key, check.limit)
+		if err != nil {
+			log.Printf("Rate limit store failed for %s, allowing request: %v", rule.Name, err)
+			continue
+		}
+		if !decision.Allowed {
+			for _, c := range charged {
+				if err := l.store.Refund(ctx, c.key, c.limit); err != nil {
+					log.Printf("Failed to refund rate limit token for %s: %v", rule.Name, err)
+				}
+			}
+			return decision
+		}
+		charged = append(charged, charge{key: key, limit: check.limit})
+	}
+	return Decision{Allowed: true}
+}
+
+// Middleware returns a handler rejecting the requests beyond the rule's budget with 429 Too Many Requests and a
+// Retry-After header.
+func (l *Limiter) Middleware(rule Rule) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var roomID string
+		if rule.RoomOf != nil {
+			roomID = rule.RoomOf(c)
+		}
+		decision := l.Allow(c.Context(), rule, auth.CallerID(c), c.IP(), roomID)
+		if decision.Allowed {
+			return c.Next()
+		}
+
+		seconds := RetryAfterSeconds(decision.RetryAfter)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return c.Status(fiber.StatusTooManyRequests).JSON(response.APIResponse{
+			Error:   errormodel.ErrRateLimited.Error(),
+			Status:  fiber.StatusTooManyRequests,
+			Message: "Too Many Requests, Retry In " + strconv.Itoa(seconds) + " Seconds.",
+		})
+	}
+}
+
+// WrapReceiver returns a receiver applying the rule to the frames clients send over room WebSocket connections
+// before passing them on to next. Frames beyond the budget are answered with an error frame instead; the
+// connection stays open.
+func (l *Limiter) WrapReceiver(rule Rule, next ws.MessageReceiver) ws.MessageReceiver {
+	return func(ctx context.Context, roomID string, userID string, data []byte) interface{} {
+		decision := l.Allow(ctx, rule, userID, "", roomID)
+		if decision.Allowed {
+			return next(ctx, roomID, userID, data)
+		}
+
+		var frame struct {
+			Message struct {
+				ClientMsgID string `json:"client_msg_id"`
+			} `json:"message"`
+		}
+		_ = json.Unmarshal(data, &frame)
+		return map[string]interface{}{
+			"type":          "error",
+			"client_msg_id": frame.Message.ClientMsgID,
+			"error":         errormodel.ErrRateLimited.Error(),
+			"retry_after":   RetryAfterSeconds(decision.RetryAfter),
+		}
+	}
+}
+
+// RoomParam returns a RoomOf reading the room from a path parameter.
+func RoomParam(name string) func(c *fiber.Ctx) string {
+	return func(c *fiber.Ctx) string {
+		return c.Params(name)
+	}
+}
+
+// RoomField is a RoomOf reading the room from the room_id field of a JSON body.
+func RoomField(c *fiber.Ctx) string {
+	var body struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return ""
+	}
+	return body.RoomID
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds, as Retry-After takes, and never below one.
+func RetryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable clock for MemoryStore.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestMemoryStoreBurstAndRefill(t *testing.T) {
+	store, clock := newTestStore()
+	ctx := context.Background()
+	limit := Limit{Burst: 3, Per: 3 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		if d, _ := store.Take(ctx, "k", limit); !d.Allowed {
+			t.Fatalf("request %d of the burst denied", i+1)
+		}
+	}
+	d, _ := store.Take(ctx, "k", limit)
+	if d.Allowed || d.RetryAfter != time.Second {
+		t.Fatalf("request beyond the burst = %+v, want denied for 1s", d)
+	}
+	if d, _ := store.Take(ctx, "other", limit); !d.Allowed {
+		t.Error("another key shares the bucket")
+	}
+
+	clock.now = clock.now.Add(400 * time.Millisecond)
+	if d, _ := store.Take(ctx, "k", limit); d.Allowed || d.RetryAfter != 600*time.Millisecond {
+		t.Errorf("request after 400ms = %+v, want denied for 600ms", d)
+	}
+	clock.now = clock.now.Add(600 * time.Millisecond)
+	if d, _ := store.Take(ctx, "k", limit); !d.Allowed {
+		t.Error("request after a refill denied")
+	}
+	if d, _ := store.Take(ctx, "k", limit); d.Allowed {
+		t.Error("a single refilled token allowed two requests")
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if d, _ := store.Take(ctx, "k", limit); !d.Allowed {
+			t.Fatalf("request %d after a full refill denied", i+1)
+		}
+	}
+	if d, _ := store.Take(ctx, "k", limit); d.Allowed {
+		t.Error("the bucket refilled beyond its burst")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store, clock := newTestStore()
+	ctx := context.Background()
+	limit := Limit{Burst: 2, Per: 2 * time.Second}
+
+	store.Take(ctx, "k", limit)
+	store.Take(ctx, "k", limit)
+	clock.now = clock.now.Add(time.Second)
+	store.sweep()
+	if _, ok := store.buckets["k"]; !ok {
+		t.Fatal("a bucket still refilling was dropped")
+	}
+	clock.now = clock.now.Add(time.Second)
+	store.sweep()
+	if _, ok := store.buckets["k"]; ok {
+		t.Fatal("a refilled bucket was kept")
+	}
+}
+
+func TestMemoryStoreRefund(t *testing.T) {
+	store, _ := newTestStore()
+	ctx := context.Background()
+	limit := Limit{Burst: 1, Per: time.Minute}
+
+	store.Take(ctx, "k", limit)
+	if err := store.Refund(ctx, "k", limit); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := store.Take(ctx, "k", limit); !d.Allowed {
+		t.Error("a refunded token could not be taken again")
+	}
+	store.Refund(ctx, "k", limit)
+	store.Refund(ctx, "k", limit)
+	store.Take(ctx, "k", limit)
+	if d, _ := store.Take(ctx, "k", limit); d.Allowed {
+		t.Error("refunds filled the bucket beyond its burst")
+	}
+	if err := store.Refund(ctx, "missing", limit); err != nil || len(store.buckets) != 1 {
+		t.Errorf("refunding a missing bucket = %v with %d buckets", err, len(store.buckets))
+	}
+}
+
+// failingStore is a Store whose every call fails.
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (Decision, error) {
+	return Decision{}, errors.New("store unavailable")
+}
+
+func (failingStore) Refund(context.Context, string, Limit) error {
+	return errors.New("store unavailable")
+}
+
+func TestAllowChargesNoBucketOnDenial(t *testing.T) {
+	store, _ := newTestStore()
+	limiter := NewLimiter(store)
+	ctx := context.Background()
+	rule := Rule{
+		Name: "post",
+		User: Limit{Burst: 5, Per: time.Minute},
+		IP:   Limit{Burst: 5, Per: time.Minute},
+		Room: Limit{Burst: 1, Per: time.Minute},
+	}
+
+	if d := limiter.Allow(ctx, rule, "alice", "10.0.0.1", "room1"); !d.Allowed {
+		t.Fatal("first request denied")
+	}
+	for i := 0; i < 10; i++ {
+		if d := limiter.Allow(ctx, rule, "alice", "10.0.0.1", "room1"); d.Allowed {
+			t.Fatal("request beyond the room budget allowed")
+		}
+	}
+	// Requests denied by the room left the user and IP budgets untouched.
+	for i := 0; i < 4; i++ {
+		if d := limiter.Allow(ctx, rule, "alice", "10.0.0.1", fmt.Sprintf("room%d", i+2)); !d.Allowed {
+			t.Fatalf("request %d in another room denied after denials in room1", i+1)
+		}
+	}
+	if d := limiter.Allow(ctx, rule, "alice", "10.0.0.2", "room9"); d.Allowed {
+		t.Error("request beyond the user budget allowed")
+	}
+	if d := limiter.Allow(ctx, rule, "bob", "10.0.0.1", "room10"); d.Allowed {
+		t.Error("request beyond the IP budget allowed")
+	}
+	if d := limiter.Allow(ctx, rule, "carol", "10.0.0.3", "room11"); !d.Allowed {
+		t.Error("a fresh user, IP and room denied after others were refunded")
+	}
+}
+
+func TestAllowSkipsUnsetBucketsAndFailingStores(t *testing.T) {
+	store, _ := newTestStore()
+	rule := Rule{Name: "post", User: Limit{Burst: 1, Per: time.Minute}, IP: Limit{Burst: 1, Per: time.Minute}}
+	limiter := NewLimiter(store)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if d := limiter.Allow(ctx, rule, "", "", "room1"); !d.Allowed {
+			t.Fatal("request without a user, IP or room limit denied")
+		}
+	}
+	if d := NewLimiter(failingStore{}).Allow(ctx, rule, "alice", "10.0.0.1", ""); !d.Allowed {
+		t.Error("request denied while the store fails")
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	for d, want := range map[time.Duration]int{0: 1, time.Millisecond: 1, time.Second: 1, 1001 * time.Millisecond: 2, time.Minute: 60} {
+		if got := RetryAfterSeconds(d); got != want {
+			t.Errorf("RetryAfterSeconds(%v) = %d, want %d", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps token buckets in memory, limiting a single instance.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket will have refilled, after which it is no different from a missing one
+	full time.Time
+}
+
+// NewMemoryStore initializes and returns an empty MemoryStore. Buckets are dropped once full by Start.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Take takes a token from the bucket of key.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Decision, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	var decision Decision
+	b.tokens, decision = refill(b.tokens, b.updated, now, limit)
+	b.updated = now
+	b.full = now.Add(time.Duration((float64(limit.Burst) - b.tokens) / limit.rate() * float64(time.Second)))
+	return decision, nil
+}
+
+// Refund returns a token to the bucket of key.
+func (s *MemoryStore) Refund(_ context.Context, key string, limit Limit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		return nil
+	}
+	b.tokens = min(float64(limit.Burst), b.tokens+1)
+	b.full = b.updated.Add(time.Duration((float64(limit.Burst) - b.tokens) / limit.rate() * float64(time.Second)))
+	return nil
+}
+
+// Start drops the buckets that have refilled every interval, until ctx is cancelled.
+func (s *MemoryStore) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sweep()
+			}
+		}
+	}()
+}
+
+// sweep drops the buckets that have refilled.
+func (s *MemoryStore) sweep() {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
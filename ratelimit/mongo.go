@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"os"
+	"time"
+)
+
+// MongoStore keeps token buckets in MongoDB, shared by every instance of a deployment. Each bucket is updated by
+// a single atomic update timed by the database server, so instances with skewed clocks agree on refills.
+type MongoStore struct {
+	bucketCollection *mongo.Collection
+}
+
+// NewMongoStore initializes and returns a new MongoStore, ensuring buckets are removed by MongoDB once they have
+// refilled.
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	s := &MongoStore{
+		bucketCollection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("rate_limits"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.bucketCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("Failed to create rate limit expiry index: %v", err)
+	}
+	return s
+}
+
+// Take takes a token from the bucket of key.
+func (s *MongoStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	burst := float64(limit.Burst)
+	elapsedSeconds := bson.M{"$max": bson.A{0, bson.M{"$divide": bson.A{
+		bson.M{"$subtract": bson.A{"$$NOW", bson.M{"$ifNull": bson.A{"$updated", "$$NOW"}}}}, 1000,
+	}}}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{burst, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", burst}},
+				bson.M{"$multiply": bson.A{elapsedSeconds, limit.rate()}},
+			}}}},
+			"updated": "$$NOW",
+		}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens":     bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"expires_at": bson.M{"$add": bson.A{"$$NOW", limit.Per.Milliseconds()}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var state struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	if err := s.bucketCollection.FindOneAndUpdate(timeoutCtx, bson.M{"_id": key}, update, opts).Decode(&state); err != nil {
+		return Decision{}, err
+	}
+	if state.Allowed {
+		return Decision{Allowed: true}, nil
+	}
+	return Decision{RetryAfter: time.Duration((1 - state.Tokens) / limit.rate() * float64(time.Second))}, nil
+}
+
+// Refund returns a token to the bucket of key.
+func (s *MongoStore) Refund(ctx context.Context, key string, limit Limit) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{float64(limit.Burst), bson.M{"$add": bson.A{"$tokens", 1}}}},
+		}}},
+	}
+	_, err := s.bucketCollection.UpdateOne(timeoutCtx, bson.M{"_id": key}, update)
+	return err
+}
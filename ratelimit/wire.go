@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/utils"
+	"os"
+	"time"
+)
+
+// Rules holds the budgets of the rate limited routes.
+type Rules struct {
+	PostMessage Rule
+	CreateRoom  Rule
+	Connect     Rule
+	// Frames limits the frames sent over room WebSocket connections, such as posts
+	Frames Rule
+	// Login limits logins and registrations, which are anonymous, per IP
+	Login Rule
+	// IncomingWebhook limits the posts through incoming webhooks, with the webhook taking the place of the user
+	IncomingWebhook Rule
+}
+
+// InitStore returns the store named by RATE_LIMIT_STORE, memory or mongo, for the buckets of every limit. In-memory
+// buckets are swept until ctx is cancelled.
+func InitStore(ctx context.Context, client *mongo.Client) Store {
+	switch storeName := utils.GetEnv("RATE_LIMIT_STORE", "memory"); storeName {
+	case "mongo":
+		return NewMongoStore(client)
+	default:
+		if storeName != "memory" {
+			log.Printf("Unknown RATE_LIMIT_STORE %q, keeping rate limits in memory", storeName)
+		}
+		memoryStore := NewMemoryStore()
+		memoryStore.Start(ctx, utils.GetEnvDuration("RATE_LIMIT_SWEEP_INTERVAL", time.Minute))
+		return memoryStore
+	}
+}
+
+// InitRateLimiter wires a Limiter keeping its buckets in store, and the rules configured from the environment.
+// Limits are written as burst/period, or off.
+func InitRateLimiter(store Store) (*Limiter, Rules) {
+	rules := Rules{
+		PostMessage: Rule{
+			Name:   "post_message",
+			User:   getEnvLimit("RATE_LIMIT_POST_MESSAGE_USER", "30/1m"),
+			IP:     getEnvLimit("RATE_LIMIT_POST_MESSAGE_IP", "60/1m"),
+			Room:   getEnvLimit("RATE_LIMIT_POST_MESSAGE_ROOM", "300/1m"),
+			RoomOf: RoomField,
+		},
+		CreateRoom: Rule{
+			Name: "create_room",
+			User: getEnvLimit("RATE_LIMIT_CREATE_ROOM_USER", "5/1m"),
+			IP:   getEnvLimit("RATE_LIMIT_CREATE_ROOM_IP", "10/1m"),
+		},
+		Connect: Rule{
+			Name:   "connect",
+			User:   getEnvLimit("RATE_LIMIT_CONNECT_USER", "20/1m"),
+			IP:     getEnvLimit("RATE_LIMIT_CONNECT_IP", "40/1m"),
+			Room:   getEnvLimit("RATE_LIMIT_CONNECT_ROOM", "600/1m"),
+			RoomOf: RoomParam("roomId"),
+		},
+		Frames: Rule{
+			Name: "frames",
+			User: getEnvLimit("RATE_LIMIT_FRAMES_USER", "30/1m"),
+			Room: getEnvLimit("RATE_LIMIT_FRAMES_ROOM", "300/1m"),
+		},
+		Login: Rule{
+			Name: "login",
+			IP:   getEnvLimit("RATE_LIMIT_LOGIN_IP", "10/1m"),
+		},
+		IncomingWebhook: Rule{
+			Name: "incoming_webhook",
+			User: getEnvLimit("RATE_LIMIT_INCOMING_WEBHOOK_TOKEN", "20/1m"),
+			IP:   getEnvLimit("RATE_LIMIT_INCOMING_WEBHOOK_IP", "60/1m"),
+			Room: getEnvLimit("RATE_LIMIT_INCOMING_WEBHOOK_ROOM", "60/1m"),
+		},
+	}
+	return NewLimiter(store), rules
+}
+
+// getEnvLimit returns the limit in the environment variable key, or def when it is unset or malformed.
+func getEnvLimit(key string, def string) Limit {
+	value := os.Getenv(key)
+	if value != "" {
+		limit, err := ParseLimit(value)
+		if err == nil {
+			return limit
+		}
+		log.Printf("Ignoring malformed %s=%q: %v", key, value, err)
+	}
+	limit, err := ParseLimit(def)
+	if err != nil {
+		log.Printf("Malformed default limit for %s: %v", key, err)
+	}
+	return limit
+}
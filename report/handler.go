@@ -0,0 +1,241 @@
+package report
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/models/response"
+	"strings"
+)
+
+const (
+	// defaultReportPageSize is used when a request for a report queue does not specify a limit.
+	defaultReportPageSize = 50
+	// maxReportPageSize caps the number of reports returned by a single request.
+	maxReportPageSize = 200
+)
+
+// ReportHandler defines the interface for handling HTTP requests to report messages and to list and resolve the
+// report queues.
+type ReportHandler interface {
+	ReportMessage(c *fiber.Ctx) error
+	ListRoomReports(c *fiber.Ctx) error
+	ListReports(c *fiber.Ctx) error
+	ResolveReport(c *fiber.Ctx) error
+}
+
+// ReportHandlerImpl implements the ReportHandler interface.
+type ReportHandlerImpl struct {
+	reportService ReportService
+}
+
+// NewReportHandler initializes and returns a new ReportHandler with the provided ReportService implementation.
+func NewReportHandler(reportService ReportService) ReportHandler {
+	return &ReportHandlerImpl{reportService: reportService}
+}
+
+// ReportMessage handles the caller reporting a message of a room to its moderators.
+func (rh *ReportHandlerImpl) ReportMessage(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	id := c.Params("id")
+	var req request.ReportMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+	req.Category = strings.TrimSpace(req.Category)
+	req.Comment = strings.TrimSpace(req.Comment)
+
+	log.Println("Report Message ", id, " in Room with id ", roomId, " Request Received.")
+
+	report, err := rh.reportService.ReportMessage(c.Context(), roomId, id, auth.CallerID(c), req)
+	if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "You Cannot Report Your Own Message.",
+		})
+	} else if errors.Is(err, errormodel.ErrAlreadyReported) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "You Have Already Reported This Message.",
+		})
+	} else if errors.Is(err, errormodel.ErrMessageNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Message Found with given id in this Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidMessageID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Message id is malformed.",
+		})
+	} else if err != nil {
+		return reportError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Message Reported",
+		Data:    report,
+	})
+}
+
+// ListRoomReports handles a moderator reading the report queue of a room, newest first, filtered by the status
+// query parameter and paged with before and limit.
+func (rh *ReportHandlerImpl) ListRoomReports(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	status, limit, ok := parseQueueParams(c)
+	if !ok {
+		return invalidQueueParams(c)
+	}
+
+	log.Println("List Reports of Room with id ", roomId, " Request Received.")
+
+	reports, err := rh.reportService.ListRoomReports(c.Context(), roomId, auth.CallerID(c), status, strings.TrimSpace(c.Query("before")), limit)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Reports Found",
+		Data:    reports,
+	})
+}
+
+// ListReports handles a moderator reading the reports of every room they moderate, with the same parameters as
+// ListRoomReports.
+func (rh *ReportHandlerImpl) ListReports(c *fiber.Ctx) error {
+	status, limit, ok := parseQueueParams(c)
+	if !ok {
+		return invalidQueueParams(c)
+	}
+
+	log.Println("List Reports Request Received.")
+
+	reports, err := rh.reportService.ListReports(c.Context(), auth.CallerID(c), status, strings.TrimSpace(c.Query("before")), limit)
+	if err != nil {
+		return reportError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Reports Found",
+		Data:    reports,
+	})
+}
+
+// ResolveReport handles a moderator resolving the reports of a message with the action in the request body.
+func (rh *ReportHandlerImpl) ResolveReport(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req request.ResolveReportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+	req.Action = strings.TrimSpace(req.Action)
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	log.Println("Resolve Report ", id, " with ", req.Action, " Request Received.")
+
+	report, err := rh.reportService.ResolveReport(c.Context(), id, auth.CallerID(c), req)
+	if errors.Is(err, errormodel.ErrReportResolved) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "The Report Has Already Been Resolved.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidSanction) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "duration_seconds Must Not Be Negative And reason At Most 500 Characters.",
+		})
+	} else if err != nil {
+		return reportError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Report Resolved",
+		Data:    report,
+	})
+}
+
+// parseQueueParams reads the status and limit of a report queue request. Open reports are listed by default and
+// reports of any status with status=all.
+func parseQueueParams(c *fiber.Ctx) (string, int, bool) {
+	status := strings.TrimSpace(c.Query("status", StatusOpen))
+	switch status {
+	case "all":
+		status = ""
+	case StatusOpen, StatusResolved:
+	default:
+		return "", 0, false
+	}
+	limit := c.QueryInt("limit", defaultReportPageSize)
+	return status, limit, limit >= 1 && limit <= maxReportPageSize
+}
+
+func invalidQueueParams(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+		Error:   "Invalid queue parameters",
+		Status:  fiber.StatusBadRequest,
+		Message: "status must be open, resolved or all and limit between 1 and 200.",
+	})
+}
+
+// reportError maps the service errors shared by the report endpoints to API responses.
+func reportError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Reports Require A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Only Moderators May Do This, And Not To Other Moderators.",
+		})
+	} else if errors.Is(err, errormodel.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Room Found with given id.",
+		})
+	} else if errors.Is(err, errormodel.ErrReportNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Report Found with given id.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidReport) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "category Must Be A Known Category, comment At Most 1000 Characters And action One Of delete, warn, ban Or dismiss.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidCursor) || errors.Is(err, errormodel.ErrInvalidRoomID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Room id or before is malformed.",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed To Process Report",
+	})
+}
@@ -0,0 +1,63 @@
+package report
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"time"
+)
+
+// Statuses of a report. Reports stay open until a moderator resolves the reports of their message.
+const (
+	StatusOpen     = "open"
+	StatusResolved = "resolved"
+)
+
+// Actions a moderator may resolve the reports of a message with.
+const (
+	// ActionDelete removes the message
+	ActionDelete = "delete"
+	// ActionWarn warns the message's sender, leaving the message as it is
+	ActionWarn = "warn"
+	// ActionBan bans the message's sender from the room
+	ActionBan = "ban"
+	// ActionDismiss rejects the reports and shows the message again if it was hidden
+	ActionDismiss = "dismiss"
+)
+
+// Categories lists the reasons a message may be reported for.
+var Categories = []string{"spam", "harassment", "hate", "violence", "sexual", "self_harm", "other"}
+
+// validCategory reports whether category is one of Categories.
+func validCategory(category string) bool {
+	return slices.Contains(Categories, category)
+}
+
+// validAction reports whether action is one a report may be resolved with.
+func validAction(action string) bool {
+	switch action {
+	case ActionDelete, ActionWarn, ActionBan, ActionDismiss:
+		return true
+	}
+	return false
+}
+
+// Report is a user's complaint about a message, queued for the moderators of its room. A user has at most one open
+// report per message.
+type Report struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RoomID     string             `bson:"room_id" json:"room_id"`
+	MessageID  string             `bson:"message_id" json:"message_id"`
+	ReporterID string             `bson:"reporter_id" json:"reporter_id"`
+	// SenderID and Body are copied from the message when it is reported, so the report can still be reviewed
+	// once the message is gone
+	SenderID  string    `bson:"sender_id" json:"sender_id"`
+	Body      string    `bson:"body,omitempty" json:"body,omitempty"`
+	Category  string    `bson:"category" json:"category"`
+	Comment   string    `bson:"comment,omitempty" json:"comment,omitempty"`
+	Status    string    `bson:"status" json:"status"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	// Resolution is the action taken by ResolvedBy when the report was resolved
+	Resolution string     `bson:"resolution,omitempty" json:"resolution,omitempty"`
+	ResolvedBy string     `bson:"resolved_by,omitempty" json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+}
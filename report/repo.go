@@ -0,0 +1,140 @@
+package report
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"messages-go/models/errormodel"
+	"os"
+	"time"
+)
+
+// ReportRepo defines an interface for persisting message reports and querying the report queues.
+type ReportRepo interface {
+	CreateReport(ctx context.Context, r *Report) (*Report, error)
+	GetReportByID(ctx context.Context, id primitive.ObjectID) (*Report, error)
+	CountOpenReports(ctx context.Context, messageID string) (int64, error)
+	GetReports(ctx context.Context, roomIDs []string, status string, beforeID primitive.ObjectID, limit int64) ([]Report, error)
+	ResolveReports(ctx context.Context, messageID string, resolution string, resolvedBy string, at time.Time) (int64, error)
+}
+
+// ReportRepoImpl is a concrete implementation of the ReportRepo interface backed by MongoDB.
+type ReportRepoImpl struct {
+	reportCollection *mongo.Collection
+}
+
+// NewReportRepository initializes and returns a new instance of ReportRepo, ensuring a user has at most one open
+// report per message.
+func NewReportRepository(client *mongo.Client) ReportRepo {
+	r := &ReportRepoImpl{
+		reportCollection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("reports"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.reportCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "message_id", Value: 1}, {Key: "reporter_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": StatusOpen}),
+		},
+		{
+			Keys: bson.D{{Key: "room_id", Value: 1}, {Key: "status", Value: 1}, {Key: "_id", Value: -1}},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create report indexes: %v", err)
+	}
+	return r
+}
+
+// CreateReport stores a report and returns it with its generated ID, or ErrAlreadyReported when the reporter has
+// an open report of the message.
+func (r *ReportRepoImpl) CreateReport(ctx context.Context, rep *Report) (*Report, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.reportCollection.InsertOne(timeoutCtx, rep)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, errormodel.ErrAlreadyReported
+	} else if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		rep.ID = oid
+	}
+	return rep, nil
+}
+
+// GetReportByID retrieves a report, returning mongo.ErrNoDocuments when it does not exist.
+func (r *ReportRepoImpl) GetReportByID(ctx context.Context, id primitive.ObjectID) (*Report, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var rep Report
+	if err := r.reportCollection.FindOne(timeoutCtx, bson.M{"_id": id}).Decode(&rep); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// CountOpenReports counts the open reports of a message, one per reporter.
+func (r *ReportRepoImpl) CountOpenReports(ctx context.Context, messageID string) (int64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return r.reportCollection.CountDocuments(timeoutCtx, bson.M{"message_id": messageID, "status": StatusOpen})
+}
+
+// GetReports retrieves at most limit reports of the given rooms, newest first, starting before beforeID unless it
+// is the zero ID. Reports of any status are returned when status is empty.
+func (r *ReportRepoImpl) GetReports(ctx context.Context, roomIDs []string, status string, beforeID primitive.ObjectID, limit int64) ([]Report, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"room_id": bson.M{"$in": roomIDs}}
+	if status != "" {
+		filter["status"] = status
+	}
+	if !beforeID.IsZero() {
+		filter["_id"] = bson.M{"$lt": beforeID}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	cursor, err := r.reportCollection.Find(timeoutCtx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			log.Default().Println(err.Error())
+		}
+	}(cursor, timeoutCtx)
+
+	reports := []Report{}
+	if err := cursor.All(timeoutCtx, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// ResolveReports resolves every open report of a message with the given action and returns how many it resolved.
+func (r *ReportRepoImpl) ResolveReports(ctx context.Context, messageID string, resolution string, resolvedBy string, at time.Time) (int64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"status":      StatusResolved,
+		"resolution":  resolution,
+		"resolved_by": resolvedBy,
+		"resolved_at": at,
+	}}
+	result, err := r.reportCollection.UpdateMany(timeoutCtx, bson.M{"message_id": messageID, "status": StatusOpen}, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,271 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/audit"
+	"messages-go/message"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/moderation"
+	"messages-go/room"
+	ws "messages-go/websocket"
+	"time"
+)
+
+// maxCommentSize bounds the comments reporters add to their reports.
+const maxCommentSize = 1000
+
+// ReportService defines the interface for reporting messages and for moderators to work through the report queues.
+type ReportService interface {
+	ReportMessage(ctx context.Context, roomId string, messageId string, callerId string, req request.ReportMessageRequest) (*Report, error)
+	ListRoomReports(ctx context.Context, roomId string, callerId string, status string, before string, limit int) ([]Report, error)
+	ListReports(ctx context.Context, callerId string, status string, before string, limit int) ([]Report, error)
+	ResolveReport(ctx context.Context, id string, callerId string, req request.ResolveReportRequest) (*Report, error)
+}
+
+// ReportServiceImpl queues the reports of messages for the moderators of their rooms, hides messages reported by
+// enough users until they are reviewed, and carries out the action a moderator resolves the reports with.
+type ReportServiceImpl struct {
+	reportRepo        ReportRepo
+	roomRepo          room.RoomRepo
+	messageService    message.MessageService
+	moderationService moderation.ModerationService
+	wsHandler         *ws.Handler
+	// auditor records resolutions in the audit log
+	auditor audit.Recorder
+	// hideThreshold is the number of open reports that hides a message; messages are never hidden when it is 0
+	hideThreshold int
+	now           func() time.Time
+}
+
+// NewReportService initializes and returns a new instance of ReportServiceImpl.
+func NewReportService(reportRepo ReportRepo, roomRepo room.RoomRepo, messageService message.MessageService, moderationService moderation.ModerationService, wsHandler *ws.Handler, auditor audit.Recorder, hideThreshold int) *ReportServiceImpl {
+	return &ReportServiceImpl{
+		reportRepo:        reportRepo,
+		roomRepo:          roomRepo,
+		messageService:    messageService,
+		moderationService: moderationService,
+		wsHandler:         wsHandler,
+		auditor:           auditor,
+		hideThreshold:     hideThreshold,
+		now:               time.Now,
+	}
+}
+
+// ReportMessage files the caller's report of a message of a room they can see. Users cannot report their own
+// messages, nor report a message again while their earlier report is open. Once the message has as many open
+// reports as the hide threshold, it is hidden from the room until a moderator reviews it.
+func (rs *ReportServiceImpl) ReportMessage(ctx context.Context, roomId string, messageId string, callerId string, req request.ReportMessageRequest) (*Report, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	if !validCategory(req.Category) || len(req.Comment) > maxCommentSize {
+		return nil, errormodel.ErrInvalidReport
+	}
+	roomData, err := rs.roomRepo.GetRoomByID(ctx, roomId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !roomData.VisibleTo(callerId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	msg, err := rs.messageService.GetMessage(ctx, roomId, messageId)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID == callerId {
+		return nil, errormodel.ErrForbidden
+	}
+
+	report, err := rs.reportRepo.CreateReport(ctx, &Report{
+		RoomID:     roomId,
+		MessageID:  msg.ID.Hex(),
+		ReporterID: callerId,
+		SenderID:   msg.SenderID,
+		Body:       msg.Body,
+		Category:   req.Category,
+		Comment:    req.Comment,
+		Status:     StatusOpen,
+		CreatedAt:  rs.now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rs.hideThreshold > 0 && !msg.Hidden {
+		rs.hideIfReported(ctx, msg)
+	}
+	return report, nil
+}
+
+// hideIfReported hides a message once it has reached the hide threshold and tells the room. Failures are logged
+// rather than failing the report, which has been filed.
+func (rs *ReportServiceImpl) hideIfReported(ctx context.Context, msg *message.Message) {
+	count, err := rs.reportRepo.CountOpenReports(ctx, msg.ID.Hex())
+	if err != nil {
+		log.Printf("Failed to count reports of message %s: %v", msg.ID.Hex(), err)
+		return
+	}
+	if count < int64(rs.hideThreshold) {
+		return
+	}
+	if _, err := rs.messageService.SetHidden(ctx, msg.RoomID, msg.ID.Hex(), true); err != nil {
+		log.Printf("Failed to hide reported message %s: %v", msg.ID.Hex(), err)
+		return
+	}
+	rs.broadcast(msg.RoomID, message.NewMessageHiddenEvent(msg.RoomID, msg.ID))
+}
+
+// ListRoomReports returns at most limit reports of a room, newest first, starting before the report with ID before
+// unless it is empty. Reports of any status are returned when status is empty. Only moderators may list reports.
+func (rs *ReportServiceImpl) ListRoomReports(ctx context.Context, roomId string, callerId string, status string, before string, limit int) ([]Report, error) {
+	if _, err := rs.moderatedRoom(ctx, roomId, callerId); err != nil {
+		return nil, err
+	}
+	return rs.listReports(ctx, []string{roomId}, status, before, limit)
+}
+
+// ListReports returns the reports of every room the caller moderates, paged as ListRoomReports does.
+func (rs *ReportServiceImpl) ListReports(ctx context.Context, callerId string, status string, before string, limit int) ([]Report, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	roomIds, err := rs.roomRepo.GetModeratedRoomIDs(ctx, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if len(roomIds) == 0 {
+		return []Report{}, nil
+	}
+	return rs.listReports(ctx, roomIds, status, before, limit)
+}
+
+func (rs *ReportServiceImpl) listReports(ctx context.Context, roomIds []string, status string, before string, limit int) ([]Report, error) {
+	var beforeID primitive.ObjectID
+	if before != "" {
+		var err error
+		if beforeID, err = primitive.ObjectIDFromHex(before); err != nil {
+			return nil, errormodel.ErrInvalidCursor
+		}
+	}
+	return rs.reportRepo.GetReports(ctx, roomIds, status, beforeID, int64(limit))
+}
+
+// ResolveReport carries out a moderator's action on the message of an open report and resolves every open report
+// of that message with it. Warnings and bans go through the moderation service, so they are logged and announced
+// like any other, and may not target moderators.
+func (rs *ReportServiceImpl) ResolveReport(ctx context.Context, id string, callerId string, req request.ResolveReportRequest) (*Report, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	if !validAction(req.Action) {
+		return nil, errormodel.ErrInvalidReport
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrReportNotFound
+	}
+	report, err := rs.reportRepo.GetReportByID(ctx, oid)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrReportNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err := rs.moderatedRoom(ctx, report.RoomID, callerId); errors.Is(err, errormodel.ErrRoomNotFound) {
+		// Reports of rooms the caller cannot see are not disclosed.
+		return nil, errormodel.ErrReportNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if report.Status != StatusOpen {
+		return nil, errormodel.ErrReportResolved
+	}
+
+	if err := rs.act(ctx, report, callerId, req); err != nil {
+		return nil, err
+	}
+	now := rs.now().UTC()
+	if _, err := rs.reportRepo.ResolveReports(ctx, report.MessageID, req.Action, callerId, now); err != nil {
+		return nil, err
+	}
+	report.Status, report.Resolution, report.ResolvedBy, report.ResolvedAt = StatusResolved, req.Action, callerId, &now
+	rs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionReportResolved,
+		ActorID:    callerId,
+		TargetType: audit.TargetReport,
+		TargetID:   report.ID.Hex(),
+		RoomID:     report.RoomID,
+		Before:     map[string]interface{}{"status": StatusOpen},
+		After:      map[string]interface{}{"status": StatusResolved, "resolution": req.Action, "message_id": report.MessageID},
+	})
+	return report, nil
+}
+
+// act carries out the action resolving the reports of a message.
+func (rs *ReportServiceImpl) act(ctx context.Context, report *Report, callerId string, req request.ResolveReportRequest) error {
+	target := request.ModerationRequest{UserID: report.SenderID, Reason: req.Reason, DurationSeconds: req.DurationSeconds}
+	switch req.Action {
+	case ActionDelete:
+		deleted, err := rs.messageService.DeleteMessage(ctx, report.RoomID, report.MessageID, callerId)
+		if errors.Is(err, errormodel.ErrMessageNotFound) {
+			// Deleted or expired since it was reported; there is nothing left to do.
+			return nil
+		} else if err != nil {
+			return err
+		}
+		rs.broadcast(deleted.RoomID, message.NewMessageDeletedEvent(deleted.RoomID, deleted.ID))
+	case ActionWarn:
+		_, err := rs.moderationService.Warn(ctx, report.RoomID, callerId, target)
+		return err
+	case ActionBan:
+		_, err := rs.moderationService.Ban(ctx, report.RoomID, callerId, target)
+		return err
+	case ActionDismiss:
+		msg, err := rs.messageService.GetMessage(ctx, report.RoomID, report.MessageID)
+		if errors.Is(err, errormodel.ErrMessageNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Hidden {
+			restored, err := rs.messageService.SetHidden(ctx, report.RoomID, report.MessageID, false)
+			if err != nil {
+				return err
+			}
+			rs.broadcast(report.RoomID, message.NewMessageRestoredEvent(restored))
+		}
+	}
+	return nil
+}
+
+// moderatedRoom returns the room when the caller may moderate it. Rooms the caller cannot see are reported as
+// not found.
+func (rs *ReportServiceImpl) moderatedRoom(ctx context.Context, roomId string, callerId string) (*room.Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	roomData, err := rs.roomRepo.GetRoomByID(ctx, roomId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !roomData.VisibleTo(callerId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	if !roomData.IsModerator(callerId) {
+		return nil, errormodel.ErrForbidden
+	}
+	return roomData, nil
+}
+
+func (rs *ReportServiceImpl) broadcast(roomId string, event map[string]interface{}) {
+	if rs.wsHandler != nil {
+		rs.wsHandler.BroadcastToRoom(roomId, event)
+	}
+}
@@ -0,0 +1,19 @@
+package report
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	"messages-go/message"
+	"messages-go/moderation"
+	"messages-go/room"
+	"messages-go/utils"
+	ws "messages-go/websocket"
+)
+
+// InitReportHandler wires the report handler. Resolutions that warn or ban go through the moderation service.
+func InitReportHandler(client *mongo.Client, roomRepo room.RoomRepo, messageService message.MessageService, moderationService moderation.ModerationService, wsHandler *ws.Handler, auditor audit.Recorder) (ReportHandler, ReportRepo, ReportService) {
+	repo := NewReportRepository(client)
+	service := NewReportService(repo, roomRepo, messageService, moderationService, wsHandler, auditor, utils.GetEnvInt("REPORT_HIDE_THRESHOLD", 3))
+	handler := NewReportHandler(service)
+	return handler, repo, service
+}
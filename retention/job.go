@@ -0,0 +1,180 @@
+// Package retention prunes room history according to server-wide and per-room retention policies.
+package retention
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/message"
+	"messages-go/pin"
+	"messages-go/room"
+	"time"
+)
+
+// roomPageSize is the number of rooms loaded at a time while walking every room.
+const roomPageSize = 100
+
+// Config holds the server-wide retention policy and the schedule of the pruning job. A zero MaxAge or MaxMessages
+// keeps history forever in that dimension unless a room overrides it.
+type Config struct {
+	MaxAge      time.Duration
+	MaxMessages int
+	Interval    time.Duration
+	// BatchSize is the most messages deleted by a single write, keeping each delete short.
+	BatchSize int
+	// DryRun logs what would be removed without removing anything.
+	DryRun bool
+}
+
+// Policy is the retention applied to a single room.
+type Policy struct {
+	MaxAge      time.Duration
+	MaxMessages int
+}
+
+// Job periodically removes the messages that fall outside the retention policy of their room. Pinned messages
+// are never removed.
+type Job struct {
+	messageRepo    message.MessageRepo
+	messageService message.MessageService
+	roomRepo       room.RoomRepo
+	pinRepo        pin.PinRepo
+	cfg            Config
+	now            func() time.Time
+}
+
+// NewJob initializes and returns a new retention Job. It runs once started.
+func NewJob(messageRepo message.MessageRepo, messageService message.MessageService, roomRepo room.RoomRepo, pinRepo pin.PinRepo, cfg Config) *Job {
+	return &Job{
+		messageRepo:    messageRepo,
+		messageService: messageService,
+		roomRepo:       roomRepo,
+		pinRepo:        pinRepo,
+		cfg:            cfg,
+		now:            time.Now,
+	}
+}
+
+// Start runs the job right away and then every cfg.Interval until ctx is cancelled.
+func (j *Job) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(j.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := j.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Retention run failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PolicyFor returns the retention policy of a room: its own settings where set, the server-wide policy otherwise.
+func (j *Job) PolicyFor(rm *room.Room) Policy {
+	policy := Policy{MaxAge: j.cfg.MaxAge, MaxMessages: j.cfg.MaxMessages}
+	if rm.Settings.RetentionDays > 0 {
+		policy.MaxAge = time.Duration(rm.Settings.RetentionDays) * 24 * time.Hour
+	}
+	if rm.Settings.RetentionMessages > 0 {
+		policy.MaxMessages = rm.Settings.RetentionMessages
+	}
+	return policy
+}
+
+// Run applies the retention policy of every room once and returns how many messages were removed, or would have
+// been in dry-run mode.
+func (j *Job) Run(ctx context.Context) (int64, error) {
+	var total int64
+	afterID := primitive.NilObjectID
+	for {
+		rooms, err := j.roomRepo.GetRoomsAfter(ctx, afterID, roomPageSize)
+		if err != nil {
+			return total, err
+		}
+		for i := range rooms {
+			removed, err := j.PruneRoom(ctx, &rooms[i])
+			if err != nil {
+				log.Printf("Retention failed for room %s: %v", rooms[i].ID.Hex(), err)
+				continue
+			}
+			total += removed
+		}
+		if len(rooms) < roomPageSize {
+			return total, nil
+		}
+		afterID = rooms[len(rooms)-1].ID
+	}
+}
+
+// PruneRoom removes the messages of a room that are older than its maximum age or beyond its newest MaxMessages,
+// except pinned ones, and returns how many were removed.
+func (j *Job) PruneRoom(ctx context.Context, rm *room.Room) (int64, error) {
+	policy := j.PolicyFor(rm)
+	roomID := rm.ID.Hex()
+
+	// Messages are removed below a cutoff ID: IDs sort by creation time, and the newest kept message bounds the
+	// count limit.
+	var cutoff primitive.ObjectID
+	if policy.MaxAge > 0 {
+		cutoff = primitive.NewObjectIDFromTimestamp(j.now().Add(-policy.MaxAge))
+	}
+	if policy.MaxMessages > 0 {
+		oldestKept, err := j.messageRepo.GetNthNewestMessageID(ctx, roomID, int64(policy.MaxMessages))
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, err
+		}
+		if err == nil && oldestKept.Hex() > cutoff.Hex() {
+			cutoff = oldestKept
+		}
+	}
+	if cutoff.IsZero() {
+		return 0, nil
+	}
+
+	pins, err := j.pinRepo.GetPinsByRoom(ctx, roomID)
+	if err != nil {
+		return 0, err
+	}
+	exempt := make([]primitive.ObjectID, 0, len(pins))
+	for _, p := range pins {
+		if oid, err := primitive.ObjectIDFromHex(p.MessageID); err == nil {
+			exempt = append(exempt, oid)
+		}
+	}
+
+	var removed int64
+	afterID := primitive.NilObjectID
+	for ctx.Err() == nil {
+		ids, err := j.messageRepo.GetMessageIDsBefore(ctx, roomID, afterID, cutoff, exempt, int64(max(1, j.cfg.BatchSize)))
+		if err != nil {
+			return removed, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		if j.cfg.DryRun {
+			log.Printf("Retention dry run: would remove %d messages from room %s (%s..%s)", len(ids), roomID, ids[0].Hex(), ids[len(ids)-1].Hex())
+			removed += int64(len(ids))
+		} else {
+			n, err := j.messageService.PruneMessages(ctx, roomID, ids)
+			if err != nil {
+				return removed, err
+			}
+			log.Printf("Retention removed %d messages from room %s (%s..%s)", n, roomID, ids[0].Hex(), ids[len(ids)-1].Hex())
+			removed += n
+		}
+		afterID = ids[len(ids)-1]
+	}
+
+	if removed > 0 {
+		log.Printf("Retention pruned room %s: %d messages (max age %s, max messages %d, dry run %t)", roomID, removed, policy.MaxAge, policy.MaxMessages, j.cfg.DryRun)
+	}
+	return removed, nil
+}
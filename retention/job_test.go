@@ -0,0 +1,271 @@
+package retention
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/message"
+	"messages-go/pin"
+	"messages-go/room"
+	"slices"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// messageID returns the ID of the nth message created at t, so that IDs sort by creation as in MongoDB.
+func messageID(t time.Time, n int) primitive.ObjectID {
+	id := primitive.NewObjectIDFromTimestamp(t)
+	id[9], id[10], id[11] = byte(n>>16), byte(n>>8), byte(n)
+	return id
+}
+
+// storedIDs is a MessageRepo holding the message IDs of rooms in ID order.
+type storedIDs struct {
+	message.MessageRepo
+	rooms map[string][]primitive.ObjectID
+	// batches records the IDs of every PruneMessages call
+	batches [][]primitive.ObjectID
+}
+
+func (s *storedIDs) GetNthNewestMessageID(_ context.Context, roomID string, n int64) (primitive.ObjectID, error) {
+	ids := s.rooms[roomID]
+	if int64(len(ids)) < n {
+		return primitive.NilObjectID, mongo.ErrNoDocuments
+	}
+	return ids[int64(len(ids))-n], nil
+}
+
+func (s *storedIDs) GetMessageIDsBefore(_ context.Context, roomID string, afterID primitive.ObjectID, beforeID primitive.ObjectID, exclude []primitive.ObjectID, limit int64) ([]primitive.ObjectID, error) {
+	var found []primitive.ObjectID
+	for _, id := range s.rooms[roomID] {
+		if id.Hex() > afterID.Hex() && id.Hex() < beforeID.Hex() && !slices.Contains(exclude, id) && int64(len(found)) < limit {
+			found = append(found, id)
+		}
+	}
+	return found, nil
+}
+
+// pruner is a MessageService pruning the messages of a storedIDs.
+type pruner struct {
+	message.MessageService
+	*storedIDs
+}
+
+func (s pruner) PruneMessages(_ context.Context, roomID string, ids []primitive.ObjectID) (int64, error) {
+	s.batches = append(s.batches, ids)
+	before := len(s.rooms[roomID])
+	s.rooms[roomID] = slices.DeleteFunc(s.rooms[roomID], func(id primitive.ObjectID) bool { return slices.Contains(ids, id) })
+	return int64(before - len(s.rooms[roomID])), nil
+}
+
+// fakePins is a PinRepo serving the pins of rooms.
+type fakePins struct {
+	pin.PinRepo
+	pins map[string][]pin.Pin
+}
+
+func (p *fakePins) GetPinsByRoom(_ context.Context, roomID string) ([]pin.Pin, error) {
+	return p.pins[roomID], nil
+}
+
+// pagedRooms is a RoomRepo serving rooms in ID order.
+type pagedRooms struct {
+	room.RoomRepo
+	rooms []room.Room
+}
+
+func (r *pagedRooms) GetRoomsAfter(_ context.Context, afterID primitive.ObjectID, limit int64) ([]room.Room, error) {
+	var page []room.Room
+	for _, rm := range r.rooms {
+		if rm.ID.Hex() > afterID.Hex() && int64(len(page)) < limit {
+			page = append(page, rm)
+		}
+	}
+	return page, nil
+}
+
+// history returns a room with one message a day for days days, oldest first, along with their IDs.
+func history(days int) (*room.Room, []primitive.ObjectID) {
+	rm := &room.Room{ID: primitive.NewObjectID()}
+	ids := make([]primitive.ObjectID, 0, days)
+	for i := days; i > 0; i-- {
+		ids = append(ids, messageID(now.Add(-time.Duration(i)*24*time.Hour), i))
+	}
+	return rm, ids
+}
+
+func newTestJob(store *storedIDs, pins *fakePins, rooms *pagedRooms, cfg Config) *Job {
+	j := NewJob(store, pruner{storedIDs: store}, rooms, pins, cfg)
+	j.now = func() time.Time { return now }
+	return j
+}
+
+func pinOf(rm *room.Room, id primitive.ObjectID) pin.Pin {
+	return pin.Pin{RoomID: rm.ID.Hex(), MessageID: id.Hex()}
+}
+
+func TestPruneRoomMaxAgeKeepsPins(t *testing.T) {
+	rm, ids := history(10)
+	store := &storedIDs{rooms: map[string][]primitive.ObjectID{rm.ID.Hex(): slices.Clone(ids)}}
+	pins := &fakePins{pins: map[string][]pin.Pin{rm.ID.Hex(): {pinOf(rm, ids[1]), {MessageID: "not an id"}}}}
+	j := newTestJob(store, pins, &pagedRooms{}, Config{MaxAge: 5*24*time.Hour - time.Hour, BatchSize: 100})
+
+	removed, err := j.PruneRoom(context.Background(), rm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Messages 10 to 5 days old are past the age, except the pinned one 9 days old.
+	if removed != 5 {
+		t.Errorf("removed %d messages, want 5", removed)
+	}
+	if want := append([]primitive.ObjectID{ids[1]}, ids[6:]...); !slices.Equal(store.rooms[rm.ID.Hex()], want) {
+		t.Errorf("kept %v, want %v", store.rooms[rm.ID.Hex()], want)
+	}
+}
+
+func TestPruneRoomMaxMessagesKeepsPins(t *testing.T) {
+	rm, ids := history(10)
+	store := &storedIDs{rooms: map[string][]primitive.ObjectID{rm.ID.Hex(): slices.Clone(ids)}}
+	pins := &fakePins{pins: map[string][]pin.Pin{rm.ID.Hex(): {pinOf(rm, ids[0]), pinOf(rm, ids[8])}}}
+	j := newTestJob(store, pins, &pagedRooms{}, Config{MaxMessages: 3, BatchSize: 100})
+
+	removed, err := j.PruneRoom(context.Background(), rm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The three newest stay, as does the oldest, which is pinned.
+	if removed != 6 {
+		t.Errorf("removed %d messages, want 6", removed)
+	}
+	if want := append([]primitive.ObjectID{ids[0]}, ids[7:]...); !slices.Equal(store.rooms[rm.ID.Hex()], want) {
+		t.Errorf("kept %v, want %v", store.rooms[rm.ID.Hex()], want)
+	}
+
+	// A room within its limit is left alone.
+	small, smallIDs := history(2)
+	store.rooms[small.ID.Hex()] = slices.Clone(smallIDs)
+	if removed, err := j.PruneRoom(context.Background(), small); err != nil || removed != 0 || len(store.rooms[small.ID.Hex()]) != 2 {
+		t.Errorf("PruneRoom of a small room = %d, %v", removed, err)
+	}
+}
+
+func TestPruneRoomUsesTheStricterLimit(t *testing.T) {
+	rm, ids := history(10)
+	rm.Settings = room.Settings{RetentionDays: 3, RetentionMessages: 6}
+	store := &storedIDs{rooms: map[string][]primitive.ObjectID{rm.ID.Hex(): slices.Clone(ids)}}
+	j := newTestJob(store, &fakePins{}, &pagedRooms{}, Config{MaxAge: 365 * 24 * time.Hour, BatchSize: 100})
+
+	if policy := j.PolicyFor(rm); policy != (Policy{MaxAge: 3 * 24 * time.Hour, MaxMessages: 6}) {
+		t.Errorf("PolicyFor = %+v, want the room's settings", policy)
+	}
+	if policy := j.PolicyFor(&room.Room{}); policy != (Policy{MaxAge: 365 * 24 * time.Hour}) {
+		t.Errorf("PolicyFor a room without settings = %+v, want the server's policy", policy)
+	}
+	// Three days keeps fewer messages than six messages would.
+	if _, err := j.PruneRoom(context.Background(), rm); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.rooms[rm.ID.Hex()]; !slices.Equal(got, ids[8:]) {
+		t.Errorf("kept %v, want the messages of the last two days", got)
+	}
+
+	unlimited := newTestJob(store, &fakePins{}, &pagedRooms{}, Config{})
+	if removed, err := unlimited.PruneRoom(context.Background(), &room.Room{ID: rm.ID}); err != nil || removed != 0 {
+		t.Errorf("PruneRoom without a policy = %d, %v", removed, err)
+	}
+}
+
+func TestPruneRoomDryRun(t *testing.T) {
+	rm, ids := history(10)
+	store := &storedIDs{rooms: map[string][]primitive.ObjectID{rm.ID.Hex(): slices.Clone(ids)}}
+	pins := &fakePins{pins: map[string][]pin.Pin{rm.ID.Hex(): {pinOf(rm, ids[2])}}}
+	j := newTestJob(store, pins, &pagedRooms{}, Config{MaxMessages: 2, BatchSize: 3, DryRun: true})
+
+	removed, err := j.PruneRoom(context.Background(), rm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 7 {
+		t.Errorf("dry run reported %d messages, want the 7 a real run would remove", removed)
+	}
+	if len(store.batches) != 0 || !slices.Equal(store.rooms[rm.ID.Hex()], ids) {
+		t.Errorf("dry run removed messages: %v", store.batches)
+	}
+}
+
+func TestPruneRoomBatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		days      int
+		batchSize int
+		pinned    []int
+		want      []int
+	}{
+		{name: "partial last batch", days: 10, batchSize: 3, want: []int{3, 3, 2}},
+		{name: "exact batches", days: 8, batchSize: 3, want: []int{3, 3}},
+		{name: "single batch", days: 10, batchSize: 100, want: []int{8}},
+		{name: "one at a time without a batch size", days: 5, batchSize: 0, want: []int{1, 1, 1}},
+		{name: "pins across batches", days: 10, batchSize: 3, pinned: []int{2, 3}, want: []int{3, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm, ids := history(tt.days)
+			store := &storedIDs{rooms: map[string][]primitive.ObjectID{rm.ID.Hex(): slices.Clone(ids)}}
+			pins := &fakePins{pins: map[string][]pin.Pin{}}
+			for _, i := range tt.pinned {
+				pins.pins[rm.ID.Hex()] = append(pins.pins[rm.ID.Hex()], pinOf(rm, ids[i]))
+			}
+			j := newTestJob(store, pins, &pagedRooms{}, Config{MaxMessages: 2, BatchSize: tt.batchSize})
+
+			removed, err := j.PruneRoom(context.Background(), rm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sizes []int
+			var pruned []primitive.ObjectID
+			for _, batch := range store.batches {
+				sizes = append(sizes, len(batch))
+				pruned = append(pruned, batch...)
+			}
+			if !slices.Equal(sizes, tt.want) {
+				t.Errorf("batch sizes = %v, want %v", sizes, tt.want)
+			}
+			// Batches follow each other in ID order without overlapping or skipping a message.
+			var want []primitive.ObjectID
+			for i, id := range ids[:len(ids)-2] {
+				if !slices.Contains(tt.pinned, i) {
+					want = append(want, id)
+				}
+			}
+			if !slices.Equal(pruned, want) || removed != int64(len(want)) {
+				t.Errorf("pruned %v (%d), want %v", pruned, removed, want)
+			}
+		})
+	}
+}
+
+func TestRunWalksEveryRoom(t *testing.T) {
+	store := &storedIDs{rooms: make(map[string][]primitive.ObjectID)}
+	rooms := &pagedRooms{}
+	for i := 0; i < roomPageSize+1; i++ {
+		rm, ids := history(3)
+		rm.ID = messageID(now, i)
+		rooms.rooms = append(rooms.rooms, *rm)
+		store.rooms[rm.ID.Hex()] = ids
+	}
+	j := newTestJob(store, &fakePins{}, rooms, Config{MaxMessages: 1, BatchSize: 10})
+
+	total, err := j.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2*int64(roomPageSize+1) {
+		t.Errorf("Run removed %d messages, want 2 from each of %d rooms", total, roomPageSize+1)
+	}
+	last := rooms.rooms[roomPageSize].ID.Hex()
+	if len(store.rooms[last]) != 1 {
+		t.Errorf("the room on the second page kept %d messages, want 1", len(store.rooms[last]))
+	}
+}
@@ -0,0 +1,26 @@
+package retention
+
+import (
+	"context"
+	"messages-go/message"
+	"messages-go/pin"
+	"messages-go/room"
+	"messages-go/utils"
+	"time"
+)
+
+// InitRetentionJob configures the retention job from the environment and starts it, running until ctx is
+// cancelled.
+func InitRetentionJob(ctx context.Context, messageRepo message.MessageRepo, messageService message.MessageService, roomRepo room.RoomRepo, pinRepo pin.PinRepo) *Job {
+	cfg := Config{
+		MaxAge:      time.Duration(utils.GetEnvInt("RETENTION_DAYS", 0)) * 24 * time.Hour,
+		MaxMessages: utils.GetEnvInt("RETENTION_MAX_MESSAGES", 0),
+		Interval:    utils.GetEnvDuration("RETENTION_INTERVAL", time.Hour),
+		BatchSize:   utils.GetEnvInt("RETENTION_BATCH_SIZE", 500),
+		DryRun:      utils.GetEnv("RETENTION_DRY_RUN", "false") == "true",
+	}
+
+	job := NewJob(messageRepo, messageService, roomRepo, pinRepo, cfg)
+	job.Start(ctx)
+	return job
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	"log"
+	"messages-go/auth"
 	"messages-go/models/errormodel"
 	"messages-go/models/request"
 	"messages-go/models/response"
@@ -15,6 +16,12 @@ type RoomHandler interface {
 	CreateRoom(c *fiber.Ctx) error
 	GetRoom(c *fiber.Ctx) error
 	UpdateRoomName(c *fiber.Ctx) error
+	AddMember(c *fiber.Ctx) error
+	RemoveMember(c *fiber.Ctx) error
+	AddModerator(c *fiber.Ctx) error
+	RemoveModerator(c *fiber.Ctx) error
+	UpdateSettings(c *fiber.Ctx) error
+	RequireRoomAccess(param string) fiber.Handler
 }
 
 // RoomHandlerImpl implements the RoomHandler interface and handles HTTP requests related to room operations.
@@ -41,8 +48,14 @@ func (rh *RoomHandlerImpl) CreateRoom(c *fiber.Ctx) error {
 
 	log.Println("Create Room Request Received.")
 
-	roomResp, err := rh.roomService.CreateRoom(c.Context(), req)
-	if err != nil {
+	roomResp, err := rh.roomService.CreateRoom(c.Context(), req, auth.CallerID(c))
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Private Rooms Require A Caller Identity.",
+		})
+	} else if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
 			Error:   err.Error(),
 			Status:  fiber.StatusInternalServerError,
@@ -73,7 +86,7 @@ func (rh *RoomHandlerImpl) GetRoom(c *fiber.Ctx) error {
 
 	log.Println("Get Room with name: ", roomName, " Request Received.")
 
-	getRoomResp, err := rh.roomService.GetRoom(c.Context(), roomName)
+	getRoomResp, err := rh.roomService.GetRoom(c.Context(), roomName, auth.CallerID(c))
 
 	if errors.Is(err, errormodel.ErrRoomNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
@@ -96,7 +109,8 @@ func (rh *RoomHandlerImpl) GetRoom(c *fiber.Ctx) error {
 	})
 }
 
-// UpdateRoomName handles updating the name of an existing room using the room ID and the new name provided in the request body.
+// UpdateRoomName handles renaming a room on behalf of its owner or one of its moderators, using the room ID and the
+// new name provided in the request body.
 func (rh *RoomHandlerImpl) UpdateRoomName(c *fiber.Ctx) error {
 	roomId := c.Params("id")
 	var req request.UpdateRoomRequest
@@ -108,10 +122,17 @@ func (rh *RoomHandlerImpl) UpdateRoomName(c *fiber.Ctx) error {
 			Message: "Invalid Request Body",
 		})
 	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   "name is required",
+			Status:  fiber.StatusBadRequest,
+			Message: "Room Name Is Required.",
+		})
+	}
 
 	log.Println("Update Room with id ", roomId, " Request Received.")
 
-	roomResp, err := rh.roomService.UpdateRoomName(c.Context(), roomId, *req.Name)
+	roomResp, err := rh.roomService.UpdateRoomName(c.Context(), roomId, auth.CallerID(c), strings.TrimSpace(*req.Name))
 
 	if errors.Is(err, errormodel.ErrRoomNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
@@ -119,6 +140,18 @@ func (rh *RoomHandlerImpl) UpdateRoomName(c *fiber.Ctx) error {
 			Status:  fiber.StatusNotFound,
 			Message: "No Room Found with given id.",
 		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Only The Owner And Moderators May Rename The Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidRoomID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Room id is malformed.",
+		})
 	} else if errors.Is(err, errormodel.ErrMongoWriteFailed) {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
 			Error:   err.Error(),
@@ -138,5 +171,216 @@ func (rh *RoomHandlerImpl) UpdateRoomName(c *fiber.Ctx) error {
 		Message: "Room Updated",
 		Data:    roomResp,
 	})
+}
+
+// AddMember handles adding a user to a room, defaulting to the caller when no user ID is given in the request body.
+func (rh *RoomHandlerImpl) AddMember(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	var req request.AddMemberRequest
 
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	callerId := auth.CallerID(c)
+	userId := callerId
+	if req.UserID != nil && strings.TrimSpace(*req.UserID) != "" {
+		userId = strings.TrimSpace(*req.UserID)
+	}
+
+	log.Println("Add Member ", userId, " to Room with id ", roomId, " Request Received.")
+
+	roomResp, err := rh.roomService.AddMember(c.Context(), roomId, callerId, userId)
+	if err != nil {
+		return membershipError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Member Added",
+		Data:    roomResp,
+	})
+}
+
+// RemoveMember handles removing a user from a room, either the caller leaving or the owner removing a member.
+func (rh *RoomHandlerImpl) RemoveMember(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	userId := c.Params("userId")
+
+	log.Println("Remove Member ", userId, " from Room with id ", roomId, " Request Received.")
+
+	roomResp, err := rh.roomService.RemoveMember(c.Context(), roomId, auth.CallerID(c), userId)
+	if err != nil {
+		return membershipError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Member Removed",
+		Data:    roomResp,
+	})
+}
+
+// AddModerator handles the owner appointing the user given in the request body as a moderator of a room.
+func (rh *RoomHandlerImpl) AddModerator(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	var req request.AddModeratorRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+	if req.UserID == nil || strings.TrimSpace(*req.UserID) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   "Missing user id",
+			Status:  fiber.StatusBadRequest,
+			Message: "user_id is required to be not empty.",
+		})
+	}
+
+	log.Println("Add Moderator ", *req.UserID, " to Room with id ", roomId, " Request Received.")
+
+	roomResp, err := rh.roomService.AddModerator(c.Context(), roomId, auth.CallerID(c), strings.TrimSpace(*req.UserID))
+	if err != nil {
+		return membershipError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Moderator Added",
+		Data:    roomResp,
+	})
+}
+
+// RemoveModerator handles the owner dismissing a moderator of a room.
+func (rh *RoomHandlerImpl) RemoveModerator(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	userId := c.Params("userId")
+
+	log.Println("Remove Moderator ", userId, " from Room with id ", roomId, " Request Received.")
+
+	roomResp, err := rh.roomService.RemoveModerator(c.Context(), roomId, auth.CallerID(c), userId)
+	if err != nil {
+		return membershipError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Moderator Removed",
+		Data:    roomResp,
+	})
+}
+
+// UpdateSettings handles a moderator changing the settings of a room.
+func (rh *RoomHandlerImpl) UpdateSettings(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	var req request.UpdateRoomSettingsRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	log.Println("Update Settings of Room with id ", roomId, " Request Received.")
+
+	roomResp, err := rh.roomService.UpdateSettings(c.Context(), roomId, auth.CallerID(c), req)
+	if errors.Is(err, errormodel.ErrInvalidSettings) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Room Settings Must Not Be Negative, And Filters Must Be Known.",
+		})
+	} else if err != nil {
+		return membershipError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Room Settings Updated",
+		Data:    roomResp,
+	})
+}
+
+// RequireRoomAccess returns a middleware that only lets the request through when the room identified by the given
+// path parameter exists and is visible to the caller.
+func (rh *RoomHandlerImpl) RequireRoomAccess(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		_, err := rh.roomService.GetAccessibleRoom(c.Context(), c.Params(param), auth.CallerID(c))
+		if errors.Is(err, errormodel.ErrBanned) {
+			return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+				Error:   err.Error(),
+				Status:  fiber.StatusForbidden,
+				Message: "You Are Banned From This Room.",
+			})
+		} else if errors.Is(err, errormodel.ErrRoomNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+				Error:   err.Error(),
+				Status:  fiber.StatusNotFound,
+				Message: "No Room Found with given id.",
+			})
+		} else if errors.Is(err, errormodel.ErrInvalidRoomID) {
+			return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+				Error:   err.Error(),
+				Status:  fiber.StatusBadRequest,
+				Message: "Room id is malformed.",
+			})
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+				Error:   err.Error(),
+				Status:  fiber.StatusInternalServerError,
+				Message: "Failed To Get Room",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// membershipError maps service errors raised while changing room membership to API responses.
+func membershipError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Membership Changes Require A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrBanned) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Banned Users Cannot Join The Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Not Allowed To Make This Change To The Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Room Found with given id.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidRoomID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Room id is malformed.",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed To Update Room Members",
+	})
 }
@@ -1,9 +1,58 @@
 package room
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+)
 
 // Room represents a struct containing information about a room, including its ID and name.
+// Private rooms are only visible to their members; public rooms are visible to everyone.
 type Room struct {
-	ID   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name string             `bson:"name,omitempty," json:"name"`
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name    string             `bson:"name,omitempty," json:"name"`
+	Topic   string             `bson:"topic,omitempty" json:"topic,omitempty"`
+	OwnerID string             `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+	Private bool               `bson:"private" json:"private"`
+	Members []string           `bson:"members,omitempty" json:"members,omitempty"`
+	// Moderators are appointed by the owner, who is always a moderator as well
+	Moderators []string `bson:"moderators,omitempty" json:"moderators,omitempty"`
+	Settings   Settings `bson:"settings" json:"settings"`
+}
+
+// Settings holds the per-room overrides of server-wide defaults. Zero values fall back to the defaults.
+type Settings struct {
+	// PinLimit caps the number of messages pinned in the room
+	PinLimit int `bson:"pin_limit,omitempty" json:"pin_limit,omitempty"`
+	// MessageTTLSeconds makes messages posted without a TTL of their own expire after this many seconds
+	MessageTTLSeconds int `bson:"message_ttl_seconds,omitempty" json:"message_ttl_seconds,omitempty"`
+	// RetentionDays and RetentionMessages cap the history kept for the room: messages older than RetentionDays,
+	// or beyond the newest RetentionMessages, are pruned
+	RetentionDays     int `bson:"retention_days,omitempty" json:"retention_days,omitempty"`
+	RetentionMessages int `bson:"retention_messages,omitempty" json:"retention_messages,omitempty"`
+	// SlowModeSeconds is the interval at which each user may post once SlowModeBurst posts, one by default, have
+	// been used up
+	SlowModeSeconds int `bson:"slow_mode_seconds,omitempty" json:"slow_mode_seconds,omitempty"`
+	SlowModeBurst   int `bson:"slow_mode_burst,omitempty" json:"slow_mode_burst,omitempty"`
+	// DuplicateWindowSeconds rejects a post repeating the sender's own message posted less than this many seconds
+	// before
+	DuplicateWindowSeconds int `bson:"duplicate_window_seconds,omitempty" json:"duplicate_window_seconds,omitempty"`
+	// Filters names the content filters posts to the room run through, in order, replacing the server's defaults
+	Filters []string `bson:"filters,omitempty" json:"filters,omitempty"`
+	// FilterWords are masked in the room in addition to the server's wordlist, when the profanity filter runs
+	FilterWords []string `bson:"filter_words,omitempty" json:"filter_words,omitempty"`
+}
+
+// IsMember reports whether the given user has joined the room.
+func (r *Room) IsMember(userID string) bool {
+	return userID != "" && slices.Contains(r.Members, userID)
+}
+
+// IsModerator reports whether the given user may moderate the room.
+func (r *Room) IsModerator(userID string) bool {
+	return userID != "" && (r.OwnerID == userID || slices.Contains(r.Moderators, userID))
+}
+
+// VisibleTo reports whether the given user may read the room and its messages.
+func (r *Room) VisibleTo(userID string) bool {
+	return !r.Private || r.IsMember(userID)
 }
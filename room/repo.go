@@ -7,6 +7,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"messages-go/models/errormodel"
 	"os"
 	"time"
 )
@@ -17,6 +18,15 @@ type RoomRepo interface {
 	GetRoomByID(ctx context.Context, id string) (*Room, error)
 	GetRoomByName(ctx context.Context, name string) (*Room, error)
 	UpdateRoomName(ctx context.Context, id string, name string) (*Room, error)
+	AddMember(ctx context.Context, id string, userID string) (*Room, error)
+	RemoveMember(ctx context.Context, id string, userID string) (*Room, error)
+	AddModerator(ctx context.Context, id string, userID string) (*Room, error)
+	RemoveModerator(ctx context.Context, id string, userID string) (*Room, error)
+	UpdateSettings(ctx context.Context, id string, settings Settings) (*Room, error)
+	UpdateTopic(ctx context.Context, id string, topic string) (*Room, error)
+	GetVisibleRoomIDs(ctx context.Context, userID string) ([]string, error)
+	GetRoomsAfter(ctx context.Context, afterID primitive.ObjectID, limit int64) ([]Room, error)
+	GetModeratedRoomIDs(ctx context.Context, userID string) ([]string, error)
 }
 
 // RoomRepoImpl is a concrete implementation of the RoomRepo interface.
@@ -56,7 +66,7 @@ func (r *RoomRepoImpl) GetRoomByID(ctx context.Context, id string) (*Room, error
 
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, errors.New("invalid ID format")
+		return nil, errormodel.ErrInvalidRoomID
 	}
 
 	var rm Room
@@ -88,7 +98,7 @@ func (r *RoomRepoImpl) UpdateRoomName(ctx context.Context, id string, name strin
 
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, errors.New("invalid ID format")
+		return nil, errormodel.ErrInvalidRoomID
 	}
 
 	var updatedRoom Room
@@ -105,3 +115,132 @@ func (r *RoomRepoImpl) UpdateRoomName(ctx context.Context, id string, name strin
 	}
 	return &updatedRoom, nil
 }
+
+// AddMember adds a user to the members of a room identified by its ID and returns the updated room or an error.
+func (r *RoomRepoImpl) AddMember(ctx context.Context, id string, userID string) (*Room, error) {
+	return r.updateRoom(ctx, id, bson.M{"$addToSet": bson.M{"members": userID}})
+}
+
+// RemoveMember removes a user from the members of a room identified by its ID and returns the updated room or an error.
+func (r *RoomRepoImpl) RemoveMember(ctx context.Context, id string, userID string) (*Room, error) {
+	return r.updateRoom(ctx, id, bson.M{"$pull": bson.M{"members": userID}})
+}
+
+// AddModerator adds a user to the moderators of a room identified by its ID and returns the updated room or an error.
+func (r *RoomRepoImpl) AddModerator(ctx context.Context, id string, userID string) (*Room, error) {
+	return r.updateRoom(ctx, id, bson.M{"$addToSet": bson.M{"moderators": userID}})
+}
+
+// RemoveModerator removes a user from the moderators of a room identified by its ID and returns the updated room or an error.
+func (r *RoomRepoImpl) RemoveModerator(ctx context.Context, id string, userID string) (*Room, error) {
+	return r.updateRoom(ctx, id, bson.M{"$pull": bson.M{"moderators": userID}})
+}
+
+// UpdateSettings replaces the settings of a room identified by its ID and returns the updated room or an error.
+func (r *RoomRepoImpl) UpdateSettings(ctx context.Context, id string, settings Settings) (*Room, error) {
+	return r.updateRoom(ctx, id, bson.M{"$set": bson.M{"settings": settings}})
+}
+
+// UpdateTopic sets the topic of a room identified by its ID, removing it when empty, and returns the updated room
+// or an error.
+func (r *RoomRepoImpl) UpdateTopic(ctx context.Context, id string, topic string) (*Room, error) {
+	if topic == "" {
+		return r.updateRoom(ctx, id, bson.M{"$unset": bson.M{"topic": ""}})
+	}
+	return r.updateRoom(ctx, id, bson.M{"$set": bson.M{"topic": topic}})
+}
+
+// GetVisibleRoomIDs returns the IDs of all public rooms and of the private rooms the given user is a member of.
+func (r *RoomRepoImpl) GetVisibleRoomIDs(ctx context.Context, userID string) ([]string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"private": bson.M{"$ne": true}}
+	if userID != "" {
+		filter = bson.M{"$or": bson.A{filter, bson.M{"members": userID}}}
+	}
+
+	cursor, err := r.roomCollection.Find(timeoutCtx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var rooms []Room
+	if err := cursor.All(timeoutCtx, &rooms); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(rooms))
+	for _, rm := range rooms {
+		ids = append(ids, rm.ID.Hex())
+	}
+	return ids, nil
+}
+
+// GetModeratedRoomIDs returns the IDs of the rooms the given user owns or moderates.
+func (r *RoomRepoImpl) GetModeratedRoomIDs(ctx context.Context, userID string) ([]string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"$or": bson.A{bson.M{"owner_id": userID}, bson.M{"moderators": userID}}}
+	cursor, err := r.roomCollection.Find(timeoutCtx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var rooms []Room
+	if err := cursor.All(timeoutCtx, &rooms); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(rooms))
+	for _, rm := range rooms {
+		ids = append(ids, rm.ID.Hex())
+	}
+	return ids, nil
+}
+
+// GetRoomsAfter returns up to limit rooms whose ID sorts after afterID, in ID order, for jobs walking every room.
+func (r *RoomRepoImpl) GetRoomsAfter(ctx context.Context, afterID primitive.ObjectID, limit int64) ([]Room, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+	cursor, err := r.roomCollection.Find(timeoutCtx, bson.M{"_id": bson.M{"$gt": afterID}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var rooms []Room
+	if err := cursor.All(timeoutCtx, &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// updateRoom applies an update document to the room identified by its ID and returns the updated room.
+func (r *RoomRepoImpl) updateRoom(ctx context.Context, id string, update bson.M) (*Room, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrInvalidRoomID
+	}
+
+	var updatedRoom Room
+	err = r.roomCollection.FindOneAndUpdate(
+		timeoutCtx,
+		bson.M{"_id": objID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedRoom)
+
+	if err != nil {
+		return nil, err
+	}
+	return &updatedRoom, nil
+}
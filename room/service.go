@@ -4,31 +4,71 @@ import (
 	"context"
 	"errors"
 	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	"messages-go/message/filter"
 	"messages-go/models/errormodel"
 	"messages-go/models/request"
 	"messages-go/utils"
+	ws "messages-go/websocket"
 	"strings"
 )
 
+// Limits on the words a room masks in addition to the server's wordlist.
+const (
+	maxFilterWords    = 200
+	maxFilterWordSize = 64
+)
+
+// maxTopicSize bounds the topics of rooms.
+const maxTopicSize = 250
+
 // RoomService defines the interface for managing room operations, including creation and retrieval of rooms.
 type RoomService interface {
-	CreateRoom(ctx context.Context, req request.CreateRoomRequest) (*Room, error)
-	GetRoom(ctx context.Context, name string) (*Room, error)
-	UpdateRoomName(ctx context.Context, id string, name string) (*Room, error)
+	CreateRoom(ctx context.Context, req request.CreateRoomRequest, ownerId string) (*Room, error)
+	GetRoom(ctx context.Context, name string, callerId string) (*Room, error)
+	GetReadableRoom(ctx context.Context, id string, callerId string) (*Room, error)
+	GetAccessibleRoom(ctx context.Context, id string, callerId string) (*Room, error)
+	UpdateRoomName(ctx context.Context, id string, callerId string, name string) (*Room, error)
+	AddMember(ctx context.Context, id string, callerId string, userId string) (*Room, error)
+	RemoveMember(ctx context.Context, id string, callerId string, userId string) (*Room, error)
+	EvictMember(ctx context.Context, id string, callerId string, userId string) (*Room, error)
+	AddModerator(ctx context.Context, id string, callerId string, userId string) (*Room, error)
+	RemoveModerator(ctx context.Context, id string, callerId string, userId string) (*Room, error)
+	UpdateSettings(ctx context.Context, id string, callerId string, req request.UpdateRoomSettingsRequest) (*Room, error)
+	UpdateTopic(ctx context.Context, id string, callerId string, topic string) (*Room, error)
+	SetAccessGuard(guard AccessGuard)
+}
+
+// AccessGuard may deny a user access to a room they could otherwise see, such as a user banned from it.
+type AccessGuard interface {
+	CheckAccess(ctx context.Context, roomID string, userID string) error
 }
 
 // RoomServiceImpl is a service that handles business logic related to room operations using a room repository.
 type RoomServiceImpl struct {
 	roomRepo RoomRepo
+	// auditor records renames, membership changes, appointments and settings changes in the audit log
+	auditor audit.Recorder
+	// wsHandler tells rooms about renames, settings changes and members joining and leaving
+	wsHandler *ws.Handler
+	// accessGuard, when set, vets users joining rooms and accessing their messages
+	accessGuard AccessGuard
 }
 
 // NewRoomService initializes and returns a new instance of RoomServiceImpl with the provided room repository.
-func NewRoomService(roomRepo RoomRepo) *RoomServiceImpl {
-	return &RoomServiceImpl{roomRepo: roomRepo}
+func NewRoomService(roomRepo RoomRepo, auditor audit.Recorder, wsHandler *ws.Handler) *RoomServiceImpl {
+	return &RoomServiceImpl{roomRepo: roomRepo, auditor: auditor, wsHandler: wsHandler}
+}
+
+// SetAccessGuard installs the guard vetting users joining rooms and accessing their messages. It must be called
+// during wiring, before the service starts handling requests.
+func (rs *RoomServiceImpl) SetAccessGuard(guard AccessGuard) {
+	rs.accessGuard = guard
 }
 
 // CreateRoom handles the creation of a new room, automatically generating a name if none is provided in the request.
-func (rs *RoomServiceImpl) CreateRoom(ctx context.Context, req request.CreateRoomRequest) (*Room, error) {
+// The caller becomes the owner and first member of the room; private rooms cannot be created anonymously.
+func (rs *RoomServiceImpl) CreateRoom(ctx context.Context, req request.CreateRoomRequest, ownerId string) (*Room, error) {
 	var roomName string
 	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
 		roomName = utils.GenerateRoomName()
@@ -36,28 +76,384 @@ func (rs *RoomServiceImpl) CreateRoom(ctx context.Context, req request.CreateRoo
 		roomName = *req.Name
 	}
 
-	room := Room{Name: roomName}
+	room := Room{Name: roomName, OwnerID: ownerId}
+	if req.Private != nil && *req.Private {
+		if ownerId == "" {
+			return nil, errormodel.ErrUnauthenticated
+		}
+		room.Private = true
+	}
+	if ownerId != "" {
+		room.Members = []string{ownerId}
+	}
 	return rs.roomRepo.CreateRoom(ctx, &room)
 }
 
-// GetRoom retrieves a room by its unique identifier from the repository and returns the room or an error if not found.
-func (rs *RoomServiceImpl) GetRoom(ctx context.Context, name string) (*Room, error) {
+// GetRoom retrieves a room by its name from the repository and returns the room or an error if not found.
+// Private rooms the caller is not a member of are reported as not found.
+func (rs *RoomServiceImpl) GetRoom(ctx context.Context, name string, callerId string) (*Room, error) {
 	room, err := rs.roomRepo.GetRoomByName(ctx, name)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !room.VisibleTo(callerId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	return room, nil
+}
+
+// GetReadableRoom retrieves a room by its ID, reporting private rooms the caller is not a member of as not found.
+func (rs *RoomServiceImpl) GetReadableRoom(ctx context.Context, id string, callerId string) (*Room, error) {
+	room, err := rs.roomRepo.GetRoomByID(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !room.VisibleTo(callerId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	return room, nil
+}
+
+// GetAccessibleRoom retrieves a room whose messages the caller may access: one they can see and are not kept
+// out of by the access guard.
+func (rs *RoomServiceImpl) GetAccessibleRoom(ctx context.Context, id string, callerId string) (*Room, error) {
+	room, err := rs.GetReadableRoom(ctx, id, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if rs.accessGuard != nil && callerId != "" {
+		if err := rs.accessGuard.CheckAccess(ctx, id, callerId); err != nil {
+			return nil, err
+		}
+	}
+	return room, nil
+}
+
+// UpdateRoomName renames a room and returns the updated room. Only the room's owner and moderators may rename it;
+// private rooms the caller cannot see are reported as not found.
+func (rs *RoomServiceImpl) UpdateRoomName(ctx context.Context, id string, callerId string, name string) (*Room, error) {
+	current, err := rs.GetReadableRoom(ctx, id, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if !current.IsModerator(callerId) {
+		return nil, errormodel.ErrForbidden
 	}
-	return room, err
+
+	updatedRoom, err := rs.writeResult(rs.roomRepo.UpdateRoomName(ctx, id, name))
+	if err != nil {
+		return nil, err
+	}
+	rs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomRenamed,
+		ActorID:    callerId,
+		TargetType: audit.TargetRoom,
+		TargetID:   id,
+		RoomID:     id,
+		Before:     map[string]interface{}{"name": current.Name},
+		After:      map[string]interface{}{"name": updatedRoom.Name},
+	})
+	// Everyone in the room hears of the rename, so only the name is sent rather than the members.
+	rs.broadcast(id, map[string]interface{}{
+		"type": "room_updated",
+		"room": map[string]interface{}{"id": updatedRoom.ID.Hex(), "name": updatedRoom.Name},
+	})
+	return updatedRoom, nil
 }
 
-// UpdateRoomName updates the name of an existing room by its ID in the repository and returns the updated room or an error.
-func (rs *RoomServiceImpl) UpdateRoomName(ctx context.Context, id string, name string) (*Room, error) {
-	updatedRoom, err := rs.roomRepo.UpdateRoomName(ctx, id, name)
+// AddMember adds a user to a room. Members may add anyone; other callers may only add themselves to a public room.
+func (rs *RoomServiceImpl) AddMember(ctx context.Context, id string, callerId string, userId string) (*Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	room, err := rs.GetReadableRoom(ctx, id, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if !room.IsMember(callerId) && userId != callerId {
+		return nil, errormodel.ErrForbidden
+	}
+	if rs.accessGuard != nil {
+		if err := rs.accessGuard.CheckAccess(ctx, id, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	updatedRoom, err := rs.roomRepo.AddMember(ctx, id, userId)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, errormodel.ErrRoomNotFound
 		}
 		return nil, errormodel.ErrMongoWriteFailed
 	}
+	if !room.IsMember(userId) {
+		rs.recordUserChange(ctx, audit.ActionMemberAdded, id, callerId, userId)
+		rs.broadcast(id, newMemberEvent("member_joined", id, userId))
+	}
 	return updatedRoom, nil
+}
+
+// RemoveMember removes a user from a room. Users may leave on their own; only the owner may remove someone else.
+func (rs *RoomServiceImpl) RemoveMember(ctx context.Context, id string, callerId string, userId string) (*Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	room, err := rs.GetReadableRoom(ctx, id, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if userId != callerId && room.OwnerID != callerId {
+		return nil, errormodel.ErrForbidden
+	}
+
+	return rs.removeMember(ctx, room, callerId, userId)
+}
+
+// EvictMember removes a user from a room on behalf of a moderation action, such as a ban, that the caller was
+// already authorized for. The removal is recorded and announced like any other.
+func (rs *RoomServiceImpl) EvictMember(ctx context.Context, id string, callerId string, userId string) (*Room, error) {
+	room, err := rs.roomRepo.GetRoomByID(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return rs.removeMember(ctx, room, callerId, userId)
+}
+
+// removeMember removes a user from a room, recording and announcing the change when the user was a member.
+func (rs *RoomServiceImpl) removeMember(ctx context.Context, room *Room, callerId string, userId string) (*Room, error) {
+	id := room.ID.Hex()
+	updatedRoom, err := rs.roomRepo.RemoveMember(ctx, id, userId)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errormodel.ErrRoomNotFound
+		}
+		return nil, errormodel.ErrMongoWriteFailed
+	}
+	if room.IsMember(userId) {
+		rs.recordUserChange(ctx, audit.ActionMemberRemoved, id, callerId, userId)
+		rs.broadcast(id, newMemberEvent("member_left", id, userId))
+	}
+	return updatedRoom, nil
+}
+
+// AddModerator appoints a user as a moderator of a room. Only the owner may appoint moderators.
+func (rs *RoomServiceImpl) AddModerator(ctx context.Context, id string, callerId string, userId string) (*Room, error) {
+	if _, err := rs.requireOwner(ctx, id, callerId); err != nil {
+		return nil, err
+	}
+	updatedRoom, err := rs.writeResult(rs.roomRepo.AddModerator(ctx, id, userId))
+	if err != nil {
+		return nil, err
+	}
+	rs.recordUserChange(ctx, audit.ActionModeratorAdded, id, callerId, userId)
+	return updatedRoom, nil
+}
 
+// RemoveModerator dismisses a moderator of a room. Only the owner may dismiss moderators.
+func (rs *RoomServiceImpl) RemoveModerator(ctx context.Context, id string, callerId string, userId string) (*Room, error) {
+	if _, err := rs.requireOwner(ctx, id, callerId); err != nil {
+		return nil, err
+	}
+	updatedRoom, err := rs.writeResult(rs.roomRepo.RemoveModerator(ctx, id, userId))
+	if err != nil {
+		return nil, err
+	}
+	rs.recordUserChange(ctx, audit.ActionModeratorRemoved, id, callerId, userId)
+	return updatedRoom, nil
+}
+
+// UpdateSettings applies the settings given in the request to a room, leaving the others unchanged.
+// Only moderators may change the settings of a room.
+func (rs *RoomServiceImpl) UpdateSettings(ctx context.Context, id string, callerId string, req request.UpdateRoomSettingsRequest) (*Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	room, err := rs.GetReadableRoom(ctx, id, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if !room.IsModerator(callerId) {
+		return nil, errormodel.ErrForbidden
+	}
+
+	settings := room.Settings
+	if req.PinLimit != nil {
+		if *req.PinLimit < 0 {
+			return nil, errormodel.ErrInvalidSettings
+		}
+		settings.PinLimit = *req.PinLimit
+	}
+	if req.MessageTTLSeconds != nil {
+		if *req.MessageTTLSeconds < 0 {
+			return nil, errormodel.ErrInvalidSettings
+		}
+		settings.MessageTTLSeconds = *req.MessageTTLSeconds
+	}
+	if req.RetentionDays != nil {
+		if *req.RetentionDays < 0 {
+			return nil, errormodel.ErrInvalidSettings
+		}
+		settings.RetentionDays = *req.RetentionDays
+	}
+	if req.RetentionMessages != nil {
+		if *req.RetentionMessages < 0 {
+			return nil, errormodel.ErrInvalidSettings
+		}
+		settings.RetentionMessages = *req.RetentionMessages
+	}
+	if req.SlowModeSeconds != nil {
+		if *req.SlowModeSeconds < 0 {
+			return nil, errormodel.ErrInvalidSettings
+		}
+		settings.SlowModeSeconds = *req.SlowModeSeconds
+	}
+	if req.SlowModeBurst != nil {
+		if *req.SlowModeBurst < 0 {
+			return nil, errormodel.ErrInvalidSettings
+		}
+		settings.SlowModeBurst = *req.SlowModeBurst
+	}
+	if req.DuplicateWindowSeconds != nil {
+		if *req.DuplicateWindowSeconds < 0 {
+			return nil, errormodel.ErrInvalidSettings
+		}
+		settings.DuplicateWindowSeconds = *req.DuplicateWindowSeconds
+	}
+	if req.Filters != nil {
+		for _, name := range *req.Filters {
+			if !filter.Known(name) {
+				return nil, errormodel.ErrInvalidSettings
+			}
+		}
+		settings.Filters = *req.Filters
+	}
+	if req.FilterWords != nil {
+		if len(*req.FilterWords) > maxFilterWords {
+			return nil, errormodel.ErrInvalidSettings
+		}
+		for _, word := range *req.FilterWords {
+			if strings.TrimSpace(word) == "" || len(word) > maxFilterWordSize {
+				return nil, errormodel.ErrInvalidSettings
+			}
+		}
+		settings.FilterWords = *req.FilterWords
+	}
+	updatedRoom, err := rs.writeResult(rs.roomRepo.UpdateSettings(ctx, id, settings))
+	if err != nil {
+		return nil, err
+	}
+	rs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomSettings,
+		ActorID:    callerId,
+		TargetType: audit.TargetRoom,
+		TargetID:   id,
+		RoomID:     id,
+		Before:     audit.Values(room.Settings),
+		After:      audit.Values(updatedRoom.Settings),
+	})
+	rs.broadcast(id, newRoomUpdatedEvent(updatedRoom))
+	return updatedRoom, nil
+}
+
+// UpdateTopic sets the topic of a room, or clears it when topic is empty. Members and moderators may change the
+// topic.
+func (rs *RoomServiceImpl) UpdateTopic(ctx context.Context, id string, callerId string, topic string) (*Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	topic = strings.TrimSpace(topic)
+	if len(topic) > maxTopicSize || strings.ContainsAny(topic, "\r\n") {
+		return nil, errormodel.ErrInvalidTopic
+	}
+	room, err := rs.GetReadableRoom(ctx, id, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if !room.IsMember(callerId) && !room.IsModerator(callerId) {
+		return nil, errormodel.ErrForbidden
+	}
+
+	updatedRoom, err := rs.writeResult(rs.roomRepo.UpdateTopic(ctx, id, topic))
+	if err != nil {
+		return nil, err
+	}
+	rs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomTopic,
+		ActorID:    callerId,
+		TargetType: audit.TargetRoom,
+		TargetID:   id,
+		RoomID:     id,
+		Before:     map[string]interface{}{"topic": room.Topic},
+		After:      map[string]interface{}{"topic": updatedRoom.Topic},
+	})
+	rs.broadcast(id, newRoomUpdatedEvent(updatedRoom))
+	return updatedRoom, nil
+}
+
+// recordUserChange records a change to a user's membership or role in a room.
+func (rs *RoomServiceImpl) recordUserChange(ctx context.Context, action string, id string, callerId string, userId string) {
+	rs.auditor.Record(ctx, audit.Entry{
+		Action:     action,
+		ActorID:    callerId,
+		TargetType: audit.TargetUser,
+		TargetID:   userId,
+		RoomID:     id,
+	})
+}
+
+func (rs *RoomServiceImpl) broadcast(id string, event map[string]interface{}) {
+	if rs.wsHandler != nil {
+		rs.wsHandler.BroadcastToRoom(id, event)
+	}
+}
+
+// newRoomUpdatedEvent builds the payload telling a room its name or settings changed.
+func newRoomUpdatedEvent(room *Room) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "room_updated",
+		"room": room,
+	}
+}
+
+// newMemberEvent builds the payload telling a room a member joined or left.
+func newMemberEvent(eventType string, id string, userId string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    eventType,
+		"room_id": id,
+		"user_id": userId,
+	}
+}
+
+// requireOwner returns the room when the caller owns it.
+func (rs *RoomServiceImpl) requireOwner(ctx context.Context, id string, callerId string) (*Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	room, err := rs.GetReadableRoom(ctx, id, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if room.OwnerID != callerId {
+		return nil, errormodel.ErrForbidden
+	}
+	return room, nil
+}
+
+// writeResult maps the errors of a room update to service errors.
+func (rs *RoomServiceImpl) writeResult(updatedRoom *Room, err error) (*Room, error) {
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errormodel.ErrRoomNotFound
+		} else if errors.Is(err, errormodel.ErrInvalidRoomID) {
+			return nil, err
+		}
+		return nil, errormodel.ErrMongoWriteFailed
+	}
+	return updatedRoom, nil
 }
@@ -2,11 +2,13 @@ package room
 
 import (
 	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	ws "messages-go/websocket"
 )
 
-func InitRoomHandler(client *mongo.Client) (RoomHandler, RoomRepo, RoomService) {
+func InitRoomHandler(client *mongo.Client, auditor audit.Recorder, wsHandler *ws.Handler) (RoomHandler, RoomRepo, RoomService) {
 	repo := NewRoomRepository(client)
-	service := NewRoomService(repo)
+	service := NewRoomService(repo, auditor, wsHandler)
 	handler := NewRoomHandler(service)
 	return handler, repo, service
 }
@@ -1,51 +1,187 @@
 package routes
 
 import (
+	"context"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/attachment"
+	"messages-go/audit"
+	"messages-go/auth"
+	"messages-go/bot"
 	"messages-go/message"
+	"messages-go/moderation"
+	"messages-go/pin"
+	"messages-go/ratelimit"
+	"messages-go/report"
+	"messages-go/retention"
 	"messages-go/room"
+	"messages-go/schedule"
+	"messages-go/search"
+	"messages-go/unfurl"
+	"messages-go/user"
+	"messages-go/webhook"
 	ws "messages-go/websocket"
 )
 
-// SetupRoutes configures all routes for the application
-func SetupRoutes(app *fiber.App, client *mongo.Client) {
+// SetupRoutes configures all routes for the application.
+// Background workers started while wiring the handlers run until ctx is cancelled.
+func SetupRoutes(ctx context.Context, app *fiber.App, client *mongo.Client) {
 	// Initialize WebSocket hub
 	hub := ws.GlobalHub
 	hub.Start()
 	wsHandler := ws.NewHandler(hub)
 
+	// Buckets of the rate limits and of room slow modes
+	rateStore := ratelimit.InitStore(ctx, client)
+
+	// Session tokens identifying callers, issued on login and verified on every request
+	signer := auth.InitSigner()
+	authenticate := auth.Middleware(signer)
+
+	// Audit log written by the services on every administrative action
+	auditHandler, auditor := audit.InitAudit(ctx, client)
+
 	// Initialize REST handlers
-	roomHandler, roomRepo, _ := room.InitRoomHandler(client)
-	messageHandler, _, _ := message.InitMessageHandler(client, roomRepo, wsHandler)
+	roomHandler, roomRepo, roomService := room.InitRoomHandler(client, auditor, wsHandler)
+	userHandler, userRepo, _ := user.InitUserHandler(client, signer, auditor)
+	attachmentHandler, attachmentRepo, _ := attachment.InitAttachmentHandler(ctx, client, wsHandler)
+	messageHandler, messageRepo, messageService := message.InitMessageHandler(ctx, client, roomRepo, attachmentRepo, userRepo, wsHandler, rateStore, auditor)
+	searchHandler, _ := search.InitSearchHandler(client, messageRepo, messageService, roomRepo)
+	unfurl.InitUnfurler(ctx, messageService, wsHandler)
+	pinHandler, pinRepo, _ := pin.InitPinHandler(client, roomRepo, messageService, wsHandler)
+	retention.InitRetentionJob(ctx, messageRepo, messageService, roomRepo, pinRepo)
+	scheduleHandler, _, _ := schedule.InitScheduleHandler(ctx, client, roomRepo, messageService, wsHandler)
+	moderationHandler, _, moderationService := moderation.InitModerationHandler(client, roomRepo, roomService, messageService, wsHandler, auditor)
+	reportHandler, _, _ := report.InitReportHandler(client, roomRepo, messageService, moderationService, wsHandler, auditor)
+	webhookHandler, _, _ := webhook.InitWebhookHandler(ctx, client, roomRepo, wsHandler, auditor)
+
+	// Scheduled deliveries post through the service directly and are not limited; they were limited when scheduled
+	limiter, rules := ratelimit.InitRateLimiter(rateStore)
+	wsHandler.WrapMessageReceiver(func(next ws.MessageReceiver) ws.MessageReceiver {
+		return limiter.WrapReceiver(rules.Frames, next)
+	})
+	incomingHandler, _, _ := webhook.InitIncomingWebhookHandler(client, roomRepo, messageService, wsHandler, limiter, rules.IncomingWebhook, auditor)
+
+	// Slash commands in posted messages, handled by built-in commands and the bots installed in rooms
+	botHandler, botSockets, _ := bot.InitBotHandler(client, roomRepo, roomService, userRepo, messageService, auditor)
 
 	// Pass WebSocket handler to message handler for broadcasting
 	// You'll need to modify your message handler to accept this
 
-	// API routes
-	api := app.Group("/api")
-	setupRoomRoutes(api, roomHandler)
-	setupMessageRoutes(api, messageHandler)
+	// API routes, each authenticating the caller and noting who made the request and from where for the audit log
+	api := app.Group("/api", authenticate, audit.Middleware())
+	setupUserRoutes(api, userHandler, limiter.Middleware(rules.Login))
+	setupRoomRoutes(api, roomHandler, messageHandler, pinHandler, moderationHandler, reportHandler, webhookHandler, incomingHandler, botHandler, limiter.Middleware(rules.CreateRoom))
+	setupMessageRoutes(api, messageHandler, roomHandler, scheduleHandler, reportHandler, limiter.Middleware(rules.PostMessage))
+	setupReportRoutes(api, reportHandler)
+	setupAdminRoutes(api, auditHandler)
+	setupAttachmentRoutes(api, attachmentHandler, roomHandler)
+	setupSearchRoutes(api, searchHandler)
+	setupBotRoutes(api, botHandler)
+
+	// Incoming webhooks, authenticated by the token in the path rather than a user session
+	setupHookRoutes(app, incomingHandler)
 
 	// WebSocket routes
-	setupWebSocketRoutes(app, wsHandler)
+	setupWebSocketRoutes(app, wsHandler, roomHandler, botHandler, botSockets, authenticate, limiter.Middleware(rules.Connect))
+}
+
+func setupUserRoutes(api fiber.Router, handler user.UserHandler, loginLimit fiber.Handler) {
+	userGroup := api.Group("/user")
+	userGroup.Post("/", loginLimit, handler.CreateUser)
+	userGroup.Post("/login", loginLimit, handler.Login)
+	userGroup.Get("/:username", handler.GetUser)
 }
 
-func setupRoomRoutes(api fiber.Router, handler room.RoomHandler) {
+func setupRoomRoutes(api fiber.Router, handler room.RoomHandler, messageHandler message.MessageHandler, pinHandler pin.PinHandler, moderationHandler moderation.ModerationHandler, reportHandler report.ReportHandler, webhookHandler webhook.WebhookHandler, incomingHandler webhook.IncomingWebhookHandler, botHandler bot.BotHandler, createLimit fiber.Handler) {
 	roomGroup := api.Group("/room")
-	roomGroup.Post("/", handler.CreateRoom)
+	roomGroup.Post("/", createLimit, handler.CreateRoom)
 	roomGroup.Get("/:name", handler.GetRoom)
 	roomGroup.Patch("/:id", handler.UpdateRoomName)
+	roomGroup.Post("/:id/members", handler.AddMember)
+	roomGroup.Delete("/:id/members/:userId", handler.RemoveMember)
+	roomGroup.Post("/:id/moderators", handler.AddModerator)
+	roomGroup.Delete("/:id/moderators/:userId", handler.RemoveModerator)
+	roomGroup.Patch("/:id/settings", handler.UpdateSettings)
+	roomGroup.Get("/:id/pins", handler.RequireRoomAccess("id"), pinHandler.ListPins)
+	roomGroup.Post("/:id/pins", pinHandler.PinMessage)
+	roomGroup.Delete("/:id/pins/:messageId", pinHandler.UnpinMessage)
+	roomGroup.Post("/:id/kick", moderationHandler.Kick)
+	roomGroup.Post("/:id/mutes", moderationHandler.Mute)
+	roomGroup.Delete("/:id/mutes/:userId", moderationHandler.Unmute)
+	roomGroup.Post("/:id/bans", moderationHandler.Ban)
+	roomGroup.Delete("/:id/bans/:userId", moderationHandler.Unban)
+	roomGroup.Get("/:id/moderation-log", moderationHandler.GetLog)
+	roomGroup.Get("/:id/reports", reportHandler.ListRoomReports)
+	roomGroup.Post("/:id/webhooks", webhookHandler.CreateWebhook)
+	roomGroup.Get("/:id/webhooks", webhookHandler.ListWebhooks)
+	// Registered before the webhook routes so "dead-letters" is not taken for a webhook ID
+	roomGroup.Get("/:id/webhooks/dead-letters", webhookHandler.ListDeadLetters)
+	roomGroup.Post("/:id/webhooks/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+	roomGroup.Delete("/:id/webhooks/:hookId", webhookHandler.DeleteWebhook)
+	roomGroup.Get("/:id/webhooks/:hookId/deliveries", webhookHandler.ListDeliveries)
+	roomGroup.Post("/:id/incoming-webhooks", incomingHandler.CreateIncomingWebhook)
+	roomGroup.Get("/:id/incoming-webhooks", incomingHandler.ListIncomingWebhooks)
+	roomGroup.Delete("/:id/incoming-webhooks/:hookId", incomingHandler.RevokeIncomingWebhook)
+	roomGroup.Post("/:id/bots", botHandler.InstallBot)
+	roomGroup.Get("/:id/bots", botHandler.ListInstallations)
+	roomGroup.Delete("/:id/bots/:botId", botHandler.UninstallBot)
+	// SSE fallback for clients whose proxies block WebSocket upgrades
+	roomGroup.Get("/:id/events", handler.RequireRoomAccess("id"), messageHandler.StreamRoomEvents)
 }
 
-func setupMessageRoutes(api fiber.Router, handler message.MessageHandler) {
+func setupMessageRoutes(api fiber.Router, handler message.MessageHandler, roomHandler room.RoomHandler, scheduleHandler schedule.ScheduleHandler, reportHandler report.ReportHandler, postLimit fiber.Handler) {
 	messageGroup := api.Group("/message")
-	messageGroup.Post("/", handler.PostMessage)
-	messageGroup.Get("/:roomId", handler.GetMessages)
+	// Registered before the room routes so "scheduled" is not taken for a room ID
+	messageGroup.Post("/scheduled", postLimit, scheduleHandler.ScheduleMessage)
+	messageGroup.Get("/scheduled", scheduleHandler.ListScheduled)
+	messageGroup.Patch("/scheduled/:id", scheduleHandler.UpdateScheduled)
+	messageGroup.Delete("/scheduled/:id", scheduleHandler.CancelScheduled)
+	messageGroup.Post("/", postLimit, handler.PostMessage)
+	messageGroup.Get("/:roomId", roomHandler.RequireRoomAccess("roomId"), handler.GetMessages)
+	messageGroup.Get("/:roomId/poll", roomHandler.RequireRoomAccess("roomId"), handler.PollMessages)
+	messageGroup.Delete("/:roomId/:id", handler.DeleteMessage)
+	messageGroup.Post("/:roomId/:id/report", roomHandler.RequireRoomAccess("roomId"), reportHandler.ReportMessage)
+	messageGroup.Post("/:roomId/:id/forward", postLimit, handler.Forward)
+	messageGroup.Post("/:id/vote", handler.Vote)
 }
 
-func setupWebSocketRoutes(app *fiber.App, wsHandler *ws.Handler) {
+func setupReportRoutes(api fiber.Router, handler report.ReportHandler) {
+	reportGroup := api.Group("/reports")
+	reportGroup.Get("/", handler.ListReports)
+	reportGroup.Post("/:id/resolve", handler.ResolveReport)
+}
+
+func setupAdminRoutes(api fiber.Router, auditHandler audit.AuditHandler) {
+	adminGroup := api.Group("/admin")
+	adminGroup.Get("/audit", auditHandler.GetEntries)
+	adminGroup.Get("/audit/export", auditHandler.Export)
+}
+
+func setupAttachmentRoutes(api fiber.Router, handler attachment.AttachmentHandler, roomHandler room.RoomHandler) {
+	attachmentGroup := api.Group("/message/:roomId/attachments", roomHandler.RequireRoomAccess("roomId"))
+	attachmentGroup.Post("/", handler.Upload)
+	attachmentGroup.Get("/:id", handler.Download)
+	attachmentGroup.Get("/:id/thumbnails/:size", handler.DownloadThumbnail)
+}
+
+func setupSearchRoutes(api fiber.Router, handler search.SearchHandler) {
+	api.Get("/search", handler.Search)
+}
+
+func setupBotRoutes(api fiber.Router, handler bot.BotHandler) {
+	botGroup := api.Group("/bots")
+	botGroup.Post("/", handler.RegisterBot)
+	botGroup.Get("/", handler.ListBots)
+	botGroup.Delete("/:id", handler.DeleteBot)
+}
+
+func setupHookRoutes(app *fiber.App, handler webhook.IncomingWebhookHandler) {
+	app.Post("/hooks/:token", handler.Post)
+}
+
+func setupWebSocketRoutes(app *fiber.App, wsHandler *ws.Handler, roomHandler room.RoomHandler, botHandler bot.BotHandler, botSockets *bot.Sockets, authenticate fiber.Handler, connectLimit fiber.Handler) {
 	// WebSocket upgrade middleware
 	app.Use("/ws", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
@@ -55,6 +191,12 @@ func setupWebSocketRoutes(app *fiber.App, wsHandler *ws.Handler) {
 		return fiber.ErrUpgradeRequired
 	})
 
+	// Personal channel of the caller, registered before the room endpoint so "me" is not taken for a room ID
+	app.Get("/ws/me", authenticate, connectLimit, websocket.New(wsHandler.HandlePersonalConnection))
+
+	// Connections of WebSocket bots, authenticated by their bot token rather than a user session
+	app.Get("/ws/bot", connectLimit, botHandler.AuthenticateSocket, websocket.New(botSockets.HandleConnection))
+
 	// WebSocket endpoint
-	app.Get("/ws/:roomId", websocket.New(wsHandler.HandleConnection))
+	app.Get("/ws/:roomId", authenticate, connectLimit, roomHandler.RequireRoomAccess("roomId"), websocket.New(wsHandler.HandleConnection))
 }
@@ -0,0 +1,148 @@
+package schedule
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/models/response"
+)
+
+// ScheduleHandler defines the interface for handling HTTP requests to schedule messages and manage pending ones.
+type ScheduleHandler interface {
+	ScheduleMessage(c *fiber.Ctx) error
+	ListScheduled(c *fiber.Ctx) error
+	UpdateScheduled(c *fiber.Ctx) error
+	CancelScheduled(c *fiber.Ctx) error
+}
+
+// ScheduleHandlerImpl implements the ScheduleHandler interface.
+type ScheduleHandlerImpl struct {
+	scheduleService ScheduleService
+}
+
+// NewScheduleHandler initializes and returns a new ScheduleHandler with the provided ScheduleService implementation.
+func NewScheduleHandler(scheduleService ScheduleService) ScheduleHandler {
+	return &ScheduleHandlerImpl{scheduleService: scheduleService}
+}
+
+// ScheduleMessage handles scheduling a message to be posted to a room at its send_at time.
+func (sh *ScheduleHandlerImpl) ScheduleMessage(c *fiber.Ctx) error {
+	var req request.ScheduleMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body.",
+		})
+	}
+
+	log.Println("Schedule Message Request Received.")
+
+	scheduled, err := sh.scheduleService.ScheduleMessage(c.Context(), req, auth.CallerID(c))
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Message Scheduled.",
+		Data:    scheduled,
+	})
+}
+
+// ListScheduled handles listing the caller's pending messages, optionally for a single room given as room_id.
+func (sh *ScheduleHandlerImpl) ListScheduled(c *fiber.Ctx) error {
+	log.Println("List Scheduled Messages Request Received.")
+
+	scheduled, err := sh.scheduleService.ListScheduled(c.Context(), auth.CallerID(c), c.Query("room_id"))
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Scheduled Messages Found",
+		Data:    scheduled,
+	})
+}
+
+// UpdateScheduled handles editing a pending message of the caller.
+func (sh *ScheduleHandlerImpl) UpdateScheduled(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req request.UpdateScheduledMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body.",
+		})
+	}
+
+	log.Println("Update Scheduled Message with id ", id, " Request Received.")
+
+	scheduled, err := sh.scheduleService.UpdateScheduled(c.Context(), id, auth.CallerID(c), req)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Scheduled Message Updated.",
+		Data:    scheduled,
+	})
+}
+
+// CancelScheduled handles cancelling a pending message of the caller.
+func (sh *ScheduleHandlerImpl) CancelScheduled(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	log.Println("Cancel Scheduled Message with id ", id, " Request Received.")
+
+	scheduled, err := sh.scheduleService.CancelScheduled(c.Context(), id, auth.CallerID(c))
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Scheduled Message Cancelled.",
+		Data:    scheduled,
+	})
+}
+
+// scheduleError maps the service errors of the schedule endpoints to API responses.
+func scheduleError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Scheduling Messages Requires A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrRoomNotFound) || errors.Is(err, errormodel.ErrInvalidRoomID) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Room found given room_id.",
+		})
+	} else if errors.Is(err, errormodel.ErrScheduledNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Pending Scheduled Message Found with given id.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidSendAt) || errors.Is(err, errormodel.ErrInvalidFormat) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Scheduled Messages Need A Future send_at And A Valid Format.",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed To Schedule Message.",
+	})
+}
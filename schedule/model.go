@@ -0,0 +1,39 @@
+package schedule
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Statuses of a scheduled message. Messages move from pending to sending when the scheduler claims them, and
+// from sending to sent or failed, or back to pending with a later send time when delivery should be retried.
+const (
+	StatusPending   = "pending"
+	StatusSending   = "sending"
+	StatusSent      = "sent"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// ScheduledMessage is a message held back until SendAt. It is delivered under a client message ID derived from
+// its ID, which is what guarantees it is posted at most once even when a delivery is retried.
+type ScheduledMessage struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RoomID   string             `bson:"room_id" json:"room_id"`
+	SenderID string             `bson:"sender_id" json:"sender_id"`
+	Body     string             `bson:"body" json:"body"`
+	Format   string             `bson:"format,omitempty" json:"format,omitempty"`
+	SendAt   time.Time          `bson:"send_at" json:"send_at"`
+	Status   string             `bson:"status" json:"status"`
+	// ClaimedAt is when a scheduler last started delivering the message; Attempts counts those deliveries. Failed
+	// attempts that are worth retrying move SendAt forward.
+	ClaimedAt time.Time `bson:"claimed_at,omitempty" json:"-"`
+	Attempts  int       `bson:"attempts,omitempty" json:"-"`
+	// Error explains why delivery failed
+	Error string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// ClientMsgID returns the client message ID the message is delivered under.
+func (s *ScheduledMessage) ClientMsgID() string {
+	return "scheduled:" + s.ID.Hex()
+}
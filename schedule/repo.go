@@ -0,0 +1,193 @@
+package schedule
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"os"
+	"time"
+)
+
+// ScheduleRepo defines an interface for persisting scheduled messages and claiming them for delivery.
+type ScheduleRepo interface {
+	CreateScheduled(ctx context.Context, s *ScheduledMessage) (*ScheduledMessage, error)
+	GetPendingBySender(ctx context.Context, senderID string, roomID string) ([]ScheduledMessage, error)
+	GetScheduledByID(ctx context.Context, id primitive.ObjectID) (*ScheduledMessage, error)
+	UpdatePending(ctx context.Context, s *ScheduledMessage) (*ScheduledMessage, error)
+	CancelPending(ctx context.Context, id primitive.ObjectID, senderID string) (*ScheduledMessage, error)
+	ClaimDue(ctx context.Context, now time.Time, staleBefore time.Time) (*ScheduledMessage, error)
+	SetStatus(ctx context.Context, id primitive.ObjectID, status string, reason string) error
+	SetRetry(ctx context.Context, id primitive.ObjectID, sendAt time.Time, reason string) error
+	NextSendAt(ctx context.Context) (time.Time, error)
+}
+
+// ScheduleRepoImpl is a concrete implementation of the ScheduleRepo interface backed by MongoDB.
+type ScheduleRepoImpl struct {
+	scheduleCollection *mongo.Collection
+}
+
+// NewScheduleRepository initializes and returns a new instance of ScheduleRepo, ensuring the indexes used to find
+// due messages and to list the messages of a sender.
+func NewScheduleRepository(client *mongo.Client) ScheduleRepo {
+	r := &ScheduleRepoImpl{
+		scheduleCollection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("scheduled_messages"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.scheduleCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "send_at", Value: 1}}},
+		{Keys: bson.D{{Key: "sender_id", Value: 1}, {Key: "status", Value: 1}, {Key: "send_at", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create scheduled message indexes: %v", err)
+	}
+	return r
+}
+
+// CreateScheduled inserts a scheduled message and returns it with its generated ID.
+func (r *ScheduleRepoImpl) CreateScheduled(ctx context.Context, s *ScheduledMessage) (*ScheduledMessage, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.scheduleCollection.InsertOne(timeoutCtx, s)
+	if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		s.ID = oid
+	}
+	return s, nil
+}
+
+// GetPendingBySender returns the pending messages of a sender, optionally restricted to a room, soonest first.
+func (r *ScheduleRepoImpl) GetPendingBySender(ctx context.Context, senderID string, roomID string) ([]ScheduledMessage, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"sender_id": senderID, "status": StatusPending}
+	if roomID != "" {
+		filter["room_id"] = roomID
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "send_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.scheduleCollection.Find(timeoutCtx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	scheduled := []ScheduledMessage{}
+	if err := cursor.All(timeoutCtx, &scheduled); err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// GetScheduledByID retrieves a scheduled message by its ID.
+func (r *ScheduleRepoImpl) GetScheduledByID(ctx context.Context, id primitive.ObjectID) (*ScheduledMessage, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var s ScheduledMessage
+	if err := r.scheduleCollection.FindOne(timeoutCtx, bson.M{"_id": id}).Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdatePending stores the body, format and send time of a message that is still pending and returns the updated
+// message. Messages that were claimed, cancelled or taken over by another sender in the meantime yield
+// mongo.ErrNoDocuments.
+func (r *ScheduleRepoImpl) UpdatePending(ctx context.Context, s *ScheduledMessage) (*ScheduledMessage, error) {
+	return r.updatePending(ctx, s.ID, s.SenderID, bson.M{"body": s.Body, "format": s.Format, "send_at": s.SendAt})
+}
+
+// CancelPending cancels a pending message of the given sender and returns it, or mongo.ErrNoDocuments when there
+// is no such message.
+func (r *ScheduleRepoImpl) CancelPending(ctx context.Context, id primitive.ObjectID, senderID string) (*ScheduledMessage, error) {
+	return r.updatePending(ctx, id, senderID, bson.M{"status": StatusCancelled})
+}
+
+// updatePending applies set to a pending message of the given sender and returns the updated message.
+func (r *ScheduleRepoImpl) updatePending(ctx context.Context, id primitive.ObjectID, senderID string, set bson.M) (*ScheduledMessage, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var updated ScheduledMessage
+	err := r.scheduleCollection.FindOneAndUpdate(
+		timeoutCtx,
+		bson.M{"_id": id, "sender_id": senderID, "status": StatusPending},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// ClaimDue atomically marks the earliest due pending message as sending and returns it. Messages left sending by
+// a scheduler that stopped before staleBefore are claimed again. It yields mongo.ErrNoDocuments when nothing is due.
+func (r *ScheduleRepoImpl) ClaimDue(ctx context.Context, now time.Time, staleBefore time.Time) (*ScheduledMessage, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": StatusPending, "send_at": bson.M{"$lte": now}},
+		bson.M{"status": StatusSending, "claimed_at": bson.M{"$lt": staleBefore}},
+	}}
+	update := bson.M{
+		"$set": bson.M{"status": StatusSending, "claimed_at": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "send_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var claimed ScheduledMessage
+	if err := r.scheduleCollection.FindOneAndUpdate(timeoutCtx, filter, update, opts).Decode(&claimed); err != nil {
+		return nil, err
+	}
+	return &claimed, nil
+}
+
+// SetStatus records the outcome of a delivery, with the reason for failures.
+func (r *ScheduleRepoImpl) SetStatus(ctx context.Context, id primitive.ObjectID, status string, reason string) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"status": status}
+	if reason != "" {
+		set["error"] = reason
+	}
+	_, err := r.scheduleCollection.UpdateByID(timeoutCtx, id, bson.M{"$set": set})
+	return err
+}
+
+// SetRetry returns a message whose delivery failed to pending, to be claimed again at sendAt.
+func (r *ScheduleRepoImpl) SetRetry(ctx context.Context, id primitive.ObjectID, sendAt time.Time, reason string) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"status": StatusPending, "send_at": sendAt, "error": reason}
+	_, err := r.scheduleCollection.UpdateByID(timeoutCtx, id, bson.M{"$set": set})
+	return err
+}
+
+// NextSendAt returns when the earliest pending message is due, or mongo.ErrNoDocuments when none is pending.
+func (r *ScheduleRepoImpl) NextSendAt(ctx context.Context) (time.Time, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "send_at", Value: 1}}).
+		SetProjection(bson.M{"send_at": 1})
+	var next ScheduledMessage
+	if err := r.scheduleCollection.FindOne(timeoutCtx, bson.M{"status": StatusPending}, opts).Decode(&next); err != nil {
+		return time.Time{}, err
+	}
+	return next.SendAt, nil
+}
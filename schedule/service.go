@@ -0,0 +1,321 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"messages-go/message"
+	"messages-go/message/format"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/room"
+	ws "messages-go/websocket"
+	"time"
+)
+
+// Config holds the limits on scheduling and the timing of the delivery loop.
+type Config struct {
+	// MaxAhead is how far in the future messages may be scheduled.
+	MaxAhead time.Duration
+	// PollInterval is the longest the scheduler sleeps between looking for due messages.
+	PollInterval time.Duration
+	// ClaimTimeout is how long a delivery may stay in progress before another scheduler takes it over.
+	ClaimTimeout time.Duration
+	// BatchSize is the most messages delivered in one pass.
+	BatchSize int
+	// MaxAttempts is how many times delivery is attempted before the message is marked failed.
+	MaxAttempts int
+	// RetryBackoff is the wait before the first retry of a failed delivery, doubling with every further attempt
+	// up to MaxRetryBackoff.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+// minWait keeps the scheduler from spinning when a message is due within a few milliseconds.
+const minWait = 50 * time.Millisecond
+
+// ScheduleService defines the interface for scheduling messages and managing a sender's pending messages.
+type ScheduleService interface {
+	ScheduleMessage(ctx context.Context, req request.ScheduleMessageRequest, senderId string) (*ScheduledMessage, error)
+	ListScheduled(ctx context.Context, senderId string, roomId string) ([]ScheduledMessage, error)
+	UpdateScheduled(ctx context.Context, id string, senderId string, req request.UpdateScheduledMessageRequest) (*ScheduledMessage, error)
+	CancelScheduled(ctx context.Context, id string, senderId string) (*ScheduledMessage, error)
+}
+
+// ScheduleServiceImpl persists scheduled messages and delivers each one when it comes due through the message
+// service, broadcasting it like any other posted message.
+type ScheduleServiceImpl struct {
+	scheduleRepo   ScheduleRepo
+	roomRepo       room.RoomRepo
+	messageService message.MessageService
+	wsHandler      *ws.Handler
+	clock          Clock
+	cfg            Config
+	// wake interrupts the delivery loop's sleep when a message is scheduled or rescheduled
+	wake chan struct{}
+}
+
+// NewScheduleService initializes and returns a new instance of ScheduleServiceImpl. The delivery loop is started
+// by Start.
+func NewScheduleService(scheduleRepo ScheduleRepo, roomRepo room.RoomRepo, messageService message.MessageService, wsHandler *ws.Handler, clock Clock, cfg Config) *ScheduleServiceImpl {
+	return &ScheduleServiceImpl{
+		scheduleRepo:   scheduleRepo,
+		roomRepo:       roomRepo,
+		messageService: messageService,
+		wsHandler:      wsHandler,
+		clock:          clock,
+		cfg:            cfg,
+		wake:           make(chan struct{}, 1),
+	}
+}
+
+// ScheduleMessage stores a message to be posted to a room at req.SendAt. The sender must be allowed to post to
+// the room when scheduling; delivery checks it again.
+func (ss *ScheduleServiceImpl) ScheduleMessage(ctx context.Context, req request.ScheduleMessageRequest, senderId string) (*ScheduledMessage, error) {
+	if senderId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	if req.RoomID == nil {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	if req.SendAt == nil {
+		return nil, errormodel.ErrInvalidSendAt
+	}
+	roomData, err := ss.roomRepo.GetRoomByID(ctx, *req.RoomID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !roomData.VisibleTo(senderId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+
+	s := &ScheduledMessage{
+		RoomID:   roomData.ID.Hex(),
+		SenderID: senderId,
+		SendAt:   req.SendAt.UTC(),
+		Status:   StatusPending,
+	}
+	if req.Body != nil {
+		s.Body = *req.Body
+	}
+	if req.Format != nil {
+		s.Format = *req.Format
+	}
+	if err := ss.validate(s); err != nil {
+		return nil, err
+	}
+
+	created, err := ss.scheduleRepo.CreateScheduled(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	ss.wakeUp()
+	return created, nil
+}
+
+// ListScheduled returns the pending messages of a sender, optionally restricted to a room, soonest first.
+func (ss *ScheduleServiceImpl) ListScheduled(ctx context.Context, senderId string, roomId string) ([]ScheduledMessage, error) {
+	if senderId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	return ss.scheduleRepo.GetPendingBySender(ctx, senderId, roomId)
+}
+
+// UpdateScheduled edits the body, format or send time of a pending message of the sender.
+func (ss *ScheduleServiceImpl) UpdateScheduled(ctx context.Context, id string, senderId string, req request.UpdateScheduledMessageRequest) (*ScheduledMessage, error) {
+	s, err := ss.getPending(ctx, id, senderId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		s.Body = *req.Body
+	}
+	if req.Format != nil {
+		s.Format = *req.Format
+	}
+	if req.SendAt != nil {
+		s.SendAt = req.SendAt.UTC()
+	}
+	if err := ss.validate(s); err != nil {
+		return nil, err
+	}
+
+	updated, err := ss.scheduleRepo.UpdatePending(ctx, s)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrScheduledNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	ss.wakeUp()
+	return updated, nil
+}
+
+// CancelScheduled cancels a pending message of the sender so that it is never delivered.
+func (ss *ScheduleServiceImpl) CancelScheduled(ctx context.Context, id string, senderId string) (*ScheduledMessage, error) {
+	s, err := ss.getPending(ctx, id, senderId)
+	if err != nil {
+		return nil, err
+	}
+	cancelled, err := ss.scheduleRepo.CancelPending(ctx, s.ID, senderId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrScheduledNotFound
+	}
+	return cancelled, err
+}
+
+// getPending returns a pending message of the sender. Messages of other senders are reported as not found.
+func (ss *ScheduleServiceImpl) getPending(ctx context.Context, id string, senderId string) (*ScheduledMessage, error) {
+	if senderId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrScheduledNotFound
+	}
+	s, err := ss.scheduleRepo.GetScheduledByID(ctx, oid)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrScheduledNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if s.SenderID != senderId || s.Status != StatusPending {
+		return nil, errormodel.ErrScheduledNotFound
+	}
+	return s, nil
+}
+
+// validate checks the format of a scheduled message and that its send time lies within the allowed window.
+func (ss *ScheduleServiceImpl) validate(s *ScheduledMessage) error {
+	if _, err := format.Render(s.Format, s.Body); err != nil {
+		return errormodel.ErrInvalidFormat
+	}
+	now := ss.clock.Now()
+	if !s.SendAt.After(now) || s.SendAt.After(now.Add(ss.cfg.MaxAhead)) {
+		return errormodel.ErrInvalidSendAt
+	}
+	return nil
+}
+
+// wakeUp makes the delivery loop recompute when the next message is due.
+func (ss *ScheduleServiceImpl) wakeUp() {
+	select {
+	case ss.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the delivery loop until ctx is cancelled. Messages that came due while no scheduler was running are
+// delivered on the first pass.
+func (ss *ScheduleServiceImpl) Start(ctx context.Context) {
+	go func() {
+		for {
+			wait := ss.cfg.PollInterval
+			if !ss.DeliverDue(ctx) {
+				if next, err := ss.scheduleRepo.NextSendAt(ctx); err == nil {
+					wait = min(wait, max(next.Sub(ss.clock.Now()), minWait))
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ss.wake:
+			case <-ss.clock.After(wait):
+			}
+		}
+	}()
+}
+
+// DeliverDue delivers the messages that are due, at most cfg.BatchSize of them, and reports whether claiming
+// them failed, in which case the remaining messages wait for the next pass. Messages whose delivery fails are
+// rescheduled or marked failed without holding up the others.
+func (ss *ScheduleServiceImpl) DeliverDue(ctx context.Context) (retry bool) {
+	for range max(1, ss.cfg.BatchSize) {
+		if ctx.Err() != nil {
+			return false
+		}
+		now := ss.clock.Now()
+		s, err := ss.scheduleRepo.ClaimDue(ctx, now, now.Add(-ss.cfg.ClaimTimeout))
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false
+		} else if err != nil {
+			log.Printf("Failed to claim scheduled messages: %v", err)
+			return true
+		}
+		ss.deliver(ctx, s)
+	}
+	return false
+}
+
+// deliver posts a claimed message and records the outcome. The message is posted under a client message ID
+// derived from the scheduled message, so a delivery retried after a crash returns the message already posted
+// instead of posting it twice.
+func (ss *ScheduleServiceImpl) deliver(ctx context.Context, s *ScheduledMessage) {
+	posted, err := ss.messageService.PostMessage(ctx, &message.Message{
+		RoomID:      s.RoomID,
+		SenderID:    s.SenderID,
+		Body:        s.Body,
+		Format:      s.Format,
+		ClientMsgID: s.ClientMsgID(),
+	})
+	switch {
+	case err == nil:
+		ss.setStatus(ctx, s, StatusSent, "")
+		if ss.wsHandler != nil && !posted.Replayed() && !posted.Ephemeral {
+			ss.wsHandler.BroadcastToRoom(posted.RoomID, message.NewMessageEvent(posted))
+		}
+
+	case errors.Is(err, errormodel.ErrRoomNotFound),
+		errors.Is(err, errormodel.ErrForbidden),
+		errors.Is(err, errormodel.ErrBanned),
+		errors.Is(err, errormodel.ErrMuted),
+		errors.Is(err, errormodel.ErrMessageRejected),
+		errors.Is(err, errormodel.ErrInvalidFormat),
+		errors.Is(err, errormodel.ErrInvalidRoomID),
+		errors.Is(err, errormodel.ErrInvalidClientMsgID),
+		errors.Is(err, errormodel.ErrInvalidAttachment),
+		errors.Is(err, errormodel.ErrInvalidTTL),
+		errors.Is(err, errormodel.ErrInvalidPoll),
+		errors.Is(err, errormodel.ErrInvalidEmbed),
+		errors.Is(err, errormodel.ErrInvalidReference):
+		log.Printf("Scheduled message %s cannot be delivered: %v", s.ID.Hex(), err)
+		ss.setStatus(ctx, s, StatusFailed, err.Error())
+
+	case s.Attempts >= ss.cfg.MaxAttempts:
+		log.Printf("Giving up on scheduled message %s after %d attempts: %v", s.ID.Hex(), s.Attempts, err)
+		ss.setStatus(ctx, s, StatusFailed, err.Error())
+
+	default:
+		wait := ss.backoff(s.Attempts)
+		var retryErr *errormodel.RetryError
+		if errors.As(err, &retryErr) {
+			// Slow mode and duplicate suppression say when the post would be accepted.
+			wait = max(wait, retryErr.RetryAfter)
+		}
+		log.Printf("Failed to deliver scheduled message %s, retrying in %s: %v", s.ID.Hex(), wait, err)
+		if err := ss.scheduleRepo.SetRetry(ctx, s.ID, ss.clock.Now().Add(wait).UTC(), err.Error()); err != nil {
+			log.Printf("Failed to reschedule scheduled message %s: %v", s.ID.Hex(), err)
+		}
+	}
+}
+
+// backoff returns how long to wait before retrying a delivery that failed on the given attempt.
+func (ss *ScheduleServiceImpl) backoff(attempt int) time.Duration {
+	wait := ss.cfg.RetryBackoff
+	for range max(0, attempt-1) {
+		if wait >= ss.cfg.MaxRetryBackoff {
+			break
+		}
+		wait *= 2
+	}
+	return min(wait, ss.cfg.MaxRetryBackoff)
+}
+
+func (ss *ScheduleServiceImpl) setStatus(ctx context.Context, s *ScheduledMessage, status string, reason string) {
+	if err := ss.scheduleRepo.SetStatus(ctx, s.ID, status, reason); err != nil {
+		log.Printf("Failed to mark scheduled message %s %s: %v", s.ID.Hex(), status, err)
+	}
+}
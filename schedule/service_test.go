@@ -0,0 +1,413 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/message"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRepo is a ScheduleRepo keeping scheduled messages in memory with the semantics of ScheduleRepoImpl.
+type memoryRepo struct {
+	mu       sync.Mutex
+	messages map[primitive.ObjectID]*ScheduledMessage
+	// failSetStatus makes the next SetStatus fail, as if the scheduler crashed after posting
+	failSetStatus bool
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{messages: make(map[primitive.ObjectID]*ScheduledMessage)}
+}
+
+func (r *memoryRepo) CreateScheduled(_ context.Context, s *ScheduledMessage) (*ScheduledMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.ID = primitive.NewObjectID()
+	stored := *s
+	r.messages[s.ID] = &stored
+	return s, nil
+}
+
+func (r *memoryRepo) GetPendingBySender(_ context.Context, senderID string, roomID string) ([]ScheduledMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var pending []ScheduledMessage
+	for _, s := range r.messages {
+		if s.SenderID == senderID && s.Status == StatusPending && (roomID == "" || s.RoomID == roomID) {
+			pending = append(pending, *s)
+		}
+	}
+	return pending, nil
+}
+
+func (r *memoryRepo) GetScheduledByID(_ context.Context, id primitive.ObjectID) (*ScheduledMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.messages[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	found := *s
+	return &found, nil
+}
+
+func (r *memoryRepo) UpdatePending(_ context.Context, s *ScheduledMessage) (*ScheduledMessage, error) {
+	return r.updatePending(s.ID, s.SenderID, func(stored *ScheduledMessage) {
+		stored.Body, stored.Format, stored.SendAt = s.Body, s.Format, s.SendAt
+	})
+}
+
+func (r *memoryRepo) CancelPending(_ context.Context, id primitive.ObjectID, senderID string) (*ScheduledMessage, error) {
+	return r.updatePending(id, senderID, func(stored *ScheduledMessage) {
+		stored.Status = StatusCancelled
+	})
+}
+
+func (r *memoryRepo) updatePending(id primitive.ObjectID, senderID string, update func(*ScheduledMessage)) (*ScheduledMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.messages[id]
+	if !ok || s.SenderID != senderID || s.Status != StatusPending {
+		return nil, mongo.ErrNoDocuments
+	}
+	update(s)
+	updated := *s
+	return &updated, nil
+}
+
+func (r *memoryRepo) ClaimDue(_ context.Context, now time.Time, staleBefore time.Time) (*ScheduledMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []*ScheduledMessage
+	for _, s := range r.messages {
+		if (s.Status == StatusPending && !s.SendAt.After(now)) || (s.Status == StatusSending && s.ClaimedAt.Before(staleBefore)) {
+			due = append(due, s)
+		}
+	}
+	if len(due) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].SendAt.Before(due[j].SendAt) })
+	s := due[0]
+	s.Status, s.ClaimedAt = StatusSending, now
+	s.Attempts++
+	claimed := *s
+	return &claimed, nil
+}
+
+func (r *memoryRepo) SetStatus(_ context.Context, id primitive.ObjectID, status string, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failSetStatus {
+		r.failSetStatus = false
+		return errors.New("scheduler crashed")
+	}
+	s := r.messages[id]
+	s.Status = status
+	if reason != "" {
+		s.Error = reason
+	}
+	return nil
+}
+
+func (r *memoryRepo) SetRetry(_ context.Context, id primitive.ObjectID, sendAt time.Time, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.messages[id]
+	s.Status, s.SendAt, s.Error = StatusPending, sendAt, reason
+	return nil
+}
+
+func (r *memoryRepo) NextSendAt(_ context.Context) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var next time.Time
+	for _, s := range r.messages {
+		if s.Status == StatusPending && (next.IsZero() || s.SendAt.Before(next)) {
+			next = s.SendAt
+		}
+	}
+	if next.IsZero() {
+		return time.Time{}, mongo.ErrNoDocuments
+	}
+	return next, nil
+}
+
+func (r *memoryRepo) get(id primitive.ObjectID) ScheduledMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.messages[id]
+}
+
+// fakeMessages is the part of the message service the scheduler uses. Posts are idempotent by sender and client
+// message ID like the real service, and fail with the errors queued for their body.
+type fakeMessages struct {
+	message.MessageService
+
+	mu     sync.Mutex
+	posted []*message.Message
+	errs   map[string][]error
+}
+
+func newFakeMessages() *fakeMessages {
+	return &fakeMessages{errs: make(map[string][]error)}
+}
+
+func (f *fakeMessages) failNext(body string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs[body] = append(f.errs[body], errs...)
+}
+
+func (f *fakeMessages) PostMessage(_ context.Context, msg *message.Message) (*message.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if errs := f.errs[msg.Body]; len(errs) > 0 {
+		f.errs[msg.Body] = errs[1:]
+		return nil, errs[0]
+	}
+	for _, p := range f.posted {
+		if p.SenderID == msg.SenderID && p.ClientMsgID == msg.ClientMsgID {
+			return p, nil
+		}
+	}
+	if !msg.ID.IsZero() {
+		return nil, errors.New("scheduled deliveries must not choose their message ID")
+	}
+	msg.ID = primitive.NewObjectID()
+	f.posted = append(f.posted, msg)
+	return msg, nil
+}
+
+func (f *fakeMessages) count(body string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, p := range f.posted {
+		if p.Body == body {
+			n++
+		}
+	}
+	return n
+}
+
+var start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestService() (*ScheduleServiceImpl, *memoryRepo, *fakeMessages, *FakeClock) {
+	repo := newMemoryRepo()
+	messages := newFakeMessages()
+	clock := NewFakeClock(start)
+	service := NewScheduleService(repo, nil, messages, nil, clock, Config{
+		MaxAhead:        24 * time.Hour,
+		PollInterval:    time.Minute,
+		ClaimTimeout:    time.Minute,
+		BatchSize:       10,
+		MaxAttempts:     3,
+		RetryBackoff:    10 * time.Second,
+		MaxRetryBackoff: 15 * time.Second,
+	})
+	return service, repo, messages, clock
+}
+
+func schedule(t *testing.T, repo *memoryRepo, body string, sendAt time.Time) *ScheduledMessage {
+	t.Helper()
+	s, err := repo.CreateScheduled(context.Background(), &ScheduledMessage{
+		RoomID:   "room",
+		SenderID: "sender",
+		Body:     body,
+		SendAt:   sendAt,
+		Status:   StatusPending,
+	})
+	if err != nil {
+		t.Fatalf("CreateScheduled: %v", err)
+	}
+	return s
+}
+
+func TestDeliverDueOnlyClaimsDueMessages(t *testing.T) {
+	service, repo, messages, clock := newTestService()
+	due := schedule(t, repo, "due", start.Add(time.Second))
+	later := schedule(t, repo, "later", start.Add(time.Hour))
+
+	clock.Advance(time.Second)
+	if service.DeliverDue(context.Background()) {
+		t.Fatal("DeliverDue asked for a retry")
+	}
+	if got := repo.get(due.ID).Status; got != StatusSent {
+		t.Errorf("due message is %s, want sent", got)
+	}
+	if got := repo.get(later.ID).Status; got != StatusPending {
+		t.Errorf("later message is %s, want pending", got)
+	}
+	if messages.count("due") != 1 || messages.count("later") != 0 {
+		t.Errorf("posted due %d times and later %d times", messages.count("due"), messages.count("later"))
+	}
+}
+
+func TestDeliveryIsExactlyOnceAcrossCrash(t *testing.T) {
+	service, repo, messages, clock := newTestService()
+	s := schedule(t, repo, "hello", start)
+
+	// The message is posted, but the scheduler dies before recording it.
+	repo.failSetStatus = true
+	service.DeliverDue(context.Background())
+	if got := repo.get(s.ID).Status; got != StatusSending {
+		t.Fatalf("message is %s after the crash, want sending", got)
+	}
+
+	// Another scheduler takes the stale claim over.
+	clock.Advance(2 * time.Minute)
+	service.DeliverDue(context.Background())
+	if got := repo.get(s.ID).Status; got != StatusSent {
+		t.Errorf("message is %s after the takeover, want sent", got)
+	}
+	if n := messages.count("hello"); n != 1 {
+		t.Errorf("message posted %d times, want 1", n)
+	}
+	if got := repo.get(s.ID).Attempts; got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+}
+
+func TestFailedDeliveryBacksOffWithoutHoldingUpTheBatch(t *testing.T) {
+	service, repo, messages, clock := newTestService()
+	failing := schedule(t, repo, "failing", start)
+	other := schedule(t, repo, "other", start.Add(time.Second))
+	messages.failNext("failing", errors.New("connection reset"), errors.New("connection reset"), errors.New("connection reset"))
+
+	clock.Advance(time.Second)
+	service.DeliverDue(context.Background())
+	if got := repo.get(other.ID).Status; got != StatusSent {
+		t.Fatalf("other message is %s, want sent despite the failing one", got)
+	}
+	retried := repo.get(failing.ID)
+	if retried.Status != StatusPending || !retried.SendAt.Equal(start.Add(11*time.Second)) {
+		t.Fatalf("failing message is %s at %v, want pending at %v", retried.Status, retried.SendAt, start.Add(11*time.Second))
+	}
+
+	// Not claimed again before its backoff has passed.
+	service.DeliverDue(context.Background())
+	if got := repo.get(failing.ID).Attempts; got != 1 {
+		t.Fatalf("attempts = %d before the backoff passed, want 1", got)
+	}
+
+	clock.Advance(10 * time.Second)
+	service.DeliverDue(context.Background())
+	retried = repo.get(failing.ID)
+	// The second backoff doubles to 20s but is capped at 15s.
+	if want := start.Add(26 * time.Second); retried.Status != StatusPending || !retried.SendAt.Equal(want) {
+		t.Fatalf("failing message is %s at %v, want pending at %v", retried.Status, retried.SendAt, want)
+	}
+
+	clock.Advance(15 * time.Second)
+	service.DeliverDue(context.Background())
+	if got := repo.get(failing.ID); got.Status != StatusFailed || got.Error == "" {
+		t.Errorf("failing message is %s (%q) after 3 attempts, want failed with the error", got.Status, got.Error)
+	}
+	if n := messages.count("failing"); n != 0 {
+		t.Errorf("failing message posted %d times", n)
+	}
+}
+
+func TestSlowModeRetriesNoEarlierThanAllowed(t *testing.T) {
+	service, repo, messages, _ := newTestService()
+	s := schedule(t, repo, "slow", start)
+	messages.failNext("slow", &errormodel.RetryError{Err: errormodel.ErrSlowMode, RetryAfter: time.Minute})
+
+	service.DeliverDue(context.Background())
+	if got := repo.get(s.ID); got.Status != StatusPending || !got.SendAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("message is %s at %v, want pending at %v", got.Status, got.SendAt, start.Add(time.Minute))
+	}
+}
+
+func TestMutedSenderFailsDelivery(t *testing.T) {
+	service, repo, messages, _ := newTestService()
+	s := schedule(t, repo, "muted", start)
+	messages.failNext("muted", errormodel.ErrMuted)
+
+	service.DeliverDue(context.Background())
+	if got := repo.get(s.ID).Status; got != StatusFailed {
+		t.Errorf("message is %s, want failed", got)
+	}
+}
+
+func TestEditAndCancelRaceWithDelivery(t *testing.T) {
+	service, repo, messages, clock := newTestService()
+	ctx := context.Background()
+	claimed := schedule(t, repo, "claimed", start.Add(time.Second))
+	cancelled := schedule(t, repo, "cancelled", start.Add(time.Second))
+	edited := schedule(t, repo, "edited", start.Add(time.Second))
+
+	// Cancelled before it comes due: never delivered.
+	if _, err := service.CancelScheduled(ctx, cancelled.ID.Hex(), "sender"); err != nil {
+		t.Fatalf("CancelScheduled: %v", err)
+	}
+	// Edited before it comes due: delivered with the edit.
+	body := "edited later"
+	if _, err := service.UpdateScheduled(ctx, edited.ID.Hex(), "sender", request.UpdateScheduledMessageRequest{Body: &body}); err != nil {
+		t.Fatalf("UpdateScheduled: %v", err)
+	}
+
+	// Once claimed, the message can no longer be edited or cancelled.
+	clock.Advance(time.Second)
+	if _, err := repo.ClaimDue(ctx, clock.Now(), clock.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("ClaimDue: %v", err)
+	}
+	var inFlight primitive.ObjectID
+	for _, s := range []*ScheduledMessage{claimed, edited} {
+		if repo.get(s.ID).Status == StatusSending {
+			inFlight = s.ID
+		}
+	}
+	if _, err := service.UpdateScheduled(ctx, inFlight.Hex(), "sender", request.UpdateScheduledMessageRequest{Body: &body}); !errors.Is(err, errormodel.ErrScheduledNotFound) {
+		t.Errorf("UpdateScheduled of a claimed message = %v, want ErrScheduledNotFound", err)
+	}
+	if _, err := service.CancelScheduled(ctx, inFlight.Hex(), "sender"); !errors.Is(err, errormodel.ErrScheduledNotFound) {
+		t.Errorf("CancelScheduled of a claimed message = %v, want ErrScheduledNotFound", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	service.DeliverDue(ctx)
+	if messages.count("cancelled") != 0 {
+		t.Error("cancelled message was delivered")
+	}
+	if messages.count("edited") != 0 || messages.count("edited later") != 1 || messages.count("claimed") != 1 {
+		t.Errorf("posted claimed %d, edited %d and edited later %d times, want 1, 0 and 1",
+			messages.count("claimed"), messages.count("edited"), messages.count("edited later"))
+	}
+}
+
+func TestStartSleepsUntilTheNextMessageIsDue(t *testing.T) {
+	service, repo, messages, clock := newTestService()
+	s := schedule(t, repo, "wake", start.Add(30*time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service.Start(ctx)
+
+	waitFor(t, func() bool { return clock.Waiters() == 1 })
+	clock.Advance(29 * time.Second)
+	if messages.count("wake") != 0 {
+		t.Fatal("message delivered before it was due")
+	}
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return repo.get(s.ID).Status == StatusSent })
+}
+
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,30 @@
+package schedule
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/message"
+	"messages-go/room"
+	"messages-go/utils"
+	ws "messages-go/websocket"
+	"time"
+)
+
+// InitScheduleHandler wires the schedule handler and starts delivering scheduled messages until ctx is cancelled.
+func InitScheduleHandler(ctx context.Context, client *mongo.Client, roomRepo room.RoomRepo, messageService message.MessageService, wsHandler *ws.Handler) (ScheduleHandler, ScheduleRepo, ScheduleService) {
+	cfg := Config{
+		MaxAhead:        utils.GetEnvDuration("SCHEDULE_MAX_AHEAD", 365*24*time.Hour),
+		PollInterval:    utils.GetEnvDuration("SCHEDULE_POLL_INTERVAL", 10*time.Second),
+		ClaimTimeout:    utils.GetEnvDuration("SCHEDULE_CLAIM_TIMEOUT", time.Minute),
+		BatchSize:       utils.GetEnvInt("SCHEDULE_BATCH_SIZE", 100),
+		MaxAttempts:     utils.GetEnvInt("SCHEDULE_MAX_ATTEMPTS", 5),
+		RetryBackoff:    utils.GetEnvDuration("SCHEDULE_RETRY_BACKOFF", 30*time.Second),
+		MaxRetryBackoff: utils.GetEnvDuration("SCHEDULE_MAX_RETRY_BACKOFF", time.Hour),
+	}
+
+	repo := NewScheduleRepository(client)
+	service := NewScheduleService(repo, roomRepo, messageService, wsHandler, RealClock{}, cfg)
+	service.Start(ctx)
+	handler := NewScheduleHandler(service)
+	return handler, repo, service
+}
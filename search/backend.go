@@ -0,0 +1,9 @@
+package search
+
+import "context"
+
+// Backend defines the interface for full-text engines that rank messages matching a query.
+// Implementations return one page of hits, best match first, and the total number of matches.
+type Backend interface {
+	Search(ctx context.Context, q Query) ([]Hit, int64, error)
+}
@@ -0,0 +1,112 @@
+package search
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/response"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPageSize is the number of results returned when the request does not specify a limit.
+	defaultPageSize = 20
+	// maxPageSize caps the number of results returned in a single page.
+	maxPageSize = 100
+)
+
+// SearchHandler defines the interface for handling HTTP requests related to message search.
+type SearchHandler interface {
+	Search(c *fiber.Ctx) error
+}
+
+// SearchHandlerImpl implements the SearchHandler interface.
+type SearchHandlerImpl struct {
+	searchService SearchService
+}
+
+// NewSearchHandler initializes and returns a new SearchHandler with the provided SearchService implementation.
+func NewSearchHandler(searchService SearchService) SearchHandler {
+	return &SearchHandlerImpl{searchService: searchService}
+}
+
+// Search handles full-text message search, parsing the query, filters and paging from the query string.
+func (sh *SearchHandlerImpl) Search(c *fiber.Ctx) error {
+	req := Request{
+		Text:     strings.TrimSpace(c.Query("q")),
+		RoomID:   strings.TrimSpace(c.Query("room")),
+		SenderID: strings.TrimSpace(c.Query("sender")),
+		Page:     c.QueryInt("page", 1),
+		Limit:    c.QueryInt("limit", defaultPageSize),
+	}
+	if req.Text == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   "Missing search query",
+			Status:  fiber.StatusBadRequest,
+			Message: "q Query Parameter is required to be not empty.",
+		})
+	}
+	if req.Page < 1 || req.Limit < 1 || req.Limit > maxPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   "Invalid paging parameters",
+			Status:  fiber.StatusBadRequest,
+			Message: "page must be at least 1 and limit between 1 and 100.",
+		})
+	}
+
+	var err error
+	if req.Before, err = parseTimeParam(c.Query("before")); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "before must be an RFC 3339 timestamp.",
+		})
+	}
+	if req.After, err = parseTimeParam(c.Query("after")); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "after must be an RFC 3339 timestamp.",
+		})
+	}
+
+	log.Println("Search Messages for: ", req.Text, " Request Received.")
+
+	results, err := sh.searchService.Search(c.Context(), req, auth.CallerID(c))
+	if errors.Is(err, errormodel.ErrInvalidSearchQuery) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "q must contain at least one word.",
+		})
+	} else if errors.Is(err, errormodel.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Room found given room.",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Search Messages",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Search Completed",
+		Data:    results,
+	})
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter, returning the zero time when it is empty.
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
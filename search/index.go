@@ -0,0 +1,200 @@
+package search
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"messages-go/message"
+	"sort"
+	"sync"
+	"time"
+)
+
+// IndexBackend is an in-process inverted index over message bodies, used when messages are not stored in MongoDB.
+// Rooms are loaded from the message repository the first time they are searched. Messages are then added as the
+// message service reports them posted or shown again, and evicted as it reports them deleted, pruned, expired or
+// hidden.
+type IndexBackend struct {
+	messageRepo message.MessageRepo
+
+	mu          sync.RWMutex
+	postings    map[string]map[primitive.ObjectID]int
+	docs        map[primitive.ObjectID]indexedDoc
+	loadedRooms map[string]bool
+}
+
+// indexedDoc is a message held by the index together with its token count.
+type indexedDoc struct {
+	msg    message.Message
+	length int
+}
+
+// NewIndexBackend initializes an empty IndexBackend that loads rooms from the given repository on demand.
+func NewIndexBackend(messageRepo message.MessageRepo) *IndexBackend {
+	return &IndexBackend{
+		messageRepo: messageRepo,
+		postings:    make(map[string]map[primitive.ObjectID]int),
+		docs:        make(map[primitive.ObjectID]indexedDoc),
+		loadedRooms: make(map[string]bool),
+	}
+}
+
+// MessagePosted adds a newly posted message to the index.
+func (b *IndexBackend) MessagePosted(_ context.Context, msg *message.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.add(*msg)
+}
+
+// MessagesDeleted removes deleted messages from the index.
+func (b *IndexBackend) MessagesDeleted(_ context.Context, _ string, ids []primitive.ObjectID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, id := range ids {
+		b.remove(id)
+	}
+}
+
+// MessageHidden evicts a message hidden pending review from the index, or adds it back once it is shown again.
+func (b *IndexBackend) MessageHidden(_ context.Context, msg *message.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if msg.Hidden {
+		b.remove(msg.ID)
+	} else if b.loadedRooms[msg.RoomID] {
+		b.add(*msg)
+	}
+}
+
+// Search ranks the indexed messages of the queried rooms by TF-IDF over the query terms.
+func (b *IndexBackend) Search(ctx context.Context, q Query) ([]Hit, int64, error) {
+	if err := b.loadRooms(ctx, q.RoomIDs); err != nil {
+		return nil, 0, err
+	}
+
+	rooms := make(map[string]bool, len(q.RoomIDs))
+	for _, id := range q.RoomIDs {
+		rooms[id] = true
+	}
+
+	b.mu.RLock()
+	scores := make(map[primitive.ObjectID]float64)
+	total := float64(len(b.docs))
+	for _, term := range q.Terms {
+		posting := b.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		idf := math.Log(1 + total/float64(len(posting)))
+		for id, tf := range posting {
+			doc := b.docs[id]
+			if !b.matches(doc.msg, q, rooms) {
+				continue
+			}
+			scores[id] += float64(tf) / math.Sqrt(float64(doc.length)) * idf
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{Message: b.docs[id].msg, Score: score})
+	}
+	b.mu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Message.ID.Hex() > hits[j].Message.ID.Hex()
+	})
+
+	count := int64(len(hits))
+	if q.Offset >= len(hits) {
+		return []Hit{}, count, nil
+	}
+	end := min(q.Offset+q.Limit, len(hits))
+	return hits[q.Offset:end], count, nil
+}
+
+// matches applies the non-text filters of a query to an indexed message.
+func (b *IndexBackend) matches(msg message.Message, q Query, rooms map[string]bool) bool {
+	if !rooms[msg.RoomID] {
+		return false
+	}
+	if q.SenderID != "" && msg.SenderID != q.SenderID {
+		return false
+	}
+	// Expired messages are evicted once they are swept
+	if msg.ExpiresAt != nil && !msg.ExpiresAt.After(time.Now()) {
+		return false
+	}
+	created := msg.ID.Timestamp()
+	if !q.After.IsZero() && !created.After(q.After) {
+		return false
+	}
+	if !q.Before.IsZero() && !created.Before(q.Before) {
+		return false
+	}
+	return true
+}
+
+// loadRooms indexes the stored messages of every room that has not been loaded yet.
+func (b *IndexBackend) loadRooms(ctx context.Context, roomIDs []string) error {
+	for _, roomID := range roomIDs {
+		b.mu.RLock()
+		loaded := b.loadedRooms[roomID]
+		b.mu.RUnlock()
+		if loaded {
+			continue
+		}
+
+		oid, err := primitive.ObjectIDFromHex(roomID)
+		if err != nil {
+			continue
+		}
+		messages, err := b.messageRepo.GetMessagesByRoomId(ctx, oid)
+		if err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		for _, msg := range messages {
+			b.add(msg)
+		}
+		b.loadedRooms[roomID] = true
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// add indexes a message unless it is already present or hidden. The caller must hold the write lock.
+func (b *IndexBackend) add(msg message.Message) {
+	if _, ok := b.docs[msg.ID]; ok || msg.Hidden {
+		return
+	}
+	terms := Tokenize(msg.Body)
+	b.docs[msg.ID] = indexedDoc{msg: msg, length: max(len(terms), 1)}
+	for _, term := range terms {
+		if b.postings[term] == nil {
+			b.postings[term] = make(map[primitive.ObjectID]int)
+		}
+		b.postings[term][msg.ID]++
+	}
+}
+
+// remove drops a message from the index. The caller must hold the write lock.
+func (b *IndexBackend) remove(id primitive.ObjectID) {
+	doc, ok := b.docs[id]
+	if !ok {
+		return
+	}
+	delete(b.docs, id)
+	for _, term := range Tokenize(doc.msg.Body) {
+		if posting := b.postings[term]; posting != nil {
+			delete(posting, id)
+			if len(posting) == 0 {
+				delete(b.postings, term)
+			}
+		}
+	}
+}
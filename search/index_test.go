@@ -0,0 +1,82 @@
+package search
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"messages-go/message"
+	"testing"
+	"time"
+)
+
+// storedMessages is a MessageRepo serving the stored messages of rooms, without hidden ones as MessageRepoImpl does.
+type storedMessages struct {
+	message.MessageRepo
+	messages []message.Message
+}
+
+func (r *storedMessages) GetMessagesByRoomId(_ context.Context, roomID primitive.ObjectID) ([]message.Message, error) {
+	var found []message.Message
+	for _, msg := range r.messages {
+		if msg.RoomID == roomID.Hex() && !msg.Hidden {
+			found = append(found, msg)
+		}
+	}
+	return found, nil
+}
+
+func search(t *testing.T, b *IndexBackend, roomID string, text string) []string {
+	t.Helper()
+	hits, total, err := b.Search(context.Background(), Query{Text: text, Terms: Tokenize(text), RoomIDs: []string{roomID}, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search(%q): %v", text, err)
+	}
+	if total != int64(len(hits)) {
+		t.Errorf("Search(%q) total = %d for %d hits", text, total, len(hits))
+	}
+	bodies := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		bodies = append(bodies, hit.Message.Body)
+	}
+	return bodies
+}
+
+func TestIndexBackendEvictsRemovedMessages(t *testing.T) {
+	roomID := primitive.NewObjectID().Hex()
+	stored := message.Message{ID: primitive.NewObjectID(), RoomID: roomID, Body: "deploy the release"}
+	hidden := message.Message{ID: primitive.NewObjectID(), RoomID: roomID, Body: "deploy secrets", Hidden: true}
+	b := NewIndexBackend(&storedMessages{messages: []message.Message{stored, hidden}})
+	ctx := context.Background()
+
+	if got := search(t, b, roomID, "deploy"); len(got) != 1 || got[0] != stored.Body {
+		t.Fatalf("Search = %q, want only the stored visible message", got)
+	}
+
+	posted := message.Message{ID: primitive.NewObjectID(), RoomID: roomID, Body: "deploy tonight"}
+	b.MessagePosted(ctx, &posted)
+	if got := search(t, b, roomID, "tonight"); len(got) != 1 {
+		t.Errorf("Search after posting = %q, want the posted message", got)
+	}
+
+	posted.Hidden = true
+	b.MessageHidden(ctx, &posted)
+	if got := search(t, b, roomID, "tonight"); len(got) != 0 {
+		t.Errorf("Search after hiding = %q, want nothing", got)
+	}
+	posted.Hidden = false
+	b.MessageHidden(ctx, &posted)
+	if got := search(t, b, roomID, "tonight"); len(got) != 1 {
+		t.Errorf("Search after showing again = %q, want the message back", got)
+	}
+
+	b.MessagesDeleted(ctx, roomID, []primitive.ObjectID{stored.ID, posted.ID})
+	if got := search(t, b, roomID, "deploy"); len(got) != 0 {
+		t.Errorf("Search after deleting = %q, want nothing", got)
+	}
+
+	expired := time.Now().Add(-time.Second)
+	ephemeral := message.Message{ID: primitive.NewObjectID(), RoomID: roomID, Body: "deploy key", ExpiresAt: &expired}
+	b.MessagePosted(ctx, &ephemeral)
+	if got := search(t, b, roomID, "key"); len(got) != 0 {
+		t.Errorf("Search = %q, want expired messages left out before they are swept", got)
+	}
+}
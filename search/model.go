@@ -0,0 +1,44 @@
+package search
+
+import (
+	"messages-go/message"
+	"time"
+)
+
+// Request represents a parsed search request as received from the API.
+type Request struct {
+	Text     string
+	RoomID   string
+	SenderID string
+	Before   time.Time
+	After    time.Time
+	Page     int
+	Limit    int
+}
+
+// Query is the backend-facing form of a search, already restricted to the rooms the caller can see.
+type Query struct {
+	Text     string
+	Terms    []string
+	RoomIDs  []string
+	SenderID string
+	Before   time.Time
+	After    time.Time
+	Offset   int
+	Limit    int
+}
+
+// Hit represents a single ranked search result with a highlighted snippet of the message body.
+type Hit struct {
+	Message message.Message `json:"message"`
+	Score   float64         `json:"score"`
+	Snippet string          `json:"snippet"`
+}
+
+// Results represents a page of search results along with the total number of matches.
+type Results struct {
+	Hits  []Hit `json:"results"`
+	Total int64 `json:"total"`
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"messages-go/message"
+	"os"
+	"time"
+)
+
+// MongoBackend is a Backend that relies on a MongoDB text index over the message body.
+type MongoBackend struct {
+	messageCollection *mongo.Collection
+}
+
+// NewMongoBackend initializes a MongoBackend for the messages collection and ensures its text index exists.
+func NewMongoBackend(client *mongo.Client) *MongoBackend {
+	b := &MongoBackend{
+		messageCollection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("messages"),
+	}
+	if err := b.ensureIndexes(context.Background()); err != nil {
+		log.Printf("Failed to create message text index: %v", err)
+	}
+	return b
+}
+
+// ensureIndexes creates the text index used for searching message bodies if it does not already exist.
+func (b *MongoBackend) ensureIndexes(ctx context.Context) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := b.messageCollection.Indexes().CreateOne(timeoutCtx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "body", Value: "text"}},
+		Options: options.Index().SetName("body_text"),
+	})
+	return err
+}
+
+// Search runs a $text query ranked by text score, newest first among equal scores.
+func (b *MongoBackend) Search(ctx context.Context, q Query) ([]Hit, int64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"$text":   bson.M{"$search": q.Text},
+		"room_id": bson.M{"$in": q.RoomIDs},
+		"hidden":  bson.M{"$ne": true},
+		// Expired messages may linger until they are swept
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+	if q.SenderID != "" {
+		filter["sender_id"] = q.SenderID
+	}
+	idRange := bson.M{}
+	if !q.After.IsZero() {
+		idRange["$gt"] = primitive.NewObjectIDFromTimestamp(q.After)
+	}
+	if !q.Before.IsZero() {
+		idRange["$lt"] = primitive.NewObjectIDFromTimestamp(q.Before)
+	}
+	if len(idRange) > 0 {
+		filter["_id"] = idRange
+	}
+
+	total, err := b.messageCollection.CountDocuments(timeoutCtx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(q.Offset)).
+		SetLimit(int64(q.Limit))
+
+	cursor, err := b.messageCollection.Find(timeoutCtx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var docs []struct {
+		message.Message `bson:",inline"`
+		Score           float64 `bson:"score"`
+	}
+	if err := cursor.All(timeoutCtx, &docs); err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]Hit, 0, len(docs))
+	for _, doc := range docs {
+		hits = append(hits, Hit{Message: doc.Message, Score: doc.Score})
+	}
+	return hits, total, nil
+}
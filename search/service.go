@@ -0,0 +1,70 @@
+package search
+
+import (
+	"context"
+	"messages-go/models/errormodel"
+	"messages-go/room"
+	"slices"
+)
+
+// SearchService defines the interface for searching messages across the rooms visible to a caller.
+type SearchService interface {
+	Search(ctx context.Context, req Request, callerId string) (*Results, error)
+}
+
+// SearchServiceImpl restricts searches to the caller's visible rooms and delegates ranking to a Backend.
+type SearchServiceImpl struct {
+	backend  Backend
+	roomRepo room.RoomRepo
+}
+
+// NewSearchService initializes and returns a new instance of SearchServiceImpl with the provided backend and room repository.
+func NewSearchService(backend Backend, roomRepo room.RoomRepo) *SearchServiceImpl {
+	return &SearchServiceImpl{backend: backend, roomRepo: roomRepo}
+}
+
+// Search returns one page of ranked matches for the request, with snippets highlighting the query terms.
+// Searching a specific room the caller cannot see is reported as the room not being found.
+func (ss *SearchServiceImpl) Search(ctx context.Context, req Request, callerId string) (*Results, error) {
+	terms := Tokenize(req.Text)
+	if len(terms) == 0 {
+		return nil, errormodel.ErrInvalidSearchQuery
+	}
+
+	roomIDs, err := ss.roomRepo.GetVisibleRoomIDs(ctx, callerId)
+	if err != nil {
+		return nil, err
+	}
+	if req.RoomID != "" {
+		if !slices.Contains(roomIDs, req.RoomID) {
+			return nil, errormodel.ErrRoomNotFound
+		}
+		roomIDs = []string{req.RoomID}
+	}
+
+	results := &Results{Hits: []Hit{}, Page: req.Page, Limit: req.Limit}
+	if len(roomIDs) == 0 {
+		return results, nil
+	}
+
+	hits, total, err := ss.backend.Search(ctx, Query{
+		Text:     req.Text,
+		Terms:    terms,
+		RoomIDs:  roomIDs,
+		SenderID: req.SenderID,
+		Before:   req.Before,
+		After:    req.After,
+		Offset:   (req.Page - 1) * req.Limit,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		hits[i].Snippet = Snippet(hits[i].Message.Body, terms)
+	}
+	results.Hits = hits
+	results.Total = total
+	return results, nil
+}
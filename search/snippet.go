@@ -0,0 +1,91 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	// snippetLength is the maximum number of characters of the message body included in a snippet.
+	snippetLength = 160
+	// snippetLead is how many characters are kept before the first highlighted term.
+	snippetLead = 40
+)
+
+// Tokenize splits text into lower-cased words made of letters and digits.
+func Tokenize(text string) []string {
+	var terms []string
+	for _, span := range tokenSpans([]rune(text)) {
+		terms = append(terms, span.term)
+	}
+	return terms
+}
+
+// Snippet returns an HTML-escaped excerpt of body around the first matching term, with every word that starts
+// with one of the query terms wrapped in <mark> tags.
+func Snippet(body string, terms []string) string {
+	runes := []rune(body)
+
+	var marks []tokenSpan
+	for _, span := range tokenSpans(runes) {
+		for _, term := range terms {
+			if strings.HasPrefix(span.term, term) {
+				marks = append(marks, span)
+				break
+			}
+		}
+	}
+
+	start := 0
+	if len(marks) > 0 {
+		start = max(0, marks[0].start-snippetLead)
+	}
+	end := min(len(runes), start+snippetLength)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, mark := range marks {
+		if mark.end > end {
+			break
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:mark.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[mark.start:mark.end])))
+		b.WriteString("</mark>")
+		pos = mark.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// tokenSpan is a word of a text with its rune offsets.
+type tokenSpan struct {
+	term       string
+	start, end int
+}
+
+// tokenSpans finds the maximal runs of letters and digits in runes.
+func tokenSpans(runes []rune) []tokenSpan {
+	var spans []tokenSpan
+	start := -1
+	for i, r := range runes {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			spans = append(spans, tokenSpan{term: strings.ToLower(string(runes[start:i])), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, tokenSpan{term: strings.ToLower(string(runes[start:])), start: start, end: len(runes)})
+	}
+	return spans
+}
@@ -0,0 +1,25 @@
+package search
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/message"
+	"messages-go/room"
+)
+
+// InitSearchHandler wires the search handler, using the Mongo text index when messages are stored in MongoDB and
+// an in-process inverted index kept current by the message service's observers otherwise.
+func InitSearchHandler(client *mongo.Client, messageRepo message.MessageRepo, messageService message.MessageService, roomRepo room.RoomRepo) (SearchHandler, SearchService) {
+	var backend Backend
+	if _, ok := messageRepo.(*message.MessageRepoImpl); ok {
+		backend = NewMongoBackend(client)
+	} else {
+		index := NewIndexBackend(messageRepo)
+		messageService.AddPostObserver(index)
+		messageService.AddDeleteObserver(index)
+		messageService.AddHideObserver(index)
+		backend = index
+	}
+	service := NewSearchService(backend, roomRepo)
+	handler := NewSearchHandler(service)
+	return handler, service
+}
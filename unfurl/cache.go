@@ -0,0 +1,70 @@
+package unfurl
+
+import (
+	"container/list"
+	"messages-go/message"
+	"sync"
+	"time"
+)
+
+// Cache is a size-bounded, least-recently-used cache of unfurled previews keyed by URL. URLs without a preview
+// are cached too, so that failing or metadata-less pages are not fetched again for every message linking them.
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type cacheEntry struct {
+	url     string
+	preview *message.Preview
+	expires time.Time
+}
+
+// NewCache returns an empty cache holding at most maxEntries previews.
+func NewCache(maxEntries int) *Cache {
+	return &Cache{
+		maxEntries: max(1, maxEntries),
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the cached preview of a URL, which is nil for URLs known to have none, and whether the URL was
+// cached and has not expired.
+func (c *Cache) Get(url string, now time.Time) (*message.Preview, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[url]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !now.Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, url)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.preview, true
+}
+
+// Put caches the preview of a URL until expires, evicting the least recently used URL when the cache is full.
+func (c *Cache) Put(url string, preview *message.Preview, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[url]; ok {
+		el.Value = &cacheEntry{url: url, preview: preview, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[url] = c.order.PushFront(&cacheEntry{url: url, preview: preview, expires: expires})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).url)
+	}
+}
@@ -0,0 +1,92 @@
+package unfurl
+
+import (
+	"html"
+	"messages-go/message"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	titleTagPattern  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	attributePattern = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// parseMeta extracts the OpenGraph and Twitter card metadata of an HTML document, falling back to its <title>
+// and description meta tag. OpenGraph properties take precedence over Twitter card ones. Relative image URLs are
+// resolved against base; images that are not http or https URLs are dropped.
+func parseMeta(doc string, base *url.URL) *message.Preview {
+	props := make(map[string]string)
+	for _, tag := range metaTagPattern.FindAllString(doc, -1) {
+		attrs := make(map[string]string)
+		for _, m := range attributePattern.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = m[2] + m[3] + m[4]
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(key)
+		if _, seen := props[key]; key != "" && !seen {
+			props[key] = clean(attrs["content"], maxDescriptionLength)
+		}
+	}
+
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if v := props[key]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	preview := &message.Preview{
+		URL:         base.String(),
+		Title:       first("og:title", "twitter:title"),
+		Description: first("og:description", "twitter:description", "description"),
+		SiteName:    first("og:site_name"),
+	}
+	if preview.Title == "" {
+		if m := titleTagPattern.FindStringSubmatch(doc); m != nil {
+			preview.Title = clean(m[1], maxTitleLength)
+		}
+	}
+	preview.Title = truncate(preview.Title, maxTitleLength)
+
+	if image := first("og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src"); image != "" {
+		if u, err := base.Parse(image); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			preview.ImageURL = u.String()
+		}
+	}
+
+	if preview.Title == "" && preview.Description == "" && preview.ImageURL == "" {
+		return nil
+	}
+	return preview
+}
+
+// clean unescapes HTML entities, collapses whitespace and truncates the result.
+func clean(s string, limit int) string {
+	return truncate(strings.Join(strings.Fields(html.UnescapeString(s)), " "), limit)
+}
+
+// truncate shortens s to at most limit bytes without splitting a UTF-8 sequence.
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "…"
+}
@@ -0,0 +1,208 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"log"
+	"messages-go/message"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNotHTML is returned by Fetch for pages that are not HTML documents.
+var ErrNotHTML = errors.New("not an HTML document")
+
+// urlPattern finds the http and https URLs in a message body.
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'()\[\]]+`)
+
+// Config holds the limits applied to fetching previews and the size and lifetime of the preview cache.
+type Config struct {
+	Workers int
+	// MaxLinks is the number of URLs of a single message that are unfurled.
+	MaxLinks     int
+	Timeout      time.Duration
+	MaxBytes     int64
+	MaxRedirects int
+	UserAgent    string
+	CacheSize    int
+	CacheTTL     time.Duration
+	// FailureTTL is how long URLs that yielded no preview are cached.
+	FailureTTL time.Duration
+	// AllowPrivateNetworks disables the SSRF protection, for development against local servers only.
+	AllowPrivateNetworks bool
+}
+
+// Unfurler fetches link previews for posted messages in the background, records them on the message, and
+// broadcasts the updated message to its room.
+type Unfurler struct {
+	messageService message.MessageService
+	broadcaster    Broadcaster
+	client         *http.Client
+	cache          *Cache
+	cfg            Config
+	jobs           chan job
+	now            func() time.Time
+}
+
+// Broadcaster delivers events to the subscribers of a room.
+type Broadcaster interface {
+	BroadcastToRoom(roomID string, message interface{})
+}
+
+type job struct {
+	messageID primitive.ObjectID
+	urls      []string
+}
+
+// NewUnfurler initializes and returns a new Unfurler. Workers are started by Start.
+func NewUnfurler(messageService message.MessageService, broadcaster Broadcaster, cfg Config) *Unfurler {
+	return &Unfurler{
+		messageService: messageService,
+		broadcaster:    broadcaster,
+		client:         NewClient(cfg),
+		cache:          NewCache(cfg.CacheSize),
+		cfg:            cfg,
+		jobs:           make(chan job, 1024),
+		now:            time.Now,
+	}
+}
+
+// Start starts the workers unfurling links until ctx is cancelled.
+func (u *Unfurler) Start(ctx context.Context) {
+	for range max(1, u.cfg.Workers) {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-u.jobs:
+					u.process(ctx, j)
+				}
+			}
+		}()
+	}
+}
+
+// MessagePosted queues the URLs of a newly posted message for unfurling. Messages are dropped, keeping no
+// previews, when the queue is full.
+func (u *Unfurler) MessagePosted(_ context.Context, msg *message.Message) {
+	urls := ExtractURLs(msg.Body, u.cfg.MaxLinks)
+	if len(urls) == 0 {
+		return
+	}
+	select {
+	case u.jobs <- job{messageID: msg.ID, urls: urls}:
+	default:
+		log.Printf("Unfurl queue full, skipping previews of message %s", msg.ID.Hex())
+	}
+}
+
+// process unfurls the URLs of a message and, when any has a preview, records and broadcasts them.
+func (u *Unfurler) process(ctx context.Context, j job) {
+	var previews []message.Preview
+	for _, rawURL := range j.urls {
+		if preview := u.Preview(ctx, rawURL); preview != nil {
+			previews = append(previews, *preview)
+		}
+	}
+	if len(previews) == 0 {
+		return
+	}
+
+	updated, err := u.messageService.SetPreviews(ctx, j.messageID, previews)
+	if err != nil {
+		log.Printf("Failed to record previews of message %s: %v", j.messageID.Hex(), err)
+		return
+	}
+	if u.broadcaster != nil {
+		u.broadcaster.BroadcastToRoom(updated.RoomID, map[string]interface{}{
+			"type":    "message_updated",
+			"message": updated,
+		})
+	}
+}
+
+// Preview returns the preview of a URL from the cache, fetching and caching it on a miss. URLs that cannot be
+// fetched or carry no metadata yield nil.
+func (u *Unfurler) Preview(ctx context.Context, rawURL string) *message.Preview {
+	if preview, ok := u.cache.Get(rawURL, u.now()); ok {
+		return preview
+	}
+	preview, err := u.Fetch(ctx, rawURL)
+	if err != nil {
+		log.Printf("Failed to unfurl %s: %v", rawURL, err)
+		u.cache.Put(rawURL, nil, u.now().Add(u.cfg.FailureTTL))
+		return nil
+	}
+	ttl := u.cfg.CacheTTL
+	if preview == nil {
+		ttl = u.cfg.FailureTTL
+	}
+	u.cache.Put(rawURL, preview, u.now().Add(ttl))
+	return preview
+}
+
+// Fetch downloads at most cfg.MaxBytes of an HTML page and extracts its preview, which is nil when the page
+// carries no metadata. The preview's URL is the one requested, not the one redirected to.
+func (u *Unfurler) Fetch(ctx context.Context, rawURL string) (*message.Preview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %s", ErrBlockedAddress, req.URL.Scheme)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", u.cfg.UserAgent)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%w: %s", ErrNotHTML, mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, u.cfg.MaxBytes))
+	if err != nil {
+		return nil, err
+	}
+	preview := parseMeta(string(body), resp.Request.URL)
+	if preview != nil {
+		preview.URL = rawURL
+	}
+	return preview, nil
+}
+
+// ExtractURLs returns the distinct http and https URLs of a message body in order of appearance, at most limit of
+// them. Trailing punctuation is not considered part of a URL.
+func ExtractURLs(body string, limit int) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, match := range urlPattern.FindAllString(body, -1) {
+		if len(urls) >= limit {
+			break
+		}
+		match = strings.TrimRight(match, ".,:;!?")
+		parsed, err := url.Parse(match)
+		if err != nil || parsed.Host == "" || seen[match] {
+			continue
+		}
+		seen[match] = true
+		urls = append(urls, match)
+	}
+	return urls
+}
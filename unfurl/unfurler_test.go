@@ -0,0 +1,364 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"messages-go/message"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	return Config{
+		Workers:      1,
+		MaxLinks:     3,
+		Timeout:      time.Second,
+		MaxBytes:     64 << 10,
+		MaxRedirects: 2,
+		UserAgent:    "unfurl-test",
+		CacheSize:    8,
+		CacheTTL:     time.Hour,
+		FailureTTL:   time.Minute,
+	}
+}
+
+// newTestUnfurler returns an unfurler allowed to fetch from the loopback test servers.
+func newTestUnfurler() *Unfurler {
+	cfg := testConfig()
+	cfg.AllowPrivateNetworks = true
+	return NewUnfurler(nil, nil, cfg)
+}
+
+func serveHTML(doc string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, doc)
+	}
+}
+
+func TestParseMeta(t *testing.T) {
+	base, _ := url.Parse("https://example.com/articles/1")
+	tests := []struct {
+		name string
+		doc  string
+		want *message.Preview
+	}{
+		{
+			name: "open graph",
+			doc: `<head><meta property="og:title" content="OG title"><meta property="og:description" content="OG description">
+				<meta property="og:site_name" content="Example"><meta property="og:image" content="https://cdn.example.com/a.png"></head>`,
+			want: &message.Preview{Title: "OG title", Description: "OG description", SiteName: "Example", ImageURL: "https://cdn.example.com/a.png"},
+		},
+		{
+			name: "twitter card",
+			doc:  `<meta name="twitter:title" content="Card title"><meta name='twitter:description' content='Card description'><meta name=twitter:image content=/img.png>`,
+			want: &message.Preview{Title: "Card title", Description: "Card description", ImageURL: "https://example.com/img.png"},
+		},
+		{
+			name: "open graph over twitter card",
+			doc:  `<meta name="twitter:title" content="Card title"><meta property="og:title" content="OG title">`,
+			want: &message.Preview{Title: "OG title"},
+		},
+		{
+			name: "first of repeated properties",
+			doc:  `<meta property="og:title" content="First"><meta property="og:title" content="Second">`,
+			want: &message.Preview{Title: "First"},
+		},
+		{
+			name: "title and description fallback",
+			doc:  "<title>\n  Page   title &amp; more\n</title><meta name=\"description\" content=\"Plain description\">",
+			want: &message.Preview{Title: "Page title & more", Description: "Plain description"},
+		},
+		{
+			name: "attribute order and case",
+			doc:  `<META CONTENT="Shouted" PROPERTY="OG:TITLE">`,
+			want: &message.Preview{Title: "Shouted"},
+		},
+		{
+			name: "unsafe image dropped",
+			doc:  `<meta property="og:title" content="T"><meta property="og:image" content="javascript:alert(1)">`,
+			want: &message.Preview{Title: "T"},
+		},
+		{
+			name: "long title truncated",
+			doc:  `<meta property="og:title" content="` + strings.Repeat("é", 200) + `">`,
+			want: &message.Preview{Title: strings.Repeat("é", 150) + "…"},
+		},
+		{
+			name: "no metadata",
+			doc:  `<html><body><p>Nothing to see</p></body></html>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseMeta(tt.doc, base)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("parseMeta = %+v, want nil", got)
+				}
+				return
+			}
+			tt.want.URL = base.String()
+			if got == nil || *got != *tt.want {
+				t.Fatalf("parseMeta = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/og", serveHTML(`<meta property="og:title" content="Hello"><meta property="og:image" content="/a.png">`))
+	mux.HandleFunc("/empty", serveHTML(`<p>no metadata</p>`))
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"title": "not html"}`)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/og", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/user-agent", func(w http.ResponseWriter, r *http.Request) {
+		serveHTML(`<title>`+r.Header.Get("User-Agent")+`</title>`)(w, r)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	u := newTestUnfurler()
+	ctx := context.Background()
+
+	preview, err := u.Fetch(ctx, server.URL+"/og")
+	if err != nil || preview == nil || preview.Title != "Hello" || preview.ImageURL != server.URL+"/a.png" {
+		t.Errorf("Fetch(/og) = %+v, %v", preview, err)
+	}
+	preview, err = u.Fetch(ctx, server.URL+"/redirect")
+	if err != nil || preview == nil || preview.URL != server.URL+"/redirect" || preview.ImageURL != server.URL+"/a.png" {
+		t.Errorf("Fetch(/redirect) = %+v, %v; want the preview of /og under the requested URL", preview, err)
+	}
+	if preview, err := u.Fetch(ctx, server.URL+"/user-agent"); err != nil || preview == nil || preview.Title != "unfurl-test" {
+		t.Errorf("Fetch(/user-agent) = %+v, %v; want the configured user agent", preview, err)
+	}
+	if preview, err := u.Fetch(ctx, server.URL+"/empty"); err != nil || preview != nil {
+		t.Errorf("Fetch(/empty) = %+v, %v; want no preview", preview, err)
+	}
+	if _, err := u.Fetch(ctx, server.URL+"/json"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("Fetch(/json) = %v, want ErrNotHTML", err)
+	}
+	if _, err := u.Fetch(ctx, server.URL+"/missing"); err == nil {
+		t.Error("Fetch(/missing) succeeded")
+	}
+	if _, err := u.Fetch(ctx, server.URL+"/loop"); !errors.Is(err, ErrTooManyRedirects) {
+		t.Errorf("Fetch(/loop) = %v, want ErrTooManyRedirects", err)
+	}
+	if _, err := u.Fetch(ctx, "ftp://example.com/file"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch(ftp) = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/large", serveHTML(`<title>Early</title>`+strings.Repeat(" ", 2048)+`<meta property="og:title" content="Late">`))
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defer close(release)
+
+	cfg := testConfig()
+	cfg.AllowPrivateNetworks = true
+	cfg.MaxBytes = 1024
+	cfg.Timeout = 100 * time.Millisecond
+	u := NewUnfurler(nil, nil, cfg)
+	ctx := context.Background()
+
+	preview, err := u.Fetch(ctx, server.URL+"/large")
+	if err != nil || preview == nil || preview.Title != "Early" {
+		t.Errorf("Fetch(/large) = %+v, %v; want only the first MaxBytes read", preview, err)
+	}
+
+	start := time.Now()
+	if _, err := u.Fetch(ctx, server.URL+"/slow"); err == nil {
+		t.Error("Fetch(/slow) succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Fetch(/slow) took %v, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestPreviewCache(t *testing.T) {
+	var fetches atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		serveHTML(`<meta property="og:title" content="Cached">`)(w, r)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	u := newTestUnfurler()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	u.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for range 3 {
+		if preview := u.Preview(ctx, server.URL+"/page"); preview == nil || preview.Title != "Cached" {
+			t.Fatalf("Preview(/page) = %+v", preview)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched /page %d times, want 1", n)
+	}
+
+	for range 3 {
+		if preview := u.Preview(ctx, server.URL+"/broken"); preview != nil {
+			t.Fatalf("Preview(/broken) = %+v, want nil", preview)
+		}
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetched %d times after failures, want failures cached", n)
+	}
+
+	// Failures expire before previews do.
+	now = now.Add(2 * time.Minute)
+	u.Preview(ctx, server.URL+"/page")
+	u.Preview(ctx, server.URL+"/broken")
+	if n := fetches.Load(); n != 3 {
+		t.Errorf("fetched %d times after the failure TTL, want only /broken fetched again", n)
+	}
+	now = now.Add(time.Hour)
+	u.Preview(ctx, server.URL+"/page")
+	if n := fetches.Load(); n != 4 {
+		t.Errorf("fetched %d times after the cache TTL, want /page fetched again", n)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache(2)
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	c.Put("a", &message.Preview{Title: "a"}, expires)
+	c.Put("b", &message.Preview{Title: "b"}, expires)
+	c.Get("a", now)
+	c.Put("c", &message.Preview{Title: "c"}, expires)
+
+	if _, ok := c.Get("b", now); ok {
+		t.Error("b was kept, want the least recently used entry evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if preview, ok := c.Get(key, now); !ok || preview.Title != key {
+			t.Errorf("Get(%q) = %+v, %v", key, preview, ok)
+		}
+	}
+	if _, ok := c.Get("a", expires); ok {
+		t.Error("a was returned after it expired")
+	}
+}
+
+// redirectingTransport answers requests to its host with a redirect to target, without connecting anywhere, and
+// passes other requests to next. It stands in for a public server redirecting into the internal network.
+type redirectingTransport struct {
+	host   string
+	target string
+	next   http.RoundTripper
+}
+
+func (rt *redirectingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != rt.host {
+		return rt.next.RoundTrip(req)
+	}
+	return &http.Response{
+		StatusCode: http.StatusFound,
+		Header:     http.Header{"Location": []string{rt.target}},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func TestFetchRejectsInternalAddresses(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		serveHTML(`<meta property="og:title" content="Internal">`)(w, r)
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	u := NewUnfurler(nil, nil, testConfig())
+	u.client.Transport = &redirectingTransport{host: "public.example", target: server.URL + "/", next: u.client.Transport}
+	ctx := context.Background()
+
+	for _, rawURL := range []string{
+		server.URL + "/",
+		fmt.Sprintf("http://localhost:%d/", port),
+		fmt.Sprintf("http://[::1]:%d/", port),
+		fmt.Sprintf("http://0.0.0.0:%d/", port),
+		"http://public.example/",
+	} {
+		if _, err := u.Fetch(ctx, rawURL); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Fetch(%s) = %v, want ErrBlockedAddress", rawURL, err)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("internal server was reached %d times", n)
+	}
+}
+
+func TestIsBlocked(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"224.0.0.1":        true,
+		"255.255.255.255":  true,
+		"198.51.100.7":     true,
+		"::1":              true,
+		"::":               true,
+		"fc00::1":          true,
+		"fe80::1":          true,
+		"ff02::1":          true,
+		"::ffff:127.0.0.1": true,
+		"::ffff:10.0.0.1":  true,
+		"64:ff9b::a00:1":   true,
+		"2001:db8::1":      true,
+		"8.8.8.8":          false,
+		"93.184.216.34":    false,
+		"2606:4700::1111":  false,
+		"::ffff:8.8.8.8":   false,
+	} {
+		if got := isBlocked(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isBlocked(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestExtractURLs(t *testing.T) {
+	body := "See https://example.com/a, (http://example.org/b) and https://example.com/a again. " +
+		"Also HTTPS://EXAMPLE.NET/c! ftp://example.com/d https://example.com/e"
+	got := ExtractURLs(body, 3)
+	want := []string{"https://example.com/a", "http://example.org/b", "HTTPS://EXAMPLE.NET/c"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("ExtractURLs = %q, want %q", got, want)
+	}
+}
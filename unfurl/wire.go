@@ -0,0 +1,36 @@
+package unfurl
+
+import (
+	"context"
+	"messages-go/message"
+	"messages-go/utils"
+	ws "messages-go/websocket"
+	"time"
+)
+
+// InitUnfurler wires an Unfurler configured from the environment, registers it with the message service, and
+// starts its workers, which run until ctx is cancelled.
+func InitUnfurler(ctx context.Context, messageService message.MessageService, wsHandler *ws.Handler) *Unfurler {
+	cfg := Config{
+		Workers:      utils.GetEnvInt("UNFURL_WORKERS", 2),
+		MaxLinks:     utils.GetEnvInt("UNFURL_MAX_LINKS", 3),
+		Timeout:      utils.GetEnvDuration("UNFURL_TIMEOUT", 5*time.Second),
+		MaxBytes:     int64(utils.GetEnvInt("UNFURL_MAX_BYTES", 512<<10)),
+		MaxRedirects: utils.GetEnvInt("UNFURL_MAX_REDIRECTS", 3),
+		UserAgent:    utils.GetEnv("UNFURL_USER_AGENT", "messages-go-unfurler/1.0"),
+		CacheSize:    utils.GetEnvInt("UNFURL_CACHE_SIZE", 1024),
+		CacheTTL:     utils.GetEnvDuration("UNFURL_CACHE_TTL", time.Hour),
+		FailureTTL:   utils.GetEnvDuration("UNFURL_FAILURE_TTL", 10*time.Minute),
+
+		AllowPrivateNetworks: utils.GetEnv("UNFURL_ALLOW_PRIVATE_NETWORKS", "false") == "true",
+	}
+
+	var broadcaster Broadcaster
+	if wsHandler != nil {
+		broadcaster = wsHandler
+	}
+	unfurler := NewUnfurler(messageService, broadcaster, cfg)
+	messageService.AddPostObserver(unfurler)
+	unfurler.Start(ctx)
+	return unfurler
+}
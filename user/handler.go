@@ -0,0 +1,148 @@
+package user
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/models/response"
+)
+
+// UserHandler defines the interface for handling HTTP requests related to users.
+type UserHandler interface {
+	CreateUser(c *fiber.Ctx) error
+	GetUser(c *fiber.Ctx) error
+	Login(c *fiber.Ctx) error
+}
+
+// UserHandlerImpl implements the UserHandler interface.
+type UserHandlerImpl struct {
+	userService UserService
+	// secureCookie restricts the session cookie to HTTPS
+	secureCookie bool
+}
+
+// NewUserHandler initializes and returns a new UserHandler with the provided UserService implementation.
+func NewUserHandler(userService UserService, secureCookie bool) UserHandler {
+	return &UserHandlerImpl{userService: userService, secureCookie: secureCookie}
+}
+
+// CreateUser handles registering a new user.
+func (uh *UserHandlerImpl) CreateUser(c *fiber.Ctx) error {
+	var req request.CreateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	log.Println("Create User Request Received.")
+
+	u, err := uh.userService.CreateUser(c.Context(), req)
+	if errors.Is(err, errormodel.ErrInvalidUsername) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "username must be 2-32 letters, digits, '_', '.' or '-' and not reserved.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidPassword) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "password must be 8 to 128 characters.",
+		})
+	} else if errors.Is(err, errormodel.ErrUsernameTaken) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "Username Already Taken.",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Create User",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "User Created",
+		Data:    u,
+	})
+}
+
+// GetUser handles looking up a user by username.
+func (uh *UserHandlerImpl) GetUser(c *fiber.Ctx) error {
+	username := c.Params("username")
+
+	u, err := uh.userService.GetUser(c.Context(), username)
+	if errors.Is(err, errormodel.ErrUserNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No User Found with given username.",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Get User",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "User Found",
+		Data:    u,
+	})
+}
+
+// Login handles a user logging in. The session token is returned in the response for clients that send it as an
+// Authorization header, and set as an HTTP-only cookie for browsers.
+func (uh *UserHandlerImpl) Login(c *fiber.Ctx) error {
+	var req request.LoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	log.Println("Login Request Received.")
+
+	session, err := uh.userService.Login(c.Context(), req)
+	if errors.Is(err, errormodel.ErrInvalidCredentials) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Invalid Username Or Password.",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed To Log In",
+		})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   uh.secureCookie,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Logged In",
+		Data:    session,
+	})
+}
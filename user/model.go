@@ -0,0 +1,32 @@
+package user
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+	"time"
+)
+
+// User represents a registered user. The hex form of ID is the user ID session tokens are issued to.
+type User struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Username    string             `bson:"username" json:"username"`
+	DisplayName string             `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	// PasswordHash is the hash of the password the user logs in with. Bot accounts have none and cannot log in.
+	PasswordHash string `bson:"password_hash,omitempty" json:"-"`
+	// Bot marks the accounts of bots, which post their replies to commands
+	Bot       bool      `bson:"bot,omitempty" json:"bot,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// UsernamePattern matches valid usernames. Usernames are stored lower-cased.
+var UsernamePattern = regexp.MustCompile(`^[a-z0-9_][a-z0-9_.-]{1,31}$`)
+
+// Session is a session token issued to a user on login.
+type Session struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      *User     `json:"user"`
+}
+
+// ReservedUsernames cannot be registered because they have a special meaning in mentions.
+var ReservedUsernames = map[string]bool{"room": true, "here": true}
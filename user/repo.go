@@ -0,0 +1,108 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"messages-go/models/errormodel"
+	"os"
+	"time"
+)
+
+// UserRepo defines an interface for user persistence operations.
+type UserRepo interface {
+	CreateUser(ctx context.Context, u *User) (*User, error)
+	GetUserByID(ctx context.Context, id string) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUsersByUsernames(ctx context.Context, usernames []string) ([]User, error)
+}
+
+// UserRepoImpl is a concrete implementation of the UserRepo interface backed by MongoDB.
+type UserRepoImpl struct {
+	userCollection *mongo.Collection
+}
+
+// NewUserRepository initializes and returns a new instance of UserRepo, ensuring usernames are unique.
+func NewUserRepository(client *mongo.Client) UserRepo {
+	r := &UserRepoImpl{
+		userCollection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("users"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.userCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create username index: %v", err)
+	}
+	return r
+}
+
+// CreateUser inserts a new user and returns it with its generated ID, or ErrUsernameTaken on a duplicate username.
+func (r *UserRepoImpl) CreateUser(ctx context.Context, u *User) (*User, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.userCollection.InsertOne(timeoutCtx, u)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, errormodel.ErrUsernameTaken
+	} else if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		u.ID = oid
+	}
+	return u, nil
+}
+
+// GetUserByID retrieves a user by ID, returning ErrUserNotFound when there is none.
+func (r *UserRepoImpl) GetUserByID(ctx context.Context, id string) (*User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrUserNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": objID})
+}
+
+// GetUserByUsername retrieves a user by username, returning ErrUserNotFound when there is none.
+func (r *UserRepoImpl) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return r.findOne(ctx, bson.M{"username": username})
+}
+
+// GetUsersByUsernames retrieves the users with the given usernames. Unknown usernames are skipped.
+func (r *UserRepoImpl) GetUsersByUsernames(ctx context.Context, usernames []string) ([]User, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.userCollection.Find(timeoutCtx, bson.M{"username": bson.M{"$in": usernames}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	var users []User
+	if err := cursor.All(timeoutCtx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *UserRepoImpl) findOne(ctx context.Context, filter bson.M) (*User, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var u User
+	err := r.userCollection.FindOne(timeoutCtx, filter).Decode(&u)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -0,0 +1,126 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"log"
+	"messages-go/audit"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"strings"
+	"time"
+)
+
+// Limits on the passwords users register with.
+const (
+	minPasswordSize = 8
+	maxPasswordSize = 128
+)
+
+// UserService defines the interface for registering and looking up users and logging them in.
+type UserService interface {
+	CreateUser(ctx context.Context, req request.CreateUserRequest) (*User, error)
+	GetUser(ctx context.Context, username string) (*User, error)
+	Login(ctx context.Context, req request.LoginRequest) (*Session, error)
+}
+
+// UserServiceImpl handles business logic related to users using a user repository.
+type UserServiceImpl struct {
+	userRepo UserRepo
+	// signer issues the session tokens of users logging in
+	signer *auth.Signer
+	// auditor records registrations and logins, failed ones included, in the audit log
+	auditor audit.Recorder
+	// dummyHash is checked against the password of unknown usernames, so that they take as long to reject as
+	// wrong passwords
+	dummyHash string
+}
+
+// NewUserService initializes and returns a new instance of UserServiceImpl with the provided user repository.
+func NewUserService(userRepo UserRepo, signer *auth.Signer, auditor audit.Recorder) *UserServiceImpl {
+	dummyHash, err := auth.HashPassword("not a password")
+	if err != nil {
+		log.Printf("Failed to hash the dummy password: %v", err)
+	}
+	return &UserServiceImpl{userRepo: userRepo, signer: signer, auditor: auditor, dummyHash: dummyHash}
+}
+
+// CreateUser validates the requested username and password and registers the user.
+func (us *UserServiceImpl) CreateUser(ctx context.Context, req request.CreateUserRequest) (*User, error) {
+	if req.Username == nil {
+		return nil, errormodel.ErrInvalidUsername
+	}
+	username := strings.ToLower(strings.TrimSpace(*req.Username))
+	if !UsernamePattern.MatchString(username) || ReservedUsernames[username] {
+		return nil, errormodel.ErrInvalidUsername
+	}
+	if req.Password == nil || len(*req.Password) < minPasswordSize || len(*req.Password) > maxPasswordSize {
+		return nil, errormodel.ErrInvalidPassword
+	}
+	passwordHash, err := auth.HashPassword(*req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	u := User{Username: username, PasswordHash: passwordHash, CreatedAt: time.Now().UTC()}
+	if req.DisplayName != nil {
+		u.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	created, err := us.userRepo.CreateUser(ctx, &u)
+	if err != nil {
+		return nil, err
+	}
+	us.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionUserCreated,
+		ActorID:    created.ID.Hex(),
+		TargetType: audit.TargetUser,
+		TargetID:   created.ID.Hex(),
+		After:      map[string]interface{}{"username": created.Username, "display_name": created.DisplayName},
+	})
+	return created, nil
+}
+
+// GetUser retrieves a user by username.
+func (us *UserServiceImpl) GetUser(ctx context.Context, username string) (*User, error) {
+	return us.userRepo.GetUserByUsername(ctx, strings.ToLower(username))
+}
+
+// Login checks a user's password and issues them a session token. Unknown usernames and wrong passwords are
+// both reported as ErrInvalidCredentials.
+func (us *UserServiceImpl) Login(ctx context.Context, req request.LoginRequest) (*Session, error) {
+	username := strings.ToLower(strings.TrimSpace(req.Username))
+	u, err := us.userRepo.GetUserByUsername(ctx, username)
+	if errors.Is(err, errormodel.ErrUserNotFound) {
+		auth.CheckPassword(us.dummyHash, req.Password)
+		us.recordLogin(ctx, audit.ActionUserLoginFailed, "", username)
+		return nil, errormodel.ErrInvalidCredentials
+	} else if err != nil {
+		return nil, err
+	}
+	if len(req.Password) > maxPasswordSize || !auth.CheckPassword(u.PasswordHash, req.Password) {
+		us.recordLogin(ctx, audit.ActionUserLoginFailed, u.ID.Hex(), username)
+		return nil, errormodel.ErrInvalidCredentials
+	}
+	token, expiresAt, err := us.signer.Issue(u.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	us.recordLogin(ctx, audit.ActionUserLoggedIn, u.ID.Hex(), username)
+	return &Session{Token: token, ExpiresAt: expiresAt, User: u}, nil
+}
+
+// recordLogin records a login attempt in the audit log. Successful logins are their user's own action; failed
+// ones have no actor, and name the user only when the username exists.
+func (us *UserServiceImpl) recordLogin(ctx context.Context, action string, userId string, username string) {
+	entry := audit.Entry{
+		Action:     action,
+		TargetType: audit.TargetUser,
+		TargetID:   userId,
+		After:      map[string]interface{}{"username": username},
+	}
+	if action == audit.ActionUserLoggedIn {
+		entry.ActorID = userId
+	}
+	us.auditor.Record(ctx, entry)
+}
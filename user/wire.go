@@ -0,0 +1,17 @@
+package user
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	"messages-go/auth"
+	"messages-go/utils"
+)
+
+// InitUserHandler wires the user handler, logging users in with session tokens issued by signer. Session
+// cookies are restricted to HTTPS unless AUTH_COOKIE_SECURE is false.
+func InitUserHandler(client *mongo.Client, signer *auth.Signer, auditor audit.Recorder) (UserHandler, UserRepo, UserService) {
+	repo := NewUserRepository(client)
+	service := NewUserService(repo, signer, auditor)
+	handler := NewUserHandler(service, utils.GetEnv("AUTH_COOKIE_SECURE", "true") != "false")
+	return handler, repo, service
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnv returns the value of the environment variable key, or def when it is unset or empty.
+func GetEnv(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// GetEnvInt returns the integer value of the environment variable key, or def when it is unset or malformed.
+func GetEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Ignoring malformed %s=%q: %v", key, value, err)
+		return def
+	}
+	return parsed
+}
+
+// GetEnvDuration returns the duration value of the environment variable key, or def when it is unset or malformed.
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Ignoring malformed %s=%q: %v", key, value, err)
+		return def
+	}
+	return parsed
+}
+
+// GetEnvList returns the comma-separated values of the environment variable key with blanks dropped, or def when
+// it is unset or empty.
+func GetEnvList(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,298 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"log"
+	"messages-go/unfurl"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// minWait keeps the delivery loop from spinning when deliveries come due in quick succession.
+const minWait = 100 * time.Millisecond
+
+// Config holds the limits applied to delivering events to webhooks.
+type Config struct {
+	Workers int
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts after which a delivery is moved to the dead-letter list.
+	MaxAttempts int
+	// BaseBackoff is the wait before the second attempt, doubling with every further attempt up to MaxBackoff.
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	// ClaimTimeout is how long an attempt may take before another worker takes the delivery over.
+	ClaimTimeout time.Duration
+	// Retention is how long delivered and dead deliveries are kept in the delivery log.
+	Retention time.Duration
+	UserAgent string
+	// AllowPrivateNetworks disables the SSRF protection, for development against local servers only.
+	AllowPrivateNetworks bool
+}
+
+// Dispatcher observes the events broadcast to rooms and delivers those that webhooks subscribed to. Events are
+// queued in memory and turned into stored deliveries in the background, so broadcasting never waits on the
+// database; stored deliveries are then posted by workers, retried with exponential backoff, and survive
+// restarts.
+type Dispatcher struct {
+	webhookRepo WebhookRepo
+	client      *http.Client
+	cfg         Config
+	events      chan roomEvent
+	// wake interrupts the delivery workers' wait when new deliveries are queued
+	wake chan struct{}
+	now  func() time.Time
+}
+
+type roomEvent struct {
+	roomID    string
+	eventType string
+	data      json.RawMessage
+	at        time.Time
+}
+
+// envelope is the body posted to webhooks.
+type envelope struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	RoomID    string          `json:"room_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NewDispatcher initializes and returns a new Dispatcher. Workers are started by Start.
+func NewDispatcher(webhookRepo WebhookRepo, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		webhookRepo: webhookRepo,
+		// Receivers answer the delivery itself; following redirects would let them point it elsewhere.
+		client: unfurl.NewClient(unfurl.Config{Timeout: cfg.Timeout, AllowPrivateNetworks: cfg.AllowPrivateNetworks}),
+		cfg:    cfg,
+		events: make(chan roomEvent, 1024),
+		wake:   make(chan struct{}, 1),
+		now:    time.Now,
+	}
+}
+
+// Sign returns the signature of a delivery sent in the X-Webhook-Signature header: the hex-encoded HMAC-SHA256 of
+// the timestamp, a dot and the body, keyed with the webhook's secret. Receivers recompute it to authenticate the
+// delivery and reject stale timestamps to prevent replays.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RoomBroadcast queues an event broadcast to a room when it is one webhooks can subscribe to. Events are dropped
+// when the queue is full.
+func (d *Dispatcher) RoomBroadcast(roomID string, message interface{}) {
+	event, ok := message.(map[string]interface{})
+	if !ok {
+		return
+	}
+	eventType, _ := event["type"].(string)
+	if !subscribable(eventType) {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode %s event of room %s for webhooks: %v", eventType, roomID, err)
+		return
+	}
+	select {
+	case d.events <- roomEvent{roomID: roomID, eventType: eventType, data: data, at: d.now().UTC()}:
+	default:
+		log.Printf("Webhook queue full, dropping %s event of room %s", eventType, roomID)
+	}
+}
+
+// Start starts queueing deliveries of observed events and the workers delivering them, until ctx is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-d.events:
+				d.enqueue(ctx, event)
+			}
+		}
+	}()
+	for range max(1, d.cfg.Workers) {
+		go func() {
+			for {
+				wait := d.cfg.PollInterval
+				d.DeliverDue(ctx)
+				if next, err := d.webhookRepo.NextAttemptAt(ctx); err == nil {
+					wait = min(wait, max(next.Sub(d.now()), minWait))
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-d.wake:
+				case <-time.After(wait):
+				}
+			}
+		}()
+	}
+}
+
+// enqueue stores a delivery of an event for every webhook of its room subscribed to it.
+func (d *Dispatcher) enqueue(ctx context.Context, event roomEvent) {
+	hooks, err := d.webhookRepo.GetSubscribedWebhooks(ctx, event.roomID, event.eventType)
+	if err != nil {
+		log.Printf("Failed to look up webhooks of room %s: %v", event.roomID, err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	deliveries := make([]Delivery, 0, len(hooks))
+	for _, hook := range hooks {
+		id := primitive.NewObjectID()
+		payload, err := json.Marshal(envelope{
+			ID:        id.Hex(),
+			Type:      event.eventType,
+			RoomID:    event.roomID,
+			CreatedAt: event.at,
+			Data:      event.data,
+		})
+		if err != nil {
+			log.Printf("Failed to encode %s delivery of room %s: %v", event.eventType, event.roomID, err)
+			return
+		}
+		deliveries = append(deliveries, Delivery{
+			ID:            id,
+			WebhookID:     hook.ID,
+			RoomID:        event.roomID,
+			Event:         event.eventType,
+			Payload:       string(payload),
+			Status:        StatusPending,
+			NextAttemptAt: event.at,
+			CreatedAt:     event.at,
+		})
+	}
+	if err := d.webhookRepo.CreateDeliveries(ctx, deliveries); err != nil {
+		log.Printf("Failed to queue %s deliveries of room %s: %v", event.eventType, event.roomID, err)
+		return
+	}
+	d.Wake()
+}
+
+// Wake makes an idle worker look for due deliveries without waiting for the poll interval.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// DeliverDue attempts the deliveries that are due until none is left or ctx is cancelled.
+func (d *Dispatcher) DeliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := d.now()
+		delivery, err := d.webhookRepo.ClaimDue(ctx, now, now.Add(d.cfg.ClaimTimeout))
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return
+		} else if err != nil {
+			log.Printf("Failed to claim webhook deliveries: %v", err)
+			return
+		}
+		d.attempt(ctx, delivery)
+	}
+}
+
+// attempt posts a claimed delivery to its webhook and records the outcome. Failed deliveries are retried after
+// an exponentially growing wait until they run out of attempts, when they are moved to the dead-letter list.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *Delivery) {
+	hook, err := d.webhookRepo.GetWebhook(ctx, delivery.WebhookID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		delivery.Attempts = d.cfg.MaxAttempts
+		d.fail(ctx, delivery, 0, "webhook removed")
+		return
+	} else if err != nil {
+		log.Printf("Failed to look up webhook %s: %v", delivery.WebhookID.Hex(), err)
+		return
+	}
+
+	statusCode, err := d.post(ctx, hook, delivery)
+	if err != nil {
+		d.fail(ctx, delivery, statusCode, err.Error())
+		return
+	}
+	now := d.now().UTC()
+	if err := d.webhookRepo.SetDelivered(ctx, delivery.ID, now, now.Add(d.cfg.Retention)); err != nil {
+		log.Printf("Failed to record webhook delivery %s: %v", delivery.ID.Hex(), err)
+	}
+}
+
+// post sends a delivery to a webhook, returning the status code of the response and an error unless the
+// receiver accepted it with a 2xx status.
+func (d *Dispatcher) post(ctx context.Context, hook *Webhook, delivery *Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", d.cfg.UserAgent)
+	req.Header.Set("X-Webhook-ID", hook.ID.Hex())
+	req.Header.Set("X-Webhook-Delivery", delivery.ID.Hex())
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Draining a little of the body lets the connection be reused; receivers are not expected to say much.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// fail records a failed attempt, scheduling the next one or moving the delivery to the dead-letter list.
+func (d *Dispatcher) fail(ctx context.Context, delivery *Delivery, statusCode int, reason string) {
+	now := d.now().UTC()
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = reason
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		expiresAt := now.Add(d.cfg.Retention)
+		delivery.Status = StatusDead
+		delivery.NextAttemptAt = now
+		delivery.ExpiresAt = &expiresAt
+	} else {
+		delivery.Status = StatusPending
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+	if err := d.webhookRepo.SetFailed(ctx, delivery); err != nil {
+		log.Printf("Failed to record webhook delivery %s: %v", delivery.ID.Hex(), err)
+	}
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.BaseBackoff
+	for i := 1; i < attempts && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.cfg.MaxBackoff)
+}
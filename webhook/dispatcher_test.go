@@ -0,0 +1,534 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"messages-go/models/errormodel"
+	"messages-go/room"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef-secret"
+
+// memoryRepo is a WebhookRepo keeping webhooks and deliveries in memory with the semantics of WebhookRepoImpl.
+type memoryRepo struct {
+	mu         sync.Mutex
+	webhooks   map[primitive.ObjectID]*Webhook
+	deliveries map[primitive.ObjectID]*Delivery
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{
+		webhooks:   make(map[primitive.ObjectID]*Webhook),
+		deliveries: make(map[primitive.ObjectID]*Delivery),
+	}
+}
+
+func (r *memoryRepo) CreateWebhook(_ context.Context, hook *Webhook) (*Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hook.ID = primitive.NewObjectID()
+	stored := *hook
+	r.webhooks[hook.ID] = &stored
+	return hook, nil
+}
+
+func (r *memoryRepo) GetWebhook(_ context.Context, id primitive.ObjectID) (*Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hook, ok := r.webhooks[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	found := *hook
+	return &found, nil
+}
+
+func (r *memoryRepo) GetWebhooksByRoom(_ context.Context, roomID string) ([]Webhook, error) {
+	return r.findWebhooks(func(hook *Webhook) bool { return hook.RoomID == roomID }), nil
+}
+
+func (r *memoryRepo) GetSubscribedWebhooks(_ context.Context, roomID string, event string) ([]Webhook, error) {
+	return r.findWebhooks(func(hook *Webhook) bool {
+		return hook.RoomID == roomID && slices.Contains(hook.Events, event)
+	}), nil
+}
+
+func (r *memoryRepo) findWebhooks(match func(*Webhook) bool) []Webhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hooks := []Webhook{}
+	for _, hook := range r.webhooks {
+		if match(hook) {
+			hooks = append(hooks, *hook)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID.Hex() < hooks[j].ID.Hex() })
+	return hooks
+}
+
+func (r *memoryRepo) CountWebhooksByRoom(ctx context.Context, roomID string) (int64, error) {
+	hooks, _ := r.GetWebhooksByRoom(ctx, roomID)
+	return int64(len(hooks)), nil
+}
+
+func (r *memoryRepo) DeleteWebhook(_ context.Context, roomID string, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if hook, ok := r.webhooks[id]; !ok || hook.RoomID != roomID {
+		return mongo.ErrNoDocuments
+	}
+	delete(r.webhooks, id)
+	return nil
+}
+
+func (r *memoryRepo) CreateDeliveries(_ context.Context, deliveries []Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range deliveries {
+		stored := d
+		r.deliveries[d.ID] = &stored
+	}
+	return nil
+}
+
+func (r *memoryRepo) ClaimDue(_ context.Context, now time.Time, leaseUntil time.Time) (*Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []*Delivery
+	for _, d := range r.deliveries {
+		if d.Status == StatusPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	if len(due) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	d := due[0]
+	d.NextAttemptAt = leaseUntil
+	d.Attempts++
+	claimed := *d
+	return &claimed, nil
+}
+
+func (r *memoryRepo) NextAttemptAt(_ context.Context) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var next time.Time
+	for _, d := range r.deliveries {
+		if d.Status == StatusPending && (next.IsZero() || d.NextAttemptAt.Before(next)) {
+			next = d.NextAttemptAt
+		}
+	}
+	if next.IsZero() {
+		return time.Time{}, mongo.ErrNoDocuments
+	}
+	return next, nil
+}
+
+func (r *memoryRepo) SetDelivered(_ context.Context, id primitive.ObjectID, at time.Time, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.deliveries[id]
+	d.Status, d.DeliveredAt, d.ExpiresAt = StatusDelivered, &at, &expiresAt
+	d.LastStatusCode, d.LastError = 0, ""
+	return nil
+}
+
+func (r *memoryRepo) SetFailed(_ context.Context, failed *Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.deliveries[failed.ID]
+	d.Status, d.NextAttemptAt = failed.Status, failed.NextAttemptAt
+	d.LastStatusCode, d.LastError = failed.LastStatusCode, failed.LastError
+	if failed.ExpiresAt != nil {
+		d.ExpiresAt = failed.ExpiresAt
+	}
+	return nil
+}
+
+func (r *memoryRepo) GetDeliveries(_ context.Context, filter DeliveryFilter, beforeID primitive.ObjectID, limit int64) ([]Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deliveries := []Delivery{}
+	for _, d := range r.deliveries {
+		if d.RoomID == filter.RoomID && (filter.WebhookID.IsZero() || d.WebhookID == filter.WebhookID) &&
+			(filter.Status == "" || d.Status == filter.Status) && (beforeID.IsZero() || d.ID.Hex() < beforeID.Hex()) {
+			deliveries = append(deliveries, *d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID.Hex() > deliveries[j].ID.Hex() })
+	return deliveries[:min(len(deliveries), int(limit))], nil
+}
+
+func (r *memoryRepo) Redeliver(_ context.Context, roomID string, id primitive.ObjectID, now time.Time) (*Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.deliveries[id]
+	if !ok || d.RoomID != roomID || d.Status != StatusDead {
+		return nil, mongo.ErrNoDocuments
+	}
+	d.Status, d.Attempts, d.NextAttemptAt, d.ExpiresAt = StatusPending, 0, now, nil
+	updated := *d
+	return &updated, nil
+}
+
+func (r *memoryRepo) get(id primitive.ObjectID) Delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.deliveries[id]
+}
+
+// only returns the single delivery queued in the repo.
+func (r *memoryRepo) only(t *testing.T) Delivery {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.deliveries) != 1 {
+		t.Fatalf("%d deliveries queued, want 1", len(r.deliveries))
+	}
+	for _, d := range r.deliveries {
+		return *d
+	}
+	return Delivery{}
+}
+
+// fakeRooms is a RoomRepo serving the rooms it was given.
+type fakeRooms struct {
+	room.RoomRepo
+	rooms map[string]*room.Room
+}
+
+func (f *fakeRooms) GetRoomByID(_ context.Context, id string) (*room.Room, error) {
+	r, ok := f.rooms[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return r, nil
+}
+
+// receivedDelivery is a request received by a receiver, with whether its signature checked out.
+type receivedDelivery struct {
+	header   http.Header
+	body     []byte
+	verified bool
+}
+
+// receiver is a webhook receiver answering with the status codes it is given, then 200, and verifying the
+// signature of every delivery as a receiver holding the secret would.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	received []receivedDelivery
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(r.Header.Get("X-Webhook-Timestamp") + "." + string(body)))
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	verified := hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Webhook-Signature")))
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received = append(rc.received, receivedDelivery{header: r.Header.Clone(), body: body, verified: verified})
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.received)
+}
+
+func (rc *receiver) last() receivedDelivery {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.received[len(rc.received)-1]
+}
+
+type testDispatcher struct {
+	*Dispatcher
+	repo     *memoryRepo
+	receiver *receiver
+	hook     *Webhook
+	clock    time.Time
+}
+
+// newTestDispatcher returns a dispatcher delivering to a receiver answering with the given status codes, then 200,
+// and the webhook of room "r1" it is registered under.
+func newTestDispatcher(t *testing.T, statuses ...int) *testDispatcher {
+	rc := &receiver{statuses: statuses}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	repo := newMemoryRepo()
+	td := &testDispatcher{
+		repo:     repo,
+		receiver: rc,
+		clock:    time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	td.Dispatcher = NewDispatcher(repo, Config{
+		Workers:              1,
+		Timeout:              time.Second,
+		MaxAttempts:          4,
+		BaseBackoff:          10 * time.Second,
+		MaxBackoff:           25 * time.Second,
+		PollInterval:         time.Minute,
+		ClaimTimeout:         time.Minute,
+		Retention:            24 * time.Hour,
+		UserAgent:            "webhook-test",
+		AllowPrivateNetworks: true,
+	})
+	td.now = func() time.Time { return td.clock }
+	td.hook, _ = repo.CreateWebhook(context.Background(), &Webhook{
+		RoomID: "r1",
+		URL:    server.URL + "/hook",
+		Secret: testSecret,
+		Events: []string{"new_message", "member_joined"},
+	})
+	return td
+}
+
+// broadcast queues the deliveries of an event broadcast to a room as the dispatcher's background loop would.
+func (td *testDispatcher) broadcast(roomID string, event map[string]interface{}) {
+	td.RoomBroadcast(roomID, event)
+	select {
+	case e := <-td.events:
+		td.enqueue(context.Background(), e)
+	default:
+	}
+}
+
+func (td *testDispatcher) advance(d time.Duration) {
+	td.clock = td.clock.Add(d)
+}
+
+func TestDeliveriesAreSigned(t *testing.T) {
+	td := newTestDispatcher(t)
+	td.broadcast("r1", map[string]interface{}{"type": "new_message", "data": map[string]interface{}{"body": "hello"}})
+	queued := td.repo.only(t)
+	td.DeliverDue(context.Background())
+
+	if n := td.receiver.count(); n != 1 {
+		t.Fatalf("receiver got %d deliveries, want 1", n)
+	}
+	got := td.receiver.last()
+	if !got.verified {
+		t.Error("signature did not verify with the webhook's secret")
+	}
+	if got.header.Get("X-Webhook-Timestamp") != strconv.FormatInt(td.clock.Unix(), 10) {
+		t.Errorf("timestamp = %q, want the time of the attempt", got.header.Get("X-Webhook-Timestamp"))
+	}
+	for name, want := range map[string]string{
+		"X-Webhook-ID":       td.hook.ID.Hex(),
+		"X-Webhook-Delivery": queued.ID.Hex(),
+		"X-Webhook-Event":    "new_message",
+		"Content-Type":       "application/json",
+		"User-Agent":         "webhook-test",
+	} {
+		if got.header.Get(name) != want {
+			t.Errorf("%s = %q, want %q", name, got.header.Get(name), want)
+		}
+	}
+	var env envelope
+	if err := json.Unmarshal(got.body, &env); err != nil || env.ID != queued.ID.Hex() || env.Type != "new_message" || env.RoomID != "r1" {
+		t.Errorf("body = %s, %v", got.body, err)
+	}
+
+	timestamp := got.header.Get("X-Webhook-Timestamp")
+	if Sign(testSecret, timestamp, got.body) != got.header.Get("X-Webhook-Signature") {
+		t.Error("Sign does not reproduce the signature sent")
+	}
+	if Sign("another-secret-entirely", timestamp, got.body) == got.header.Get("X-Webhook-Signature") {
+		t.Error("signature verified with the wrong secret")
+	}
+	tampered := append([]byte{}, got.body...)
+	tampered[len(tampered)-2] ^= 1
+	if Sign(testSecret, timestamp, tampered) == got.header.Get("X-Webhook-Signature") {
+		t.Error("signature verified a tampered body")
+	}
+	if Sign(testSecret, strconv.FormatInt(td.clock.Unix()+1, 10), got.body) == got.header.Get("X-Webhook-Signature") {
+		t.Error("signature verified another timestamp")
+	}
+
+	if d := td.repo.get(queued.ID); d.Status != StatusDelivered || d.Attempts != 1 || d.ExpiresAt == nil {
+		t.Errorf("delivery = %+v, want delivered after one attempt", d)
+	}
+}
+
+func TestOnlySubscribedEventsAreDelivered(t *testing.T) {
+	td := newTestDispatcher(t)
+	td.broadcast("r1", map[string]interface{}{"type": "room_updated"})
+	td.broadcast("r1", map[string]interface{}{"type": "typing"})
+	td.broadcast("r2", map[string]interface{}{"type": "new_message"})
+	td.DeliverDue(context.Background())
+	if n := td.receiver.count(); n != 0 {
+		t.Errorf("receiver got %d deliveries, want none", n)
+	}
+}
+
+func TestFailedDeliveriesBackOff(t *testing.T) {
+	td := newTestDispatcher(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusTooManyRequests)
+	td.broadcast("r1", map[string]interface{}{"type": "member_joined"})
+	id := td.repo.only(t).ID
+	ctx := context.Background()
+
+	for i, wait := range []time.Duration{10 * time.Second, 20 * time.Second, 25 * time.Second} {
+		td.DeliverDue(ctx)
+		if n := td.receiver.count(); n != i+1 {
+			t.Fatalf("after attempt %d the receiver got %d deliveries", i+1, n)
+		}
+		d := td.repo.get(id)
+		if d.Status != StatusPending || !d.NextAttemptAt.Equal(td.clock.Add(wait)) || d.LastStatusCode == 0 || d.LastError == "" {
+			t.Fatalf("after attempt %d delivery = %+v, want a retry in %v", i+1, d, wait)
+		}
+
+		// Nothing is attempted before the backoff has passed.
+		td.advance(wait - time.Second)
+		td.DeliverDue(ctx)
+		if n := td.receiver.count(); n != i+1 {
+			t.Fatalf("attempt %d was retried %v early", i+1, time.Second)
+		}
+		td.advance(time.Second)
+	}
+
+	td.DeliverDue(ctx)
+	if d := td.repo.get(id); d.Status != StatusDelivered || d.Attempts != 4 || d.LastError != "" {
+		t.Errorf("delivery = %+v, want delivered on the fourth attempt", d)
+	}
+	if !td.receiver.last().verified {
+		t.Error("retried delivery was not signed")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, Config{BaseBackoff: time.Second, MaxBackoff: time.Minute})
+	for attempts, want := range map[int]time.Duration{
+		1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 6: 32 * time.Second, 7: time.Minute, 100: time.Minute,
+	} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestDeliveriesAreDeadLetteredAfterMaxAttempts(t *testing.T) {
+	td := newTestDispatcher(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable,
+		http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	td.broadcast("r1", map[string]interface{}{"type": "new_message"})
+	id := td.repo.only(t).ID
+	ctx := context.Background()
+
+	for range 10 {
+		td.DeliverDue(ctx)
+		td.advance(time.Minute)
+	}
+	if n := td.receiver.count(); n != td.cfg.MaxAttempts {
+		t.Errorf("receiver got %d deliveries, want %d", n, td.cfg.MaxAttempts)
+	}
+	d := td.repo.get(id)
+	if d.Status != StatusDead || d.Attempts != td.cfg.MaxAttempts || d.LastStatusCode != http.StatusServiceUnavailable || d.ExpiresAt == nil {
+		t.Errorf("delivery = %+v, want dead after %d attempts", d, td.cfg.MaxAttempts)
+	}
+	if _, err := td.repo.NextAttemptAt(ctx); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("NextAttemptAt = %v, want nothing pending", err)
+	}
+}
+
+func TestDeliveriesOfRemovedWebhooksAreDeadLettered(t *testing.T) {
+	td := newTestDispatcher(t)
+	td.broadcast("r1", map[string]interface{}{"type": "new_message"})
+	id := td.repo.only(t).ID
+	ctx := context.Background()
+	if err := td.repo.DeleteWebhook(ctx, "r1", td.hook.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	td.DeliverDue(ctx)
+	if n := td.receiver.count(); n != 0 {
+		t.Errorf("receiver got %d deliveries, want none", n)
+	}
+	if d := td.repo.get(id); d.Status != StatusDead || d.LastError != "webhook removed" {
+		t.Errorf("delivery = %+v, want dead", d)
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	td := newTestDispatcher(t, http.StatusInternalServerError, http.StatusInternalServerError,
+		http.StatusInternalServerError, http.StatusInternalServerError)
+	rooms := &fakeRooms{rooms: map[string]*room.Room{"r1": {OwnerID: "owner", Members: []string{"owner", "member"}}}}
+	service := NewWebhookService(td.repo, rooms, td.Dispatcher, nil, 10)
+	service.now = td.now
+	td.broadcast("r1", map[string]interface{}{"type": "new_message"})
+	id := td.repo.only(t).ID
+	ctx := context.Background()
+
+	if _, err := service.Redeliver(ctx, "r1", id.Hex(), "owner"); !errors.Is(err, errormodel.ErrDeliveryNotFound) {
+		t.Errorf("Redeliver of a pending delivery = %v, want ErrDeliveryNotFound", err)
+	}
+	for range td.cfg.MaxAttempts {
+		td.DeliverDue(ctx)
+		td.advance(time.Minute)
+	}
+	dead := td.repo.get(id)
+	if dead.Status != StatusDead {
+		t.Fatalf("delivery = %+v, want dead", dead)
+	}
+	first := td.receiver.last()
+
+	if _, err := service.Redeliver(ctx, "r1", id.Hex(), "member"); !errors.Is(err, errormodel.ErrForbidden) {
+		t.Errorf("Redeliver by a member = %v, want ErrForbidden", err)
+	}
+	if _, err := service.Redeliver(ctx, "r2", id.Hex(), "owner"); !errors.Is(err, errormodel.ErrRoomNotFound) {
+		t.Errorf("Redeliver in another room = %v, want ErrRoomNotFound", err)
+	}
+	select {
+	case <-td.wake:
+	default:
+	}
+
+	redelivered, err := service.Redeliver(ctx, "r1", id.Hex(), "owner")
+	if err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	if redelivered.Status != StatusPending || redelivered.Attempts != 0 || !redelivered.NextAttemptAt.Equal(td.clock) || redelivered.ExpiresAt != nil {
+		t.Errorf("redelivered = %+v, want pending with fresh attempts", redelivered)
+	}
+	select {
+	case <-td.wake:
+	default:
+		t.Error("Redeliver did not wake the dispatcher")
+	}
+
+	td.DeliverDue(ctx)
+	if d := td.repo.get(id); d.Status != StatusDelivered || d.Attempts != 1 {
+		t.Errorf("delivery = %+v, want delivered on the first fresh attempt", d)
+	}
+	got := td.receiver.last()
+	if !got.verified || string(got.body) != string(first.body) || got.header.Get("X-Webhook-Delivery") != id.Hex() {
+		t.Errorf("redelivery = %s (verified %v), want the original payload under the same delivery ID", got.body, got.verified)
+	}
+	if _, err := service.Redeliver(ctx, "r1", id.Hex(), "owner"); !errors.Is(err, errormodel.ErrDeliveryNotFound) {
+		t.Errorf("Redeliver of a delivered delivery = %v, want ErrDeliveryNotFound", err)
+	}
+}
@@ -0,0 +1,242 @@
+package webhook
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/models/response"
+	"strings"
+)
+
+const (
+	// defaultDeliveryPageSize is used when a request for the delivery log does not specify a limit.
+	defaultDeliveryPageSize = 50
+	// maxDeliveryPageSize caps the number of deliveries returned by a single request.
+	maxDeliveryPageSize = 200
+)
+
+// WebhookHandler defines the interface for handling HTTP requests to manage the webhooks of a room and inspect
+// their deliveries.
+type WebhookHandler interface {
+	CreateWebhook(c *fiber.Ctx) error
+	ListWebhooks(c *fiber.Ctx) error
+	DeleteWebhook(c *fiber.Ctx) error
+	ListDeliveries(c *fiber.Ctx) error
+	ListDeadLetters(c *fiber.Ctx) error
+	Redeliver(c *fiber.Ctx) error
+}
+
+// WebhookHandlerImpl implements the WebhookHandler interface.
+type WebhookHandlerImpl struct {
+	webhookService WebhookService
+}
+
+// NewWebhookHandler initializes and returns a new WebhookHandler with the provided WebhookService implementation.
+func NewWebhookHandler(webhookService WebhookService) WebhookHandler {
+	return &WebhookHandlerImpl{webhookService: webhookService}
+}
+
+// CreateWebhook handles a room owner registering a webhook for the room.
+func (wh *WebhookHandlerImpl) CreateWebhook(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	var req request.CreateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	for i, event := range req.Events {
+		req.Events[i] = strings.TrimSpace(event)
+	}
+
+	log.Println("Create Webhook for Room with id ", roomId, " Request Received.")
+
+	hook, err := wh.webhookService.CreateWebhook(c.Context(), roomId, auth.CallerID(c), req)
+	if errors.Is(err, errormodel.ErrWebhookLimitReached) {
+		return c.Status(fiber.StatusConflict).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusConflict,
+			Message: "The Room Has Reached Its Webhook Limit.",
+		})
+	} else if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Webhook Created",
+		Data:    hook,
+	})
+}
+
+// ListWebhooks handles a room owner reading the webhooks of the room.
+func (wh *WebhookHandlerImpl) ListWebhooks(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+
+	log.Println("List Webhooks of Room with id ", roomId, " Request Received.")
+
+	hooks, err := wh.webhookService.ListWebhooks(c.Context(), roomId, auth.CallerID(c))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Webhooks Found",
+		Data:    hooks,
+	})
+}
+
+// DeleteWebhook handles a room owner removing a webhook of the room.
+func (wh *WebhookHandlerImpl) DeleteWebhook(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	id := c.Params("hookId")
+
+	log.Println("Delete Webhook ", id, " of Room with id ", roomId, " Request Received.")
+
+	if err := wh.webhookService.DeleteWebhook(c.Context(), roomId, id, auth.CallerID(c)); err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Webhook Deleted",
+	})
+}
+
+// ListDeliveries handles a room owner reading the delivery log of a webhook, newest first, filtered by the status
+// query parameter and paged with before and limit.
+func (wh *WebhookHandlerImpl) ListDeliveries(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	id := c.Params("hookId")
+	status := strings.TrimSpace(c.Query("status"))
+	switch status {
+	case "", StatusPending, StatusDelivered, StatusDead:
+	default:
+		return invalidLogParams(c)
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return invalidLogParams(c)
+	}
+
+	log.Println("List Deliveries of Webhook ", id, " of Room with id ", roomId, " Request Received.")
+
+	deliveries, err := wh.webhookService.ListDeliveries(c.Context(), roomId, id, auth.CallerID(c), status, strings.TrimSpace(c.Query("before")), limit)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Deliveries Found",
+		Data:    deliveries,
+	})
+}
+
+// ListDeadLetters handles a room owner reading the deliveries of the room's webhooks that ran out of attempts,
+// paged with before and limit.
+func (wh *WebhookHandlerImpl) ListDeadLetters(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	limit, ok := parseLimit(c)
+	if !ok {
+		return invalidLogParams(c)
+	}
+
+	log.Println("List Dead Webhook Deliveries of Room with id ", roomId, " Request Received.")
+
+	deliveries, err := wh.webhookService.ListDeadLetters(c.Context(), roomId, auth.CallerID(c), strings.TrimSpace(c.Query("before")), limit)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Deliveries Found",
+		Data:    deliveries,
+	})
+}
+
+// Redeliver handles a room owner retrying a delivery from the dead-letter list.
+func (wh *WebhookHandlerImpl) Redeliver(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	id := c.Params("deliveryId")
+
+	log.Println("Redeliver Webhook Delivery ", id, " of Room with id ", roomId, " Request Received.")
+
+	delivery, err := wh.webhookService.Redeliver(c.Context(), roomId, id, auth.CallerID(c))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(response.APIResponse{
+		Status:  fiber.StatusAccepted,
+		Message: "Delivery Queued",
+		Data:    delivery,
+	})
+}
+
+func parseLimit(c *fiber.Ctx) (int, bool) {
+	limit := c.QueryInt("limit", defaultDeliveryPageSize)
+	return limit, limit >= 1 && limit <= maxDeliveryPageSize
+}
+
+func invalidLogParams(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+		Error:   "Invalid delivery log parameters",
+		Status:  fiber.StatusBadRequest,
+		Message: "status must be pending, delivered or dead and limit between 1 and 200.",
+	})
+}
+
+// webhookError maps the service errors shared by the webhook endpoints to API responses.
+func webhookError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errormodel.ErrUnauthenticated) {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnauthorized,
+			Message: "Webhooks Require A Caller Identity.",
+		})
+	} else if errors.Is(err, errormodel.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "Only The Room Owner Can Manage Webhooks.",
+		})
+	} else if errors.Is(err, errormodel.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Room Found with given id.",
+		})
+	} else if errors.Is(err, errormodel.ErrWebhookNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Webhook Found with given id in this Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrDeliveryNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Dead Delivery Found with given id in this Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidWebhook) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "url Must Be An http Or https URL, secret Between 16 And 256 Characters And events Known Event Types.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidCursor) || errors.Is(err, errormodel.ErrInvalidRoomID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Room id or before is malformed.",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(response.APIResponse{
+		Error:   err.Error(),
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed To Process Webhook Request",
+	})
+}
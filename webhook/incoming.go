@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// IncomingWebhook is a token a room's owner created for integrations, such as CI systems, to post into the room
+// without a user session. Only a hash of the token is stored; the token itself is shown once, when it is created.
+type IncomingWebhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RoomID    string             `bson:"room_id" json:"room_id"`
+	Name      string             `bson:"name" json:"name"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	// RevokedAt is set once the token has been revoked, after which it is no longer accepted
+	RevokedAt *time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokedBy string     `bson:"revoked_by,omitempty" json:"revoked_by,omitempty"`
+}
+
+// SenderID returns the sender of the messages posted through the webhook.
+func (h *IncomingWebhook) SenderID() string {
+	return "webhook:" + h.ID.Hex()
+}
+
+// CreatedIncomingWebhook is a newly created incoming webhook together with its token.
+type CreatedIncomingWebhook struct {
+	*IncomingWebhook
+	Token string `json:"token"`
+}
+
+// newToken returns a random token for an incoming webhook and the hash it is stored under.
+func newToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the hash an incoming webhook token is stored and looked up under.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
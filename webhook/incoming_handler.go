@@ -0,0 +1,226 @@
+package webhook
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"messages-go/auth"
+	"messages-go/message"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/models/response"
+	"messages-go/ratelimit"
+	ws "messages-go/websocket"
+	"strconv"
+)
+
+// IncomingWebhookHandler defines the interface for handling HTTP requests to manage the incoming webhooks of a
+// room and the posts integrations make through them.
+type IncomingWebhookHandler interface {
+	CreateIncomingWebhook(c *fiber.Ctx) error
+	ListIncomingWebhooks(c *fiber.Ctx) error
+	RevokeIncomingWebhook(c *fiber.Ctx) error
+	Post(c *fiber.Ctx) error
+}
+
+// IncomingWebhookHandlerImpl implements the IncomingWebhookHandler interface.
+type IncomingWebhookHandlerImpl struct {
+	incomingService IncomingWebhookService
+	wsHandler       *ws.Handler
+	limiter         *ratelimit.Limiter
+	// rule limits the posts of each webhook, of each client IP and into each room
+	rule ratelimit.Rule
+}
+
+// NewIncomingWebhookHandler initializes and returns a new IncomingWebhookHandler with the provided
+// IncomingWebhookService implementation.
+func NewIncomingWebhookHandler(incomingService IncomingWebhookService, wsHandler *ws.Handler, limiter *ratelimit.Limiter, rule ratelimit.Rule) IncomingWebhookHandler {
+	return &IncomingWebhookHandlerImpl{
+		incomingService: incomingService,
+		wsHandler:       wsHandler,
+		limiter:         limiter,
+		rule:            rule,
+	}
+}
+
+// CreateIncomingWebhook handles a room owner creating an incoming webhook. The response carries its token, which
+// is not shown again.
+func (ih *IncomingWebhookHandlerImpl) CreateIncomingWebhook(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	var req request.CreateIncomingWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	log.Println("Create Incoming Webhook for Room with id ", roomId, " Request Received.")
+
+	hook, err := ih.incomingService.CreateIncomingWebhook(c.Context(), roomId, auth.CallerID(c), req)
+	if errors.Is(err, errormodel.ErrInvalidIncomingWebhook) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "name Is Required And At Most 80 Characters.",
+		})
+	} else if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response.APIResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Incoming Webhook Created",
+		Data:    hook,
+	})
+}
+
+// ListIncomingWebhooks handles a room owner reading the incoming webhooks of the room.
+func (ih *IncomingWebhookHandlerImpl) ListIncomingWebhooks(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+
+	log.Println("List Incoming Webhooks of Room with id ", roomId, " Request Received.")
+
+	hooks, err := ih.incomingService.ListIncomingWebhooks(c.Context(), roomId, auth.CallerID(c))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Incoming Webhooks Found",
+		Data:    hooks,
+	})
+}
+
+// RevokeIncomingWebhook handles a room owner revoking an incoming webhook of the room.
+func (ih *IncomingWebhookHandlerImpl) RevokeIncomingWebhook(c *fiber.Ctx) error {
+	roomId := c.Params("id")
+	id := c.Params("hookId")
+
+	log.Println("Revoke Incoming Webhook ", id, " of Room with id ", roomId, " Request Received.")
+
+	hook, err := ih.incomingService.RevokeIncomingWebhook(c.Context(), roomId, id, auth.CallerID(c))
+	if errors.Is(err, errormodel.ErrIncomingWebhookNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Active Incoming Webhook Found with given id in this Room.",
+		})
+	} else if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Status:  fiber.StatusOK,
+		Message: "Incoming Webhook Revoked",
+		Data:    hook,
+	})
+}
+
+// Post handles an integration posting a message with the token in the path. The token is the only credential, so
+// it is never logged. Posts are limited per webhook, client IP and room, and broadcast like any other message;
+// a post retried with the same Idempotency-Key header returns the message first posted.
+func (ih *IncomingWebhookHandlerImpl) Post(c *fiber.Ctx) error {
+	var req request.IncomingMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid Request Body",
+		})
+	}
+
+	hook, err := ih.incomingService.Authenticate(c.Context(), c.Params("token"))
+	if errors.Is(err, errormodel.ErrIncomingWebhookNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusNotFound,
+			Message: "No Incoming Webhook Found For This Token.",
+		})
+	} else if err != nil {
+		return webhookError(c, err)
+	}
+
+	log.Println("Incoming Webhook ", hook.ID.Hex(), " Post to Room with id ", hook.RoomID, " Request Received.")
+
+	if ih.limiter != nil {
+		decision := ih.limiter.Allow(c.Context(), ih.rule, hook.ID.Hex(), c.IP(), hook.RoomID)
+		if !decision.Allowed {
+			seconds := ratelimit.RetryAfterSeconds(decision.RetryAfter)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+			return c.Status(fiber.StatusTooManyRequests).JSON(response.APIResponse{
+				Error:   errormodel.ErrRateLimited.Error(),
+				Status:  fiber.StatusTooManyRequests,
+				Message: "Too Many Requests, Retry In " + strconv.Itoa(seconds) + " Seconds.",
+			})
+		}
+	}
+
+	posted, err := ih.incomingService.Post(c.Context(), hook, req, c.Get(message.IdempotencyKeyHeader))
+	if errors.Is(err, errormodel.ErrInvalidIncomingWebhook) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Posts Need text Or attachments.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidEmbed) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "At Most 5 attachments, Each With A title Or text, An http Or https title_link And A Hex color; username At Most 80 Characters.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidFormat) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Format Must Be plain Or markdown, With A Body Small Enough To Render.",
+		})
+	} else if errors.Is(err, errormodel.ErrInvalidClientMsgID) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Message: "Idempotency Key Must Be At Most 128 Characters.",
+		})
+	} else if errors.Is(err, errormodel.ErrMessageRejected) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusUnprocessableEntity,
+			Message: "Message Rejected By The Room's Content Filters.",
+		})
+	} else if errors.Is(err, errormodel.ErrBanned) || errors.Is(err, errormodel.ErrMuted) {
+		return c.Status(fiber.StatusForbidden).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusForbidden,
+			Message: "This Webhook May Not Post To The Room.",
+		})
+	} else if errors.Is(err, errormodel.ErrSlowMode) || errors.Is(err, errormodel.ErrDuplicateMessage) {
+		var retryErr *errormodel.RetryError
+		seconds := 1
+		if errors.As(err, &retryErr) {
+			seconds = ratelimit.RetryAfterSeconds(retryErr.RetryAfter)
+		}
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return c.Status(fiber.StatusTooManyRequests).JSON(response.APIResponse{
+			Error:   err.Error(),
+			Status:  fiber.StatusTooManyRequests,
+			Message: "Slow Down, You May Post Again In " + strconv.Itoa(seconds) + " Seconds.",
+		})
+	} else if err != nil {
+		return webhookError(c, err)
+	}
+
+	if posted.Replayed() {
+		return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+			Data:    posted,
+			Status:  fiber.StatusOK,
+			Message: "Message Already Posted.",
+		})
+	}
+	if ih.wsHandler != nil {
+		ih.wsHandler.BroadcastToRoom(posted.RoomID, message.NewMessageEvent(posted))
+	}
+	return c.Status(fiber.StatusOK).JSON(response.APIResponse{
+		Data:    posted,
+		Status:  fiber.StatusOK,
+		Message: "Message Posted.",
+	})
+}
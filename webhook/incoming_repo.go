@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"os"
+	"time"
+)
+
+// IncomingWebhookRepo defines an interface for persisting the incoming webhooks of rooms.
+type IncomingWebhookRepo interface {
+	CreateIncomingWebhook(ctx context.Context, hook *IncomingWebhook) (*IncomingWebhook, error)
+	GetIncomingWebhookByTokenHash(ctx context.Context, tokenHash string) (*IncomingWebhook, error)
+	GetIncomingWebhooksByRoom(ctx context.Context, roomID string) ([]IncomingWebhook, error)
+	RevokeIncomingWebhook(ctx context.Context, roomID string, id primitive.ObjectID, revokedBy string, at time.Time) (*IncomingWebhook, error)
+}
+
+// IncomingWebhookRepoImpl is a concrete implementation of the IncomingWebhookRepo interface backed by MongoDB.
+type IncomingWebhookRepoImpl struct {
+	collection *mongo.Collection
+}
+
+// NewIncomingWebhookRepository initializes and returns a new instance of IncomingWebhookRepo, with a unique index
+// on the token hashes that tokens are looked up by.
+func NewIncomingWebhookRepository(client *mongo.Client) IncomingWebhookRepo {
+	r := &IncomingWebhookRepoImpl{
+		collection: client.Database(os.Getenv("MONGO_DB_NAME")).Collection("incoming_webhooks"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "room_id", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create incoming webhook indexes: %v", err)
+	}
+	return r
+}
+
+// CreateIncomingWebhook stores an incoming webhook and returns it with its generated ID.
+func (r *IncomingWebhookRepoImpl) CreateIncomingWebhook(ctx context.Context, hook *IncomingWebhook) (*IncomingWebhook, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(timeoutCtx, hook)
+	if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		hook.ID = oid
+	}
+	return hook, nil
+}
+
+// GetIncomingWebhookByTokenHash retrieves the incoming webhook of a token, returning mongo.ErrNoDocuments when
+// there is none or it has been revoked.
+func (r *IncomingWebhookRepoImpl) GetIncomingWebhookByTokenHash(ctx context.Context, tokenHash string) (*IncomingWebhook, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"token_hash": tokenHash, "revoked_at": bson.M{"$exists": false}}
+	var hook IncomingWebhook
+	if err := r.collection.FindOne(timeoutCtx, filter).Decode(&hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// GetIncomingWebhooksByRoom retrieves the incoming webhooks of a room, including revoked ones, in the order they
+// were created.
+func (r *IncomingWebhookRepoImpl) GetIncomingWebhooksByRoom(ctx context.Context, roomID string) ([]IncomingWebhook, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(timeoutCtx, bson.M{"room_id": roomID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	hooks := []IncomingWebhook{}
+	if err := cursor.All(timeoutCtx, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// RevokeIncomingWebhook revokes an incoming webhook of a room and returns it, or mongo.ErrNoDocuments when the
+// room has no such webhook that is not revoked already.
+func (r *IncomingWebhookRepoImpl) RevokeIncomingWebhook(ctx context.Context, roomID string, id primitive.ObjectID, revokedBy string, at time.Time) (*IncomingWebhook, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "room_id": roomID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revoked_at": at, "revoked_by": revokedBy}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var revoked IncomingWebhook
+	if err := r.collection.FindOneAndUpdate(timeoutCtx, filter, update, opts).Decode(&revoked); err != nil {
+		return nil, err
+	}
+	return &revoked, nil
+}
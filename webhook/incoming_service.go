@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	"messages-go/message"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/room"
+	"strings"
+	"time"
+)
+
+// maxIncomingNameSize bounds the names of incoming webhooks.
+const maxIncomingNameSize = 80
+
+// IncomingWebhookService defines the interface for room owners to manage the incoming webhooks of their rooms and
+// for integrations to post through them.
+type IncomingWebhookService interface {
+	CreateIncomingWebhook(ctx context.Context, roomId string, callerId string, req request.CreateIncomingWebhookRequest) (*CreatedIncomingWebhook, error)
+	ListIncomingWebhooks(ctx context.Context, roomId string, callerId string) ([]IncomingWebhook, error)
+	RevokeIncomingWebhook(ctx context.Context, roomId string, id string, callerId string) (*IncomingWebhook, error)
+	Authenticate(ctx context.Context, token string) (*IncomingWebhook, error)
+	Post(ctx context.Context, hook *IncomingWebhook, req request.IncomingMessageRequest, clientMsgID string) (*message.Message, error)
+}
+
+// IncomingWebhookServiceImpl issues and revokes the tokens of incoming webhooks and posts the messages of
+// integrations through the message service, so they are vetted and stored like any other.
+type IncomingWebhookServiceImpl struct {
+	incomingRepo   IncomingWebhookRepo
+	roomRepo       room.RoomRepo
+	messageService message.MessageService
+	// auditor records incoming webhooks being created and revoked in the audit log
+	auditor audit.Recorder
+	now     func() time.Time
+}
+
+// NewIncomingWebhookService initializes and returns a new instance of IncomingWebhookServiceImpl.
+func NewIncomingWebhookService(incomingRepo IncomingWebhookRepo, roomRepo room.RoomRepo, messageService message.MessageService, auditor audit.Recorder) *IncomingWebhookServiceImpl {
+	return &IncomingWebhookServiceImpl{
+		incomingRepo:   incomingRepo,
+		roomRepo:       roomRepo,
+		messageService: messageService,
+		auditor:        auditor,
+		now:            time.Now,
+	}
+}
+
+// CreateIncomingWebhook creates an incoming webhook for a room and returns it with its token, which is not shown
+// again. Only the room's owner may create incoming webhooks.
+func (is *IncomingWebhookServiceImpl) CreateIncomingWebhook(ctx context.Context, roomId string, callerId string, req request.CreateIncomingWebhookRequest) (*CreatedIncomingWebhook, error) {
+	if _, err := ownedRoom(ctx, is.roomRepo, roomId, callerId); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxIncomingNameSize {
+		return nil, errormodel.ErrInvalidIncomingWebhook
+	}
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := is.incomingRepo.CreateIncomingWebhook(ctx, &IncomingWebhook{
+		RoomID:    roomId,
+		Name:      name,
+		TokenHash: tokenHash,
+		CreatedBy: callerId,
+		CreatedAt: is.now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	is.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionIncomingCreated,
+		ActorID:    callerId,
+		TargetType: audit.TargetIncoming,
+		TargetID:   hook.ID.Hex(),
+		RoomID:     roomId,
+		After:      map[string]interface{}{"name": hook.Name},
+	})
+	return &CreatedIncomingWebhook{IncomingWebhook: hook, Token: token}, nil
+}
+
+// ListIncomingWebhooks returns the incoming webhooks of a room to its owner, without their tokens.
+func (is *IncomingWebhookServiceImpl) ListIncomingWebhooks(ctx context.Context, roomId string, callerId string) ([]IncomingWebhook, error) {
+	if _, err := ownedRoom(ctx, is.roomRepo, roomId, callerId); err != nil {
+		return nil, err
+	}
+	return is.incomingRepo.GetIncomingWebhooksByRoom(ctx, roomId)
+}
+
+// RevokeIncomingWebhook revokes an incoming webhook of a room, rejecting every later post with its token.
+func (is *IncomingWebhookServiceImpl) RevokeIncomingWebhook(ctx context.Context, roomId string, id string, callerId string) (*IncomingWebhook, error) {
+	if _, err := ownedRoom(ctx, is.roomRepo, roomId, callerId); err != nil {
+		return nil, err
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrIncomingWebhookNotFound
+	}
+	hook, err := is.incomingRepo.RevokeIncomingWebhook(ctx, roomId, oid, callerId, is.now().UTC())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrIncomingWebhookNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	is.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionIncomingRevoked,
+		ActorID:    callerId,
+		TargetType: audit.TargetIncoming,
+		TargetID:   id,
+		RoomID:     roomId,
+		Before:     map[string]interface{}{"name": hook.Name},
+	})
+	return hook, nil
+}
+
+// Authenticate returns the incoming webhook of a token, or ErrIncomingWebhookNotFound when the token is unknown
+// or revoked.
+func (is *IncomingWebhookServiceImpl) Authenticate(ctx context.Context, token string) (*IncomingWebhook, error) {
+	if token == "" {
+		return nil, errormodel.ErrIncomingWebhookNotFound
+	}
+	hook, err := is.incomingRepo.GetIncomingWebhookByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrIncomingWebhookNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// Post posts an integration's message into the room of an incoming webhook. The message is sent by the webhook,
+// shown under its name unless the request overrides it, with the request's attachments as embeds. Posts need
+// text or an attachment.
+func (is *IncomingWebhookServiceImpl) Post(ctx context.Context, hook *IncomingWebhook, req request.IncomingMessageRequest, clientMsgID string) (*message.Message, error) {
+	if strings.TrimSpace(req.Text) == "" && len(req.Attachments) == 0 {
+		return nil, errormodel.ErrInvalidIncomingWebhook
+	}
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		username = hook.Name
+	}
+	embeds := make([]message.Embed, 0, len(req.Attachments))
+	for _, a := range req.Attachments {
+		embeds = append(embeds, message.Embed{Title: a.Title, URL: a.TitleLink, Text: a.Text, Color: a.Color})
+	}
+
+	return is.messageService.PostMessage(ctx, &message.Message{
+		RoomID:      hook.RoomID,
+		SenderID:    hook.SenderID(),
+		Body:        req.Text,
+		Format:      req.Format,
+		ClientMsgID: clientMsgID,
+		Integration: &message.Integration{
+			Kind:     message.IntegrationIncomingWebhook,
+			ID:       hook.ID.Hex(),
+			Username: username,
+		},
+		Embeds: embeds,
+	})
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"time"
+)
+
+// Events is the set of room events webhooks may subscribe to.
+var Events = []string{"new_message", "room_updated", "member_joined", "member_left"}
+
+// subscribable reports whether an event type is one of Events.
+func subscribable(eventType string) bool {
+	return slices.Contains(Events, eventType)
+}
+
+// Webhook is a URL registered by a room's owner to receive the room's events. The secret signs every delivery
+// and is never returned.
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RoomID    string             `bson:"room_id" json:"room_id"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"-"`
+	Events    []string           `bson:"events" json:"events"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Statuses of a delivery.
+const (
+	// StatusPending deliveries are waiting for their next attempt
+	StatusPending = "pending"
+	// StatusDelivered deliveries were accepted by the receiver
+	StatusDelivered = "delivered"
+	// StatusDead deliveries ran out of attempts and wait in the dead-letter list until redelivered
+	StatusDead = "dead"
+)
+
+// Delivery is an event on its way to a webhook. It doubles as the delivery log, recording the outcome of the
+// latest attempt.
+type Delivery struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	WebhookID primitive.ObjectID `bson:"webhook_id" json:"webhook_id"`
+	RoomID    string             `bson:"room_id" json:"room_id"`
+	Event     string             `bson:"event" json:"event"`
+	// Payload is the JSON body posted to the webhook, fixed when the event occurred
+	Payload  string `bson:"payload" json:"payload"`
+	Status   string `bson:"status" json:"status"`
+	Attempts int    `bson:"attempts" json:"attempts"`
+	// NextAttemptAt is when a pending delivery is next tried; while an attempt is in progress, when it may be
+	// taken over
+	NextAttemptAt time.Time `bson:"next_attempt_at" json:"next_attempt_at"`
+	// LastStatusCode and LastError describe the latest failed attempt
+	LastStatusCode int        `bson:"last_status_code,omitempty" json:"last_status_code,omitempty"`
+	LastError      string     `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt      time.Time  `bson:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+	// ExpiresAt is when MongoDB removes a delivered or dead delivery
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"-"`
+}
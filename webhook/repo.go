@@ -0,0 +1,288 @@
+package webhook
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"os"
+	"time"
+)
+
+// WebhookRepo defines an interface for persisting the webhooks of rooms and the deliveries queued for them.
+type WebhookRepo interface {
+	CreateWebhook(ctx context.Context, hook *Webhook) (*Webhook, error)
+	GetWebhook(ctx context.Context, id primitive.ObjectID) (*Webhook, error)
+	GetWebhooksByRoom(ctx context.Context, roomID string) ([]Webhook, error)
+	GetSubscribedWebhooks(ctx context.Context, roomID string, event string) ([]Webhook, error)
+	CountWebhooksByRoom(ctx context.Context, roomID string) (int64, error)
+	DeleteWebhook(ctx context.Context, roomID string, id primitive.ObjectID) error
+	CreateDeliveries(ctx context.Context, deliveries []Delivery) error
+	ClaimDue(ctx context.Context, now time.Time, leaseUntil time.Time) (*Delivery, error)
+	NextAttemptAt(ctx context.Context) (time.Time, error)
+	SetDelivered(ctx context.Context, id primitive.ObjectID, at time.Time, expiresAt time.Time) error
+	SetFailed(ctx context.Context, d *Delivery) error
+	GetDeliveries(ctx context.Context, filter DeliveryFilter, beforeID primitive.ObjectID, limit int64) ([]Delivery, error)
+	Redeliver(ctx context.Context, roomID string, id primitive.ObjectID, now time.Time) (*Delivery, error)
+}
+
+// DeliveryFilter selects the deliveries of a room, optionally of one webhook and in one status.
+type DeliveryFilter struct {
+	RoomID    string
+	WebhookID primitive.ObjectID
+	Status    string
+}
+
+// WebhookRepoImpl is a concrete implementation of the WebhookRepo interface backed by MongoDB.
+type WebhookRepoImpl struct {
+	webhookCollection  *mongo.Collection
+	deliveryCollection *mongo.Collection
+}
+
+// NewWebhookRepository initializes and returns a new instance of WebhookRepo, indexing deliveries for the
+// delivery loop and the delivery log and having MongoDB remove finished deliveries once they expire.
+func NewWebhookRepository(client *mongo.Client) WebhookRepo {
+	db := client.Database(os.Getenv("MONGO_DB_NAME"))
+	r := &WebhookRepoImpl{
+		webhookCollection:  db.Collection("webhooks"),
+		deliveryCollection: db.Collection("webhook_deliveries"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.webhookCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "room_id", Value: 1}, {Key: "events", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Failed to create webhook index: %v", err)
+	}
+	_, err = r.deliveryCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "room_id", Value: 1}, {Key: "webhook_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create webhook delivery indexes: %v", err)
+	}
+	return r
+}
+
+// CreateWebhook stores a webhook and returns it with its generated ID.
+func (r *WebhookRepoImpl) CreateWebhook(ctx context.Context, hook *Webhook) (*Webhook, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.webhookCollection.InsertOne(timeoutCtx, hook)
+	if err != nil {
+		return nil, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		hook.ID = oid
+	}
+	return hook, nil
+}
+
+// GetWebhook retrieves a webhook, returning mongo.ErrNoDocuments when it does not exist.
+func (r *WebhookRepoImpl) GetWebhook(ctx context.Context, id primitive.ObjectID) (*Webhook, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var hook Webhook
+	if err := r.webhookCollection.FindOne(timeoutCtx, bson.M{"_id": id}).Decode(&hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// GetWebhooksByRoom retrieves the webhooks of a room in the order they were registered.
+func (r *WebhookRepoImpl) GetWebhooksByRoom(ctx context.Context, roomID string) ([]Webhook, error) {
+	return r.findWebhooks(ctx, bson.M{"room_id": roomID})
+}
+
+// GetSubscribedWebhooks retrieves the webhooks of a room subscribed to an event.
+func (r *WebhookRepoImpl) GetSubscribedWebhooks(ctx context.Context, roomID string, event string) ([]Webhook, error) {
+	return r.findWebhooks(ctx, bson.M{"room_id": roomID, "events": event})
+}
+
+func (r *WebhookRepoImpl) findWebhooks(ctx context.Context, filter bson.M) ([]Webhook, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.webhookCollection.Find(timeoutCtx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(timeoutCtx)
+
+	hooks := []Webhook{}
+	if err := cursor.All(timeoutCtx, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// CountWebhooksByRoom counts the webhooks of a room.
+func (r *WebhookRepoImpl) CountWebhooksByRoom(ctx context.Context, roomID string) (int64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return r.webhookCollection.CountDocuments(timeoutCtx, bson.M{"room_id": roomID})
+}
+
+// DeleteWebhook removes a webhook of a room, returning mongo.ErrNoDocuments when it does not exist. Its pending
+// deliveries are dropped when they come due.
+func (r *WebhookRepoImpl) DeleteWebhook(ctx context.Context, roomID string, id primitive.ObjectID) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.webhookCollection.DeleteOne(timeoutCtx, bson.M{"_id": id, "room_id": roomID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// CreateDeliveries queues deliveries under the IDs they were given.
+func (r *WebhookRepoImpl) CreateDeliveries(ctx context.Context, deliveries []Delivery) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	docs := make([]interface{}, 0, len(deliveries))
+	for _, d := range deliveries {
+		docs = append(docs, d)
+	}
+	_, err := r.deliveryCollection.InsertMany(timeoutCtx, docs)
+	return err
+}
+
+// ClaimDue atomically takes the pending delivery that has been due the longest, counting an attempt and leasing
+// it until leaseUntil, after which another worker may take it over if it was not finished. It returns
+// mongo.ErrNoDocuments when nothing is due.
+func (r *WebhookRepoImpl) ClaimDue(ctx context.Context, now time.Time, leaseUntil time.Time) (*Delivery, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"status": StatusPending, "next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{
+		"$set": bson.M{"next_attempt_at": leaseUntil},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+	var claimed Delivery
+	if err := r.deliveryCollection.FindOneAndUpdate(timeoutCtx, filter, update, opts).Decode(&claimed); err != nil {
+		return nil, err
+	}
+	return &claimed, nil
+}
+
+// NextAttemptAt returns when the next pending delivery is due, or mongo.ErrNoDocuments when none is pending.
+func (r *WebhookRepoImpl) NextAttemptAt(ctx context.Context) (time.Time, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetProjection(bson.M{"next_attempt_at": 1})
+	var next Delivery
+	if err := r.deliveryCollection.FindOne(timeoutCtx, bson.M{"status": StatusPending}, opts).Decode(&next); err != nil {
+		return time.Time{}, err
+	}
+	return next.NextAttemptAt, nil
+}
+
+// SetDelivered records that a delivery was accepted.
+func (r *WebhookRepoImpl) SetDelivered(ctx context.Context, id primitive.ObjectID, at time.Time, expiresAt time.Time) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set":   bson.M{"status": StatusDelivered, "delivered_at": at, "expires_at": expiresAt},
+		"$unset": bson.M{"last_status_code": "", "last_error": ""},
+	}
+	_, err := r.deliveryCollection.UpdateOne(timeoutCtx, bson.M{"_id": id}, update)
+	return err
+}
+
+// SetFailed records a failed attempt of a delivery with its status, next attempt and expiry.
+func (r *WebhookRepoImpl) SetFailed(ctx context.Context, d *Delivery) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	set := bson.M{
+		"status":           d.Status,
+		"next_attempt_at":  d.NextAttemptAt,
+		"last_status_code": d.LastStatusCode,
+		"last_error":       d.LastError,
+	}
+	update := bson.M{"$set": set}
+	if d.ExpiresAt != nil {
+		set["expires_at"] = *d.ExpiresAt
+	}
+	_, err := r.deliveryCollection.UpdateOne(timeoutCtx, bson.M{"_id": d.ID}, update)
+	return err
+}
+
+// GetDeliveries retrieves at most limit deliveries matching the filter, newest first, starting before beforeID
+// unless it is the zero ID.
+func (r *WebhookRepoImpl) GetDeliveries(ctx context.Context, filter DeliveryFilter, beforeID primitive.ObjectID, limit int64) ([]Delivery, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := bson.M{"room_id": filter.RoomID}
+	if !filter.WebhookID.IsZero() {
+		query["webhook_id"] = filter.WebhookID
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if !beforeID.IsZero() {
+		query["_id"] = bson.M{"$lt": beforeID}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	cursor, err := r.deliveryCollection.Find(timeoutCtx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			log.Default().Println(err.Error())
+		}
+	}(cursor, timeoutCtx)
+
+	deliveries := []Delivery{}
+	if err := cursor.All(timeoutCtx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Redeliver moves a dead delivery of a room back to the queue with fresh attempts, due at now. It returns
+// mongo.ErrNoDocuments when the room has no such dead delivery.
+func (r *WebhookRepoImpl) Redeliver(ctx context.Context, roomID string, id primitive.ObjectID, now time.Time) (*Delivery, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "room_id": roomID, "status": StatusDead}
+	update := bson.M{
+		"$set":   bson.M{"status": StatusPending, "attempts": 0, "next_attempt_at": now},
+		"$unset": bson.M{"expires_at": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated Delivery
+	if err := r.deliveryCollection.FindOneAndUpdate(timeoutCtx, filter, update, opts).Decode(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
@@ -0,0 +1,242 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	"messages-go/models/errormodel"
+	"messages-go/models/request"
+	"messages-go/room"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Limits on the webhooks registered by room owners.
+const (
+	maxURLSize    = 2048
+	minSecretSize = 16
+	maxSecretSize = 256
+)
+
+// WebhookService defines the interface for room owners to manage the webhooks of their rooms and inspect their
+// deliveries.
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, roomId string, callerId string, req request.CreateWebhookRequest) (*Webhook, error)
+	ListWebhooks(ctx context.Context, roomId string, callerId string) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, roomId string, id string, callerId string) error
+	ListDeliveries(ctx context.Context, roomId string, id string, callerId string, status string, before string, limit int) ([]Delivery, error)
+	ListDeadLetters(ctx context.Context, roomId string, callerId string, before string, limit int) ([]Delivery, error)
+	Redeliver(ctx context.Context, roomId string, deliveryId string, callerId string) (*Delivery, error)
+}
+
+// WebhookServiceImpl lets the owners of rooms register webhooks, which the Dispatcher delivers the room's events
+// to, and retry deliveries that ended up in the dead-letter list.
+type WebhookServiceImpl struct {
+	webhookRepo WebhookRepo
+	roomRepo    room.RoomRepo
+	dispatcher  *Dispatcher
+	// auditor records webhooks being registered and removed in the audit log
+	auditor audit.Recorder
+	// maxPerRoom is the number of webhooks a room may have
+	maxPerRoom int
+	now        func() time.Time
+}
+
+// NewWebhookService initializes and returns a new instance of WebhookServiceImpl.
+func NewWebhookService(webhookRepo WebhookRepo, roomRepo room.RoomRepo, dispatcher *Dispatcher, auditor audit.Recorder, maxPerRoom int) *WebhookServiceImpl {
+	return &WebhookServiceImpl{
+		webhookRepo: webhookRepo,
+		roomRepo:    roomRepo,
+		dispatcher:  dispatcher,
+		auditor:     auditor,
+		maxPerRoom:  maxPerRoom,
+		now:         time.Now,
+	}
+}
+
+// CreateWebhook registers an http or https URL to receive the events of a room named in the request, signed with
+// its secret. Only the room's owner may register webhooks.
+func (hs *WebhookServiceImpl) CreateWebhook(ctx context.Context, roomId string, callerId string, req request.CreateWebhookRequest) (*Webhook, error) {
+	if _, err := ownedRoom(ctx, hs.roomRepo, roomId, callerId); err != nil {
+		return nil, err
+	}
+	events, ok := validate(req)
+	if !ok {
+		return nil, errormodel.ErrInvalidWebhook
+	}
+	count, err := hs.webhookRepo.CountWebhooksByRoom(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= int64(hs.maxPerRoom) {
+		return nil, errormodel.ErrWebhookLimitReached
+	}
+
+	hook, err := hs.webhookRepo.CreateWebhook(ctx, &Webhook{
+		RoomID:    roomId,
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Events:    events,
+		CreatedBy: callerId,
+		CreatedAt: hs.now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	hs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionWebhookCreated,
+		ActorID:    callerId,
+		TargetType: audit.TargetWebhook,
+		TargetID:   hook.ID.Hex(),
+		RoomID:     roomId,
+		After:      map[string]interface{}{"url": hook.URL, "events": hook.Events},
+	})
+	return hook, nil
+}
+
+// validate checks a webhook request, returning its events without duplicates.
+func validate(req request.CreateWebhookRequest) ([]string, bool) {
+	if len(req.URL) > maxURLSize || len(req.Secret) < minSecretSize || len(req.Secret) > maxSecretSize {
+		return nil, false
+	}
+	parsed, err := url.Parse(req.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.User != nil {
+		return nil, false
+	}
+	if len(req.Events) == 0 {
+		return nil, false
+	}
+	events := make([]string, 0, len(req.Events))
+	for _, event := range req.Events {
+		if !subscribable(event) {
+			return nil, false
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	return events, true
+}
+
+// ListWebhooks returns the webhooks of a room to its owner.
+func (hs *WebhookServiceImpl) ListWebhooks(ctx context.Context, roomId string, callerId string) ([]Webhook, error) {
+	if _, err := ownedRoom(ctx, hs.roomRepo, roomId, callerId); err != nil {
+		return nil, err
+	}
+	return hs.webhookRepo.GetWebhooksByRoom(ctx, roomId)
+}
+
+// DeleteWebhook removes a webhook of a room. Its pending deliveries are moved to the dead-letter list as they come
+// due.
+func (hs *WebhookServiceImpl) DeleteWebhook(ctx context.Context, roomId string, id string, callerId string) error {
+	if _, err := ownedRoom(ctx, hs.roomRepo, roomId, callerId); err != nil {
+		return err
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errormodel.ErrWebhookNotFound
+	}
+	hook, err := hs.webhookRepo.GetWebhook(ctx, oid)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && hook.RoomID != roomId) {
+		return errormodel.ErrWebhookNotFound
+	} else if err != nil {
+		return err
+	}
+	if err := hs.webhookRepo.DeleteWebhook(ctx, roomId, oid); errors.Is(err, mongo.ErrNoDocuments) {
+		return errormodel.ErrWebhookNotFound
+	} else if err != nil {
+		return err
+	}
+	hs.auditor.Record(ctx, audit.Entry{
+		Action:     audit.ActionWebhookDeleted,
+		ActorID:    callerId,
+		TargetType: audit.TargetWebhook,
+		TargetID:   id,
+		RoomID:     roomId,
+		Before:     map[string]interface{}{"url": hook.URL, "events": hook.Events},
+	})
+	return nil
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first, starting before the delivery with ID before
+// unless it is empty. Deliveries of any status are returned when status is empty.
+func (hs *WebhookServiceImpl) ListDeliveries(ctx context.Context, roomId string, id string, callerId string, status string, before string, limit int) ([]Delivery, error) {
+	if _, err := ownedRoom(ctx, hs.roomRepo, roomId, callerId); err != nil {
+		return nil, err
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errormodel.ErrWebhookNotFound
+	}
+	hook, err := hs.webhookRepo.GetWebhook(ctx, oid)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && hook.RoomID != roomId) {
+		return nil, errormodel.ErrWebhookNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return hs.listDeliveries(ctx, DeliveryFilter{RoomID: roomId, WebhookID: oid, Status: status}, before, limit)
+}
+
+// ListDeadLetters returns the dead deliveries of every webhook of a room, including removed ones, paged as
+// ListDeliveries does.
+func (hs *WebhookServiceImpl) ListDeadLetters(ctx context.Context, roomId string, callerId string, before string, limit int) ([]Delivery, error) {
+	if _, err := ownedRoom(ctx, hs.roomRepo, roomId, callerId); err != nil {
+		return nil, err
+	}
+	return hs.listDeliveries(ctx, DeliveryFilter{RoomID: roomId, Status: StatusDead}, before, limit)
+}
+
+func (hs *WebhookServiceImpl) listDeliveries(ctx context.Context, filter DeliveryFilter, before string, limit int) ([]Delivery, error) {
+	var beforeID primitive.ObjectID
+	if before != "" {
+		var err error
+		if beforeID, err = primitive.ObjectIDFromHex(before); err != nil {
+			return nil, errormodel.ErrInvalidCursor
+		}
+	}
+	return hs.webhookRepo.GetDeliveries(ctx, filter, beforeID, int64(limit))
+}
+
+// Redeliver moves a dead delivery of a room back to the queue with a fresh set of attempts.
+func (hs *WebhookServiceImpl) Redeliver(ctx context.Context, roomId string, deliveryId string, callerId string) (*Delivery, error) {
+	if _, err := ownedRoom(ctx, hs.roomRepo, roomId, callerId); err != nil {
+		return nil, err
+	}
+	oid, err := primitive.ObjectIDFromHex(deliveryId)
+	if err != nil {
+		return nil, errormodel.ErrDeliveryNotFound
+	}
+	delivery, err := hs.webhookRepo.Redeliver(ctx, roomId, oid, hs.now().UTC())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrDeliveryNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if hs.dispatcher != nil {
+		hs.dispatcher.Wake()
+	}
+	return delivery, nil
+}
+
+// ownedRoom returns the room when the caller owns it. Rooms the caller cannot see are reported as not found.
+func ownedRoom(ctx context.Context, roomRepo room.RoomRepo, roomId string, callerId string) (*room.Room, error) {
+	if callerId == "" {
+		return nil, errormodel.ErrUnauthenticated
+	}
+	roomData, err := roomRepo.GetRoomByID(ctx, roomId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errormodel.ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !roomData.VisibleTo(callerId) {
+		return nil, errormodel.ErrRoomNotFound
+	}
+	if roomData.OwnerID != callerId {
+		return nil, errormodel.ErrForbidden
+	}
+	return roomData, nil
+}
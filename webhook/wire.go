@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"messages-go/audit"
+	"messages-go/message"
+	"messages-go/ratelimit"
+	"messages-go/room"
+	"messages-go/utils"
+	ws "messages-go/websocket"
+	"time"
+)
+
+// InitWebhookHandler wires the webhook handler and a Dispatcher configured from the environment, registers the
+// dispatcher as an observer of the events broadcast to rooms, and starts its workers, which run until ctx is
+// cancelled.
+func InitWebhookHandler(ctx context.Context, client *mongo.Client, roomRepo room.RoomRepo, wsHandler *ws.Handler, auditor audit.Recorder) (WebhookHandler, WebhookRepo, WebhookService) {
+	cfg := Config{
+		Workers:      utils.GetEnvInt("WEBHOOK_WORKERS", 2),
+		Timeout:      utils.GetEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		MaxAttempts:  utils.GetEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		BaseBackoff:  utils.GetEnvDuration("WEBHOOK_BASE_BACKOFF", 10*time.Second),
+		MaxBackoff:   utils.GetEnvDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+		PollInterval: utils.GetEnvDuration("WEBHOOK_POLL_INTERVAL", 30*time.Second),
+		ClaimTimeout: utils.GetEnvDuration("WEBHOOK_CLAIM_TIMEOUT", time.Minute),
+		Retention:    utils.GetEnvDuration("WEBHOOK_DELIVERY_RETENTION", 30*24*time.Hour),
+		UserAgent:    utils.GetEnv("WEBHOOK_USER_AGENT", "messages-go-webhooks/1.0"),
+
+		AllowPrivateNetworks: utils.GetEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "false") == "true",
+	}
+
+	repo := NewWebhookRepository(client)
+	dispatcher := NewDispatcher(repo, cfg)
+	if wsHandler != nil {
+		wsHandler.AddBroadcastObserver(dispatcher)
+	}
+	dispatcher.Start(ctx)
+	service := NewWebhookService(repo, roomRepo, dispatcher, auditor, utils.GetEnvInt("WEBHOOK_MAX_PER_ROOM", 10))
+	handler := NewWebhookHandler(service)
+	return handler, repo, service
+}
+
+// InitIncomingWebhookHandler wires the incoming webhook handler, posting through messageService and limiting posts
+// with rule.
+func InitIncomingWebhookHandler(client *mongo.Client, roomRepo room.RoomRepo, messageService message.MessageService, wsHandler *ws.Handler, limiter *ratelimit.Limiter, rule ratelimit.Rule, auditor audit.Recorder) (IncomingWebhookHandler, IncomingWebhookRepo, IncomingWebhookService) {
+	repo := NewIncomingWebhookRepository(client)
+	service := NewIncomingWebhookService(repo, roomRepo, messageService, auditor)
+	handler := NewIncomingWebhookHandler(service, wsHandler, limiter, rule)
+	return handler, repo, service
+}
@@ -1,24 +1,33 @@
 package websocket
 
 import (
+	"context"
 	"log"
 
 	"github.com/gofiber/websocket/v2"
 )
 
-// Client represents a WebSocket connection for a specific room
+// Client represents a WebSocket connection for a specific room.
+// Conn is nil for clients created through Hub.Subscribe.
 type Client struct {
 	Conn   *websocket.Conn
 	RoomID string
+	UserID string
 	Send   chan []byte
 	Hub    *Hub
+	// receiver handles the frames read from Conn; without one they are discarded
+	receiver MessageReceiver
 }
 
-// NewClient creates a new WebSocket client
-func NewClient(conn *websocket.Conn, roomID string, hub *Hub) *Client {
+// maxFrameSize bounds the frames read from clients
+const maxFrameSize = 64 << 10
+
+// NewClient creates a new WebSocket client; userID is empty for anonymous connections
+func NewClient(conn *websocket.Conn, roomID string, userID string, hub *Hub) *Client {
 	return &Client{
 		Conn:   conn,
 		RoomID: roomID,
+		UserID: userID,
 		Send:   make(chan []byte, 256),
 		Hub:    hub,
 	}
@@ -27,7 +36,12 @@ func NewClient(conn *websocket.Conn, roomID string, hub *Hub) *Client {
 // Start begins the client's read and write pumps
 func (c *Client) Start() {
 	log.Printf("Starting client for room: %s", c.RoomID)
-	c.Hub.register <- c
+	select {
+	case c.Hub.register <- c:
+	case <-c.Hub.quit:
+		c.Conn.Close()
+		return
+	}
 	log.Printf("Client registered, starting pumps for room: %s", c.RoomID)
 
 	go c.writePump()
@@ -62,19 +76,24 @@ func (c *Client) writePump() {
 // readPump pumps messages from the websocket connection to the hub.
 func (c *Client) readPump() {
 	defer func() {
-		c.Hub.unregister <- c
+		c.Hub.Unsubscribe(c)
 		c.Conn.Close()
 	}()
 
+	c.Conn.SetReadLimit(maxFrameSize)
 	for {
-		_, _, err := c.Conn.ReadMessage()
+		_, data, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
-		// We're only broadcasting, not receiving messages through WebSocket
-		// Messages are still posted through the REST API
+		if c.receiver == nil {
+			continue
+		}
+		if reply := c.receiver(context.Background(), c.RoomID, c.UserID, data); reply != nil {
+			c.Hub.sendToClient(c, reply)
+		}
 	}
 }
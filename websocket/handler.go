@@ -1,14 +1,28 @@
 package websocket
 
 import (
+	"context"
 	"log"
+	"messages-go/auth"
 
 	"github.com/gofiber/websocket/v2"
 )
 
+// MessageReceiver handles a frame a client sent over its room connection and returns the reply sent back to that
+// client alone, or nil for none
+type MessageReceiver func(ctx context.Context, roomID string, userID string, data []byte) interface{}
+
+// BroadcastObserver is shown every event broadcast to a room through the Handler, such as to forward it to
+// outside subscribers. It is called on the broadcasting goroutine and must not block.
+type BroadcastObserver interface {
+	RoomBroadcast(roomID string, message interface{})
+}
+
 // Handler manages WebSocket connections
 type Handler struct {
-	hub *Hub
+	hub       *Hub
+	receiver  MessageReceiver
+	observers []BroadcastObserver
 }
 
 // NewHandler creates a new WebSocket handler
@@ -28,16 +42,92 @@ func (h *Handler) HandleConnection(c *websocket.Conn) {
 	}
 
 	// Create and start client
-	client := NewClient(c, roomID, h.hub)
+	client := NewClient(c, roomID, callerID(c), h.hub)
+	client.receiver = h.receiver
+	client.Start()
+}
+
+// SetMessageReceiver installs the receiver of frames sent over room connections, which are discarded without one.
+// It must be called during wiring, before connections are accepted.
+func (h *Handler) SetMessageReceiver(receiver MessageReceiver) {
+	h.receiver = receiver
+}
+
+// WrapMessageReceiver replaces the installed receiver with the one wrap returns for it, such as a rate limiter in
+// front of it. It does nothing without a receiver and, like SetMessageReceiver, must be called during wiring.
+func (h *Handler) WrapMessageReceiver(wrap func(MessageReceiver) MessageReceiver) {
+	if h.receiver != nil {
+		h.receiver = wrap(h.receiver)
+	}
+}
+
+// HandlePersonalConnection handles WebSocket connections to the caller's personal channel, which receives
+// events addressed to the user, such as mentions, regardless of the rooms they are connected to
+func (h *Handler) HandlePersonalConnection(c *websocket.Conn) {
+	userID := callerID(c)
+	if userID == "" {
+		log.Println("User ID not provided for personal channel")
+		c.Close()
+		return
+	}
+
+	client := NewClient(c, UserChannel(userID), userID, h.hub)
 	client.Start()
 }
 
-// BroadcastToRoom is a convenience method to broadcast to a specific room
+// BroadcastToUser sends a message to every personal channel connection of a user
+func (h *Handler) BroadcastToUser(userID string, message interface{}) {
+	h.hub.BroadcastToRoom(UserChannel(userID), message)
+}
+
+// ConnectedUserIDs returns the distinct users with an active connection to a room
+func (h *Handler) ConnectedUserIDs(roomID string) []string {
+	return h.hub.ConnectedUserIDs(roomID)
+}
+
+// UserChannel returns the hub channel carrying a user's personal events
+func UserChannel(userID string) string {
+	return "user:" + userID
+}
+
+// callerID returns the caller ID stored in the connection locals before the upgrade
+func callerID(c *websocket.Conn) string {
+	userID, _ := c.Locals(auth.LocalsUserID).(string)
+	return userID
+}
+
+// BroadcastToRoom is a convenience method to broadcast to a specific room. Broadcast observers are shown the
+// message once it is handed to the hub.
 func (h *Handler) BroadcastToRoom(roomID string, message interface{}) {
 	h.hub.BroadcastToRoom(roomID, message)
+	for _, observer := range h.observers {
+		observer.RoomBroadcast(roomID, message)
+	}
+}
+
+// AddBroadcastObserver registers an observer of the events broadcast to rooms. Events sent to personal channels
+// or to the clients being disconnected are not shown to observers. Observers must be registered during wiring,
+// before connections are accepted.
+func (h *Handler) AddBroadcastObserver(observer BroadcastObserver) {
+	h.observers = append(h.observers, observer)
+}
+
+// DisconnectUser closes every connection of a user to a room after sending each the given message
+func (h *Handler) DisconnectUser(roomID string, userID string, message interface{}) int {
+	return h.hub.DisconnectUser(roomID, userID, message)
 }
 
 // GetRoomConnections returns the number of active connections in a room
 func (h *Handler) GetRoomConnections(roomID string) int {
 	return h.hub.GetRoomConnections(roomID)
 }
+
+// Subscribe registers a connectionless subscriber for a room, used by HTTP streaming transports
+func (h *Handler) Subscribe(roomID string, userID string) *Client {
+	return h.hub.Subscribe(roomID, userID)
+}
+
+// Unsubscribe removes a subscriber previously returned by Subscribe
+func (h *Handler) Unsubscribe(client *Client) {
+	h.hub.Unsubscribe(client)
+}
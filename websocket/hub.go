@@ -20,6 +20,10 @@ type Hub struct {
 	// Unregister requests from connections.
 	unregister chan *Client
 
+	// Closed when the hub is stopped
+	quit     chan struct{}
+	stopOnce sync.Once
+
 	// Mutex to protect the rooms map
 	mu sync.RWMutex
 }
@@ -30,11 +34,17 @@ type BroadcastMessage struct {
 }
 
 // Global hub instance
-var GlobalHub = &Hub{
-	rooms:      make(map[string]map[*Client]bool),
-	broadcast:  make(chan BroadcastMessage),
-	register:   make(chan *Client),
-	unregister: make(chan *Client),
+var GlobalHub = NewHub()
+
+// NewHub creates a hub without connections; it handles none until started.
+func NewHub() *Hub {
+	return &Hub{
+		rooms:      make(map[string]map[*Client]bool),
+		broadcast:  make(chan BroadcastMessage),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		quit:       make(chan struct{}),
+	}
 }
 
 // Start initializes and runs the hub
@@ -42,6 +52,14 @@ func (h *Hub) Start() {
 	go h.run()
 }
 
+// Stop shuts the hub down, closing the Send channel of every registered client so that
+// WebSocket pumps and HTTP streams waiting on the hub are released.
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.quit)
+	})
+}
+
 func (h *Hub) run() {
 	for {
 		select {
@@ -69,13 +87,13 @@ func (h *Hub) run() {
 			log.Printf("Client unregistered for room: %s", client.RoomID)
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
+			h.mu.Lock()
 			room := h.rooms[message.RoomID]
-			h.mu.RUnlock()
 
 			if room != nil {
 				messageBytes, err := json.Marshal(message.Message)
 				if err != nil {
+					h.mu.Unlock()
 					log.Printf("Error marshaling message: %v", err)
 					continue
 				}
@@ -88,18 +106,135 @@ func (h *Hub) run() {
 						delete(room, client)
 					}
 				}
+				if len(room) == 0 {
+					delete(h.rooms, message.RoomID)
+				}
 			}
+			h.mu.Unlock()
 
+		case <-h.quit:
+			h.mu.Lock()
+			for roomID, room := range h.rooms {
+				for client := range room {
+					close(client.Send)
+				}
+				delete(h.rooms, roomID)
+			}
+			h.mu.Unlock()
+			log.Println("Hub stopped")
+			return
 		}
 	}
 }
 
 // BroadcastToRoom sends a message to all connections in a specific room
 func (h *Hub) BroadcastToRoom(roomID string, message interface{}) {
-	h.broadcast <- BroadcastMessage{
+	select {
+	case h.broadcast <- BroadcastMessage{
 		RoomID:  roomID,
 		Message: message,
+	}:
+	case <-h.quit:
+	}
+}
+
+// sendToClient delivers a message to a single client, provided it is still registered. Like broadcasts, it is
+// dropped when the client's buffer is full.
+func (h *Hub) sendToClient(client *Client, message interface{}) {
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	// The hub closes Send under the lock when unregistering, so a registered client's channel is open here
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.rooms[client.RoomID][client] {
+		return
+	}
+	select {
+	case client.Send <- messageBytes:
+	default:
+		log.Printf("Dropping reply to slow client in room: %s", client.RoomID)
+	}
+}
+
+// DisconnectUser removes every connection of a user to a room, sending each the given message first. Closing
+// their Send channels makes WebSocket connections close once the message is written and ends HTTP streams. It
+// returns the number of connections removed.
+func (h *Hub) DisconnectUser(roomID string, userID string, message interface{}) int {
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		messageBytes = nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room := h.rooms[roomID]
+	removed := 0
+	for client := range room {
+		if userID == "" || client.UserID != userID {
+			continue
+		}
+		if messageBytes != nil {
+			select {
+			case client.Send <- messageBytes:
+			default:
+			}
+		}
+		close(client.Send)
+		delete(room, client)
+		removed++
+	}
+	if room != nil && len(room) == 0 {
+		delete(h.rooms, roomID)
+	}
+	return removed
+}
+
+// Subscribe registers a connectionless client for a room on behalf of a user, who may be anonymous, and returns it.
+// Broadcasts for the room are delivered on the client's Send channel, which is closed
+// when the client is unsubscribed, falls too far behind, or the hub stops.
+func (h *Hub) Subscribe(roomID string, userID string) *Client {
+	client := &Client{
+		RoomID: roomID,
+		UserID: userID,
+		Send:   make(chan []byte, 256),
+		Hub:    h,
+	}
+	select {
+	case h.register <- client:
+	case <-h.quit:
+		close(client.Send)
+	}
+	return client
+}
+
+// Unsubscribe removes a client from its room. It is safe to call after the hub has stopped.
+func (h *Hub) Unsubscribe(client *Client) {
+	select {
+	case h.unregister <- client:
+	case <-h.quit:
+	}
+}
+
+// ConnectedUserIDs returns the distinct users with an active connection to a room, ignoring anonymous connections
+func (h *Hub) ConnectedUserIDs(roomID string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var userIDs []string
+	for client := range h.rooms[roomID] {
+		if client.UserID != "" && !seen[client.UserID] {
+			seen[client.UserID] = true
+			userIDs = append(userIDs, client.UserID)
+		}
 	}
+	return userIDs
 }
 
 // GetRoomConnections returns the number of active connections in a room